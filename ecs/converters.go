@@ -0,0 +1,305 @@
+package ecs
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/internal/mapping"
+)
+
+// Converter sets the ECS fields of a property value under target, and reports whether the value converted.
+// Values that do not are kept under winlog.event_data.
+type Converter = mapping.Converter
+
+// Converters shared with the OCSF mapper.
+var (
+	// String sets the string of the value, unless empty or -.
+	String Converter = mapping.String
+	// Lowercase sets the string of the value in lower case, such as the network.transport of Sysmon protocols.
+	Lowercase Converter = mapping.Lowercase
+	// Integer sets integers, as int64 when signed and uint64 otherwise, and strings of integers in decimal
+	// or hexadecimal with 0x prefix.
+	Integer Converter = mapping.Integer
+	// Bool sets booleans, and the strings true and false.
+	Bool Converter = mapping.Bool
+	// SID sets the security identifier of the value, such as the user.id of classic kernel process events.
+	SID Converter = mapping.SID
+)
+
+// Timestamp sets times, and strings in the layout of Sysmon UtcTime fields or RFC 3339.
+func Timestamp(document Document, target string, value etw.Value) bool {
+	t, ok := mapping.ParseTime(value)
+	if !ok {
+		return false
+	}
+	document.Set(target, t)
+	return true
+}
+
+// IP sets the address of the value, and adds it to related.ip.
+func IP(document Document, target string, value etw.Value) bool {
+	addr, ok := mapping.ParseAddr(value)
+	if !ok {
+		return false
+	}
+	document.Set(target, addr.String())
+	document.Append("related.ip", addr.String())
+	return true
+}
+
+// Executable sets the executable and name fields of the process at target from an image path.
+func Executable(document Document, target string, value etw.Value) bool {
+	path := value.String()
+	if mapping.IsEmpty(path) {
+		return false
+	}
+	_, name := mapping.SplitPath(path)
+	document.Set(target+".executable", path)
+	document.Set(target+".name", name)
+	return true
+}
+
+// Path sets the path, name, directory and extension fields of the file at target.
+func Path(document Document, target string, value etw.Value) bool {
+	path := value.String()
+	if mapping.IsEmpty(path) {
+		return false
+	}
+	directory, name := mapping.SplitPath(path)
+	document.Set(target+".path", path)
+	document.Set(target+".name", name)
+	if directory != "" {
+		document.Set(target+".directory", directory)
+	}
+	if dot := strings.LastIndexByte(name, '.'); dot > 0 && dot < len(name)-1 {
+		document.Set(target+".extension", strings.ToLower(name[dot+1:]))
+	}
+	return true
+}
+
+// User sets the domain and name fields of the user at target from DOMAIN\name, and adds the name to related.user.
+func User(document Document, target string, value etw.Value) bool {
+	s := value.String()
+	if mapping.IsEmpty(s) {
+		return false
+	}
+	domain, name := mapping.SplitUser(s)
+	if domain != "" {
+		document.Set(target+".domain", domain)
+	}
+	document.Set(target+".name", name)
+	document.Append("related.user", name)
+	return true
+}
+
+// Hashes sets the hash fields under target from the ALGORITHM=digest lists of Sysmon, separated by commas,
+// and adds the digests to related.hash. IMPHASH goes to the pe.imphash field.
+func Hashes(document Document, target string, value etw.Value) bool {
+	s := value.String()
+	if mapping.IsEmpty(s) {
+		return false
+	}
+	hashes := mapping.ParseHashes(s)
+	for _, hash := range hashes {
+		if algorithm := strings.ToLower(hash.Algorithm); algorithm == "imphash" {
+			document.Set(target+".pe.imphash", hash.Digest)
+		} else {
+			document.Set(target+".hash."+algorithm, hash.Digest)
+		}
+		document.Append("related.hash", hash.Digest)
+	}
+	return len(hashes) > 0
+}
+
+// Direction sets the network.direction of the Initiated field of Sysmon network events.
+func Direction(document Document, target string, value etw.Value) bool {
+	initiated, err := strconv.ParseBool(value.String())
+	if err != nil {
+		return false
+	}
+	if initiated {
+		document.Set(target, "egress")
+	} else {
+		document.Set(target, "ingress")
+	}
+	return true
+}
+
+// NetworkType sets the network.type of the IsIpv6 fields of Sysmon network events.
+func NetworkType(document Document, target string, value etw.Value) bool {
+	isIPv6, err := strconv.ParseBool(value.String())
+	if err != nil {
+		return false
+	}
+	if isIPv6 {
+		document.Set(target, "ipv6")
+	} else {
+		document.Set(target, "ipv4")
+	}
+	return true
+}
+
+// registryHives abbreviates the root keys of registry paths, as in registry.hive.
+var registryHives = map[string]string{
+	"HKEY_LOCAL_MACHINE":  "HKLM",
+	"HKEY_USERS":          "HKU",
+	"HKEY_CURRENT_USER":   "HKCU",
+	"HKEY_CLASSES_ROOT":   "HKCR",
+	"HKEY_CURRENT_CONFIG": "HKCC",
+	`\REGISTRY\MACHINE`:   "HKLM",
+	`\REGISTRY\USER`:      "HKU",
+}
+
+// RegistryKey sets the path, hive and key fields of the registry at target from the path of a key.
+func RegistryKey(document Document, target string, value etw.Value) bool {
+	return registryPath(document, target, value.String(), false)
+}
+
+// RegistryValue sets the path, hive, key and value fields of the registry at target from the path of a value.
+func RegistryValue(document Document, target string, value etw.Value) bool {
+	return registryPath(document, target, value.String(), true)
+}
+
+func registryPath(document Document, target string, path string, isValue bool) bool {
+	if mapping.IsEmpty(path) {
+		return false
+	}
+	document.Set(target+".path", path)
+
+	hive, key := "", path
+	upper := strings.ToUpper(path)
+	for root, abbreviation := range registryHives {
+		if upper == root || strings.HasPrefix(upper, root+`\`) {
+			hive, key = abbreviation, strings.TrimPrefix(path[len(root):], `\`)
+			break
+		}
+	}
+	if hive == "" {
+		if separator := strings.IndexByte(path, '\\'); separator > 0 {
+			hive, key = path[:separator], path[separator+1:]
+		}
+	}
+	if hive != "" {
+		document.Set(target+".hive", hive)
+	}
+
+	if isValue {
+		separator := strings.LastIndexByte(key, '\\')
+		document.Set(target+".value", key[separator+1:])
+		if separator < 0 {
+			return true
+		}
+		key = key[:separator]
+	}
+	if key != "" {
+		document.Set(target+".key", key)
+	}
+	return true
+}
+
+// registryDataTypes maps the prefixes of the Details of Sysmon registry events to their data type.
+var registryDataTypes = []struct {
+	prefix   string
+	dataType string
+}{
+	{"DWORD (", "REG_DWORD"},
+	{"QWORD (", "REG_QWORD"},
+	{"Binary Data", "REG_BINARY"},
+}
+
+// RegistryData sets the data strings and type of the registry at target from the Details of Sysmon events.
+func RegistryData(document Document, target string, value etw.Value) bool {
+	details := value.String()
+	if mapping.IsEmpty(details) {
+		return false
+	}
+	dataType := "REG_SZ"
+	for _, registryDataType := range registryDataTypes {
+		if strings.HasPrefix(details, registryDataType.prefix) {
+			dataType = registryDataType.dataType
+			if strings.HasSuffix(details, ")") { // DWORD (0x00000001): the number
+				details = details[len(registryDataType.prefix) : len(details)-1]
+			}
+			break
+		}
+	}
+	document.Set(target+".data.strings", []string{details})
+	document.Set(target+".data.type", dataType)
+	return true
+}
+
+// registryEventTypes gives the event.type of the EventType of Sysmon registry events.
+var registryEventTypes = map[string]string{
+	"CreateKey":   "creation",
+	"DeleteKey":   "deletion",
+	"DeleteValue": "deletion",
+	"SetValue":    "change",
+	"RenameKey":   "change",
+}
+
+// Action sets the event.action from a property, such as the EventType of Sysmon registry, pipe and WMI events,
+// and the event.type of registry actions.
+func Action(document Document, target string, value etw.Value) bool {
+	action := value.String()
+	if mapping.IsEmpty(action) {
+		return false
+	}
+	document.Set(target, action)
+	if eventType, ok := registryEventTypes[action]; ok {
+		document.Set("event.type", []string{eventType})
+	}
+	return true
+}
+
+// dnsResponseCodes names the Win32 status codes of Sysmon DNS queries, as in dns.response_code.
+var dnsResponseCodes = map[int64]string{
+	0:    "NOERROR",
+	9001: "FORMERR",
+	9002: "SERVFAIL",
+	9003: "NXDOMAIN",
+	9004: "NOTIMP",
+	9005: "REFUSED",
+	9501: "NOERROR", // DNS_INFO_NO_RECORDS
+}
+
+// DNSResponseCode sets the dns.response_code of the QueryStatus of Sysmon DNS events.
+func DNSResponseCode(document Document, target string, value etw.Value) bool {
+	status, err := strconv.ParseInt(value.String(), 0, 64)
+	if err != nil {
+		return false
+	}
+	if code, ok := dnsResponseCodes[status]; ok {
+		document.Set(target, code)
+		return true
+	}
+	return false
+}
+
+// DNSAnswers sets the answers and resolved_ip fields of dns at target from the QueryResults of Sysmon DNS events,
+// addresses and records of type, such as "type:  5 example.com", separated by semicolons.
+func DNSAnswers(document Document, target string, value etw.Value) bool {
+	results := value.String()
+	if mapping.IsEmpty(results) {
+		return false
+	}
+
+	var answers []Document
+	var resolved []string
+	for _, answer := range mapping.ParseAnswers(results) {
+		answers = append(answers, Document{"type": answer.Type, "data": answer.Data})
+		if answer.Addr.IsValid() {
+			resolved = append(resolved, answer.Data)
+			document.Append("related.ip", answer.Data)
+		}
+	}
+
+	if len(answers) == 0 {
+		return false
+	}
+	document.Set(target+".answers", answers)
+	if len(resolved) > 0 {
+		document.Set(target+".resolved_ip", resolved)
+	}
+	return true
+}
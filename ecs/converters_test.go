@@ -0,0 +1,80 @@
+package ecs
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+func TestConverters(t *testing.T) {
+	text := func(s string) etw.Value { return etw.StringValue(s, winapi.TdhInTypeUnicodestring, 0) }
+
+	tests := []struct {
+		name    string
+		convert Converter
+		target  string
+		value   etw.Value
+		want    string // document as JSON, empty when the value does not convert
+	}{
+		{"string", String, "a", text("x"), `{"a":"x"}`},
+		{"string missing", String, "a", text("-"), ``},
+		{"lowercase", Lowercase, "a", text("UDP"), `{"a":"udp"}`},
+		{"integer", Integer, "a", etw.NewValue(int32(-5), winapi.TdhInTypeInt32, 0), `{"a":-5}`},
+		{"integer hex string", Integer, "a", text("0x1F"), `{"a":31}`},
+		{"integer max uint64", Integer, "a", etw.NewValue(uint64(math.MaxUint64), winapi.TdhInTypeUint64, 0), `{"a":18446744073709551615}`},
+		{"integer mapped", Integer, "a", etw.NewValue(uint32(6), winapi.TdhInTypeUint32, 0).WithName("TCP"), `{"a":6}`},
+		{"bool string", Bool, "a", text("true"), `{"a":true}`},
+		{"timestamp sysmon", Timestamp, "a", text("2024-05-06 07:08:09.123"), `{"a":"2024-05-06T07:08:09.123Z"}`},
+		{"timestamp time", Timestamp, "a", etw.NewValue(time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), winapi.TdhInTypeFiletime, 0),
+			`{"a":"2024-05-06T07:08:09Z"}`},
+		{"ip", IP, "source.ip", text("::ffff:10.0.0.1"), `{"related":{"ip":["::ffff:10.0.0.1"]},"source":{"ip":"::ffff:10.0.0.1"}}`},
+		{"ip invalid", IP, "source.ip", text("-"), ``},
+		{"path without directory", Path, "file", text("a.txt"), `{"file":{"extension":"txt","name":"a.txt","path":"a.txt"}}`},
+		{"path dotfile", Path, "file", text(`C:\x\.gitignore`), `{"file":{"directory":"C:\\x","name":".gitignore","path":"C:\\x\\.gitignore"}}`},
+		{"user without domain", User, "user", text("SYSTEM"), `{"related":{"user":["SYSTEM"]},"user":{"name":"SYSTEM"}}`},
+		{"sid", SID, "user.id", text("S-1-5-18"), `{"user":{"id":"S-1-5-18"}}`},
+		{"sid invalid", SID, "user.id", text("alice"), ``},
+		{"hashes", Hashes, "file", text("MD5=AB,SHA1=CD"), `{"file":{"hash":{"md5":"ab","sha1":"cd"}},"related":{"hash":["ab","cd"]}}`},
+		{"hashes malformed", Hashes, "file", text("MD5"), ``},
+		{"direction", Direction, "network.direction", text("false"), `{"network":{"direction":"ingress"}}`},
+		{"network type", NetworkType, "network.type", text("true"), `{"network":{"type":"ipv6"}}`},
+		{"registry key", RegistryKey, "registry", text(`HKLM\System\CurrentControlSet\Services`),
+			`{"registry":{"hive":"HKLM","key":"System\\CurrentControlSet\\Services","path":"HKLM\\System\\CurrentControlSet\\Services"}}`},
+		{"registry value", RegistryValue, "registry", text(`\REGISTRY\MACHINE\SOFTWARE\Run\evil`),
+			`{"registry":{"hive":"HKLM","key":"SOFTWARE\\Run","path":"\\REGISTRY\\MACHINE\\SOFTWARE\\Run\\evil","value":"evil"}}`},
+		{"registry data", RegistryData, "registry", text("DWORD (0x00000001)"),
+			`{"registry":{"data":{"strings":["0x00000001"],"type":"REG_DWORD"}}}`},
+		{"action", Action, "event.action", text("DeleteValue"), `{"event":{"action":"DeleteValue","type":["deletion"]}}`},
+		{"dns response code", DNSResponseCode, "dns.response_code", text("9003"), `{"dns":{"response_code":"NXDOMAIN"}}`},
+		{"dns response code unknown", DNSResponseCode, "dns.response_code", text("5"), ``},
+		{"dns answers", DNSAnswers, "dns", text("type:  5 edge.example.net;::ffff:93.184.216.34;"),
+			`{"dns":{"answers":[{"data":"edge.example.net","type":"CNAME"},{"data":"93.184.216.34","type":"A"}],"resolved_ip":["93.184.216.34"]},"related":{"ip":["93.184.216.34"]}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			document := Document{}
+			converted := test.convert(document, test.target, test.value)
+			if converted != (test.want != "") {
+				t.Fatalf("converted = %t, want %t", converted, test.want != "")
+			}
+			if !converted {
+				if len(document) != 0 {
+					t.Errorf("document = %v, want empty", document)
+				}
+				return
+			}
+			data, err := json.Marshal(document)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(data) != test.want {
+				t.Errorf("document = %s, want %s", data, test.want)
+			}
+		})
+	}
+}
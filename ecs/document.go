@@ -0,0 +1,9 @@
+package ecs
+
+import (
+	"github.com/quentin-nozomi/microsoft-etw/internal/mapping"
+)
+
+// Document is an ECS document, nested objects as maps, as marshalled to JSON. Set, Get and Append take
+// the dotted paths of fields, such as process.parent.pid.
+type Document = mapping.Object
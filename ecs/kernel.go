@@ -0,0 +1,152 @@
+package ecs
+
+// https://learn.microsoft.com/en-us/windows/win32/etw/process
+// https://learn.microsoft.com/en-us/windows/win32/etw/tcpip
+// https://learn.microsoft.com/en-us/windows/win32/etw/fileio
+
+// GUIDs of the manifest-based kernel providers.
+const (
+	KernelProcessProvider = "{22FB2CD6-0E7B-422B-A0C7-2FAD1FD0E716}" // Microsoft-Windows-Kernel-Process
+	KernelNetworkProvider = "{7DD42A49-5329-4832-8DFD-43D979153A88}" // Microsoft-Windows-Kernel-Network
+	KernelFileProvider    = "{EDD08927-9CC4-4E65-B970-C2560FB5C289}" // Microsoft-Windows-Kernel-File
+)
+
+// Endpoints of kernel TCP/IP events, whose daddr is the remote address and saddr the local one:
+// connections the process initiates go from saddr to daddr, accepted ones the other way.
+var (
+	kernelOutbound = []Field{
+		{Property: "PID", Target: "process.pid", Convert: Integer},
+		{Property: "saddr", Target: "source.ip", Convert: IP},
+		{Property: "sport", Target: "source.port", Convert: Integer},
+		{Property: "daddr", Target: "destination.ip", Convert: IP},
+		{Property: "dport", Target: "destination.port", Convert: Integer},
+	}
+	kernelInbound = []Field{
+		{Property: "PID", Target: "process.pid", Convert: Integer},
+		{Property: "daddr", Target: "source.ip", Convert: IP},
+		{Property: "dport", Target: "source.port", Convert: Integer},
+		{Property: "saddr", Target: "destination.ip", Convert: IP},
+		{Property: "sport", Target: "destination.port", Convert: Integer},
+	}
+)
+
+func kernelConnection(action string, endpoints []Field) *Mapping {
+	return &Mapping{
+		Category: []string{"network"}, Type: []string{"connection", "start"}, Action: action,
+		Values: map[string]interface{}{"network.transport": "tcp"},
+		Fields: endpoints,
+	}
+}
+
+func kernelDatagram(action string, endpoints []Field) *Mapping {
+	return &Mapping{
+		Category: []string{"network"}, Type: []string{"connection"}, Action: action,
+		Values: map[string]interface{}{"network.transport": "udp"},
+		Fields: endpoints,
+	}
+}
+
+func kernelKey(provider string, eventID uint16) Key {
+	return Key{Provider: provider, EventID: eventID}
+}
+
+func classicKey(eventType string) Key {
+	return Key{EventType: eventType}
+}
+
+// KernelMappings maps the process, network and file events of the kernel providers, and of the classic
+// NT Kernel Logger.
+var KernelMappings = map[Key]*Mapping{
+	kernelKey(KernelProcessProvider, 1): {
+		Category: []string{"process"}, Type: []string{"start"}, Action: "ProcessStart",
+		Fields: []Field{
+			{Property: "ProcessID", Target: "process.pid", Convert: Integer},
+			{Property: "ParentProcessID", Target: "process.parent.pid", Convert: Integer},
+			{Property: "ImageName", Target: "process", Convert: Executable},
+			{Property: "CreateTime", Target: "process.start", Convert: Timestamp},
+		},
+	},
+	kernelKey(KernelProcessProvider, 2): {
+		Category: []string{"process"}, Type: []string{"end"}, Action: "ProcessStop",
+		Fields: []Field{
+			{Property: "ProcessID", Target: "process.pid", Convert: Integer},
+			{Property: "ImageName", Target: "process", Convert: Executable},
+			{Property: "CreateTime", Target: "process.start", Convert: Timestamp},
+			{Property: "ExitTime", Target: "process.end", Convert: Timestamp},
+			{Property: "ExitCode", Target: "process.exit_code", Convert: Integer},
+		},
+	},
+	kernelKey(KernelProcessProvider, 5): {
+		Category: []string{"library"}, Type: []string{"start"}, Action: "ImageLoad",
+		Fields: []Field{
+			{Property: "ProcessID", Target: "process.pid", Convert: Integer},
+			{Property: "ImageName", Target: "dll", Convert: Path},
+		},
+	},
+
+	kernelKey(KernelNetworkProvider, 12): kernelConnection("TcpConnect", kernelOutbound),
+	kernelKey(KernelNetworkProvider, 15): kernelConnection("TcpAccept", kernelInbound),
+	kernelKey(KernelNetworkProvider, 28): kernelConnection("TcpConnect", kernelOutbound),
+	kernelKey(KernelNetworkProvider, 31): kernelConnection("TcpAccept", kernelInbound),
+	kernelKey(KernelNetworkProvider, 42): kernelDatagram("UdpSend", kernelOutbound),
+	kernelKey(KernelNetworkProvider, 43): kernelDatagram("UdpReceive", kernelInbound),
+	kernelKey(KernelNetworkProvider, 58): kernelDatagram("UdpSend", kernelOutbound),
+	kernelKey(KernelNetworkProvider, 59): kernelDatagram("UdpReceive", kernelInbound),
+
+	kernelKey(KernelFileProvider, 12): {
+		Category: []string{"file"}, Type: []string{"access"}, Action: "FileCreate",
+		Fields: []Field{{Property: "FileName", Target: "file", Convert: Path}},
+	},
+	kernelKey(KernelFileProvider, 26): {
+		Category: []string{"file"}, Type: []string{"deletion"}, Action: "FileDelete",
+		Fields: []Field{{Property: "FilePath", Target: "file", Convert: Path}},
+	},
+	kernelKey(KernelFileProvider, 27): {
+		Category: []string{"file"}, Type: []string{"change"}, Action: "FileRename",
+		Fields: []Field{{Property: "FilePath", Target: "file", Convert: Path}},
+	},
+	kernelKey(KernelFileProvider, 30): {
+		Category: []string{"file"}, Type: []string{"creation"}, Action: "FileCreateNew",
+		Fields: []Field{{Property: "FileName", Target: "file", Convert: Path}},
+	},
+
+	classicKey("Process/Start"): {
+		Category: []string{"process"}, Type: []string{"start"}, Action: "ProcessStart",
+		Fields: []Field{
+			{Property: "ProcessId", Target: "process.pid", Convert: Integer},
+			{Property: "ParentId", Target: "process.parent.pid", Convert: Integer},
+			{Property: "ImageFileName", Target: "process.name"},
+			{Property: "CommandLine", Target: "process.command_line"},
+			{Property: "UserSID", Target: "user.id", Convert: SID},
+		},
+	},
+	classicKey("Process/End"): {
+		Category: []string{"process"}, Type: []string{"end"}, Action: "ProcessEnd",
+		Fields: []Field{
+			{Property: "ProcessId", Target: "process.pid", Convert: Integer},
+			{Property: "ParentId", Target: "process.parent.pid", Convert: Integer},
+			{Property: "ImageFileName", Target: "process.name"},
+			{Property: "ExitStatus", Target: "process.exit_code", Convert: Integer},
+			{Property: "UserSID", Target: "user.id", Convert: SID},
+		},
+	},
+	classicKey("ImageLoad/Load"): {
+		Category: []string{"library"}, Type: []string{"start"}, Action: "ImageLoad",
+		Fields: []Field{
+			{Property: "ProcessId", Target: "process.pid", Convert: Integer},
+			{Property: "FileName", Target: "dll", Convert: Path},
+		},
+	},
+	classicKey("TcpIp/ConnectIPV4"): kernelConnection("TcpConnect", kernelOutbound),
+	classicKey("TcpIp/AcceptIPV4"):  kernelConnection("TcpAccept", kernelInbound),
+	classicKey("TcpIp/ConnectIPV6"): kernelConnection("TcpConnect", kernelOutbound),
+	classicKey("TcpIp/AcceptIPV6"):  kernelConnection("TcpAccept", kernelInbound),
+	classicKey("UdpIp/SendIPV4"):    kernelDatagram("UdpSend", kernelOutbound),
+	classicKey("UdpIp/RecvIPV4"):    kernelDatagram("UdpReceive", kernelInbound),
+	classicKey("UdpIp/SendIPV6"):    kernelDatagram("UdpSend", kernelOutbound),
+	classicKey("UdpIp/RecvIPV6"):    kernelDatagram("UdpReceive", kernelInbound),
+	classicKey("FileIo/Create"): {
+		Category: []string{"file"}, Type: []string{"access"}, Action: "FileCreate",
+		Fields: []Field{{Property: "OpenPath", Target: "file", Convert: Path}},
+	},
+}
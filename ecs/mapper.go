@@ -0,0 +1,136 @@
+package ecs
+
+import (
+	"strconv"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/internal/mapping"
+)
+
+// https://www.elastic.co/guide/en/ecs/current/ecs-field-reference.html
+// https://www.elastic.co/guide/en/ecs/current/ecs-allowed-values-event-category.html
+
+// Version is the ECS version of the documents, written as ecs.version.
+const Version = "8.11.0"
+
+// Key identifies the events a Mapping applies to: by provider GUID and event ID, or by EventType for classic
+// kernel events, such as Process/Start.
+type Key = mapping.Key
+
+// KeyOf returns the key of event.
+func KeyOf(event *etw.Event) Key {
+	return mapping.KeyOf(event)
+}
+
+// Mapping maps an event to ECS: its categorization, and the fields its properties set.
+type Mapping struct {
+	Category []string               // event.category
+	Type     []string               // event.type
+	Action   string                 // event.action
+	Values   map[string]interface{} // fields set as is, such as network.transport
+	Fields   []Field
+}
+
+// Field maps a property of the event to the ECS field Target. Convert sets the fields under Target from the value,
+// when nil the string of the value is set as is.
+type Field = mapping.Field
+
+// Mapper maps events to ECS documents with the Mapping registered for their key.
+type Mapper struct {
+	registry *mapping.Registry[*Mapping]
+}
+
+// NewMapper returns a Mapper with the mappings of Sysmon and kernel events, see SysmonMappings and KernelMappings.
+func NewMapper() *Mapper {
+	mapper := &Mapper{registry: mapping.NewRegistry[*Mapping]()}
+	for key, mapping := range SysmonMappings {
+		mapper.Register(key, mapping)
+	}
+	for key, mapping := range KernelMappings {
+		mapper.Register(key, mapping)
+	}
+	return mapper
+}
+
+// Register maps the events of key with mapping, replacing the mapping registered before, if any.
+func (m *Mapper) Register(key Key, mapping *Mapping) {
+	m.registry.Register(key, mapping)
+}
+
+// Mapping returns the mapping registered for key.
+func (m *Mapper) Mapping(key Key) (*Mapping, bool) {
+	return m.registry.Mapping(key)
+}
+
+// Map converts event to an ECS document, and reports whether a mapping applies to it. Every document holds
+// the header of the event under event, host and winlog, and the properties no mapping converts, or that fail
+// to convert, under winlog.event_data.
+func (m *Mapper) Map(event *etw.Event) (Document, bool) {
+	document := baseDocument(event)
+
+	eventMapping, mapped := m.registry.Mapping(KeyOf(event))
+	var converted map[string]bool
+	if mapped {
+		if len(eventMapping.Category) > 0 {
+			document.Set("event.category", eventMapping.Category)
+		}
+		if len(eventMapping.Type) > 0 {
+			document.Set("event.type", eventMapping.Type)
+		}
+		if eventMapping.Action != "" {
+			document.Set("event.action", eventMapping.Action)
+		}
+		converted = mapping.Apply(document, event, eventMapping.Values, eventMapping.Fields)
+	}
+	mapping.SetUnconverted(document, event, "winlog.event_data.", converted)
+
+	return document, mapped
+}
+
+func baseDocument(event *etw.Event) Document {
+	system := &event.System
+
+	document := Document{}
+	document.Set("@timestamp", system.TimestampUTC)
+	document.Set("ecs.version", Version)
+	document.Set("event.kind", "event")
+	document.Set("event.code", strconv.FormatUint(uint64(system.EventID), 10))
+	if system.Provider.Name != "" {
+		document.Set("event.provider", system.Provider.Name)
+	}
+	if event.Message != "" {
+		document.Set("message", event.Message)
+	}
+	if system.Computer != "" {
+		document.Set("host.hostname", system.Computer)
+	}
+	if event.UserSID != nil {
+		document.Set("user.id", event.UserSID.String())
+	}
+
+	document.Set("winlog.event_id", system.EventID)
+	document.Set("winlog.provider_guid", system.Provider.Guid)
+	if system.Provider.Name != "" {
+		document.Set("winlog.provider_name", system.Provider.Name)
+	}
+	if system.Channel != "" {
+		document.Set("winlog.channel", system.Channel)
+	}
+	if system.EventType != "" {
+		document.Set("winlog.event_type", system.EventType)
+	}
+	if system.Opcode.Name != "" {
+		document.Set("winlog.opcode", system.Opcode.Name)
+	}
+	if system.Task.Name != "" {
+		document.Set("winlog.task", system.Task.Name)
+	}
+	document.Set("winlog.version", system.Version)
+	document.Set("winlog.process.pid", system.Execution.ProcessID)
+	document.Set("winlog.process.thread.id", system.Execution.ThreadID)
+	if system.EventRecordID != 0 {
+		document.Set("winlog.record_id", system.EventRecordID)
+	}
+
+	return document
+}
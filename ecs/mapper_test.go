@@ -0,0 +1,201 @@
+package ecs
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+// loadEvent reads an event of the fixtures shared by the mappers, in the JSON representation of etw.Event.
+func loadEvent(t *testing.T, name string) *etw.Event {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "events", name+".json"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var event etw.Event
+	if err = json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Unmarshal %s: %v", name, err)
+	}
+	return &event
+}
+
+func checkGolden(t *testing.T, name string, document Document) {
+	t.Helper()
+
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	path := filepath.Join("testdata", name+".json")
+	if *update {
+		if err = os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if golden = bytes.TrimRight(golden, "\n"); !bytes.Equal(data, golden) {
+		t.Errorf("%s:\n got %s\nwant %s", name, data, golden)
+	}
+}
+
+func TestMapFixtures(t *testing.T) {
+	tests := []struct {
+		name     string
+		category string
+		action   string
+	}{
+		{"sysmon_process_create", "process", "ProcessCreate"},
+		{"sysmon_network_connect", "network", "NetworkConnect"},
+		{"sysmon_file_create", "file", "FileCreate"},
+		{"kernel_process_start", "process", "ProcessStart"},
+		{"kernel_tcp_connect", "network", "TcpConnect"},
+		{"kernel_file_create", "file", "FileCreate"},
+		{"classic_process_start", "process", "ProcessStart"},
+	}
+
+	mapper := NewMapper()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			document, mapped := mapper.Map(loadEvent(t, test.name))
+			if !mapped {
+				t.Fatalf("no mapping applies")
+			}
+			if category, _ := document.Get("event.category"); !reflect.DeepEqual(category, []string{test.category}) {
+				t.Errorf("event.category = %v, want [%s]", category, test.category)
+			}
+			if action, _ := document.Get("event.action"); action != test.action {
+				t.Errorf("event.action = %v, want %s", action, test.action)
+			}
+			checkGolden(t, test.name, document)
+		})
+	}
+}
+
+// TestMapFields asserts the fields the detection rules of process, network and file events rely on,
+// as set from the typed values of the fixtures.
+func TestMapFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+	}{
+		{"sysmon_process_create", map[string]interface{}{
+			"process.entity_id":        "{8F3A1C2B-4D5E-6F70-8192-A3B4C5D6E7F8}",
+			"process.pid":              uint64(4242),
+			"process.executable":       `C:\Windows\System32\cmd.exe`,
+			"process.name":             "cmd.exe",
+			"process.command_line":     "cmd.exe /c whoami",
+			"process.hash.sha256":      "b99d61d874728edc0918ca0eb10eab93d381e7367e377406e65963366c874450",
+			"process.pe.imphash":       "272245e2988e1e430500b852c4fb5e18",
+			"process.parent.pid":       uint64(1000),
+			"process.parent.name":      "explorer.exe",
+			"process.parent.user.name": "alice",
+			"user.domain":              "CONTOSO",
+			"user.name":                "alice",
+		}},
+		{"sysmon_network_connect", map[string]interface{}{
+			"network.transport": "tcp",
+			"network.direction": "egress",
+			"network.type":      "ipv4",
+			"source.ip":         "10.0.0.5",
+			"source.port":       uint64(50123),
+			"destination.ip":    "93.184.216.34",
+			"destination.port":  uint64(443),
+			"related.ip":        []string{"10.0.0.5", "93.184.216.34"},
+		}},
+		{"sysmon_file_create", map[string]interface{}{
+			"file.path":      `C:\Users\alice\AppData\Local\Temp\payload.PS1`,
+			"file.name":      "payload.PS1",
+			"file.directory": `C:\Users\alice\AppData\Local\Temp`,
+			"file.extension": "ps1",
+		}},
+		{"kernel_tcp_connect", map[string]interface{}{
+			"network.transport": "tcp",
+			"process.pid":       uint64(4242),
+			"source.ip":         "10.0.0.5",
+			"source.port":       uint64(50123),
+			"destination.ip":    "93.184.216.34",
+			"destination.port":  uint64(443),
+		}},
+		{"kernel_file_create", map[string]interface{}{
+			"file.path":      `\Device\HarddiskVolume3\Users\alice\Documents\Report.DOCX`,
+			"file.name":      "Report.DOCX",
+			"file.extension": "docx",
+		}},
+		{"classic_process_start", map[string]interface{}{
+			"process.name": "cmd.exe",
+			"user.id":      "S-1-5-21-1004336348-1177238915-682003330-1001",
+		}},
+	}
+
+	mapper := NewMapper()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			document, _ := mapper.Map(loadEvent(t, test.name))
+			for field, want := range test.fields {
+				if got, _ := document.Get(field); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s = %#v, want %#v", field, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMapUnmapped(t *testing.T) {
+	event := loadEvent(t, "sysmon_file_create")
+	event.System.EventID = 255
+
+	document, mapped := NewMapper().Map(event)
+	if mapped {
+		t.Errorf("mapped event 255")
+	}
+	if _, ok := document.Get("event.category"); ok {
+		t.Errorf("event.category set without a mapping")
+	}
+	if got, _ := document.Get("winlog.event_data.TargetFilename"); got != `C:\Users\alice\AppData\Local\Temp\payload.PS1` {
+		t.Errorf("winlog.event_data.TargetFilename = %v", got)
+	}
+	if got, _ := document.Get("event.code"); got != "255" {
+		t.Errorf("event.code = %v, want 255", got)
+	}
+}
+
+func TestMapEventData(t *testing.T) {
+	event := &etw.Event{EventData: map[string]string{"TargetFilename": `C:\temp\a.txt`, "ProcessId": "12"}}
+	event.System.EventID = 11
+	event.System.Provider.Guid = "{5770385f-c22a-43e0-bf4c-06f5698ffbd9}"
+
+	document, mapped := NewMapper().Map(event)
+	if !mapped {
+		t.Fatalf("no mapping applies to the lowercase provider GUID")
+	}
+	if got, _ := document.Get("file.name"); got != "a.txt" {
+		t.Errorf("file.name = %v, want a.txt", got)
+	}
+	if got, _ := document.Get("process.pid"); got != int64(12) {
+		t.Errorf("process.pid = %#v, want 12", got)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	mapper := NewMapper()
+	key := Key{Provider: "{5770385f-c22a-43e0-bf4c-06f5698ffbd9}", EventID: 11}
+	mapper.Register(key, &Mapping{Action: "Custom"})
+
+	mapping, ok := mapper.Mapping(sysmonKey(11))
+	if !ok || mapping.Action != "Custom" {
+		t.Errorf("Mapping = %+v, want the registered one", mapping)
+	}
+}
@@ -0,0 +1,260 @@
+package ecs
+
+// https://learn.microsoft.com/en-us/sysinternals/downloads/sysmon#events
+// https://github.com/elastic/integrations/tree/main/packages/windows/data_stream/sysmon_operational
+
+// SysmonProvider is the GUID of the Microsoft-Windows-Sysmon provider.
+const SysmonProvider = "{5770385F-C22A-43E0-BF4C-06F5698FFBD9}"
+
+// Fields common to the Sysmon events of a process.
+var (
+	sysmonRule = []Field{
+		{Property: "RuleName", Target: "rule.name"},
+		{Property: "UtcTime", Target: "@timestamp", Convert: Timestamp},
+	}
+	sysmonProcess = []Field{
+		{Property: "ProcessGuid", Target: "process.entity_id"},
+		{Property: "ProcessId", Target: "process.pid", Convert: Integer},
+		{Property: "Image", Target: "process", Convert: Executable},
+		{Property: "User", Target: "user", Convert: User},
+	}
+	sysmonTargetFile = []Field{
+		{Property: "TargetFilename", Target: "file", Convert: Path},
+		{Property: "Hashes", Target: "file", Convert: Hashes},
+	}
+)
+
+// sysmonPE maps the version information of the image of Sysmon events to the pe fields under target.
+func sysmonPE(target string) []Field {
+	return []Field{
+		{Property: "FileVersion", Target: target + ".pe.file_version"},
+		{Property: "Description", Target: target + ".pe.description"},
+		{Property: "Product", Target: target + ".pe.product"},
+		{Property: "Company", Target: target + ".pe.company"},
+		{Property: "OriginalFileName", Target: target + ".pe.original_file_name"},
+	}
+}
+
+func sysmonCodeSignature(target string) []Field {
+	return []Field{
+		{Property: "Signed", Target: target + ".code_signature.signed", Convert: Bool},
+		{Property: "Signature", Target: target + ".code_signature.subject_name"},
+		{Property: "SignatureStatus", Target: target + ".code_signature.status"},
+	}
+}
+
+func fields(groups ...[]Field) []Field {
+	var all []Field
+	for _, group := range groups {
+		all = append(all, group...)
+	}
+	return all
+}
+
+func sysmonKey(eventID uint16) Key {
+	return Key{Provider: SysmonProvider, EventID: eventID}
+}
+
+// SysmonMappings maps the events 1 to 29 of Sysmon.
+var SysmonMappings = map[Key]*Mapping{
+	sysmonKey(1): {
+		Category: []string{"process"}, Type: []string{"start"}, Action: "ProcessCreate",
+		Fields: fields(sysmonRule, sysmonProcess, sysmonPE("process"), []Field{
+			{Property: "CommandLine", Target: "process.command_line"},
+			{Property: "CurrentDirectory", Target: "process.working_directory"},
+			{Property: "Hashes", Target: "process", Convert: Hashes},
+			{Property: "ParentProcessGuid", Target: "process.parent.entity_id"},
+			{Property: "ParentProcessId", Target: "process.parent.pid", Convert: Integer},
+			{Property: "ParentImage", Target: "process.parent", Convert: Executable},
+			{Property: "ParentCommandLine", Target: "process.parent.command_line"},
+			{Property: "ParentUser", Target: "process.parent.user", Convert: User},
+		}),
+	},
+	sysmonKey(2): {
+		Category: []string{"file"}, Type: []string{"change"}, Action: "FileCreateTime",
+		Fields: fields(sysmonRule, sysmonProcess, sysmonTargetFile, []Field{
+			{Property: "CreationUtcTime", Target: "file.created", Convert: Timestamp},
+		}),
+	},
+	sysmonKey(3): {
+		Category: []string{"network"}, Type: []string{"connection", "start"}, Action: "NetworkConnect",
+		Fields: fields(sysmonRule, sysmonProcess, []Field{
+			{Property: "Protocol", Target: "network.transport", Convert: Lowercase},
+			{Property: "Initiated", Target: "network.direction", Convert: Direction},
+			{Property: "SourceIsIpv6", Target: "network.type", Convert: NetworkType},
+			{Property: "SourceIp", Target: "source.ip", Convert: IP},
+			{Property: "SourceHostname", Target: "source.domain"},
+			{Property: "SourcePort", Target: "source.port", Convert: Integer},
+			{Property: "DestinationIp", Target: "destination.ip", Convert: IP},
+			{Property: "DestinationHostname", Target: "destination.domain"},
+			{Property: "DestinationPort", Target: "destination.port", Convert: Integer},
+		}),
+	},
+	sysmonKey(4): {
+		Category: []string{"process"}, Type: []string{"change"}, Action: "SysmonServiceStateChange",
+		Fields: fields(sysmonRule, []Field{
+			{Property: "State", Target: "service.state"},
+			{Property: "Version", Target: "service.version"},
+		}),
+	},
+	sysmonKey(5): {
+		Category: []string{"process"}, Type: []string{"end"}, Action: "ProcessTerminate",
+		Fields: fields(sysmonRule, sysmonProcess),
+	},
+	sysmonKey(6): {
+		Category: []string{"driver"}, Type: []string{"start"}, Action: "DriverLoad",
+		Fields: fields(sysmonRule, sysmonCodeSignature("file"), []Field{
+			{Property: "ImageLoaded", Target: "file", Convert: Path},
+			{Property: "Hashes", Target: "file", Convert: Hashes},
+		}),
+	},
+	sysmonKey(7): {
+		Category: []string{"library"}, Type: []string{"start"}, Action: "ImageLoad",
+		Fields: fields(sysmonRule, sysmonProcess, sysmonPE("dll"), sysmonCodeSignature("dll"), []Field{
+			{Property: "ImageLoaded", Target: "dll", Convert: Path},
+			{Property: "Hashes", Target: "dll", Convert: Hashes},
+		}),
+	},
+	sysmonKey(8): {
+		Category: []string{"process"}, Type: []string{"change"}, Action: "CreateRemoteThread",
+		Fields: fields(sysmonRule, []Field{
+			{Property: "SourceProcessGuid", Target: "process.entity_id"},
+			{Property: "SourceProcessId", Target: "process.pid", Convert: Integer},
+			{Property: "SourceImage", Target: "process", Convert: Executable},
+			{Property: "SourceUser", Target: "user", Convert: User},
+			{Property: "NewThreadId", Target: "process.thread.id", Convert: Integer},
+		}),
+	},
+	sysmonKey(9): {
+		Category: []string{"file"}, Type: []string{"access"}, Action: "RawAccessRead",
+		Fields: fields(sysmonRule, sysmonProcess, []Field{
+			{Property: "Device", Target: "file.path"},
+		}),
+	},
+	sysmonKey(10): {
+		Category: []string{"process"}, Type: []string{"access"}, Action: "ProcessAccess",
+		Fields: fields(sysmonRule, []Field{
+			{Property: "SourceProcessGUID", Target: "process.entity_id"},
+			{Property: "SourceProcessId", Target: "process.pid", Convert: Integer},
+			{Property: "SourceThreadId", Target: "process.thread.id", Convert: Integer},
+			{Property: "SourceImage", Target: "process", Convert: Executable},
+			{Property: "SourceUser", Target: "user", Convert: User},
+		}),
+	},
+	sysmonKey(11): {
+		Category: []string{"file"}, Type: []string{"creation"}, Action: "FileCreate",
+		Fields: fields(sysmonRule, sysmonProcess, sysmonTargetFile, []Field{
+			{Property: "CreationUtcTime", Target: "file.created", Convert: Timestamp},
+		}),
+	},
+	sysmonKey(12): {
+		Category: []string{"registry"}, Type: []string{"change"}, Action: "RegistryEvent",
+		Fields: fields(sysmonRule, sysmonProcess, []Field{
+			{Property: "EventType", Target: "event.action", Convert: Action},
+			{Property: "TargetObject", Target: "registry", Convert: RegistryKey},
+		}),
+	},
+	sysmonKey(13): {
+		Category: []string{"registry"}, Type: []string{"change"}, Action: "RegistryEvent",
+		Fields: fields(sysmonRule, sysmonProcess, []Field{
+			{Property: "EventType", Target: "event.action", Convert: Action},
+			{Property: "TargetObject", Target: "registry", Convert: RegistryValue},
+			{Property: "Details", Target: "registry", Convert: RegistryData},
+		}),
+	},
+	sysmonKey(14): {
+		Category: []string{"registry"}, Type: []string{"change"}, Action: "RegistryEvent",
+		Fields: fields(sysmonRule, sysmonProcess, []Field{
+			{Property: "EventType", Target: "event.action", Convert: Action},
+			{Property: "TargetObject", Target: "registry", Convert: RegistryKey},
+		}),
+	},
+	sysmonKey(15): {
+		Category: []string{"file"}, Type: []string{"creation"}, Action: "FileCreateStreamHash",
+		Fields: fields(sysmonRule, sysmonProcess, []Field{
+			{Property: "TargetFilename", Target: "file", Convert: Path},
+			{Property: "Hash", Target: "file", Convert: Hashes},
+			{Property: "CreationUtcTime", Target: "file.created", Convert: Timestamp},
+		}),
+	},
+	sysmonKey(16): {
+		Category: []string{"configuration"}, Type: []string{"change"}, Action: "ServiceConfigurationChange",
+		Fields: fields(sysmonRule, []Field{
+			{Property: "Configuration", Target: "file", Convert: Path},
+			{Property: "ConfigurationFileHash", Target: "file", Convert: Hashes},
+		}),
+	},
+	sysmonKey(17): {
+		Category: []string{"file"}, Type: []string{"creation"}, Action: "CreatePipe",
+		Fields: fields(sysmonRule, sysmonProcess, []Field{
+			{Property: "EventType", Target: "event.action", Convert: Action},
+			{Property: "PipeName", Target: "file.name"},
+		}),
+	},
+	sysmonKey(18): {
+		Category: []string{"file"}, Type: []string{"access"}, Action: "ConnectPipe",
+		Fields: fields(sysmonRule, sysmonProcess, []Field{
+			{Property: "EventType", Target: "event.action", Convert: Action},
+			{Property: "PipeName", Target: "file.name"},
+		}),
+	},
+	sysmonKey(19): {
+		Category: []string{"configuration"}, Type: []string{"change"}, Action: "WmiFilterEvent",
+		Fields: fields(sysmonRule, []Field{
+			{Property: "EventType", Target: "event.action", Convert: Action},
+			{Property: "User", Target: "user", Convert: User},
+		}),
+	},
+	sysmonKey(20): {
+		Category: []string{"configuration"}, Type: []string{"change"}, Action: "WmiConsumerEvent",
+		Fields: fields(sysmonRule, []Field{
+			{Property: "EventType", Target: "event.action", Convert: Action},
+			{Property: "User", Target: "user", Convert: User},
+		}),
+	},
+	sysmonKey(21): {
+		Category: []string{"configuration"}, Type: []string{"change"}, Action: "WmiBindingEvent",
+		Fields: fields(sysmonRule, []Field{
+			{Property: "EventType", Target: "event.action", Convert: Action},
+			{Property: "User", Target: "user", Convert: User},
+		}),
+	},
+	sysmonKey(22): {
+		Category: []string{"network"}, Type: []string{"protocol", "info"}, Action: "DnsQuery",
+		Fields: fields(sysmonRule, sysmonProcess, []Field{
+			{Property: "QueryName", Target: "dns.question.name"},
+			{Property: "QueryStatus", Target: "dns.response_code", Convert: DNSResponseCode},
+			{Property: "QueryResults", Target: "dns", Convert: DNSAnswers},
+		}),
+	},
+	sysmonKey(23): {
+		Category: []string{"file"}, Type: []string{"deletion"}, Action: "FileDelete",
+		Fields: fields(sysmonRule, sysmonProcess, sysmonTargetFile),
+	},
+	sysmonKey(24): {
+		Category: []string{"process"}, Type: []string{"info"}, Action: "ClipboardChange",
+		Fields: fields(sysmonRule, sysmonProcess, []Field{
+			{Property: "Hashes", Target: "file", Convert: Hashes},
+		}),
+	},
+	sysmonKey(25): {
+		Category: []string{"process"}, Type: []string{"change"}, Action: "ProcessTampering",
+		Fields: fields(sysmonRule, sysmonProcess),
+	},
+	sysmonKey(26): {
+		Category: []string{"file"}, Type: []string{"deletion"}, Action: "FileDeleteDetected",
+		Fields: fields(sysmonRule, sysmonProcess, sysmonTargetFile),
+	},
+	sysmonKey(27): {
+		Category: []string{"file"}, Type: []string{"creation", "denied"}, Action: "FileBlockExecutable",
+		Fields: fields(sysmonRule, sysmonProcess, sysmonTargetFile),
+	},
+	sysmonKey(28): {
+		Category: []string{"file"}, Type: []string{"deletion", "denied"}, Action: "FileBlockShredding",
+		Fields: fields(sysmonRule, sysmonProcess, sysmonTargetFile),
+	},
+	sysmonKey(29): {
+		Category: []string{"file"}, Type: []string{"creation"}, Action: "FileExecutableDetected",
+		Fields: fields(sysmonRule, sysmonProcess, sysmonTargetFile),
+	},
+}
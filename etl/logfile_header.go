@@ -0,0 +1,129 @@
+package etl
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/evntrace/ns-evntrace-trace_logfile_header
+
+// LogfileHeader is the TRACE_LOGFILE_HEADER logged as the first event of a trace file.
+type LogfileHeader struct {
+	BufferSize         uint32
+	Version            uint32
+	ProviderVersion    uint32 // build number of the operating system
+	NumberOfProcessors uint32
+	EndTime            int64 // FILETIME
+	TimerResolution    uint32
+	MaximumFileSize    uint32
+	LogFileMode        uint32
+	BuffersWritten     uint32
+	StartBuffers       uint32
+	PointerSize        uint32
+	EventsLost         uint32
+	CpuSpeedInMHz      uint32
+
+	TimeZoneBias int32 // in minutes, UTC = local time + bias
+	StandardName string
+	StandardBias int32
+	DaylightName string
+	DaylightBias int32
+
+	LoggerName  string
+	LogFileName string
+
+	BootTime      int64 // FILETIME
+	PerfFreq      int64 // frequency of the raw time stamps, when ReservedFlags is ClockTypeQueryPerformanceCounter
+	StartTime     int64 // FILETIME
+	ReservedFlags uint32
+	BuffersLost   uint32
+}
+
+// Clock types, in LogfileHeader.ReservedFlags.
+// https://learn.microsoft.com/en-us/windows/win32/etw/wnode-header
+const (
+	ClockTypeQueryPerformanceCounter = 1
+	ClockTypeSystemTime              = 2
+	ClockTypeCPUCycleCounter         = 3
+)
+
+const (
+	logfileHeaderFixedSize = 56  // up to the LoggerName pointer
+	timeZoneInfoSize       = 172 // TIME_ZONE_INFORMATION
+	timeZoneNameLength     = 32  // in characters
+)
+
+// parseLogfileHeader reads the user data of the first event of a file, with the logger pointer size.
+// The LoggerName and LogFileName pointers are garbage, both strings follow the structure.
+func parseLogfileHeader(data []byte, pointerSize uint32) (*LogfileHeader, error) {
+	if len(data) < logfileHeaderFixedSize {
+		return nil, fmt.Errorf("%w: logfile header of %d bytes", ErrInvalidEvent, len(data))
+	}
+
+	header := LogfileHeader{
+		BufferSize:         binary.LittleEndian.Uint32(data),
+		Version:            binary.LittleEndian.Uint32(data[4:]),
+		ProviderVersion:    binary.LittleEndian.Uint32(data[8:]),
+		NumberOfProcessors: binary.LittleEndian.Uint32(data[12:]),
+		EndTime:            int64(binary.LittleEndian.Uint64(data[16:])),
+		TimerResolution:    binary.LittleEndian.Uint32(data[24:]),
+		MaximumFileSize:    binary.LittleEndian.Uint32(data[28:]),
+		LogFileMode:        binary.LittleEndian.Uint32(data[32:]),
+		BuffersWritten:     binary.LittleEndian.Uint32(data[36:]),
+		StartBuffers:       binary.LittleEndian.Uint32(data[40:]),
+		PointerSize:        binary.LittleEndian.Uint32(data[44:]),
+		EventsLost:         binary.LittleEndian.Uint32(data[48:]),
+		CpuSpeedInMHz:      binary.LittleEndian.Uint32(data[52:]),
+	}
+	if header.PointerSize == 4 || header.PointerSize == 8 {
+		pointerSize = header.PointerSize
+	} else {
+		header.PointerSize = pointerSize
+	}
+
+	timeZoneOffset := logfileHeaderFixedSize + 2*int(pointerSize)
+	timesOffset := align(timeZoneOffset+timeZoneInfoSize, 8)
+	size := timesOffset + 32
+	if len(data) < size {
+		return nil, fmt.Errorf("%w: logfile header of %d bytes", ErrInvalidEvent, len(data))
+	}
+
+	timeZone := data[timeZoneOffset:]
+	header.TimeZoneBias = int32(binary.LittleEndian.Uint32(timeZone))
+	header.StandardName = fixedUTF16String(timeZone[4:])
+	header.StandardBias = int32(binary.LittleEndian.Uint32(timeZone[84:]))
+	header.DaylightName = fixedUTF16String(timeZone[88:])
+	header.DaylightBias = int32(binary.LittleEndian.Uint32(timeZone[168:]))
+
+	times := data[timesOffset:]
+	header.BootTime = int64(binary.LittleEndian.Uint64(times))
+	header.PerfFreq = int64(binary.LittleEndian.Uint64(times[8:]))
+	header.StartTime = int64(binary.LittleEndian.Uint64(times[16:]))
+	header.ReservedFlags = binary.LittleEndian.Uint32(times[24:])
+	header.BuffersLost = binary.LittleEndian.Uint32(times[28:])
+
+	decoder := etw.PropertyDecoder{PointerSize: pointerSize}
+	names := data[size:]
+	for _, name := range []*string{&header.LoggerName, &header.LogFileName} {
+		value, consumed, err := decoder.Decode(names, winapi.TdhInTypeUnicodestring, winapi.TdhOutTypeString, 0)
+		if err != nil {
+			break
+		}
+		*name = value.String()
+		names = names[consumed:]
+	}
+
+	return &header, nil
+}
+
+// fixedUTF16String reads a WCHAR[32] time zone name.
+func fixedUTF16String(data []byte) string {
+	value, _, err := etw.PropertyDecoder{}.Decode(data[:2*timeZoneNameLength], winapi.TdhInTypeUnicodestring, winapi.TdhOutTypeString, 0)
+	if err != nil {
+		return ""
+	}
+	return value.String()
+}
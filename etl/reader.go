@@ -0,0 +1,244 @@
+package etl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+// https://www.geoffchappell.com/studies/windows/km/ntoskrnl/inc/api/ntwmi/wmi_buffer_header/index.htm
+
+// BufferHeader is the WMI_BUFFER_HEADER starting every buffer of a trace file.
+type BufferHeader struct {
+	BufferSize     uint32
+	SavedOffset    uint32
+	CurrentOffset  uint32
+	ReferenceCount int32
+	TimeStamp      int64
+	SequenceNumber int64
+	Clock          uint64 // ClockType on 3 bits, then Frequency
+	ClientContext  winapi.BufferContext
+	State          uint32
+	Offset         uint32
+	BufferFlag     uint16
+	BufferType     uint16
+	StartTime      int64
+	StartPerfClock int64
+}
+
+const (
+	bufferHeaderSize = 72
+	maxBufferSize    = 64 << 20 // ETW buffers are at most a few MB, larger sizes come from damaged files
+)
+
+// https://www.geoffchappell.com/studies/windows/km/ntoskrnl/inc/api/ntwmi/wmi_buffer_header/bufferflag.htm
+const (
+	ETW_BUFFER_FLAG_NORMAL           = 0x0000
+	ETW_BUFFER_FLAG_FLUSH_MARKER     = 0x0001
+	ETW_BUFFER_FLAG_EVENTS_LOST      = 0x0002
+	ETW_BUFFER_FLAG_BUFFER_LOST      = 0x0004
+	ETW_BUFFER_FLAG_RTBACKUP_CORRUPT = 0x0008
+	ETW_BUFFER_FLAG_RTBACKUP         = 0x0010
+	ETW_BUFFER_FLAG_PROC_INDEX       = 0x0020
+	ETW_BUFFER_FLAG_COMPRESSED       = 0x0040
+)
+
+var (
+	ErrInvalidBuffer     = fmt.Errorf("invalid trace buffer")
+	ErrCompressedBuffer  = fmt.Errorf("compressed trace buffers are not supported")
+	ErrMissingFileHeader = fmt.Errorf("trace file does not start with a logfile header")
+)
+
+// dataEnd returns the offset of the end of the events in a buffer of header.BufferSize bytes.
+func (b *BufferHeader) dataEnd() int {
+	for _, offset := range []uint32{b.SavedOffset, b.CurrentOffset, b.Offset} {
+		if offset >= bufferHeaderSize && offset <= b.BufferSize {
+			return int(offset)
+		}
+	}
+	return int(b.BufferSize)
+}
+
+// Reader reads the events of a trace file, an .etl file, without ETW. It builds on every platform.
+//
+// Events are returned in file order: the buffers of each processor are written as they fill up,
+// so time stamps only increase within a buffer. Time stamps are converted to FILETIME,
+// as ProcessTrace does without PROCESS_TRACE_MODE_RAW_TIMESTAMP.
+type Reader struct {
+	source io.Reader
+	header *LogfileHeader
+
+	bufferHeader BufferHeader
+	buffer       []byte
+	offset       int
+	end          int
+
+	syncTimestamp int64 // raw time stamp of the logfile header event, logged at header.StartTime
+	frequency     int64 // of raw time stamps, in Hz
+}
+
+// NewReader reads the first buffer of r, which starts with the logfile header.
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{source: r}
+
+	if err := reader.readBuffer(); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = ErrMissingFileHeader
+		}
+		return nil, err
+	}
+
+	header, err := readTraceHeader(reader.buffer[reader.offset:reader.end])
+	if err != nil || (header.headerType != TRACE_HEADER_TYPE_SYSTEM32 && header.headerType != TRACE_HEADER_TYPE_SYSTEM64) {
+		return nil, ErrMissingFileHeader
+	}
+	record, err := newRecord(header, reader.buffer[reader.offset:reader.end], 0)
+	if err != nil {
+		return nil, err
+	}
+	if record.EventHeader.ProviderId != winapi.KernelEventGroupMapping[0] {
+		return nil, ErrMissingFileHeader
+	}
+
+	pointerSize := uint32(8)
+	if header.headerType == TRACE_HEADER_TYPE_SYSTEM32 {
+		pointerSize = 4
+	}
+	if reader.header, err = parseLogfileHeader(record.UserData, pointerSize); err != nil {
+		return nil, err
+	}
+
+	reader.syncTimestamp = record.EventHeader.TimeStamp
+	switch reader.header.ReservedFlags {
+	case ClockTypeQueryPerformanceCounter:
+		reader.frequency = reader.header.PerfFreq
+	case ClockTypeCPUCycleCounter:
+		reader.frequency = int64(reader.header.CpuSpeedInMHz) * 1000000
+	}
+
+	return reader, nil
+}
+
+// Header returns the logfile header, also returned as the first event by Next.
+func (r *Reader) Header() *LogfileHeader {
+	return r.header
+}
+
+// BufferHeader returns the header of the buffer holding the last event returned by Next.
+func (r *Reader) BufferHeader() *BufferHeader {
+	return &r.bufferHeader
+}
+
+// Next returns the next event, or io.EOF at the end of the file. Records stay valid after further calls.
+// Errors other than io.EOF concern a damaged or unsupported buffer, whose remaining events are skipped:
+// calling Next again resumes with the next buffer.
+func (r *Reader) Next() (*etw.Record, error) {
+	for {
+		if r.offset+4 > r.end || binary.LittleEndian.Uint32(r.buffer[r.offset:]) == endOfBuffer {
+			if err := r.readBuffer(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		data := r.buffer[r.offset:r.end]
+		header, err := readTraceHeader(data)
+		if err != nil {
+			r.offset = r.end
+			return nil, err
+		}
+		r.offset += align(header.size, eventAlignment)
+
+		record, err := newRecord(header, data, r.header.PointerSize)
+		if err != nil {
+			r.offset = r.end
+			return nil, err
+		}
+
+		record.BufferContext = r.bufferHeader.ClientContext
+		if record.EventHeader.TimeStamp == 0 {
+			record.EventHeader.TimeStamp = r.bufferHeader.TimeStamp
+		}
+		record.EventHeader.TimeStamp = r.Filetime(record.EventHeader.TimeStamp)
+
+		return record, nil
+	}
+}
+
+// Filetime converts a raw time stamp of the file to a FILETIME.
+func (r *Reader) Filetime(timestamp int64) int64 {
+	if r.header == nil || r.header.ReservedFlags == ClockTypeSystemTime || r.frequency <= 0 {
+		return timestamp
+	}
+
+	// delta*10^7 overflows for traces longer than a day
+	delta := timestamp - r.syncTimestamp
+	ticks := (delta/r.frequency)*10000000 + (delta%r.frequency)*10000000/r.frequency
+	return r.header.StartTime + ticks
+}
+
+func (r *Reader) readBuffer() error {
+	headerBytes := make([]byte, bufferHeaderSize)
+	if _, err := io.ReadFull(r.source, headerBytes); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("%w: truncated buffer header", ErrInvalidBuffer)
+		}
+		return err
+	}
+
+	var bufferHeader BufferHeader
+	if err := binary.Read(bytes.NewReader(headerBytes), binary.LittleEndian, &bufferHeader); err != nil {
+		return err
+	}
+	if bufferHeader.BufferSize < bufferHeaderSize || bufferHeader.BufferSize > maxBufferSize {
+		return fmt.Errorf("%w: buffer of %d bytes", ErrInvalidBuffer, bufferHeader.BufferSize)
+	}
+
+	buffer := make([]byte, bufferHeader.BufferSize)
+	copy(buffer, headerBytes)
+	if _, err := io.ReadFull(r.source, buffer[bufferHeaderSize:]); err != nil {
+		return fmt.Errorf("%w: truncated buffer: %s", ErrInvalidBuffer, err)
+	}
+
+	r.bufferHeader = bufferHeader
+	r.buffer = buffer
+	r.offset = bufferHeaderSize
+	r.end = bufferHeader.dataEnd()
+
+	if bufferHeader.BufferFlag&ETW_BUFFER_FLAG_COMPRESSED == ETW_BUFFER_FLAG_COMPRESSED {
+		r.offset = r.end
+		return ErrCompressedBuffer
+	}
+	return nil
+}
+
+// File is a Reader over an .etl file.
+type File struct {
+	*Reader
+	file *os.File
+}
+
+func Open(name string) (*File, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := NewReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &File{Reader: reader, file: file}, nil
+}
+
+func (f *File) Close() error {
+	return f.file.Close()
+}
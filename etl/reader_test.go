@@ -0,0 +1,515 @@
+package etl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/synthetic.etl")
+
+const (
+	testBufferSize = 1024
+
+	testStartTime     = int64(133500000000000000) // FILETIME of the logfile header event
+	testSyncTimestamp = int64(1000)               // raw QPC time stamp of the logfile header event
+	testPerfFreq      = int64(10000000)
+)
+
+var (
+	testProviderGUID = *winguid.MustParse("{5770385F-C22A-43E0-BF4C-06F5698FFBD9}")
+	testActivityGUID = *winguid.MustParse("{11111111-2222-3333-4444-555555555555}")
+	testRelatedGUID  = *winguid.MustParse("{66666666-7777-8888-9999-AAAAAAAAAAAA}")
+	testClassGUID    = *winguid.MustParse("{3D6FA8D0-FE05-11D0-9DDA-00C04FD7BA7C}")
+	testMessageGUID  = *winguid.MustParse("{E6A7D2F0-1C0B-4B44-B3C3-2C1F6A6E6C1D}")
+)
+
+// traceBuilder writes trace files: buffers of testBufferSize bytes, each holding 8 bytes aligned events.
+type traceBuilder struct {
+	bytes.Buffer
+	events [][]byte // of the buffer being built
+}
+
+func le(values ...interface{}) []byte {
+	var buffer bytes.Buffer
+	for _, value := range values {
+		if err := binary.Write(&buffer, binary.LittleEndian, value); err != nil {
+			panic(err)
+		}
+	}
+	return buffer.Bytes()
+}
+
+func utf16z(s string) []byte {
+	var data []byte
+	for _, r := range s {
+		data = append(data, byte(r), byte(r>>8))
+	}
+	return append(data, 0, 0)
+}
+
+func (b *traceBuilder) event(data []byte) {
+	b.events = append(b.events, data)
+}
+
+// flush writes the pending events in a buffer, with flags as BufferFlag.
+func (b *traceBuilder) flush(flags uint16) {
+	body := make([]byte, 0, testBufferSize)
+	for _, event := range b.events {
+		body = append(body, event...)
+		body = append(body, make([]byte, align(len(event), eventAlignment)-len(event))...)
+	}
+	b.events = nil
+
+	end := bufferHeaderSize + len(body)
+	header := BufferHeader{
+		BufferSize:    testBufferSize,
+		SavedOffset:   uint32(end),
+		CurrentOffset: uint32(end),
+		TimeStamp:     testSyncTimestamp,
+		ClientContext: winapi.BufferContext{Union: 1},
+		Offset:        uint32(end),
+		BufferFlag:    flags,
+	}
+	buffer := append(le(header), body...)
+	for len(buffer) < testBufferSize {
+		buffer = append(buffer, 0xff)
+	}
+	b.Write(buffer)
+}
+
+// systemEvent is a SYSTEM_TRACE_HEADER event of the kernel logger, 64 bits.
+func systemEvent(group, eventType uint8, timestamp int64, userData []byte) []byte {
+	return append(le(uint16(2), uint8(TRACE_HEADER_TYPE_SYSTEM64), uint8(TRACE_HEADER_FLAG|TRACE_HEADER_EVENT_TRACE),
+		uint16(systemTraceHeaderSize+len(userData)), eventType, group,
+		uint32(4), uint32(8), timestamp, uint32(10), uint32(20)), userData...)
+}
+
+// logfileHeader is the user data of the first event, with 8 bytes pointers.
+func logfileHeader() []byte {
+	data := le(uint32(testBufferSize), uint32(0x0a000000), uint32(19045), uint32(4),
+		int64(0), uint32(156250), uint32(0), uint32(0x01000001), uint32(2), uint32(0), uint32(8), uint32(3), uint32(2400),
+		uint64(0), uint64(0)) // LoggerName and LogFileName pointers
+	timeZone := make([]byte, timeZoneInfoSize)
+	binary.LittleEndian.PutUint32(timeZone, uint32(0xffffffc4)) // -60
+	copy(timeZone[4:], utf16z("Romance Standard Time"))
+	data = append(data, timeZone...)
+	data = append(data, make([]byte, align(len(data), 8)-len(data))...)
+	data = append(data, le(int64(133400000000000000), testPerfFreq, testStartTime, uint32(ClockTypeQueryPerformanceCounter), uint32(1))...)
+	data = append(data, utf16z("Test Session")...)
+	return append(data, utf16z(`C:\trace.etl`)...)
+}
+
+// manifestEvent is an EVENT_HEADER event with a related activity ID item.
+func manifestEvent(timestamp int64, userData []byte) []byte {
+	extendedData := append(le(uint16(0), uint16(winapi.EVENT_HEADER_EXT_TYPE_RELATED_ACTIVITYID), uint16(0), uint16(16)), winguid.ToBytes(&testRelatedGUID)...)
+	size := eventHeaderSize + len(extendedData) + len(userData)
+	header := le(uint16(size), uint8(TRACE_HEADER_TYPE_EVENT_HEADER64), uint8(TRACE_HEADER_FLAG|TRACE_HEADER_EVENT_TRACE),
+		uint16(winapi.EVENT_HEADER_FLAG_EXTENDED_INFO|winapi.EVENT_HEADER_FLAG_64_BIT_HEADER), uint16(0),
+		uint32(100), uint32(200), timestamp)
+	header = append(header, winguid.ToBytes(&testProviderGUID)...)
+	header = append(header, le(winapi.EventDescriptor{Id: 7, Version: 1, Channel: 16, Level: 4, Opcode: 1, Task: 3, Keyword: 0x80})...)
+	header = append(header, le(uint64(0))...)
+	header = append(header, winguid.ToBytes(&testActivityGUID)...)
+	return append(append(header, extendedData...), userData...)
+}
+
+// classicEvent is an EVENT_TRACE_HEADER event of a MOF provider.
+func classicEvent(timestamp int64, userData []byte) []byte {
+	header := le(uint16(fullTraceHeaderSize+len(userData)), uint8(TRACE_HEADER_TYPE_FULL_HEADER64), uint8(TRACE_HEADER_FLAG|TRACE_HEADER_EVENT_TRACE),
+		uint8(2), uint8(4), uint16(3), uint32(300), uint32(400), timestamp)
+	header = append(header, winguid.ToBytes(&testClassGUID)...)
+	return append(append(header, le(uint64(0))...), userData...)
+}
+
+// messageEvent is a WPP MESSAGE_TRACE_HEADER event with a GUID, a time stamp and system information.
+func messageEvent(timestamp int64, userData []byte) []byte {
+	header := le(uint16(messageTraceHeaderSize+32+len(userData)), uint8(TRACE_HEADER_TYPE_MESSAGE), uint8(TRACE_HEADER_FLAG|TRACE_MESSAGE),
+		uint16(12), uint16(TRACE_MESSAGE_GUID|TRACE_MESSAGE_TIMESTAMP|TRACE_MESSAGE_SYSTEMINFO))
+	header = append(header, winguid.ToBytes(&testMessageGUID)...)
+	header = append(header, le(timestamp, uint32(500), uint32(600))...)
+	return append(header, userData...)
+}
+
+// syntheticTrace holds two buffers: the logfile header and a manifest event,
+// then a classic event, a WPP message and a process end kernel event.
+func syntheticTrace() []byte {
+	var builder traceBuilder
+	builder.event(systemEvent(0, 0, testSyncTimestamp, logfileHeader()))
+	builder.event(manifestEvent(testSyncTimestamp+testPerfFreq, []byte{1, 2, 3}))
+	builder.flush(ETW_BUFFER_FLAG_NORMAL)
+	builder.event(classicEvent(testSyncTimestamp+2*testPerfFreq, []byte{4, 5}))
+	builder.event(messageEvent(testSyncTimestamp+3*testPerfFreq, []byte{6}))
+	builder.event(systemEvent(3, 2, testSyncTimestamp+4*testPerfFreq, []byte{7, 8, 9, 10}))
+	builder.flush(ETW_BUFFER_FLAG_NORMAL)
+	return builder.Bytes()
+}
+
+func TestSyntheticFile(t *testing.T) {
+	name := filepath.Join("testdata", "synthetic.etl")
+	if *update {
+		if err := os.WriteFile(name, syntheticTrace(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, syntheticTrace()) {
+		t.Fatalf("%s is out of date, run go test -update", name)
+	}
+
+	file, err := Open(name)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer file.Close()
+
+	checkSyntheticTrace(t, file.Reader)
+}
+
+func checkSyntheticTrace(t *testing.T, reader *Reader) {
+	t.Helper()
+
+	header := reader.Header()
+	if header.LoggerName != "Test Session" || header.LogFileName != `C:\trace.etl` {
+		t.Errorf("names = %q %q", header.LoggerName, header.LogFileName)
+	}
+	if header.PointerSize != 8 || header.NumberOfProcessors != 4 || header.ProviderVersion != 19045 || header.EventsLost != 3 {
+		t.Errorf("header = %+v", header)
+	}
+	if header.TimeZoneBias != -60 || header.StandardName != "Romance Standard Time" {
+		t.Errorf("time zone = %d %q", header.TimeZoneBias, header.StandardName)
+	}
+	if header.StartTime != testStartTime || header.PerfFreq != testPerfFreq || header.ReservedFlags != ClockTypeQueryPerformanceCounter {
+		t.Errorf("clock = %d %d %d", header.StartTime, header.PerfFreq, header.ReservedFlags)
+	}
+
+	var records []*etw.Record
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 5 {
+		t.Fatalf("%d records, want 5", len(records))
+	}
+
+	for i, record := range records {
+		if want := testStartTime + int64(i)*10000000; record.EventHeader.TimeStamp != want {
+			t.Errorf("record %d: time stamp %d, want %d", i, record.EventHeader.TimeStamp, want)
+		}
+	}
+
+	logfile := records[0]
+	if logfile.EventHeader.ProviderId != winapi.KernelEventGroupMapping[0] || !logfile.IsClassic() || logfile.PointerSize() != 8 {
+		t.Errorf("logfile header record = %+v", logfile.EventHeader)
+	}
+
+	manifest := records[1]
+	if manifest.EventHeader.ProviderId != testProviderGUID || manifest.EventHeader.ActivityId != testActivityGUID {
+		t.Errorf("manifest GUIDs = %v %v", manifest.EventHeader.ProviderId, manifest.EventHeader.ActivityId)
+	}
+	if descriptor := manifest.EventHeader.EventDescriptor; descriptor.Id != 7 || descriptor.Version != 1 || descriptor.Task != 3 || descriptor.Keyword != 0x80 {
+		t.Errorf("manifest descriptor = %+v", descriptor)
+	}
+	if manifest.EventHeader.ProcessId != 200 || manifest.EventHeader.ThreadId != 100 || manifest.BufferContext.LoggerId != 0 {
+		t.Errorf("manifest execution = %+v", manifest.EventHeader)
+	}
+	if related, ok := manifest.ExtendedDataItem(winapi.EVENT_HEADER_EXT_TYPE_RELATED_ACTIVITYID); !ok || winguid.FromBytes(related) != testRelatedGUID {
+		t.Errorf("manifest extended data = %v", manifest.ExtendedData)
+	}
+	if !bytes.Equal(manifest.UserData, []byte{1, 2, 3}) {
+		t.Errorf("manifest user data = %v", manifest.UserData)
+	}
+
+	classic := records[2]
+	if classic.EventHeader.ProviderId != testClassGUID || !classic.IsClassic() || classic.EventHeader.EventDescriptor.Opcode != 2 ||
+		classic.EventHeader.EventDescriptor.Version != 3 || classic.EventHeader.ProcessId != 400 {
+		t.Errorf("classic header = %+v", classic.EventHeader)
+	}
+	if !bytes.Equal(classic.UserData, []byte{4, 5}) {
+		t.Errorf("classic user data = %v", classic.UserData)
+	}
+
+	message := records[3]
+	if message.EventHeader.ProviderId != testMessageGUID || message.EventHeader.EventDescriptor.Id != 12 ||
+		message.EventHeader.ThreadId != 500 || message.EventHeader.ProcessId != 600 ||
+		message.EventHeader.Flags&winapi.EVENT_HEADER_FLAG_TRACE_MESSAGE == 0 {
+		t.Errorf("message header = %+v", message.EventHeader)
+	}
+	if !bytes.Equal(message.UserData, []byte{6}) {
+		t.Errorf("message user data = %v", message.UserData)
+	}
+
+	kernel := records[4]
+	if kernel.EventHeader.ProviderId != winapi.KernelEventGroupMapping[3] || kernel.EventHeader.EventDescriptor.Opcode != 2 {
+		t.Errorf("kernel header = %+v", kernel.EventHeader)
+	}
+	if !bytes.Equal(kernel.UserData, []byte{7, 8, 9, 10}) {
+		t.Errorf("kernel user data = %v", kernel.UserData)
+	}
+}
+
+// TestRecordedFile reads a trace recorded by the NT kernel logger and a manifest provider, when available:
+// such traces can only be captured on Windows, with xperf or logman, see readme.md.
+func TestRecordedFile(t *testing.T) {
+	name := filepath.Join("testdata", "recorded.etl")
+	if _, err := os.Stat(name); err != nil {
+		t.Skipf("no recorded trace: %v", err)
+	}
+
+	file, err := Open(name)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer file.Close()
+
+	if file.Header().PointerSize != 4 && file.Header().PointerSize != 8 {
+		t.Errorf("pointer size %d", file.Header().PointerSize)
+	}
+
+	count := 0
+	for {
+		record, nextErr := file.Next()
+		if nextErr == io.EOF {
+			break
+		}
+		if errors.Is(nextErr, ErrCompressedBuffer) {
+			continue
+		}
+		if nextErr != nil {
+			t.Fatalf("record %d: %v", count, nextErr)
+		}
+		if record.EventHeader.TimeStamp < file.Header().BootTime {
+			t.Errorf("record %d: time stamp %d before boot", count, record.EventHeader.TimeStamp)
+		}
+		count++
+	}
+	if count == 0 {
+		t.Error("no records")
+	}
+}
+
+func TestNewReaderErrors(t *testing.T) {
+	trace := syntheticTrace()
+
+	oversized := append([]byte{}, trace...)
+	binary.LittleEndian.PutUint32(oversized, maxBufferSize+1)
+
+	var noHeader traceBuilder
+	noHeader.event(manifestEvent(0, nil))
+	noHeader.flush(ETW_BUFFER_FLAG_NORMAL)
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, ErrMissingFileHeader},
+		{"truncated buffer header", trace[:40], ErrInvalidBuffer},
+		{"truncated buffer", trace[:testBufferSize-1], ErrInvalidBuffer},
+		{"oversized buffer", oversized, ErrInvalidBuffer},
+		{"no logfile header", noHeader.Bytes(), ErrMissingFileHeader},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewReader(bytes.NewReader(test.data))
+			if !errors.Is(err, test.err) {
+				t.Errorf("err = %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+// readAll returns the records of data, and the errors other than io.EOF met on the way.
+func readAll(t *testing.T, data []byte) ([]*etw.Record, []error) {
+	t.Helper()
+
+	reader, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+
+	var records []*etw.Record
+	var errs []error
+	for i := 0; i < 100; i++ {
+		record, nextErr := reader.Next()
+		if nextErr == io.EOF {
+			return records, errs
+		}
+		if nextErr != nil {
+			errs = append(errs, nextErr)
+			continue
+		}
+		records = append(records, record)
+	}
+	t.Fatal("Next does not reach io.EOF")
+	return nil, nil
+}
+
+func TestReaderTruncatedFile(t *testing.T) {
+	trace := syntheticTrace()
+
+	records, errs := readAll(t, trace[:testBufferSize+bufferHeaderSize+10])
+	if len(records) != 2 || len(errs) != 1 || !errors.Is(errs[0], ErrInvalidBuffer) {
+		t.Errorf("truncated second buffer: %d records, errors %v", len(records), errs)
+	}
+
+	records, errs = readAll(t, trace[:testBufferSize+20])
+	if len(records) != 2 || len(errs) != 1 || !errors.Is(errs[0], ErrInvalidBuffer) {
+		t.Errorf("truncated second buffer header: %d records, errors %v", len(records), errs)
+	}
+
+	records, errs = readAll(t, trace[:testBufferSize])
+	if len(records) != 2 || len(errs) != 0 {
+		t.Errorf("first buffer only: %d records, errors %v", len(records), errs)
+	}
+}
+
+func TestReaderDamagedBuffer(t *testing.T) {
+	trace := syntheticTrace()
+
+	var builder traceBuilder
+	builder.event(classicEvent(testSyncTimestamp, nil))
+	builder.event(classicEvent(testSyncTimestamp, nil))
+	builder.flush(ETW_BUFFER_FLAG_NORMAL)
+	damaged := builder.Bytes()
+	binary.LittleEndian.PutUint16(damaged[bufferHeaderSize+fullTraceHeaderSize:], 2000) // second event overflows the buffer
+
+	data := append(append(append([]byte{}, trace[:testBufferSize]...), damaged...), trace[testBufferSize:]...)
+	records, errs := readAll(t, data)
+	if len(records) != 6 || len(errs) != 1 || !errors.Is(errs[0], ErrInvalidEvent) {
+		t.Errorf("%d records, errors %v, want 6 records and %v", len(records), errs, ErrInvalidEvent)
+	}
+}
+
+func TestReaderCompressedBuffer(t *testing.T) {
+	trace := syntheticTrace()
+
+	var builder traceBuilder
+	builder.event(classicEvent(testSyncTimestamp, nil))
+	builder.flush(ETW_BUFFER_FLAG_COMPRESSED)
+
+	data := append(append(append([]byte{}, trace[:testBufferSize]...), builder.Bytes()...), trace[testBufferSize:]...)
+	records, errs := readAll(t, data)
+	if len(records) != 5 || len(errs) != 1 || !errors.Is(errs[0], ErrCompressedBuffer) {
+		t.Errorf("%d records, errors %v, want 5 records and %v", len(records), errs, ErrCompressedBuffer)
+	}
+}
+
+func TestReadTraceHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		size int
+		err  error
+	}{
+		{"system", systemEvent(3, 1, 0, []byte{1}), systemTraceHeaderSize + 1, nil},
+		{"event header", manifestEvent(0, nil), eventHeaderSize + 24, nil},
+		{"classic", classicEvent(0, nil), fullTraceHeaderSize, nil},
+		{"message", messageEvent(0, nil), messageTraceHeaderSize + 32, nil},
+		{"short", []byte{1, 2, 3}, 0, ErrInvalidEvent},
+		{"unknown type", []byte{8, 0, 99, 0x80, 0, 0, 0, 0}, 0, ErrUnsupportedHeader},
+		{"size past data", []byte{0xff, 0, TRACE_HEADER_TYPE_FULL_HEADER64, 0x80, 0, 0, 0, 0}, 0, ErrInvalidEvent},
+		{"size below header", []byte{4, 0, TRACE_HEADER_TYPE_FULL_HEADER64, 0x80, 0, 0, 0, 0}, 0, ErrInvalidEvent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header, err := readTraceHeader(test.data)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("err = %v, want %v", err, test.err)
+			}
+			if err == nil && header.size != test.size {
+				t.Errorf("size = %d, want %d", header.size, test.size)
+			}
+		})
+	}
+}
+
+func TestNewRecordTruncatedHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"system", systemEvent(3, 1, 0, nil)[:systemTraceHeaderSize-1]},
+		{"classic", classicEvent(0, nil)[:fullTraceHeaderSize-1]},
+		{"event header", manifestEvent(0, nil)[:eventHeaderSize-1]},
+		{"extended data", manifestEvent(0, nil)[:eventHeaderSize+12]},
+		{"message", messageEvent(0, nil)[:messageTraceHeaderSize+20]},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := traceHeader{headerType: test.data[2], size: len(test.data)}
+			if _, err := newRecord(header, test.data, 8); !errors.Is(err, ErrInvalidEvent) {
+				t.Errorf("err = %v, want %v", err, ErrInvalidEvent)
+			}
+		})
+	}
+}
+
+func TestFiletime(t *testing.T) {
+	reader := Reader{
+		header:        &LogfileHeader{StartTime: testStartTime, ReservedFlags: ClockTypeQueryPerformanceCounter},
+		syncTimestamp: testSyncTimestamp,
+		frequency:     3000000,
+	}
+
+	tests := []struct {
+		timestamp int64
+		filetime  int64
+	}{
+		{testSyncTimestamp, testStartTime},
+		{testSyncTimestamp + 3000000, testStartTime + 10000000},
+		{testSyncTimestamp + 1, testStartTime + 3},
+		{testSyncTimestamp + 3000000*86400*30, testStartTime + 10000000*86400*30}, // no overflow over 30 days
+		{testSyncTimestamp - 3000000, testStartTime - 10000000},
+	}
+	for _, test := range tests {
+		if filetime := reader.Filetime(test.timestamp); filetime != test.filetime {
+			t.Errorf("Filetime(%d) = %d, want %d", test.timestamp, filetime, test.filetime)
+		}
+	}
+
+	reader.header.ReservedFlags = ClockTypeSystemTime
+	if filetime := reader.Filetime(42); filetime != 42 {
+		t.Errorf("system time Filetime(42) = %d, want 42", filetime)
+	}
+}
+
+func FuzzReaderNext(f *testing.F) {
+	f.Add(syntheticTrace())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reader, err := NewReader(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		// every call consumes an event or a buffer, so the file ends before len(data) calls
+		for i := 0; i <= len(data); i++ {
+			if _, err = reader.Next(); err == io.EOF {
+				return
+			}
+		}
+		t.Fatal("Next does not reach io.EOF")
+	})
+}
@@ -0,0 +1,386 @@
+package etl
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// Every event in a buffer starts with a marker, whose third byte is the header type
+// and whose fourth byte holds the TRACE_HEADER_FLAG bits.
+// https://www.geoffchappell.com/studies/windows/km/ntoskrnl/api/etw/tracesup/trace_header_type.htm
+const (
+	TRACE_HEADER_TYPE_SYSTEM32       = 1
+	TRACE_HEADER_TYPE_SYSTEM64       = 2
+	TRACE_HEADER_TYPE_COMPACT32      = 3
+	TRACE_HEADER_TYPE_COMPACT64      = 4
+	TRACE_HEADER_TYPE_FULL_HEADER32  = 10
+	TRACE_HEADER_TYPE_INSTANCE32     = 11
+	TRACE_HEADER_TYPE_TIMED          = 12
+	TRACE_HEADER_TYPE_ERROR          = 13
+	TRACE_HEADER_TYPE_WNODE_HEADER   = 14
+	TRACE_HEADER_TYPE_MESSAGE        = 15
+	TRACE_HEADER_TYPE_PERFINFO32     = 16
+	TRACE_HEADER_TYPE_PERFINFO64     = 17
+	TRACE_HEADER_TYPE_EVENT_HEADER32 = 18
+	TRACE_HEADER_TYPE_EVENT_HEADER64 = 19
+	TRACE_HEADER_TYPE_FULL_HEADER64  = 20
+	TRACE_HEADER_TYPE_INSTANCE64     = 21
+)
+
+const (
+	TRACE_HEADER_FLAG        = 0x80
+	TRACE_HEADER_EVENT_TRACE = 0x40
+	TRACE_MESSAGE            = 0x10
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/evntrace/nf-evntrace-tracemessage
+const (
+	TRACE_MESSAGE_SEQUENCE              = 0x0001
+	TRACE_MESSAGE_GUID                  = 0x0002
+	TRACE_MESSAGE_COMPONENTID           = 0x0004
+	TRACE_MESSAGE_TIMESTAMP             = 0x0008
+	TRACE_MESSAGE_PERFORMANCE_TIMESTAMP = 0x0010
+	TRACE_MESSAGE_SYSTEMINFO            = 0x0020
+)
+
+// Header sizes, user data follows.
+const (
+	systemTraceHeaderSize   = 32 // SYSTEM_TRACE_HEADER
+	compactTraceHeaderSize  = 24 // SYSTEM_TRACE_HEADER without processor times
+	perfInfoTraceHeaderSize = 16 // PERFINFO_TRACE_HEADER
+	fullTraceHeaderSize     = 48 // EVENT_TRACE_HEADER
+	instanceTraceHeaderSize = 56 // EVENT_INSTANCE_HEADER
+	eventHeaderSize         = 80 // EVENT_HEADER
+	messageTraceHeaderSize  = 8  // MESSAGE_TRACE_HEADER
+	extendedDataHeaderSize  = 8  // EVENT_HEADER_EXTENDED_DATA_ITEM, with the data in place of DataPtr
+	instanceInfoSize        = 24 // EVENT_EXTENDED_ITEM_INSTANCE
+
+	eventAlignment = 8
+	endOfBuffer    = 0xFFFFFFFF // padding marker
+)
+
+var (
+	ErrInvalidEvent      = fmt.Errorf("invalid event")
+	ErrUnsupportedHeader = fmt.Errorf("unsupported trace header type")
+)
+
+// traceHeader is the common prefix of every trace header.
+type traceHeader struct {
+	headerType uint8
+	flags      uint8
+	size       int
+}
+
+func readTraceHeader(data []byte) (traceHeader, error) {
+	if len(data) < 8 {
+		return traceHeader{}, fmt.Errorf("%w: %d bytes left in buffer", ErrInvalidEvent, len(data))
+	}
+
+	header := traceHeader{
+		headerType: data[2],
+		flags:      data[3],
+	}
+
+	switch header.headerType {
+	case TRACE_HEADER_TYPE_SYSTEM32, TRACE_HEADER_TYPE_SYSTEM64,
+		TRACE_HEADER_TYPE_COMPACT32, TRACE_HEADER_TYPE_COMPACT64,
+		TRACE_HEADER_TYPE_PERFINFO32, TRACE_HEADER_TYPE_PERFINFO64:
+		header.size = int(binary.LittleEndian.Uint16(data[4:])) // in the WMI_TRACE_PACKET, after the version
+	case TRACE_HEADER_TYPE_FULL_HEADER32, TRACE_HEADER_TYPE_FULL_HEADER64,
+		TRACE_HEADER_TYPE_INSTANCE32, TRACE_HEADER_TYPE_INSTANCE64,
+		TRACE_HEADER_TYPE_EVENT_HEADER32, TRACE_HEADER_TYPE_EVENT_HEADER64,
+		TRACE_HEADER_TYPE_MESSAGE:
+		header.size = int(binary.LittleEndian.Uint16(data))
+	default:
+		if header.flags&TRACE_MESSAGE == 0 {
+			return header, fmt.Errorf("%w: %d", ErrUnsupportedHeader, header.headerType)
+		}
+		header.headerType = TRACE_HEADER_TYPE_MESSAGE
+		header.size = int(binary.LittleEndian.Uint16(data))
+	}
+
+	if header.size < 8 || header.size > len(data) {
+		return header, fmt.Errorf("%w: size %d with %d bytes left in buffer", ErrInvalidEvent, header.size, len(data))
+	}
+	return header, nil
+}
+
+// newRecord converts the event at the start of data, header.size bytes long, to a Record laid out
+// like the EVENT_RECORD ProcessTrace delivers. Time stamps are left raw.
+func newRecord(header traceHeader, data []byte, pointerSize uint32) (*etw.Record, error) {
+	data = data[:header.size]
+
+	switch header.headerType {
+	case TRACE_HEADER_TYPE_SYSTEM32, TRACE_HEADER_TYPE_SYSTEM64:
+		return newSystemRecord(header, data, systemTraceHeaderSize)
+	case TRACE_HEADER_TYPE_COMPACT32, TRACE_HEADER_TYPE_COMPACT64:
+		return newSystemRecord(header, data, compactTraceHeaderSize)
+	case TRACE_HEADER_TYPE_PERFINFO32, TRACE_HEADER_TYPE_PERFINFO64:
+		return newSystemRecord(header, data, perfInfoTraceHeaderSize)
+	case TRACE_HEADER_TYPE_FULL_HEADER32, TRACE_HEADER_TYPE_FULL_HEADER64:
+		return newFullRecord(header, data)
+	case TRACE_HEADER_TYPE_INSTANCE32, TRACE_HEADER_TYPE_INSTANCE64:
+		return newInstanceRecord(header, data)
+	case TRACE_HEADER_TYPE_EVENT_HEADER32, TRACE_HEADER_TYPE_EVENT_HEADER64:
+		return newEventHeaderRecord(data)
+	case TRACE_HEADER_TYPE_MESSAGE:
+		return newMessageRecord(data, pointerSize)
+	}
+	return nil, fmt.Errorf("%w: %d", ErrUnsupportedHeader, header.headerType)
+}
+
+func headerPointerFlag(headerType uint8) uint16 {
+	switch headerType {
+	case TRACE_HEADER_TYPE_SYSTEM32, TRACE_HEADER_TYPE_COMPACT32, TRACE_HEADER_TYPE_PERFINFO32,
+		TRACE_HEADER_TYPE_FULL_HEADER32, TRACE_HEADER_TYPE_INSTANCE32, TRACE_HEADER_TYPE_EVENT_HEADER32:
+		return winapi.EVENT_HEADER_FLAG_32_BIT_HEADER
+	}
+	return winapi.EVENT_HEADER_FLAG_64_BIT_HEADER
+}
+
+func pointerSizeFlag(pointerSize uint32) uint16 {
+	if pointerSize == 4 {
+		return winapi.EVENT_HEADER_FLAG_32_BIT_HEADER
+	}
+	return winapi.EVENT_HEADER_FLAG_64_BIT_HEADER
+}
+
+func checkHeaderSize(data []byte, headerSize int) error {
+	if len(data) < headerSize {
+		return fmt.Errorf("%w: %d bytes for a %d bytes header", ErrInvalidEvent, len(data), headerSize)
+	}
+	return nil
+}
+
+// processorTime packs kernel and user times the way the ProcessorTime union of EVENT_HEADER does.
+func processorTime(data []byte) int64 {
+	return int64(binary.LittleEndian.Uint64(data))
+}
+
+// newSystemRecord decodes the system, compact and perfinfo headers of the kernel logger.
+// They identify events by group and type, in their hook ID, rather than by GUID.
+//
+// https://www.geoffchappell.com/studies/windows/km/ntoskrnl/inc/api/ntwmi/system_trace_header.htm
+func newSystemRecord(header traceHeader, data []byte, headerSize int) (*etw.Record, error) {
+	if err := checkHeaderSize(data, headerSize); err != nil {
+		return nil, err
+	}
+
+	version := binary.LittleEndian.Uint16(data)
+	eventType, group := data[6], data[7]
+
+	record := &etw.Record{UserData: data[headerSize:]}
+	eventHeader := &record.EventHeader
+	eventHeader.Size = uint16(header.size)
+	eventHeader.HeaderType = uint16(header.headerType)
+	eventHeader.Flags = winapi.EVENT_HEADER_FLAG_CLASSIC_HEADER | headerPointerFlag(header.headerType)
+	eventHeader.ProviderId = winapi.KernelEventGroupMapping[group]
+	eventHeader.EventDescriptor.Version = uint8(version)
+	eventHeader.EventDescriptor.Opcode = eventType
+
+	if headerSize == perfInfoTraceHeaderSize {
+		eventHeader.TimeStamp = int64(binary.LittleEndian.Uint64(data[8:]))
+		eventHeader.ThreadId, eventHeader.ProcessId = ^uint32(0), ^uint32(0)
+		eventHeader.Flags |= winapi.EVENT_HEADER_FLAG_NO_CPUTIME
+		return record, nil
+	}
+
+	eventHeader.ThreadId = binary.LittleEndian.Uint32(data[8:])
+	eventHeader.ProcessId = binary.LittleEndian.Uint32(data[12:])
+	eventHeader.TimeStamp = int64(binary.LittleEndian.Uint64(data[16:]))
+	if headerSize == systemTraceHeaderSize {
+		eventHeader.Time = processorTime(data[24:])
+	} else {
+		eventHeader.Flags |= winapi.EVENT_HEADER_FLAG_NO_CPUTIME
+	}
+
+	return record, nil
+}
+
+// classicRecord decodes the fields EVENT_TRACE_HEADER and EVENT_INSTANCE_HEADER share.
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/evntrace/ns-evntrace-event_trace_header
+func classicRecord(header traceHeader, data []byte, headerSize int) *etw.Record {
+	record := &etw.Record{UserData: data[headerSize:]}
+	eventHeader := &record.EventHeader
+	eventHeader.Size = uint16(header.size)
+	eventHeader.HeaderType = uint16(header.headerType)
+	eventHeader.Flags = winapi.EVENT_HEADER_FLAG_CLASSIC_HEADER | headerPointerFlag(header.headerType)
+	eventHeader.EventDescriptor.Opcode = data[4]
+	eventHeader.EventDescriptor.Level = data[5]
+	eventHeader.EventDescriptor.Version = uint8(binary.LittleEndian.Uint16(data[6:]))
+	eventHeader.ThreadId = binary.LittleEndian.Uint32(data[8:])
+	eventHeader.ProcessId = binary.LittleEndian.Uint32(data[12:])
+	eventHeader.TimeStamp = int64(binary.LittleEndian.Uint64(data[16:]))
+	eventHeader.Time = processorTime(data[40:])
+	return record
+}
+
+func newFullRecord(header traceHeader, data []byte) (*etw.Record, error) {
+	if err := checkHeaderSize(data, fullTraceHeaderSize); err != nil {
+		return nil, err
+	}
+
+	record := classicRecord(header, data, fullTraceHeaderSize)
+	record.EventHeader.ProviderId = winguid.FromBytes(data[24:40])
+	return record, nil
+}
+
+// Instance events name their class through a registration handle, meaningless outside the logging process:
+// their ProviderId is left empty. Instance IDs are given as an EVENT_HEADER_EXT_TYPE_INSTANCE_INFO item, like ProcessTrace does.
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/evntrace/ns-evntrace-event_instance_header
+func newInstanceRecord(header traceHeader, data []byte) (*etw.Record, error) {
+	if err := checkHeaderSize(data, instanceTraceHeaderSize); err != nil {
+		return nil, err
+	}
+
+	record := classicRecord(header, data, instanceTraceHeaderSize)
+	record.EventHeader.Flags |= winapi.EVENT_HEADER_FLAG_EXTENDED_INFO
+
+	instanceInfo := make([]byte, instanceInfoSize) // InstanceId, ParentInstanceId, ParentGuid
+	copy(instanceInfo, data[32:40])
+	record.ExtendedData = []etw.ExtendedDataItem{
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_INSTANCE_INFO, Data: instanceInfo},
+	}
+	return record, nil
+}
+
+// newEventHeaderRecord decodes the EVENT_HEADER of manifest and TraceLogging events. Extended data items
+// follow the header, each one prefixed by the first fields of EVENT_HEADER_EXTENDED_DATA_ITEM
+// and aligned on 8 bytes, Linkage telling whether another item follows.
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/evntcons/ns-evntcons-event_header
+func newEventHeaderRecord(data []byte) (*etw.Record, error) {
+	if err := checkHeaderSize(data, eventHeaderSize); err != nil {
+		return nil, err
+	}
+
+	record := &etw.Record{}
+	eventHeader := &record.EventHeader
+	eventHeader.Size = binary.LittleEndian.Uint16(data)
+	eventHeader.HeaderType = uint16(data[2])
+	eventHeader.Flags = binary.LittleEndian.Uint16(data[4:])
+	eventHeader.EventProperty = binary.LittleEndian.Uint16(data[6:])
+	eventHeader.ThreadId = binary.LittleEndian.Uint32(data[8:])
+	eventHeader.ProcessId = binary.LittleEndian.Uint32(data[12:])
+	eventHeader.TimeStamp = int64(binary.LittleEndian.Uint64(data[16:]))
+	eventHeader.ProviderId = winguid.FromBytes(data[24:40])
+	eventHeader.EventDescriptor = winapi.EventDescriptor{
+		Id:      binary.LittleEndian.Uint16(data[40:]),
+		Version: data[42],
+		Channel: data[43],
+		Level:   data[44],
+		Opcode:  data[45],
+		Task:    binary.LittleEndian.Uint16(data[46:]),
+		Keyword: binary.LittleEndian.Uint64(data[48:]),
+	}
+	eventHeader.Time = processorTime(data[56:])
+	eventHeader.ActivityId = winguid.FromBytes(data[64:80])
+
+	if eventHeader.Flags&(winapi.EVENT_HEADER_FLAG_32_BIT_HEADER|winapi.EVENT_HEADER_FLAG_64_BIT_HEADER) == 0 {
+		eventHeader.Flags |= headerPointerFlag(data[2])
+	}
+
+	offset := eventHeaderSize
+	if eventHeader.Flags&winapi.EVENT_HEADER_FLAG_EXTENDED_INFO == winapi.EVENT_HEADER_FLAG_EXTENDED_INFO {
+		for linkage := true; linkage; {
+			if len(data)-offset < extendedDataHeaderSize {
+				return nil, fmt.Errorf("%w: truncated extended data item", ErrInvalidEvent)
+			}
+			extType := binary.LittleEndian.Uint16(data[offset+2:])
+			linkage = binary.LittleEndian.Uint16(data[offset+4:])&1 == 1
+			dataSize := int(binary.LittleEndian.Uint16(data[offset+6:]))
+
+			offset += extendedDataHeaderSize
+			if len(data)-offset < dataSize {
+				return nil, fmt.Errorf("%w: extended data item of %d bytes", ErrInvalidEvent, dataSize)
+			}
+			record.ExtendedData = append(record.ExtendedData, etw.ExtendedDataItem{
+				ExtType: extType,
+				Data:    data[offset : offset+dataSize],
+			})
+			offset = align(offset+dataSize, eventAlignment)
+			if offset > len(data) {
+				offset = len(data)
+			}
+		}
+	}
+
+	record.UserData = data[offset:]
+	return record, nil
+}
+
+// newMessageRecord decodes the MESSAGE_TRACE_HEADER of WPP events. The optional fields selected by
+// the option flags follow it, in flag order. The message GUID and number become the provider GUID and event ID,
+// as ProcessTrace does, and the record gets EVENT_HEADER_FLAG_TRACE_MESSAGE.
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/evntrace/nf-evntrace-tracemessage
+func newMessageRecord(data []byte, pointerSize uint32) (*etw.Record, error) {
+	if err := checkHeaderSize(data, messageTraceHeaderSize); err != nil {
+		return nil, err
+	}
+
+	messageNumber := binary.LittleEndian.Uint16(data[4:])
+	options := binary.LittleEndian.Uint16(data[6:])
+
+	record := &etw.Record{}
+	eventHeader := &record.EventHeader
+	eventHeader.Size = binary.LittleEndian.Uint16(data)
+	eventHeader.HeaderType = TRACE_HEADER_TYPE_MESSAGE
+	eventHeader.Flags = winapi.EVENT_HEADER_FLAG_TRACE_MESSAGE | winapi.EVENT_HEADER_FLAG_NO_CPUTIME | pointerSizeFlag(pointerSize)
+	eventHeader.EventDescriptor.Id = messageNumber
+
+	offset := messageTraceHeaderSize
+	field := func(size int) ([]byte, error) {
+		if len(data)-offset < size {
+			return nil, fmt.Errorf("%w: truncated message header", ErrInvalidEvent)
+		}
+		b := data[offset : offset+size]
+		offset += size
+		return b, nil
+	}
+
+	if options&TRACE_MESSAGE_SEQUENCE != 0 {
+		if _, err := field(4); err != nil {
+			return nil, err
+		}
+	}
+	if options&TRACE_MESSAGE_GUID != 0 {
+		guid, err := field(16)
+		if err != nil {
+			return nil, err
+		}
+		eventHeader.ProviderId = winguid.FromBytes(guid)
+	} else if options&TRACE_MESSAGE_COMPONENTID != 0 {
+		if _, err := field(4); err != nil {
+			return nil, err
+		}
+	}
+	if options&TRACE_MESSAGE_TIMESTAMP != 0 {
+		timestamp, err := field(8)
+		if err != nil {
+			return nil, err
+		}
+		eventHeader.TimeStamp = int64(binary.LittleEndian.Uint64(timestamp))
+	}
+	if options&TRACE_MESSAGE_SYSTEMINFO != 0 {
+		systemInfo, err := field(8)
+		if err != nil {
+			return nil, err
+		}
+		eventHeader.ThreadId = binary.LittleEndian.Uint32(systemInfo)
+		eventHeader.ProcessId = binary.LittleEndian.Uint32(systemInfo[4:])
+	}
+
+	record.UserData = data[offset:]
+	return record, nil
+}
+
+func align(offset int, alignment int) int {
+	return (offset + alignment - 1) &^ (alignment - 1)
+}
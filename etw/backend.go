@@ -0,0 +1,95 @@
+package etw
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrSchemaNotFound    = fmt.Errorf("event schema not found")
+	ErrFormatUnsupported = fmt.Errorf("property formatting not supported")
+)
+
+// DecoderBackend gives EventRecordParser the schema of event records, and formats the properties
+// the pure-Go PropertyDecoder can not. It isolates the parsing logic from tdh.dll.
+type DecoderBackend interface {
+	// EventSchema returns the schema describing the user data of record, or ErrSchemaNotFound.
+	EventSchema(record *Record) (*Schema, error)
+
+	// FormatProperty renders the property at the start of data, with its value map applied,
+	// and returns the number of bytes it occupies. Backends without formatting return ErrFormatUnsupported.
+	FormatProperty(record *Record, schema *Schema, property *PropertyInfo, length uint32, data []byte) (string, int, error)
+}
+
+// SchemaBackend is a pure-Go DecoderBackend, resolving records against schemas registered in memory.
+// It builds on every platform, and is safe for concurrent use.
+type SchemaBackend struct {
+	mutex   sync.RWMutex
+	schemas map[SchemaKey]*Schema
+}
+
+func NewSchemaBackend(schemas ...*Schema) *SchemaBackend {
+	schemaBackend := &SchemaBackend{
+		schemas: make(map[SchemaKey]*Schema, len(schemas)),
+	}
+	for _, schema := range schemas {
+		schemaBackend.Register(schema)
+	}
+	return schemaBackend
+}
+
+// Register adds schema, replacing any schema with the same key.
+func (s *SchemaBackend) Register(schema *Schema) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.schemas[schema.Key()] = schema
+}
+
+func (s *SchemaBackend) EventSchema(record *Record) (*Schema, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if schema, ok := s.schemas[RecordSchemaKey(record)]; ok {
+		return schema, nil
+	}
+	return nil, ErrSchemaNotFound
+}
+
+func (s *SchemaBackend) FormatProperty(*Record, *Schema, *PropertyInfo, uint32, []byte) (string, int, error) {
+	return "", 0, ErrFormatUnsupported
+}
+
+// Schemas returns the registered schemas, in no particular order.
+func (s *SchemaBackend) Schemas() []*Schema {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	schemas := make([]*Schema, 0, len(s.schemas))
+	for _, schema := range s.schemas {
+		schemas = append(schemas, schema)
+	}
+	return schemas
+}
+
+// SchemaRecorder registers every schema its DecoderBackend resolves in Schemas.
+// Recording with the TdhBackend of a live session, then WriteSchemas, captures the schemas
+// that decode the same events on hosts without the providers.
+type SchemaRecorder struct {
+	DecoderBackend
+	Schemas *SchemaBackend
+}
+
+func NewSchemaRecorder(backend DecoderBackend) *SchemaRecorder {
+	return &SchemaRecorder{
+		DecoderBackend: backend,
+		Schemas:        NewSchemaBackend(),
+	}
+}
+
+func (s *SchemaRecorder) EventSchema(record *Record) (*Schema, error) {
+	schema, err := s.DecoderBackend.EventSchema(record)
+	if err == nil {
+		s.Schemas.Register(schema)
+	}
+	return schema, err
+}
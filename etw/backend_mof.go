@@ -0,0 +1,121 @@
+package etw
+
+import (
+	"sync"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// KernelProviderName is the provider TDH reports for the events of the kernel MOF classes.
+const KernelProviderName = "MSNT_SystemTrace"
+
+// KernelProviderGUID is the GUID of KernelProviderName, the event GUID of the events being their class GUID.
+var KernelProviderGUID = *winguid.MustParse("{9E814AAD-3204-11D2-9A82-006008A86939}")
+
+// MOFBackend decodes the events of the kernel MOF classes from the layouts of winapi.ManagedObjectFormatMapping,
+// without the WMI repository. Records of other classes go to its DecoderBackend, when not nil, and so do
+// the versions of a class without layout, unless the DecoderBackend fails: the latest earlier layout then applies.
+type MOFBackend struct {
+	DecoderBackend
+
+	mutex   sync.RWMutex
+	schemas map[SchemaKey]*Schema
+}
+
+func NewMOFBackend(backend DecoderBackend) *MOFBackend {
+	return &MOFBackend{
+		DecoderBackend: backend,
+		schemas:        make(map[SchemaKey]*Schema),
+	}
+}
+
+func (m *MOFBackend) EventSchema(record *Record) (*Schema, error) {
+	if !record.IsClassic() {
+		return m.fallback(record)
+	}
+
+	if schema, ok := m.cached(record); ok {
+		return schema, nil
+	}
+
+	class, ok := winapi.ManagedObjectFormatMapping[record.EventHeader.ProviderId.Data1]
+	if !ok || len(class.Layouts) == 0 {
+		return m.fallback(record)
+	}
+	descriptor := &record.EventHeader.EventDescriptor
+	if layout, exact := class.Layout(descriptor.Opcode, descriptor.Version, true); exact {
+		return m.register(newMOFSchema(record, class, layout)), nil
+	}
+
+	schema, err := m.fallback(record)
+	if err == nil {
+		return schema, nil
+	}
+	if layout, ok := class.Layout(descriptor.Opcode, descriptor.Version, false); ok {
+		return m.register(newMOFSchema(record, class, layout)), nil
+	}
+	return nil, err
+}
+
+// FormatProperty formats with the DecoderBackend the properties of the schemas it provided.
+func (m *MOFBackend) FormatProperty(record *Record, schema *Schema, property *PropertyInfo, length uint32, data []byte) (string, int, error) {
+	if m.DecoderBackend == nil || m.owns(schema) {
+		return "", 0, ErrFormatUnsupported
+	}
+	return m.DecoderBackend.FormatProperty(record, schema, property, length, data)
+}
+
+func (m *MOFBackend) fallback(record *Record) (*Schema, error) {
+	if m.DecoderBackend == nil {
+		return nil, ErrSchemaNotFound
+	}
+	return m.DecoderBackend.EventSchema(record)
+}
+
+func (m *MOFBackend) cached(record *Record) (*Schema, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	schema, ok := m.schemas[RecordSchemaKey(record)]
+	return schema, ok
+}
+
+func (m *MOFBackend) owns(schema *Schema) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.schemas[schema.Key()] == schema
+}
+
+func (m *MOFBackend) register(schema *Schema) *Schema {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.schemas[schema.Key()] = schema
+	return schema
+}
+
+// newMOFSchema describes record, the descriptor version is kept even when the layout is from an earlier version,
+// so that the schema key matches the record.
+func newMOFSchema(record *Record, class winapi.ManagedObjectFormatClass, layout *winapi.ManagedObjectFormatLayout) *Schema {
+	schema := &Schema{
+		ProviderGUID:    KernelProviderGUID,
+		EventGUID:       record.EventHeader.ProviderId,
+		EventDescriptor: record.EventHeader.EventDescriptor,
+		DecodingSource:  winapi.DecodingSourceWbem,
+		ProviderName:    KernelProviderName,
+		TaskName:        class.Name,
+		OpcodeName:      class.EventTypes[record.EventHeader.EventDescriptor.Opcode],
+	}
+
+	schema.Properties = make([]PropertyInfo, 0, len(layout.Fields))
+	for _, field := range layout.Fields {
+		schema.Properties = append(schema.Properties, PropertyInfo{
+			Name:    field.Name,
+			InType:  field.InType,
+			OutType: field.OutType,
+			Length:  field.Length,
+		})
+	}
+	schema.TopLevelPropertyCount = len(schema.Properties)
+
+	return schema
+}
@@ -0,0 +1,140 @@
+package etw
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+var processClassGUID = *winguid.MustParse("{3D6FA8D0-FE05-11D0-9DDA-00C04FD7BA7C}")
+
+// systemSID is S-1-5-18.
+var systemSID = []byte{1, 1, 0, 0, 0, 0, 0, 5, 18, 0, 0, 0}
+
+// processRecord is a Process event of type opcode, in version.
+func processRecord(opcode uint8, version uint8, userData []byte) *Record {
+	record := testRecord(userData)
+	record.EventHeader.Flags = winapi.EVENT_HEADER_FLAG_CLASSIC_HEADER | winapi.EVENT_HEADER_FLAG_64_BIT_HEADER
+	record.EventHeader.ProviderId = processClassGUID
+	record.EventHeader.EventDescriptor = winapi.EventDescriptor{Opcode: opcode, Version: version}
+	return record
+}
+
+func TestMOFBackendDecodes(t *testing.T) {
+	record := processRecord(1, 3, userData(
+		uint64(0xffffa0012345678), uint32(4321), uint32(1234), uint32(1), int32(0),
+		uint64(0x1aa000),
+		uint64(0xffffa00100000010), uint64(0), systemSID, // TOKEN_USER, then the SID
+		[]byte("cmd.exe\x00"),
+		utf16z("cmd.exe /c dir"),
+	))
+
+	backend := NewMOFBackend(nil)
+	event, err := DecodeRecord(record, backend)
+	if err != nil {
+		t.Fatalf("DecodeRecord: %v", err)
+	}
+
+	want := map[string]string{
+		"UniqueProcessKey":   "0xFFFFA0012345678",
+		"ProcessId":          "4321",
+		"ParentId":           "1234",
+		"SessionId":          "1",
+		"ExitStatus":         "0",
+		"DirectoryTableBase": "0x1AA000",
+		"UserSID":            "S-1-5-18",
+		"ImageFileName":      "cmd.exe",
+		"CommandLine":        "cmd.exe /c dir",
+	}
+	for name, value := range want {
+		if event.EventData[name] != value {
+			t.Errorf("%s = %q, want %q", name, event.EventData[name], value)
+		}
+	}
+	if len(event.EventData) != len(want) {
+		t.Errorf("EventData = %v", event.EventData)
+	}
+	if event.System.Provider.Guid != "{9E814AAD-3204-11D2-9A82-006008A86939}" || event.System.Provider.Name != KernelProviderName {
+		t.Errorf("Provider = %+v, want %s", event.System.Provider, KernelProviderName)
+	}
+	if event.System.EventGuid != "{3D6FA8D0-FE05-11D0-9DDA-00C04FD7BA7C}" || event.System.EventType != "Process/Start" {
+		t.Errorf("EventGuid = %s, EventType = %s, want the Process class", event.System.EventGuid, event.System.EventType)
+	}
+
+	schema, _ := backend.EventSchema(record)
+	if schema.ProviderName != KernelProviderName || schema.TaskName != "Process" || schema.OpcodeName != "Start" || schema.EventGUID != processClassGUID {
+		t.Errorf("schema names = %s %s %s %v", schema.ProviderName, schema.TaskName, schema.OpcodeName, schema.EventGUID)
+	}
+	if again, _ := backend.EventSchema(record); again != schema {
+		t.Error("schema is not reused")
+	}
+	if _, _, err = backend.FormatProperty(record, schema, &schema.Properties[0], 0, nil); !errors.Is(err, ErrFormatUnsupported) {
+		t.Errorf("FormatProperty of an owned schema, err = %v, want %v", err, ErrFormatUnsupported)
+	}
+}
+
+func TestMOFBackend32BitPointers(t *testing.T) {
+	record := processRecord(2, 2, userData(
+		uint32(0x81234567), uint32(4321), uint32(1234), uint32(1), int32(-1),
+		uint32(0),
+		[]byte("cmd.exe\x00"),
+		utf16z(""),
+	))
+	record.EventHeader.Flags = winapi.EVENT_HEADER_FLAG_CLASSIC_HEADER | winapi.EVENT_HEADER_FLAG_32_BIT_HEADER
+
+	event, err := DecodeRecord(record, NewMOFBackend(nil))
+	if err != nil {
+		t.Fatalf("DecodeRecord: %v", err)
+	}
+	if event.EventData["UniqueProcessKey"] != "0x81234567" || event.EventData["ExitStatus"] != "-1" || event.EventData["ImageFileName"] != "cmd.exe" {
+		t.Errorf("EventData = %v", event.EventData)
+	}
+}
+
+func TestMOFBackendVersions(t *testing.T) {
+	newer := processRecord(1, 9, nil)
+
+	schema, err := NewMOFBackend(nil).EventSchema(newer)
+	if err != nil {
+		t.Fatalf("EventSchema: %v", err)
+	}
+	if schema.EventDescriptor.Version != 9 || len(schema.Properties) != 12 || schema.Properties[11].Name != "ApplicationId" {
+		t.Errorf("version 9 falls back to the version 4 layout, got version %d with %d properties", schema.EventDescriptor.Version, len(schema.Properties))
+	}
+
+	published := &Schema{EventGUID: processClassGUID, EventDescriptor: newer.EventHeader.EventDescriptor, DecodingSource: winapi.DecodingSourceWbem}
+	schema, err = NewMOFBackend(NewSchemaBackend(published)).EventSchema(newer)
+	if err != nil || schema != published {
+		t.Errorf("a version without layout goes to the backend first, got %v, %v", schema, err)
+	}
+
+	exact := &Schema{EventGUID: processClassGUID, EventDescriptor: winapi.EventDescriptor{Opcode: 1, Version: 3}, DecodingSource: winapi.DecodingSourceWbem}
+	schema, _ = NewMOFBackend(NewSchemaBackend(exact)).EventSchema(processRecord(1, 3, nil))
+	if schema == exact {
+		t.Error("a version with layout does not go to the backend")
+	}
+
+	if _, err = NewMOFBackend(nil).EventSchema(processRecord(1, 1, nil)); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("version before the first layout, err = %v, want %v", err, ErrSchemaNotFound)
+	}
+}
+
+func TestMOFBackendOtherRecords(t *testing.T) {
+	if _, err := NewMOFBackend(nil).EventSchema(testRecord(nil)); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("manifest record, err = %v, want %v", err, ErrSchemaNotFound)
+	}
+
+	diskPerf := processRecord(1, 2, nil)
+	diskPerf.EventHeader.ProviderId = *winguid.MustParse("{BDD865D1-D7C1-11D0-A501-00A0C9062910}")
+	if _, err := NewMOFBackend(nil).EventSchema(diskPerf); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("class without layout, err = %v, want %v", err, ErrSchemaNotFound)
+	}
+
+	schema := testSchema(-1)
+	found, err := NewMOFBackend(NewSchemaBackend(schema)).EventSchema(testRecord(nil))
+	if err != nil || found != schema {
+		t.Errorf("manifest record goes to the backend, got %v, %v", found, err)
+	}
+}
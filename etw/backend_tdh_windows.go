@@ -0,0 +1,206 @@
+package etw
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+// TdhBackend resolves schemas with the providers registered on the local machine, through tdh.dll.
+// It only decodes records coming from a live session.
+type TdhBackend struct{}
+
+func (t *TdhBackend) EventSchema(record *Record) (*Schema, error) {
+	if record.native == nil {
+		return nil, ErrSchemaNotFound
+	}
+
+	traceEventInfo, err := record.native.GetEventInformation()
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := newSchemaFromTraceEventInfo(traceEventInfo)
+	if err != nil {
+		return nil, err
+	}
+	for i := range schema.Properties {
+		mapName := schema.Properties[i].MapName
+		if mapName == "" {
+			continue
+		}
+		if _, ok := schema.Maps[mapName]; ok {
+			continue
+		}
+
+		utf16MapName, _ := syscall.UTF16PtrFromString(mapName)
+		mapInfo, mapErr := record.native.GetMapInfo(utf16MapName, uint32(schema.DecodingSource))
+		if mapErr != nil || mapInfo.EntryCount == 0 {
+			continue // TdhFormatProperty renders the raw value as well
+		}
+		if schema.Maps == nil {
+			schema.Maps = make(map[string]*ValueMap)
+		}
+		valueMap, mapErr := newValueMapFromEventMapInfo(mapName, mapInfo)
+		if mapErr != nil {
+			continue
+		}
+		schema.Maps[mapName] = valueMap
+	}
+
+	return schema, nil
+}
+
+const minPropertyBufferSize = uint32(512) // in bytes
+
+func (t *TdhBackend) FormatProperty(record *Record, schema *Schema, property *PropertyInfo, length uint32, data []byte) (string, int, error) {
+	if record.native == nil || schema.native == nil {
+		return "", 0, ErrFormatUnsupported
+	}
+
+	var err error
+	var mapInfo *winapi.EventMapInfo
+	if valueMap, ok := schema.Maps[property.MapName]; ok && valueMap.native != nil {
+		mapInfo = valueMap.native
+	} else if property.MapName != "" {
+		mapName, _ := syscall.UTF16PtrFromString(property.MapName)
+		if mapInfo, err = record.native.GetMapInfo(mapName, uint32(schema.DecodingSource)); err != nil {
+			return "", 0, fmt.Errorf("failed to get map info: %s", err)
+		}
+	}
+
+	var userData uintptr
+	if len(data) > 0 {
+		userData = uintptr(unsafe.Pointer(&data[0]))
+	}
+
+	var buffer []uint16
+	bufferSize := minPropertyBufferSize
+	var userDataConsumed uint16
+
+	for {
+		buffer = make([]uint16, bufferSize)
+		err = winapi.TdhFormatProperty(
+			schema.native,
+			mapInfo,
+			record.PointerSize(),
+			uint16(property.InType),
+			uint16(property.OutType),
+			uint16(length),
+			uint16(len(data)),
+			userData,
+			&bufferSize,
+			&buffer[0],
+			&userDataConsumed,
+		)
+
+		if err == syscall.ERROR_INSUFFICIENT_BUFFER {
+			continue // retry with updated buffer size
+		}
+
+		if err == windows.ERROR_EVT_INVALID_EVENT_DATA {
+			if mapInfo == nil {
+				break
+			}
+			mapInfo = nil
+			continue
+		}
+
+		if err == nil {
+			break
+		}
+
+		return "", 0, fmt.Errorf("failed to format property: %s", err)
+	}
+
+	return syscall.UTF16ToString(buffer), int(userDataConsumed), err
+}
+
+func newSchemaFromTraceEventInfo(traceEventInfo *winapi.TraceEventInfo) (*Schema, error) {
+	schema := Schema{
+		ProviderGUID:    traceEventInfo.ProviderGUID,
+		EventGUID:       traceEventInfo.EventGUID,
+		EventDescriptor: traceEventInfo.EventDescriptor,
+		DecodingSource:  traceEventInfo.DecodingSource,
+		Flags:           traceEventInfo.Flags & (winapi.TEMPLATE_EVENT_DATA | winapi.TEMPLATE_USER_DATA),
+		Tags:            traceEventInfo.Tags(),
+
+		ProviderName:          traceEventInfo.ProviderName(),
+		LevelName:             traceEventInfo.LevelName(),
+		ChannelName:           traceEventInfo.ChannelName(),
+		KeywordsName:          traceEventInfo.KeywordName(),
+		TaskName:              traceEventInfo.TaskName(),
+		OpcodeName:            traceEventInfo.OpcodeName(),
+		EventMessage:          traceEventInfo.EventMessage(),
+		ProviderMessage:       traceEventInfo.ProviderMessage(),
+		ActivityIDName:        traceEventInfo.ActivityIDName(),
+		RelatedActivityIDName: traceEventInfo.RelatedActivityIDName(),
+
+		Properties:            make([]PropertyInfo, traceEventInfo.PropertyCount),
+		TopLevelPropertyCount: int(traceEventInfo.TopLevelPropertyCount),
+
+		native: traceEventInfo,
+	}
+
+	for i := range schema.Properties {
+		eventPropertyInfo, err := traceEventInfo.GetEventPropertyInfoAt(uint32(i))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err)
+		}
+		property := PropertyInfo{
+			Name:   traceEventInfo.PropertyName(uint32(i)),
+			Flags:  eventPropertyInfo.Flags,
+			Count:  eventPropertyInfo.Count(),
+			Length: eventPropertyInfo.Length(),
+		}
+		if property.Flags&winapi.PropertyHasTags == winapi.PropertyHasTags {
+			property.Tags = eventPropertyInfo.Tags()
+		}
+
+		if property.IsStruct() {
+			property.StructStartIndex = eventPropertyInfo.StructStartIndex()
+			property.NumOfStructMembers = eventPropertyInfo.NumOfStructMembers()
+		} else {
+			property.InType = winapi.TdhInType(eventPropertyInfo.InType())
+			property.OutType = winapi.TdhOutType(eventPropertyInfo.OutType())
+			property.MapName = traceEventInfo.PropertyMapName(uint32(i))
+		}
+
+		schema.Properties[i] = property
+	}
+
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func newValueMapFromEventMapInfo(name string, mapInfo *winapi.EventMapInfo) (*ValueMap, error) {
+	valueMap := ValueMap{
+		Name:    name,
+		Flag:    mapInfo.Flag,
+		Entries: make([]ValueMapEntry, mapInfo.EntryCount),
+		native:  mapInfo,
+	}
+
+	stringKeys := mapInfo.Flag&winapi.EVENTMAP_INFO_FLAG_MANIFEST_PATTERNMAP != 0 ||
+		mapInfo.ValueType() == winapi.EVENTMAP_ENTRY_VALUETYPE_STRING
+	for i := range valueMap.Entries {
+		entry, err := mapInfo.GetEventMapEntryAt(i)
+		if err != nil {
+			return nil, err
+		}
+		valueMap.Entries[i].Output = mapInfo.EntryOutput(entry)
+		if stringKeys {
+			valueMap.Entries[i].Input = mapInfo.EntryInput(entry)
+		} else {
+			valueMap.Entries[i].Value = entry.Value()
+		}
+	}
+
+	return &valueMap, nil
+}
@@ -0,0 +1,99 @@
+package etw
+
+import (
+	"fmt"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+var (
+	ErrPropertySkipped = fmt.Errorf("property not decoded after a preceding failure")
+)
+
+// DecodeError records a property that failed to decode.
+type DecodeError struct {
+	Path    string // such as Addresses[2].Port, empty for errors not tied to a property
+	InType  winapi.TdhInType
+	OutType winapi.TdhOutType
+	Err     error
+}
+
+func (d *DecodeError) Error() string {
+	if d.Path == "" {
+		return d.Err.Error()
+	}
+	return fmt.Sprintf("%s (in type %d, out type %d): %s", d.Path, d.InType, d.OutType, d.Err)
+}
+
+func (d *DecodeError) Unwrap() error {
+	return d.Err
+}
+
+// Is makes the errors of properties match ErrPropertyParsing.
+func (d *DecodeError) Is(target error) bool {
+	return target == ErrPropertyParsing && d.Path != ""
+}
+
+// DecodePolicy chooses what becomes of the events that fail to decode.
+type DecodePolicy uint8
+
+const (
+	// DecodePartial delivers the properties that decoded, the others being listed in Event.DecodeErrors.
+	DecodePartial = DecodePolicy(iota)
+	// DecodeDrop drops the event.
+	DecodeDrop
+	// DecodeRaw delivers the event header with its raw user data in Event.UserData, and no property.
+	DecodeRaw
+)
+
+// DecodeRecordWith decodes record as DecodeRecord does, then applies policy when it fails, events without schema
+// included. It returns a nil event when the policy drops it, and the decoding error in every case.
+func DecodeRecordWith(record *Record, backend DecoderBackend, policy DecodePolicy) (*Event, error) {
+	eventParser, err := newEventParser(record, backend)
+	if err != nil {
+		return applyDecodePolicy(record, nil, err, policy)
+	}
+
+	event, err := eventParser.buildEvent()
+	return applyDecodePolicy(record, event, err, policy)
+}
+
+// applyDecodePolicy applies policy to event, decoded from record with err, event being nil when record has no schema.
+func applyDecodePolicy(record *Record, event *Event, err error, policy DecodePolicy) (*Event, error) {
+	if err == nil {
+		return event, nil
+	}
+	if policy == DecodeDrop {
+		return nil, err
+	}
+	if event == nil {
+		return rawEvent(record, err), err
+	}
+
+	if policy == DecodeRaw {
+		event.Properties = nil
+		event.EventData = make(map[string]string)
+		event.EventDataArrays = make(map[string][]string)
+		event.EventDataStructs = make(map[string][]map[string]string)
+		event.Message = ""
+		event.UserData = copyBytes(record.UserData)
+	}
+	return event, err
+}
+
+// rawEvent fills the header of an event without schema, leaving out the names the schema would give.
+func rawEvent(record *Record, err error) *Event {
+	event := &Event{
+		EventData:        make(map[string]string),
+		EventDataArrays:  make(map[string][]string),
+		EventDataStructs: make(map[string][]map[string]string),
+		UserData:         copyBytes(record.UserData),
+		DecodeErrors:     []DecodeError{{Err: err}},
+	}
+
+	loadHeader(event, record)
+
+	event.ExtendedData, _ = DecodeExtendedData(record.ExtendedData)
+
+	return event
+}
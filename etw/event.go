@@ -2,48 +2,100 @@ package etw
 
 import (
 	"time"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
 )
 
 type Event struct {
-	EventData        map[string]string
-	EventDataArrays  map[string][]string
-	EventDataStructs map[string][]map[string]string
-
-	UserDataTemplate bool
-
-	System struct {
-		Channel     string
-		EventID     uint16
-		EventType   string
-		EventGuid   string
-		Correlation struct {
-			ActivityID string
-		}
-		Execution struct {
-			ProcessID uint32
-			ThreadID  uint32
-		}
-		Keywords struct {
-			Value uint64
-			Name  string
-		}
-		Level struct {
-			Value uint8
-			Name  string
-		}
-		Opcode struct {
-			Value uint8
-			Name  string
-		}
-		Task struct {
-			Value uint8
-			Name  string
-		}
-		Provider struct {
-			Guid string
-			Name string
+	// Properties holds the decoded user data, in schema order.
+	Properties []Property `json:"properties"`
+
+	// String renderings of Properties, kept for backward compatibility.
+	EventData        map[string]string              `json:"event_data"`
+	EventDataArrays  map[string][]string            `json:"event_data_arrays"`
+	EventDataStructs map[string][]map[string]string `json:"event_data_structs"`
+
+	UserDataTemplate bool `json:"user_data_template"`
+	// UserDataName and UserDataNamespace name the element wrapping the properties of UserData templates in XML,
+	// which TDH does not provide: see MarshalEventXML.
+	UserDataName      string `json:"user_data_name,omitempty"`
+	UserDataNamespace string `json:"user_data_namespace,omitempty"`
+
+	// Message is the event message of the schema rendered with Properties, empty when the schema has none.
+	Message string `json:"message,omitempty"`
+
+	// DecodeErrors lists the properties that failed to decode, and those left undecoded after them.
+	DecodeErrors []DecodeError `json:"decode_errors"`
+	// UserData holds the raw user data of events delivered by DecodeRaw.
+	UserData []byte `json:"user_data"`
+
+	System System `json:"system"`
+
+	// ExtendedData holds the decoded extended data items of the record, its fields are promoted.
+	ExtendedData `json:"extended_data"`
+}
+
+// System holds the event header and descriptor, with the names the schema gives them, as the System element
+// of the event log does.
+type System struct {
+	Channel      string `json:"channel,omitempty"` // name of the channel, empty when the schema has none
+	EventID      uint16 `json:"event_id"`
+	Version      uint8  `json:"version"`
+	ChannelValue uint8  `json:"channel_value"` // channel of the descriptor, named by Channel
+	EventType    string `json:"event_type,omitempty"`
+	EventGuid    string `json:"event_guid,omitempty"`
+	Correlation  struct {
+		ActivityID            string `json:"activity_id"`
+		ActivityIDName        string `json:"activity_id_name,omitempty"`
+		RelatedActivityID     string `json:"related_activity_id,omitempty"` // empty when the record has no related activity ID
+		RelatedActivityIDName string `json:"related_activity_id_name,omitempty"`
+	} `json:"correlation"`
+	Execution struct {
+		ProcessID   uint32 `json:"process_id"`
+		ThreadID    uint32 `json:"thread_id"`
+		ProcessorID uint16 `json:"processor_id"` // processor number, or index when Flags.HasProcessorIndex
+		// CPU time in ticks, KernelTime and UserTime are left to zero for private sessions,
+		// which report ProcessorTime instead, and none is set with Flags.HasNoCPUTime.
+		KernelTime    uint32 `json:"kernel_time"`
+		UserTime      uint32 `json:"user_time"`
+		ProcessorTime uint64 `json:"processor_time"`
+	} `json:"execution"`
+	Keywords struct {
+		Value uint64 `json:"value"`
+		Name  string `json:"name,omitempty"`
+	} `json:"keywords"`
+	Level struct {
+		Value uint8  `json:"value"`
+		Name  string `json:"name,omitempty"`
+	} `json:"level"`
+	Opcode struct {
+		Value uint8  `json:"value"`
+		Name  string `json:"name,omitempty"`
+	} `json:"opcode"`
+	Task struct {
+		Value uint16 `json:"value"`
+		Name  string `json:"name,omitempty"`
+	} `json:"task"`
+	Provider struct {
+		Guid string `json:"guid"`
+		Name string `json:"name,omitempty"`
+	} `json:"provider"`
+	Flags         winapi.HeaderFlags        `json:"flags"`
+	EventProperty winapi.EventPropertyFlags `json:"event_property"`
+	LoggerID      uint16                    `json:"logger_id"`
+	Timestamp     int64                     `json:"timestamp"` // raw header timestamp, a FILETIME unless the session clock says otherwise
+	TimestampUTC  time.Time                 `json:"timestamp_utc"`
+	// Computer and EventRecordID are not part of ETW records, and left to the consumer: see MarshalEventXML.
+	Computer      string `json:"computer,omitempty"`
+	EventRecordID uint64 `json:"event_record_id,omitempty"`
+}
+
+// Property returns the top level property called name.
+func (e *Event) Property(name string) (Value, bool) {
+	for _, property := range e.Properties {
+		if property.Name == name {
+			return property.Value, true
 		}
-		TimestampUTC time.Time
 	}
-	ExtendedData []string
+	return Value{}, false
 }
@@ -0,0 +1,407 @@
+package etw
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// EventJSONVersion is the version of the JSON representation of Event, written as schema_version.
+// Renaming or retyping a field, or changing the encoding of a value, bumps it; adding a field does not.
+//
+// Version 1:
+//
+//	{
+//	  "schema_version": 1,
+//	  "properties": [{"name": "Image", "value": {"kind": "string", "in_type": 1, "out_type": 1, "value": "..."}}],
+//	  "event_data": {}, "event_data_arrays": {}, "event_data_structs": {},
+//	  "user_data_template": false, "user_data_name": "", "user_data_namespace": "",
+//	  "message": "",
+//	  "user_data": null,
+//	  "system": {"channel", "event_id", "version", "channel_value", "event_type", "event_guid",
+//	             "correlation": {"activity_id", "activity_id_name", "related_activity_id", "related_activity_id_name"},
+//	             "execution": {"process_id", "thread_id", "processor_id", "kernel_time", "user_time", "processor_time"},
+//	             "keywords", "level", "opcode", "task": {"value", "name"}, "provider": {"guid", "name"},
+//	             "flags", "event_property", "logger_id", "timestamp", "timestamp_utc", "computer", "event_record_id"},
+//	  "decode_errors": [{"path", "in_type", "out_type", "error"}],
+//	  "extended_data": {"related_activity_id", "user_sid", "terminal_session_id", "instance_info", "stack_trace",
+//	                    "stack_key", "pebs_index", "pmc_counters", "psm_key", "process_start_key", "event_key",
+//	                    "qpc_delta", "container_id", "control_guid", "unknown"}
+//	}
+//
+// Fields are written in that order, and map keys sorted. GUIDs are written in their registry format, SIDs in their
+// string form, times in RFC 3339 with nanoseconds, binary data in base64, and fields holding their zero value
+// may be omitted. Values hold their kind, see ValueKind.String, and a value of matching JSON type: numbers for
+// integers and floats, except non-finite floats written as the strings NaN, +Inf and -Inf, arrays of properties
+// for structures and arrays of values for arrays.
+const EventJSONVersion = 1
+
+var (
+	ErrEventEncoding = fmt.Errorf("invalid event encoding")
+)
+
+// eventFields drops the Event methods, to avoid recursing in MarshalJSON.
+type eventFields Event
+
+// eventJSON replaces the fields that do not encode as is, error values and GUID structures.
+type eventJSON struct {
+	SchemaVersion int `json:"schema_version"`
+	*eventFields
+	DecodeErrors []decodeErrorJSON `json:"decode_errors"`
+	ExtendedData extendedDataJSON  `json:"extended_data"`
+}
+
+type decodeErrorJSON struct {
+	Path    string            `json:"path,omitempty"`
+	InType  winapi.TdhInType  `json:"in_type,omitempty"`
+	OutType winapi.TdhOutType `json:"out_type,omitempty"`
+	Error   string            `json:"error"`
+}
+
+type extendedDataJSON struct {
+	RelatedActivityID string             `json:"related_activity_id,omitempty"`
+	UserSID           string             `json:"user_sid,omitempty"`
+	TerminalSessionID *uint32            `json:"terminal_session_id,omitempty"`
+	InstanceInfo      *instanceInfoJSON  `json:"instance_info,omitempty"`
+	StackTrace        *StackTrace        `json:"stack_trace,omitempty"`
+	StackKey          *StackKey          `json:"stack_key,omitempty"`
+	PEBSIndex         uint64             `json:"pebs_index,omitempty"`
+	PMCCounters       []uint64           `json:"pmc_counters,omitempty"`
+	PSMKey            uint64             `json:"psm_key,omitempty"`
+	ProcessStartKey   uint64             `json:"process_start_key,omitempty"`
+	EventKey          uint64             `json:"event_key,omitempty"`
+	QPCDelta          uint64             `json:"qpc_delta,omitempty"`
+	ContainerID       string             `json:"container_id,omitempty"`
+	ControlGUID       string             `json:"control_guid,omitempty"`
+	Unknown           []ExtendedDataItem `json:"unknown,omitempty"`
+}
+
+type instanceInfoJSON struct {
+	InstanceID       uint32 `json:"instance_id"`
+	ParentInstanceID uint32 `json:"parent_instance_id"`
+	ParentGUID       string `json:"parent_guid,omitempty"`
+}
+
+// MarshalJSON writes the event in the representation of EventJSONVersion. Its value receiver applies it to
+// events held by value as well, within other structures or slices.
+func (e Event) MarshalJSON() ([]byte, error) {
+	encoded := eventJSON{
+		SchemaVersion: EventJSONVersion,
+		eventFields:   (*eventFields)(&e),
+		ExtendedData:  e.ExtendedData.toJSON(),
+	}
+	if e.DecodeErrors != nil {
+		encoded.DecodeErrors = make([]decodeErrorJSON, len(e.DecodeErrors))
+		for i, decodeErr := range e.DecodeErrors {
+			encoded.DecodeErrors[i] = decodeErrorJSON{Path: decodeErr.Path, InType: decodeErr.InType, OutType: decodeErr.OutType}
+			if decodeErr.Err != nil {
+				encoded.DecodeErrors[i].Error = decodeErr.Err.Error()
+			}
+		}
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON reads an event written by MarshalJSON, of version EventJSONVersion. The errors of DecodeErrors
+// come back as their message, wrapping the error of this package they started with, if any.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var version struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return err
+	}
+	if version.SchemaVersion != EventJSONVersion {
+		return fmt.Errorf("%w: unsupported schema version %d", ErrEventEncoding, version.SchemaVersion)
+	}
+
+	*e = Event{}
+	decoded := eventJSON{eventFields: (*eventFields)(e)}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	if decoded.DecodeErrors != nil {
+		e.DecodeErrors = make([]DecodeError, len(decoded.DecodeErrors))
+		for i, decodeErr := range decoded.DecodeErrors {
+			e.DecodeErrors[i] = DecodeError{Path: decodeErr.Path, InType: decodeErr.InType, OutType: decodeErr.OutType, Err: decodedError(decodeErr.Error)}
+		}
+	}
+
+	var err error
+	e.ExtendedData, err = decoded.ExtendedData.fromJSON()
+	return err
+}
+
+// decodeErrorSentinels are the errors decodedError recognizes at the start of messages.
+var decodeErrorSentinels = []error{
+	ErrPropertySkipped, ErrTruncatedProperty, ErrUnsupportedInType, ErrTooManyValues, ErrInvalidSchema,
+	ErrInvalidExtendedData, ErrInvalidTraceLogging, ErrSchemaNotFound, ErrFormatUnsupported,
+}
+
+// decodedError restores an error from its message, wrapping the error of this package it starts with.
+func decodedError(message string) error {
+	if message == "" {
+		return nil
+	}
+	for _, sentinel := range decodeErrorSentinels {
+		if strings.HasPrefix(message, sentinel.Error()) {
+			return fmt.Errorf("%w%s", sentinel, message[len(sentinel.Error()):])
+		}
+	}
+	return fmt.Errorf("%s", message)
+}
+
+func (x *ExtendedData) toJSON() extendedDataJSON {
+	encoded := extendedDataJSON{
+		RelatedActivityID: guidJSON(x.RelatedActivityID),
+		TerminalSessionID: x.TerminalSessionID,
+		StackTrace:        x.StackTrace,
+		StackKey:          x.StackKey,
+		PEBSIndex:         x.PEBSIndex,
+		PMCCounters:       x.PMCCounters,
+		PSMKey:            x.PSMKey,
+		ProcessStartKey:   x.ProcessStartKey,
+		EventKey:          x.EventKey,
+		QPCDelta:          x.QPCDelta,
+		ContainerID:       guidJSON(x.ContainerID),
+		ControlGUID:       guidJSON(x.ControlGUID),
+		Unknown:           x.Unknown,
+	}
+	if x.UserSID != nil {
+		encoded.UserSID = x.UserSID.String()
+	}
+	if x.InstanceInfo != nil {
+		encoded.InstanceInfo = &instanceInfoJSON{
+			InstanceID:       x.InstanceInfo.InstanceID,
+			ParentInstanceID: x.InstanceInfo.ParentInstanceID,
+			ParentGUID:       guidJSON(x.InstanceInfo.ParentGUID),
+		}
+	}
+	return encoded
+}
+
+func (x *extendedDataJSON) fromJSON() (ExtendedData, error) {
+	extendedData := ExtendedData{
+		TerminalSessionID: x.TerminalSessionID,
+		StackTrace:        x.StackTrace,
+		StackKey:          x.StackKey,
+		PEBSIndex:         x.PEBSIndex,
+		PMCCounters:       x.PMCCounters,
+		PSMKey:            x.PSMKey,
+		ProcessStartKey:   x.ProcessStartKey,
+		EventKey:          x.EventKey,
+		QPCDelta:          x.QPCDelta,
+		Unknown:           x.Unknown,
+	}
+
+	guids := []struct {
+		text   string
+		target *winguid.GUID
+	}{
+		{x.RelatedActivityID, &extendedData.RelatedActivityID},
+		{x.ContainerID, &extendedData.ContainerID},
+		{x.ControlGUID, &extendedData.ControlGUID},
+	}
+	if x.InstanceInfo != nil {
+		extendedData.InstanceInfo = &InstanceInfo{
+			InstanceID:       x.InstanceInfo.InstanceID,
+			ParentInstanceID: x.InstanceInfo.ParentInstanceID,
+		}
+		guids = append(guids, struct {
+			text   string
+			target *winguid.GUID
+		}{x.InstanceInfo.ParentGUID, &extendedData.InstanceInfo.ParentGUID})
+	}
+	for _, guid := range guids {
+		if guid.text == "" {
+			continue
+		}
+		parsed, err := winguid.Parse(guid.text)
+		if err != nil {
+			return extendedData, fmt.Errorf("%w: %s", ErrEventEncoding, err)
+		}
+		*guid.target = *parsed
+	}
+
+	if x.UserSID != "" {
+		sid, err := ParseSID(x.UserSID)
+		if err != nil {
+			return extendedData, fmt.Errorf("%w: %s", ErrEventEncoding, err)
+		}
+		extendedData.UserSID = &sid
+	}
+
+	return extendedData, nil
+}
+
+// guidJSON writes guid in its registry format, and the zero GUID as an empty string.
+func guidJSON(guid winguid.GUID) string {
+	if guid == (winguid.GUID{}) {
+		return ""
+	}
+	return winguid.ToString(&guid)
+}
+
+// valueJSON holds the kind of the value with its in and out types, and the name its value map gives it.
+type valueJSON struct {
+	Kind    string            `json:"kind"`
+	InType  winapi.TdhInType  `json:"in_type,omitempty"`
+	OutType winapi.TdhOutType `json:"out_type,omitempty"`
+	Name    string            `json:"name,omitempty"`
+	Value   json.RawMessage   `json:"value,omitempty"`
+}
+
+func (v Value) MarshalJSON() ([]byte, error) {
+	encoded := valueJSON{Kind: v.Kind.String(), InType: v.InType, OutType: v.OutType, Name: v.name}
+
+	var raw interface{}
+	switch v.Kind {
+	case KindNull:
+	case KindInt8, KindInt16, KindInt32, KindInt64:
+		encoded.Value = json.RawMessage(strconv.FormatInt(v.Int(), 10))
+	case KindUint8, KindUint16, KindUint32, KindUint64:
+		encoded.Value = json.RawMessage(strconv.FormatUint(v.Uint(), 10))
+	case KindFloat32, KindFloat64:
+		bitSize := 64
+		if v.Kind == KindFloat32 {
+			bitSize = 32
+		}
+		float := v.Float()
+		if math.IsNaN(float) || math.IsInf(float, 0) {
+			raw = strconv.FormatFloat(float, 'g', -1, bitSize)
+		} else {
+			encoded.Value = json.RawMessage(strconv.FormatFloat(float, 'g', -1, bitSize))
+		}
+	case KindBool:
+		raw = v.Bool()
+	case KindTime:
+		raw = v.Time().Format(time.RFC3339Nano)
+	case KindString, KindGUID, KindSID:
+		raw = v.Raw().String()
+	default: // []byte, netip and Property and Value slices encode as they are
+		raw = v.object
+	}
+
+	if raw != nil {
+		var err error
+		if encoded.Value, err = json.Marshal(raw); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(encoded)
+}
+
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var decoded valueJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	kind := ValueKind(0)
+	for kind < ValueKind(len(valueKindNames)) && valueKindNames[kind] != decoded.Kind {
+		kind++
+	}
+	if int(kind) == len(valueKindNames) {
+		return fmt.Errorf("%w: unknown value kind %q", ErrEventEncoding, decoded.Kind)
+	}
+
+	value, err := decodeValueJSON(kind, decoded.Value)
+	if err != nil {
+		return fmt.Errorf("%w: %s value: %s", ErrEventEncoding, kind, err)
+	}
+
+	*v = NewValue(value, decoded.InType, decoded.OutType).WithName(decoded.Name)
+	return nil
+}
+
+// decodeValueJSON returns the Go value of kind held by raw, with the type NewValue expects.
+func decodeValueJSON(kind ValueKind, raw json.RawMessage) (interface{}, error) {
+	text := string(raw)
+
+	switch kind {
+	case KindNull:
+		return nil, nil
+	case KindInt8:
+		integer, err := strconv.ParseInt(text, 10, 8)
+		return int8(integer), err
+	case KindInt16:
+		integer, err := strconv.ParseInt(text, 10, 16)
+		return int16(integer), err
+	case KindInt32:
+		integer, err := strconv.ParseInt(text, 10, 32)
+		return int32(integer), err
+	case KindInt64:
+		return strconv.ParseInt(text, 10, 64)
+	case KindUint8:
+		integer, err := strconv.ParseUint(text, 10, 8)
+		return uint8(integer), err
+	case KindUint16:
+		integer, err := strconv.ParseUint(text, 10, 16)
+		return uint16(integer), err
+	case KindUint32:
+		integer, err := strconv.ParseUint(text, 10, 32)
+		return uint32(integer), err
+	case KindUint64:
+		return strconv.ParseUint(text, 10, 64)
+	case KindFloat32, KindFloat64:
+		var special string
+		if json.Unmarshal(raw, &special) == nil {
+			text = special
+		}
+		if kind == KindFloat32 {
+			float, err := strconv.ParseFloat(text, 32)
+			return float32(float), err
+		}
+		return strconv.ParseFloat(text, 64)
+	case KindBool:
+		var b bool
+		err := json.Unmarshal(raw, &b)
+		return b, err
+	case KindStruct:
+		var fields []Property
+		err := json.Unmarshal(raw, &fields)
+		return fields, err
+	case KindArray:
+		var elements []Value
+		err := json.Unmarshal(raw, &elements)
+		return elements, err
+	case KindBinary:
+		var b []byte
+		err := json.Unmarshal(raw, &b)
+		return b, err
+	case KindAddr:
+		var addr netip.Addr
+		err := json.Unmarshal(raw, &addr)
+		return addr, err
+	case KindAddrPort:
+		var addrPort netip.AddrPort
+		err := json.Unmarshal(raw, &addrPort)
+		return addrPort, err
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	switch kind {
+	case KindGUID:
+		guid, err := winguid.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		return *guid, nil
+	case KindTime:
+		return time.Parse(time.RFC3339Nano, s)
+	case KindSID:
+		return ParseSID(s)
+	}
+	return s, nil
+}
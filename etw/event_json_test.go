@@ -0,0 +1,217 @@
+package etw
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// jsonTestEvent returns an event holding a property of every value kind, with decode errors and extended data.
+func jsonTestEvent() Event {
+	guid := *winguid.MustParse("{5770385F-C22A-43E0-BF4C-06F5698FFBD9}")
+	sid := SID{Revision: 1, IdentifierAuthority: [6]byte{0, 0, 0, 0, 0, 5}, SubAuthorities: []uint32{21, 1000}}
+	terminalSessionID := uint32(1)
+
+	event := Event{
+		Properties: []Property{
+			{Name: "Null", Value: NewValue(nil, 0, 0)},
+			{Name: "String", Value: NewValue("C:\\Windows\\System32\\cmd.exe", winapi.TdhInTypeUnicodestring, winapi.TdhOutTypeString)},
+			{Name: "Int8", Value: NewValue(int8(-8), winapi.TdhInTypeInt8, 0)},
+			{Name: "Int16", Value: NewValue(int16(-16), winapi.TdhInTypeInt16, 0)},
+			{Name: "Int32", Value: NewValue(int32(-32), winapi.TdhInTypeInt32, 0)},
+			{Name: "Int64", Value: NewValue(int64(math.MinInt64), winapi.TdhInTypeInt64, 0)},
+			{Name: "Uint8", Value: NewValue(uint8(8), winapi.TdhInTypeUint8, 0)},
+			{Name: "Uint16", Value: NewValue(uint16(16), winapi.TdhInTypeUint16, 0)},
+			{Name: "Uint32", Value: NewValue(uint32(0x1F), winapi.TdhInTypeUint32, winapi.TdhOutTypeHexint32)},
+			{Name: "Uint64", Value: NewValue(uint64(math.MaxUint64), winapi.TdhInTypeUint64, 0)},
+			{Name: "Float32", Value: NewValue(float32(1.5), winapi.TdhInTypeFloat, 0)},
+			{Name: "Float64", Value: NewValue(math.Inf(-1), winapi.TdhInTypeDouble, 0)},
+			{Name: "Bool", Value: NewValue(true, winapi.TdhInTypeBoolean, 0)},
+			{Name: "GUID", Value: NewValue(guid, winapi.TdhInTypeGUID, 0)},
+			{Name: "Time", Value: NewValue(time.Date(2024, 5, 6, 7, 8, 9, 123456700, time.UTC), winapi.TdhInTypeFiletime, 0)},
+			{Name: "SID", Value: NewValue(sid, winapi.TdhInTypeSid, 0)},
+			{Name: "Binary", Value: NewValue([]byte{0xDE, 0xAD, 0xBE, 0xEF}, winapi.TdhInTypeBinary, 0)},
+			{Name: "Addr", Value: NewValue(netip.MustParseAddr("fe80::1"), winapi.TdhInTypeBinary, winapi.TdhOutTypeIpv6)},
+			{Name: "AddrPort", Value: NewValue(netip.MustParseAddrPort("10.0.0.1:443"), winapi.TdhInTypeBinary, winapi.TdhOutTypeSocketaddress)},
+			{Name: "Protocol", Value: NewValue(uint32(6), winapi.TdhInTypeUint32, 0).WithName("TCP")},
+			{Name: "Endpoint", Value: NewValue([]Property{
+				{Name: "Port", Value: NewValue(uint16(443), winapi.TdhInTypeUint16, winapi.TdhOutTypePort)},
+				{Name: "Host", Value: NewValue("example.com", winapi.TdhInTypeAnsistring, 0)},
+			}, 0, 0)},
+			{Name: "Flags", Value: NewValue([]Value{
+				NewValue(uint32(1), winapi.TdhInTypeUint32, 0).WithName("Read"),
+				NewValue(uint32(2), winapi.TdhInTypeUint32, 0).WithName("Write"),
+			}, 0, 0)},
+		},
+		EventData:        map[string]string{"String": "C:\\Windows\\System32\\cmd.exe"},
+		EventDataArrays:  map[string][]string{"Flags": {"Read", "Write"}},
+		EventDataStructs: map[string][]map[string]string{"Endpoint": {{"Port": "443", "Host": "example.com"}}},
+		Message:          "Process Create",
+		DecodeErrors: []DecodeError{
+			{Path: "Hashes", InType: winapi.TdhInTypeUnicodestring, Err: ErrTruncatedProperty},
+			{Path: "User", Err: errors.New("custom failure")},
+		},
+		ExtendedData: ExtendedData{
+			RelatedActivityID: *winguid.MustParse("{A1B2C3D4-0000-1111-2222-333344445555}"),
+			UserSID:           &sid,
+			TerminalSessionID: &terminalSessionID,
+			StackTrace:        &StackTrace{MatchID: 7, Addresses: []uint64{0x7FF800001000, 0x7FF800002000}},
+			ProcessStartKey:   42,
+		},
+	}
+
+	event.System.EventID = 1
+	event.System.Version = 5
+	event.System.Channel = "Microsoft-Windows-Sysmon/Operational"
+	event.System.ChannelValue = 16
+	event.System.Correlation.ActivityID = "{00000000-0000-0000-0000-000000000000}"
+	event.System.Execution.ProcessID = 1234
+	event.System.Execution.ThreadID = 5678
+	event.System.Keywords.Value = 0x8000000000000000
+	event.System.Level.Value = 4
+	event.System.Level.Name = "Information"
+	event.System.Task.Value = 1
+	event.System.Provider.Guid = winguid.ToString(&guid)
+	event.System.Provider.Name = "Microsoft-Windows-Sysmon"
+	event.System.Flags = winapi.EVENT_HEADER_FLAG_64_BIT_HEADER
+	event.System.Timestamp = 133000000000000000
+	event.System.TimestampUTC = time.Date(2022, 6, 18, 4, 26, 40, 0, time.UTC)
+	return event
+}
+
+func TestEventJSONRoundTrip(t *testing.T) {
+	event := jsonTestEvent()
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded Event
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if len(decoded.DecodeErrors) != len(event.DecodeErrors) {
+		t.Fatalf("decode errors = %v, want %v", decoded.DecodeErrors, event.DecodeErrors)
+	}
+	if !errors.Is(decoded.DecodeErrors[0].Err, ErrTruncatedProperty) {
+		t.Errorf("decode error = %v, want to wrap %v", decoded.DecodeErrors[0].Err, ErrTruncatedProperty)
+	}
+	for i := range event.DecodeErrors {
+		if got, want := decoded.DecodeErrors[i].Error(), event.DecodeErrors[i].Error(); got != want {
+			t.Errorf("decode error %d = %q, want %q", i, got, want)
+		}
+	}
+	decoded.DecodeErrors, event.DecodeErrors = nil, nil
+
+	for i, property := range event.Properties {
+		if i >= len(decoded.Properties) {
+			t.Errorf("property %s missing", property.Name)
+			continue
+		}
+		if !reflect.DeepEqual(decoded.Properties[i], property) {
+			t.Errorf("property %s = %#v, want %#v", property.Name, decoded.Properties[i], property)
+		}
+	}
+	if !reflect.DeepEqual(decoded, event) {
+		t.Errorf("round trip = %+v, want %+v", decoded, event)
+	}
+}
+
+func TestEventJSONValueReceiver(t *testing.T) {
+	event := jsonTestEvent()
+
+	byPointer, err := json.Marshal(&event)
+	if err != nil {
+		t.Fatalf("Marshal pointer: %v", err)
+	}
+	byValue, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Marshal value: %v", err)
+	}
+	if string(byValue) != string(byPointer) {
+		t.Errorf("marshaled by value = %s, want %s", byValue, byPointer)
+	}
+
+	inSlice, err := json.Marshal([]Event{event})
+	if err != nil {
+		t.Fatalf("Marshal slice: %v", err)
+	}
+	if string(inSlice) != "["+string(byPointer)+"]" {
+		t.Errorf("marshaled in a slice = %s, want [%s]", inSlice, byPointer)
+	}
+	if !strings.HasPrefix(string(byValue), `{"schema_version":1,`) {
+		t.Errorf("marshaled by value = %s, want the schema version first", byValue)
+	}
+}
+
+func TestValueJSON(t *testing.T) {
+	tests := []struct {
+		value Value
+		want  string
+	}{
+		{NewValue(nil, 0, 0), `{"kind":"null"}`},
+		{NewValue(*winguid.MustParse("{5770385F-C22A-43E0-BF4C-06F5698FFBD9}"), winapi.TdhInTypeGUID, 0),
+			`{"kind":"guid","in_type":15,"value":"{5770385F-C22A-43E0-BF4C-06F5698FFBD9}"}`},
+		{NewValue(time.Date(2024, 5, 6, 7, 8, 9, 100, time.UTC), winapi.TdhInTypeFiletime, 0),
+			`{"kind":"time","in_type":17,"value":"2024-05-06T07:08:09.0000001Z"}`},
+		{NewValue(netip.MustParseAddr("192.168.1.1"), winapi.TdhInTypeUint32, winapi.TdhOutTypeIpv4),
+			`{"kind":"addr","in_type":8,"out_type":23,"value":"192.168.1.1"}`},
+		{NewValue([]byte{1, 2, 3}, winapi.TdhInTypeBinary, 0), `{"kind":"binary","in_type":14,"value":"AQID"}`},
+		{NewValue([]Property{{Name: "a", Value: NewValue(uint8(1), 0, 0)}}, 0, 0),
+			`{"kind":"struct","value":[{"name":"a","value":{"kind":"uint8","value":1}}]}`},
+		{NewValue([]Value{NewValue("x", 0, 0)}, 0, 0), `{"kind":"array","value":[{"kind":"string","value":"x"}]}`},
+		{NewValue(uint32(6), winapi.TdhInTypeUint32, 0).WithName("TCP"), `{"kind":"uint32","in_type":8,"name":"TCP","value":6}`},
+		{NewValue(float32(math.NaN()), winapi.TdhInTypeFloat, 0), `{"kind":"float32","in_type":11,"value":"NaN"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value.Kind.String(), func(t *testing.T) {
+			data, err := json.Marshal(test.value)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(data) != test.want {
+				t.Errorf("Marshal = %s, want %s", data, test.want)
+			}
+
+			var decoded Value
+			if err = json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if decoded.Kind == KindFloat32 {
+				if !math.IsNaN(decoded.Float()) {
+					t.Errorf("Unmarshal = %v, want NaN", decoded.Float())
+				}
+				return
+			}
+			if !reflect.DeepEqual(decoded, test.value) {
+				t.Errorf("Unmarshal = %#v, want %#v", decoded, test.value)
+			}
+		})
+	}
+}
+
+func TestEventJSONErrors(t *testing.T) {
+	tests := []string{
+		`{"schema_version":2}`,
+		`{"schema_version":1,"properties":[{"name":"a","value":{"kind":"unknown"}}]}`,
+		`{"schema_version":1,"properties":[{"name":"a","value":{"kind":"guid","value":"not a guid"}}]}`,
+		`{"schema_version":1,"extended_data":{"user_sid":"X-1"}}`,
+	}
+
+	for _, test := range tests {
+		var event Event
+		if err := json.Unmarshal([]byte(test), &event); !errors.Is(err, ErrEventEncoding) {
+			t.Errorf("Unmarshal(%s) err = %v, want %v", test, err, ErrEventEncoding)
+		}
+	}
+}
@@ -0,0 +1,658 @@
+package etw
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/etw/retrieving-event-data-using-tdh
+
+type EventRecordParser struct {
+	Record *Record
+	Schema *Schema
+
+	// Properties holds the decoded top level properties, in schema order. Structures hold their members,
+	// arrays their elements, down to the deepest nesting. Properties that failed hold a null value.
+	Properties []Property
+
+	backend  DecoderBackend
+	values   []Value              // last value decoded for each schema property, resolves length and count references
+	offset   int                  // position in Record.UserData
+	depth    int                  // of the structure being decoded
+	decoded  int                  // values and structures decoded, see maxEmptyValues
+	failures map[int]*DecodeError // of the top level properties skipped after failing
+	err      error                // that stopped the decoding
+}
+
+type PropertyParser struct {
+	eventRecordParser *EventRecordParser
+	propertyInfo      *PropertyInfo
+
+	name string
+
+	data   []byte // user data from the start of the property
+	value  Value
+	length uint32
+	size   int
+}
+
+// maxStructDepth bounds the nesting of structures, which a malformed schema could make cyclic.
+const maxStructDepth = 32
+
+// maxEmptyValues bounds the values decoded from no user data, such as empty strings without terminator:
+// nested arrays of them could otherwise decode billions of values out of a few bytes.
+const maxEmptyValues = 1 << 16
+
+var (
+	ErrPropertyParsing = fmt.Errorf("error parsing property")
+	ErrTooManyValues   = fmt.Errorf("too many values for the user data size")
+)
+
+func newEventParser(record *Record, backend DecoderBackend) (*EventRecordParser, error) {
+	eventRecordParser := EventRecordParser{
+		Record:  record,
+		backend: backend,
+	}
+
+	var err error
+	eventRecordParser.Schema, err = backend.EventSchema(record)
+	if err != nil {
+		return &eventRecordParser, err
+	}
+	if err = eventRecordParser.Schema.Validate(); err != nil {
+		return &eventRecordParser, err
+	}
+
+	eventRecordParser.values = make([]Value, len(eventRecordParser.Schema.Properties))
+
+	return &eventRecordParser, nil
+}
+
+// DecodeRecord builds the Event of record, with the schema provided by backend. When a property fails to decode,
+// the event is returned with the error, holding the properties that decoded, see Event.DecodeErrors.
+func DecodeRecord(record *Record, backend DecoderBackend) (*Event, error) {
+	eventParser, err := newEventParser(record, backend)
+	if err != nil {
+		return nil, err
+	}
+	return eventParser.buildEvent()
+}
+
+func (e *EventRecordParser) loadMetadata(event *Event) {
+	loadHeader(event, e.Record)
+
+	event.System.EventID = e.Schema.EventID()
+	event.System.Version = e.Schema.EventDescriptor.Version
+	event.System.ChannelValue = e.Schema.EventDescriptor.Channel
+	event.System.Channel = e.Schema.ChannelName
+	event.System.Provider.Guid = winguid.ToString(&e.Schema.ProviderGUID)
+	event.System.Provider.Name = e.Schema.ProviderName
+	event.System.Level.Value = e.Schema.EventDescriptor.Level
+	event.System.Level.Name = e.Schema.LevelName
+	event.System.Opcode.Value = e.Schema.EventDescriptor.Opcode
+	event.System.Opcode.Name = e.Schema.OpcodeName
+	event.System.Keywords.Value = e.Schema.EventDescriptor.Keyword
+	event.System.Keywords.Name = e.Schema.KeywordsName
+	event.System.Task.Value = e.Schema.EventDescriptor.Task
+	event.System.Task.Name = e.Schema.TaskName
+	event.System.Correlation.ActivityIDName = e.Schema.ActivityIDName
+	event.System.Correlation.RelatedActivityIDName = e.Schema.RelatedActivityIDName
+
+	if e.Schema.IsManagedObjectFormat() {
+		var eventType string
+		if managedObjectFormat, ok := winapi.ManagedObjectFormatMapping[e.Schema.EventGUID.Data1]; ok {
+			eventType = fmt.Sprintf("%s/%s", managedObjectFormat.Name, event.System.Opcode.Name)
+		} else {
+			eventType = fmt.Sprintf("UnknownClass/%s", event.System.Opcode.Name)
+		}
+		event.System.EventType = eventType
+		event.System.EventGuid = winguid.ToString(&e.Schema.EventGUID)
+	}
+}
+
+// loadHeader fills System from the event header and buffer context of record, the descriptor included,
+// which the schema may override.
+func loadHeader(event *Event, record *Record) {
+	header := &record.EventHeader
+	flags := header.HeaderFlags()
+
+	event.System.EventID = header.EventDescriptor.Id
+	event.System.Version = header.EventDescriptor.Version
+	event.System.ChannelValue = header.EventDescriptor.Channel
+	event.System.Level.Value = header.EventDescriptor.Level
+	event.System.Opcode.Value = header.EventDescriptor.Opcode
+	event.System.Task.Value = header.EventDescriptor.Task
+	event.System.Keywords.Value = header.EventDescriptor.Keyword
+	event.System.Provider.Guid = winguid.ToString(&header.ProviderId)
+
+	event.System.Correlation.ActivityID = winguid.ToString(&header.ActivityId)
+	if data, ok := record.ExtendedDataItem(winapi.EVENT_HEADER_EXT_TYPE_RELATED_ACTIVITYID); ok && len(data) >= 16 {
+		relatedActivityID := winguid.FromBytes(data)
+		event.System.Correlation.RelatedActivityID = winguid.ToString(&relatedActivityID)
+	}
+
+	event.System.Execution.ProcessID = header.ProcessId
+	event.System.Execution.ThreadID = header.ThreadId
+	event.System.Execution.ProcessorID = record.BufferContext.Processor(flags)
+	event.System.Execution.KernelTime, _ = header.KernelTime()
+	event.System.Execution.UserTime, _ = header.UserTime()
+	event.System.Execution.ProcessorTime, _ = header.ProcessorTime()
+
+	event.System.Flags = flags
+	event.System.EventProperty = header.EventPropertyFlags()
+	event.System.LoggerID = record.BufferContext.LoggerId
+	event.System.Timestamp = header.TimeStamp
+	event.System.TimestampUTC = winapi.FiletimeToTime(header.TimeStamp)
+}
+
+func (e *EventRecordParser) remainingUserData() []byte {
+	return e.Record.UserData[e.offset:]
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/tdh/nf-tdh-tdhformatproperty#parameters
+// https://learn.microsoft.com/en-us/windows/win32/api/tdh/ns-tdh-event_property_info#members
+// Does not correspond to the actual size in memory, required to decode strings and binary data
+func (e *EventRecordParser) getPropertyLengthSpecification(propertyInfo *PropertyInfo) (uint32, error) {
+	if !propertyInfo.HasParamLength() {
+		return uint32(propertyInfo.Length), nil
+	}
+
+	length, err := e.referencedValue(propertyInfo.Length)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get property length: %w", err)
+	}
+	return uint32(length), nil
+}
+
+func (e *EventRecordParser) getCount(propertyInfo *PropertyInfo) (uint16, error) {
+	if propertyInfo.HasParamCountPrefix() {
+		data := e.remainingUserData()
+		if len(data) < 2 {
+			return 0, fmt.Errorf("failed to get property count: %w", ErrTruncatedProperty)
+		}
+		e.offset += 2
+		return binary.LittleEndian.Uint16(data), nil
+	}
+
+	if !propertyInfo.HasParamCount() {
+		if propertyInfo.Count == 0 {
+			return 1, nil
+		}
+		return propertyInfo.Count, nil
+	}
+
+	count, err := e.referencedValue(propertyInfo.Count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get property count: %w", err)
+	}
+	return uint16(count), nil
+}
+
+// referencedValue returns the integer value of a length or count property, decoded earlier in the event,
+// or earlier in the current structure.
+func (e *EventRecordParser) referencedValue(index uint16) (uint64, error) {
+	if int(index) >= len(e.values) {
+		return 0, fmt.Errorf("%w: property index %d out of range", ErrInvalidSchema, index)
+	}
+	value := e.values[index]
+	if !value.IsInteger() {
+		return 0, fmt.Errorf("property %s is not an integer", e.Schema.Properties[index].Name)
+	}
+	return value.Uint(), nil
+}
+
+func (e *EventRecordParser) getPropertyObject(index uint16) (*PropertyParser, error) {
+	if int(index) >= len(e.Schema.Properties) {
+		return nil, fmt.Errorf("%w: property index %d out of range", ErrInvalidSchema, index)
+	}
+
+	property := PropertyParser{
+		eventRecordParser: e,
+		propertyInfo:      &e.Schema.Properties[index],
+		data:              e.remainingUserData(),
+	}
+	property.name = property.propertyInfo.Name
+
+	var err error
+	property.length, err = e.getPropertyLengthSpecification(property.propertyInfo)
+	if err != nil {
+		return &property, err
+	}
+
+	property.size, err = property.decode()
+	if err != nil {
+		return &property, err
+	}
+	if property.size < 0 || property.size > len(property.data) { // sizes reported by TDH included
+		return &property, fmt.Errorf("%w: %d bytes of %d left", ErrTruncatedProperty, property.size, len(property.data))
+	}
+	e.offset += property.size // advance iterator
+	e.values[index] = property.value
+
+	return &property, nil
+}
+
+// getPropertiesObjects decodes every top level property, and returns the first failure, if any.
+func (e *EventRecordParser) getPropertiesObjects() error {
+	e.parseUntil(e.Schema.TopLevelPropertyCount - 1)
+	for i := range e.Properties {
+		if failure, failed := e.failures[i]; failed {
+			return failure
+		}
+	}
+	return e.err
+}
+
+// parseUntil decodes the top level properties up to index, resuming after those already in Properties.
+// A property that fails is kept with a null value when its size is known without decoding it, see skippedSize,
+// and the decoding resumes after it. Otherwise the failure stops the decoding, and is returned again by later calls.
+// It returns the failure of the property at index, or the one that stopped the decoding before it, as a *DecodeError.
+func (e *EventRecordParser) parseUntil(index int) error {
+	if e.Properties == nil {
+		e.Properties = make([]Property, 0, e.Schema.TopLevelPropertyCount)
+	}
+
+	for e.err == nil && len(e.Properties) <= index && len(e.Properties) < e.Schema.TopLevelPropertyCount {
+		propertyIndex := len(e.Properties)
+		name := e.Schema.Properties[propertyIndex].Name
+		start := e.offset
+		value, err := e.parseProperty(uint16(propertyIndex), name)
+		if err != nil {
+			decodeErr := e.decodeError(name, &e.Schema.Properties[propertyIndex], err)
+			size, known := e.skippedSize(uint16(propertyIndex), e.Record.UserData[start:], 0)
+			if !known || errors.Is(err, ErrTooManyValues) {
+				e.err = decodeErr
+				break
+			}
+			if e.failures == nil {
+				e.failures = make(map[int]*DecodeError)
+			}
+			e.failures[propertyIndex] = decodeErr
+			e.values[propertyIndex] = Value{}
+			e.offset = start + size
+		}
+		e.Properties = append(e.Properties, Property{Name: name, Value: value})
+	}
+
+	if index < len(e.Properties) {
+		if failure, failed := e.failures[index]; failed {
+			return failure
+		}
+		return nil
+	}
+	return e.err
+}
+
+// skippedSize returns the size of the property at index at the start of data, when it is known without decoding
+// its values: values of fixed size, strings and binary data of a given length, and counted values, in arrays whose
+// count the schema gives, prefixes, or a top level property decoded earlier references.
+func (e *EventRecordParser) skippedSize(index uint16, data []byte, depth int) (int, bool) {
+	if int(index) >= len(e.Schema.Properties) || depth > maxStructDepth {
+		return 0, false
+	}
+	propertyInfo := &e.Schema.Properties[index]
+
+	count, size := 1, 0
+	switch {
+	case propertyInfo.HasParamCountPrefix():
+		if len(data) < 2 {
+			return 0, false
+		}
+		count, size = int(binary.LittleEndian.Uint16(data)), 2
+	case propertyInfo.HasParamCount():
+		referenced, known := e.decodedReference(propertyInfo.Count)
+		if !known {
+			return 0, false
+		}
+		count = int(uint16(referenced))
+	case propertyInfo.Count > 0:
+		count = int(propertyInfo.Count)
+	}
+
+	for i := 0; i < count; i++ {
+		var elementSize int
+		var known bool
+		if propertyInfo.IsStruct() {
+			elementSize, known = e.skippedStructSize(propertyInfo, data[size:], depth)
+		} else {
+			elementSize, known = e.skippedValueSize(propertyInfo, data[size:])
+		}
+		if !known || elementSize > len(data)-size {
+			return 0, false
+		}
+		size += elementSize
+		if elementSize == 0 { // the other elements are empty as well
+			break
+		}
+	}
+	return size, true
+}
+
+func (e *EventRecordParser) skippedStructSize(propertyInfo *PropertyInfo, data []byte, depth int) (int, bool) {
+	size := 0
+	lastMemberIndex := int(propertyInfo.StructStartIndex) + int(propertyInfo.NumOfStructMembers)
+	for memberIndex := int(propertyInfo.StructStartIndex); memberIndex < lastMemberIndex; memberIndex++ {
+		memberSize, known := e.skippedSize(uint16(memberIndex), data[size:], depth+1)
+		if !known {
+			return 0, false
+		}
+		size += memberSize
+	}
+	return size, true
+}
+
+// skippedValueSize returns the size of one value of propertyInfo at the start of data, as PropertyDecoder reads it.
+func (e *EventRecordParser) skippedValueSize(propertyInfo *PropertyInfo, data []byte) (int, bool) {
+	switch propertyInfo.InType {
+	case winapi.TdhInTypeNull:
+		return 0, true
+	case winapi.TdhInTypeInt8, winapi.TdhInTypeUint8, winapi.TdhInTypeAnsichar:
+		return 1, true
+	case winapi.TdhInTypeInt16, winapi.TdhInTypeUint16, winapi.TdhInTypeUnicodechar:
+		return 2, true
+	case winapi.TdhInTypeInt32, winapi.TdhInTypeUint32, winapi.TdhInTypeHexint32, winapi.TdhInTypeFloat, winapi.TdhInTypeBoolean:
+		return 4, true
+	case winapi.TdhInTypeInt64, winapi.TdhInTypeUint64, winapi.TdhInTypeHexint64, winapi.TdhInTypeDouble, winapi.TdhInTypeFiletime:
+		return 8, true
+	case winapi.TdhInTypeGUID, winapi.TdhInTypeSystemtime:
+		return 16, true
+	case winapi.TdhInTypePointer, winapi.TdhInTypeSizet:
+		return int(e.Record.PointerSize()), true
+	case winapi.TdhInTypeManifestCountedstring, winapi.TdhInTypeCountedstring,
+		winapi.TdhInTypeManifestCountedansistring, winapi.TdhInTypeCountedansistring, winapi.TdhInTypeManifestCountedbinary:
+		if len(data) < 2 {
+			return 0, false
+		}
+		return 2 + int(binary.LittleEndian.Uint16(data)), true
+	case winapi.TdhInTypeReversedcountedstring, winapi.TdhInTypeReversedcountedansistring:
+		if len(data) < 2 {
+			return 0, false
+		}
+		return 2 + int(binary.BigEndian.Uint16(data)), true
+	case winapi.TdhInTypeHexdump:
+		if len(data) < 4 {
+			return 0, false
+		}
+		return 4 + int(binary.LittleEndian.Uint32(data)), true
+	case winapi.TdhInTypeSid, winapi.TdhInTypeWbemsid:
+		return 0, false
+	}
+
+	length := int(propertyInfo.Length)
+	if propertyInfo.HasParamLength() {
+		referenced, known := e.decodedReference(propertyInfo.Length)
+		if !known {
+			return 0, false
+		}
+		length = int(uint32(referenced))
+	}
+
+	switch propertyInfo.InType {
+	case winapi.TdhInTypeUnicodestring, winapi.TdhInTypeNonnullterminatedstring:
+		return 2 * length, length > 0
+	case winapi.TdhInTypeBinary:
+		if length == 0 && propertyInfo.OutType == winapi.TdhOutTypeIpv6 {
+			return ipv6AddressSize, true
+		}
+		return length, true
+	}
+	// ANSI strings, and the in types PropertyDecoder does not know, are as long as the schema says
+	return length, length > 0
+}
+
+// decodedReference returns the integer value of the top level property at index, when it decoded.
+// The values of structure members belong to the element that failed, and are not trusted.
+func (e *EventRecordParser) decodedReference(index uint16) (uint64, bool) {
+	if int(index) >= e.Schema.TopLevelPropertyCount || int(index) >= len(e.values) {
+		return 0, false
+	}
+	value := e.values[index]
+	if !value.IsInteger() {
+		return 0, false
+	}
+	return value.Uint(), true
+}
+
+// decodeError ties err to the property at path, unless a nested property failed.
+func (e *EventRecordParser) decodeError(path string, propertyInfo *PropertyInfo, err error) *DecodeError {
+	if decodeErr, ok := err.(*DecodeError); ok {
+		return decodeErr
+	}
+	return &DecodeError{Path: path, InType: propertyInfo.InType, OutType: propertyInfo.OutType, Err: err}
+}
+
+// decodeErrors lists the failures of the top level properties skipped, the failure that stopped the decoding,
+// and the top level properties it left undecoded.
+func (e *EventRecordParser) decodeErrors() []DecodeError {
+	var decodeErrors []DecodeError
+	for i := range e.Properties {
+		if failure, failed := e.failures[i]; failed {
+			decodeErrors = append(decodeErrors, *failure)
+		}
+	}
+	if e.err == nil {
+		return decodeErrors
+	}
+	decodeErrors = append(decodeErrors, *e.err.(*DecodeError))
+	for i := len(e.Properties) + 1; i < e.Schema.TopLevelPropertyCount; i++ {
+		propertyInfo := &e.Schema.Properties[i]
+		decodeErrors = append(decodeErrors, DecodeError{Path: propertyInfo.Name, InType: propertyInfo.InType, OutType: propertyInfo.OutType, Err: ErrPropertySkipped})
+	}
+	return decodeErrors
+}
+
+// parseProperty decodes the property at index in the schema: a single value, a structure, or an array of either.
+// path names the property within the event.
+func (e *EventRecordParser) parseProperty(index uint16, path string) (Value, error) {
+	if int(index) >= len(e.Schema.Properties) {
+		return Value{}, fmt.Errorf("%w: property index %d out of range", ErrInvalidSchema, index)
+	}
+	propertyInfo := &e.Schema.Properties[index]
+
+	count, err := e.getCount(propertyInfo) // count is 1 if not an array
+	if err != nil {
+		return Value{}, e.decodeError(path, propertyInfo, err)
+	}
+
+	if !propertyInfo.IsArray() {
+		return e.parseElement(index, path)
+	}
+
+	elements := make([]Value, 0, count)
+	for elementIndex := uint16(0); elementIndex < count; elementIndex++ {
+		elementPath := fmt.Sprintf("%s[%d]", path, elementIndex)
+		element, elementErr := e.parseElement(index, elementPath)
+		if elementErr != nil {
+			return Value{}, e.decodeError(elementPath, propertyInfo, elementErr)
+		}
+		elements = append(elements, element)
+	}
+	return NewValue(elements, propertyInfo.InType, propertyInfo.OutType), nil
+}
+
+// parseElement decodes one element of the property at index.
+func (e *EventRecordParser) parseElement(index uint16, path string) (Value, error) {
+	propertyInfo := &e.Schema.Properties[index]
+
+	e.decoded++
+	if e.decoded > len(e.Record.UserData)+maxEmptyValues {
+		return Value{}, e.decodeError(path, propertyInfo, fmt.Errorf("%w: %d", ErrTooManyValues, e.decoded))
+	}
+
+	if !propertyInfo.IsStruct() {
+		property, err := e.getPropertyObject(index)
+		if err != nil {
+			return Value{}, e.decodeError(path, propertyInfo, err)
+		}
+		return property.value, nil
+	}
+
+	if e.depth == maxStructDepth {
+		return Value{}, e.decodeError(path, propertyInfo, fmt.Errorf("structures nested deeper than %d", maxStructDepth))
+	}
+	e.depth++
+	defer func() { e.depth-- }()
+
+	fields := make([]Property, 0, propertyInfo.NumOfStructMembers)
+	lastMemberIndex := int(propertyInfo.StructStartIndex) + int(propertyInfo.NumOfStructMembers)
+	for memberIndex := int(propertyInfo.StructStartIndex); memberIndex < lastMemberIndex; memberIndex++ {
+		memberPath := path + "." + e.memberName(memberIndex)
+		value, err := e.parseProperty(uint16(memberIndex), memberPath)
+		if err != nil {
+			return Value{}, e.decodeError(memberPath, propertyInfo, err)
+		}
+		fields = append(fields, Property{Name: e.Schema.Properties[memberIndex].Name, Value: value})
+	}
+	return NewValue(fields, 0, 0), nil
+}
+
+func (e *EventRecordParser) memberName(index int) string {
+	if index < len(e.Schema.Properties) {
+		return e.Schema.Properties[index].Name
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+// buildEvent decodes the event, past the properties that fail when their size is known. The event is returned with
+// the first failure, the failures and the properties left undecoded listed in DecodeErrors.
+func (e *EventRecordParser) buildEvent() (*Event, error) {
+	parseErr := e.getPropertiesObjects()
+
+	event := Event{
+		EventData:        make(map[string]string),
+		EventDataArrays:  make(map[string][]string),
+		EventDataStructs: make(map[string][]map[string]string),
+	}
+
+	e.parseAllPropertiesObjects(&event)
+
+	e.loadMetadata(&event)
+	event.Message = e.Schema.FormatMessage(e.Properties)
+	event.DecodeErrors = e.decodeErrors()
+
+	var extendedDataErrors []DecodeError
+	event.ExtendedData, extendedDataErrors = decodeExtendedData(e.Record.ExtendedData)
+	event.DecodeErrors = append(event.DecodeErrors, extendedDataErrors...)
+
+	if parseErr != nil {
+		return &event, parseErr
+	}
+	if len(extendedDataErrors) > 0 {
+		return &event, &extendedDataErrors[len(extendedDataErrors)-1]
+	}
+	return &event, nil
+}
+
+func (e *EventRecordParser) parseAllPropertiesObjects(event *Event) {
+	if (e.Schema.Flags & winapi.TEMPLATE_USER_DATA) == winapi.TEMPLATE_USER_DATA {
+		event.UserDataTemplate = true
+	}
+
+	event.Properties = make([]Property, 0, len(e.Properties))
+	for i, property := range e.Properties { // top level properties, in schema order
+		if _, failed := e.failures[i]; failed {
+			continue
+		}
+		event.Properties = append(event.Properties, property)
+		addPropertyStrings(event, property, e.Schema.Properties[i].IsStruct())
+	}
+}
+
+// addPropertyStrings renders a top level property in the string maps of event, isStruct telling arrays of structures
+// from arrays of values, even empty ones.
+func addPropertyStrings(event *Event, property Property, isStruct bool) {
+	value := property.Value
+	switch value.Kind {
+	case KindStruct:
+		event.EventDataStructs[property.Name] = []map[string]string{structStrings(value)}
+	case KindArray:
+		elements := value.Elements()
+		if isStruct {
+			structs := make([]map[string]string, 0, len(elements))
+			for _, element := range elements {
+				structs = append(structs, structStrings(element))
+			}
+			event.EventDataStructs[property.Name] = structs
+			return
+		}
+		values := make([]string, 0, len(elements))
+		for _, element := range elements {
+			values = append(values, element.String())
+		}
+		event.EventDataArrays[property.Name] = values
+	default:
+		event.EventData[property.Name] = value.String()
+	}
+}
+
+// structStrings renders the members of a structure, nested structures and arrays as by Value.String.
+func structStrings(structure Value) map[string]string {
+	fields := structure.Fields()
+	strings := make(map[string]string, len(fields))
+	for _, field := range fields {
+		strings[field.Name] = field.Value.String()
+	}
+	return strings
+}
+
+func (e *EventRecordParser) ProviderGUID() string {
+	return winguid.ToString(&e.Schema.ProviderGUID)
+}
+
+func (e *EventRecordParser) Provider() string {
+	return e.Schema.ProviderName
+}
+
+func (e *EventRecordParser) Channel() string {
+	return e.Schema.ChannelName
+}
+
+func (e *EventRecordParser) EventID() uint16 {
+	return e.Schema.EventID()
+}
+
+// decode reads the property with the pure-Go PropertyDecoder and names it with its value map,
+// and only relies on the backend for the properties it can not decode, or whose map the schema lacks.
+// It returns the property size.
+func (p *PropertyParser) decode() (int, error) {
+	record := p.eventRecordParser.Record
+	schema := p.eventRecordParser.Schema
+	inType := p.propertyInfo.InType
+	outType := p.propertyInfo.OutType
+
+	valueMap, mapped := schema.Maps[p.propertyInfo.MapName]
+	if p.propertyInfo.MapName != "" && !mapped {
+		formatted, size, err := p.eventRecordParser.backend.FormatProperty(record, schema, p.propertyInfo, p.length, p.data)
+		if err == nil {
+			p.value = StringValue(formatted, inType, outType)
+			return size, nil
+		}
+	}
+
+	decoder := PropertyDecoder{PointerSize: record.PointerSize()}
+	value, size, decodeErr := decoder.Decode(p.data, inType, outType, p.length)
+	if decodeErr == nil {
+		if mapped {
+			if name, ok := valueMap.Resolve(value); ok {
+				value = value.WithName(name)
+			}
+		}
+		p.value = value
+		return size, nil
+	}
+
+	formatted, size, err := p.eventRecordParser.backend.FormatProperty(record, schema, p.propertyInfo, p.length, p.data)
+	if errors.Is(err, ErrFormatUnsupported) {
+		return 0, decodeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	p.value = StringValue(formatted, inType, outType)
+
+	return size, nil
+}
@@ -0,0 +1,487 @@
+package etw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+var testProviderGUID = *winguid.MustParse("{5770385F-C22A-43E0-BF4C-06F5698FFBD9}")
+
+// testSchema describes event 1 of testProviderGUID with properties, all top level unless topLevel says otherwise.
+func testSchema(topLevel int, properties ...PropertyInfo) *Schema {
+	if topLevel < 0 {
+		topLevel = len(properties)
+	}
+	return &Schema{
+		ProviderGUID:          testProviderGUID,
+		EventDescriptor:       winapi.EventDescriptor{Id: 1, Version: 2, Level: 4},
+		ProviderName:          "Test-Provider",
+		Properties:            properties,
+		TopLevelPropertyCount: topLevel,
+	}
+}
+
+// testRecord is an event matching testSchema, carrying userData.
+func testRecord(userData []byte) *Record {
+	return &Record{
+		EventHeader: winapi.EventHeader{
+			Flags:           winapi.EVENT_HEADER_FLAG_64_BIT_HEADER,
+			ProcessId:       1234,
+			ThreadId:        5678,
+			TimeStamp:       133000000000000000,
+			ProviderId:      testProviderGUID,
+			EventDescriptor: winapi.EventDescriptor{Id: 1, Version: 2, Level: 4},
+		},
+		UserData: userData,
+	}
+}
+
+// userData concatenates the raw bytes of values: byte slices as is, integers little-endian.
+func userData(values ...interface{}) []byte {
+	var data []byte
+	for _, value := range values {
+		switch typed := value.(type) {
+		case []byte:
+			data = append(data, typed...)
+		default:
+			buffer := bytes.Buffer{}
+			if err := binary.Write(&buffer, binary.LittleEndian, typed); err != nil {
+				panic(err)
+			}
+			data = append(data, buffer.Bytes()...)
+		}
+	}
+	return data
+}
+
+func TestDecodeRecord(t *testing.T) {
+	schema := testSchema(-1,
+		PropertyInfo{Name: "Image", InType: winapi.TdhInTypeUnicodestring},
+		PropertyInfo{Name: "Size", InType: winapi.TdhInTypeUint32},
+		PropertyInfo{Name: "Data", InType: winapi.TdhInTypeBinary, Flags: winapi.PropertyParamLength, Length: 1},
+		PropertyInfo{Name: "Count", InType: winapi.TdhInTypeUint16},
+		PropertyInfo{Name: "Ports", InType: winapi.TdhInTypeUint16, OutType: winapi.TdhOutTypePort, Flags: winapi.PropertyParamCount, Count: 3},
+		PropertyInfo{Name: "Status", InType: winapi.TdhInTypeUint32, OutType: winapi.TdhOutTypeNtstatus},
+	)
+	record := testRecord(userData(
+		utf16z("cmd.exe"),
+		uint32(2), []byte{0xca, 0xfe},
+		uint16(2), []byte{0x00, 0x50, 0x01, 0xbb},
+		uint32(0xc0000022),
+	))
+
+	event, err := DecodeRecord(record, NewSchemaBackend(schema))
+	if err != nil {
+		t.Fatalf("DecodeRecord: %v", err)
+	}
+
+	wantData := map[string]string{"Image": "cmd.exe", "Size": "2", "Data": "0xCAFE", "Count": "2", "Status": "0xC0000022"}
+	if !reflect.DeepEqual(event.EventData, wantData) {
+		t.Errorf("EventData = %v, want %v", event.EventData, wantData)
+	}
+	wantArrays := map[string][]string{"Ports": {"80", "443"}}
+	if !reflect.DeepEqual(event.EventDataArrays, wantArrays) {
+		t.Errorf("EventDataArrays = %v, want %v", event.EventDataArrays, wantArrays)
+	}
+	if len(event.Properties) != 6 || event.Properties[4].Name != "Ports" || len(event.Properties[4].Value.Elements()) != 2 {
+		t.Errorf("Properties = %v", event.Properties)
+	}
+	if len(event.DecodeErrors) != 0 {
+		t.Errorf("DecodeErrors = %v", event.DecodeErrors)
+	}
+
+	if event.System.EventID != 1 || event.System.Version != 2 || event.System.Level.Value != 4 {
+		t.Errorf("descriptor = %d/%d/%d, want 1/2/4", event.System.EventID, event.System.Version, event.System.Level.Value)
+	}
+	if event.System.Provider.Name != "Test-Provider" || event.System.Provider.Guid != "{5770385F-C22A-43E0-BF4C-06F5698FFBD9}" {
+		t.Errorf("provider = %s %s", event.System.Provider.Name, event.System.Provider.Guid)
+	}
+	if event.System.Execution.ProcessID != 1234 || event.System.Execution.ThreadID != 5678 {
+		t.Errorf("execution = %d/%d, want 1234/5678", event.System.Execution.ProcessID, event.System.Execution.ThreadID)
+	}
+}
+
+func TestDecodeRecordSchemaNotFound(t *testing.T) {
+	_, err := DecodeRecord(testRecord(nil), NewSchemaBackend())
+	if !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("err = %v, want %v", err, ErrSchemaNotFound)
+	}
+}
+
+func TestDecodeRecordInvalidSchema(t *testing.T) {
+	schema := testSchema(-1, PropertyInfo{Name: "Data", InType: winapi.TdhInTypeBinary, Flags: winapi.PropertyParamLength, Length: 5})
+	_, err := DecodeRecord(testRecord(nil), NewSchemaBackend(schema))
+	if !errors.Is(err, ErrInvalidSchema) {
+		t.Errorf("err = %v, want %v", err, ErrInvalidSchema)
+	}
+}
+
+// formattingBackend formats every property as "formatted", consuming length bytes.
+type formattingBackend struct {
+	*SchemaBackend
+}
+
+func (f formattingBackend) FormatProperty(_ *Record, _ *Schema, _ *PropertyInfo, length uint32, _ []byte) (string, int, error) {
+	return "formatted", int(length), nil
+}
+
+func TestDecodeRecordFormatFallback(t *testing.T) {
+	schema := testSchema(-1,
+		PropertyInfo{Name: "Opaque", InType: winapi.TdhInTypeReserved24, Length: 3},
+		PropertyInfo{Name: "After", InType: winapi.TdhInTypeUint8},
+	)
+	record := testRecord([]byte{1, 2, 3, 42})
+
+	event, err := DecodeRecord(record, formattingBackend{NewSchemaBackend(schema)})
+	if err != nil {
+		t.Fatalf("DecodeRecord: %v", err)
+	}
+	if event.EventData["Opaque"] != "formatted" || event.EventData["After"] != "42" {
+		t.Errorf("EventData = %v", event.EventData)
+	}
+
+	_, err = DecodeRecord(record, NewSchemaBackend(schema))
+	if !errors.Is(err, ErrUnsupportedInType) {
+		t.Errorf("without formatting, err = %v, want %v", err, ErrUnsupportedInType)
+	}
+}
+
+// nestedSchema holds a structure with a nested structure and a member whose length is a sibling member,
+// and an array of structures whose count is a top level property.
+func nestedSchema() *Schema {
+	return testSchema(3,
+		PropertyInfo{Name: "EndpointCount", InType: winapi.TdhInTypeUint16},
+		PropertyInfo{Name: "Connection", Flags: winapi.PropertyStruct, StructStartIndex: 3, NumOfStructMembers: 3},
+		PropertyInfo{Name: "Endpoints", Flags: winapi.PropertyStruct | winapi.PropertyParamCount, Count: 0, StructStartIndex: 6, NumOfStructMembers: 2},
+		PropertyInfo{Name: "Local", Flags: winapi.PropertyStruct, StructStartIndex: 8, NumOfStructMembers: 2},
+		PropertyInfo{Name: "NameLength", InType: winapi.TdhInTypeUint8},
+		PropertyInfo{Name: "Name", InType: winapi.TdhInTypeBinary, Flags: winapi.PropertyParamLength, Length: 4},
+		PropertyInfo{Name: "Port", InType: winapi.TdhInTypeUint16, OutType: winapi.TdhOutTypePort},
+		PropertyInfo{Name: "Address", InType: winapi.TdhInTypeUint32, OutType: winapi.TdhOutTypeIpv4},
+		PropertyInfo{Name: "Port", InType: winapi.TdhInTypeUint16, OutType: winapi.TdhOutTypePort},
+		PropertyInfo{Name: "Address", InType: winapi.TdhInTypeUint32, OutType: winapi.TdhOutTypeIpv4},
+	)
+}
+
+func TestDecodeRecordNestedStructures(t *testing.T) {
+	record := testRecord(userData(
+		uint16(2),
+		[]byte{0x01, 0xbb, 10, 0, 0, 1}, uint8(2), []byte{0xca, 0xfe},
+		[]byte{0x00, 0x50, 1, 2, 3, 4},
+		[]byte{0x00, 0x35, 8, 8, 8, 8},
+	))
+
+	event, err := DecodeRecord(record, NewSchemaBackend(nestedSchema()))
+	if err != nil {
+		t.Fatalf("DecodeRecord: %v", err)
+	}
+
+	connection, _ := event.Property("Connection")
+	local, _ := connection.Field("Local")
+	port, _ := local.Field("Port")
+	if connection.Kind != KindStruct || local.Kind != KindStruct || port.String() != "443" {
+		t.Errorf("Connection = %s, Local = %s, Port = %s", connection.Kind, local.Kind, port)
+	}
+
+	wantStructs := map[string][]map[string]string{
+		"Connection": {{"Local": local.String(), "NameLength": "2", "Name": "0xCAFE"}},
+		"Endpoints":  {{"Port": "80", "Address": "1.2.3.4"}, {"Port": "53", "Address": "8.8.8.8"}},
+	}
+	if !reflect.DeepEqual(event.EventDataStructs, wantStructs) {
+		t.Errorf("EventDataStructs = %v, want %v", event.EventDataStructs, wantStructs)
+	}
+	if len(event.EventDataArrays) != 0 || event.EventData["EndpointCount"] != "2" {
+		t.Errorf("EventData = %v, EventDataArrays = %v", event.EventData, event.EventDataArrays)
+	}
+
+	endpoints, _ := event.Property("Endpoints")
+	if elements := endpoints.Elements(); len(elements) != 2 || elements[1].Kind != KindStruct {
+		t.Errorf("Endpoints = %v", endpoints)
+	}
+}
+
+func TestDecodeRecordEmptyStructureArray(t *testing.T) {
+	record := testRecord(userData(uint16(0), []byte{0x01, 0xbb, 10, 0, 0, 1}, uint8(0)))
+
+	event, err := DecodeRecord(record, NewSchemaBackend(nestedSchema()))
+	if err != nil {
+		t.Fatalf("DecodeRecord: %v", err)
+	}
+	if endpoints, ok := event.EventDataStructs["Endpoints"]; !ok || len(endpoints) != 0 {
+		t.Errorf("Endpoints = %v, %v, want an empty array of structures", endpoints, ok)
+	}
+}
+
+func TestDecodeRecordNestedFailure(t *testing.T) {
+	record := testRecord(userData(
+		uint16(2),
+		[]byte{0x01, 0xbb, 10, 0, 0, 1}, uint8(0),
+		[]byte{0x00, 0x50, 1, 2, 3, 4},
+		[]byte{0x00, 0x35, 8, 8},
+	))
+
+	event, err := DecodeRecord(record, NewSchemaBackend(nestedSchema()))
+	if !errors.Is(err, ErrTruncatedProperty) {
+		t.Fatalf("err = %v, want %v", err, ErrTruncatedProperty)
+	}
+	if len(event.DecodeErrors) == 0 || event.DecodeErrors[0].Path != "Endpoints[1].Address" {
+		t.Errorf("DecodeErrors = %v, want the path of the truncated member", event.DecodeErrors)
+	}
+}
+
+func TestDecodeRecordCyclicStructure(t *testing.T) {
+	schema := testSchema(1, PropertyInfo{Name: "Loop", Flags: winapi.PropertyStruct, StructStartIndex: 0, NumOfStructMembers: 1})
+
+	event, err := DecodeRecord(testRecord([]byte{1}), NewSchemaBackend(schema))
+	if err == nil || len(event.DecodeErrors) == 0 || !strings.HasPrefix(event.DecodeErrors[0].Path, "Loop.Loop") {
+		t.Errorf("err = %v, DecodeErrors = %v", err, event.DecodeErrors)
+	}
+}
+
+// unknownInType is decoded by no decoder: its properties fail, and are skipped when the schema gives their length.
+const unknownInType = winapi.TdhInType(0x200)
+
+func TestDecodeRecordSkipsFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		schema     *Schema
+		userData   []byte
+		failed     string
+		properties []string
+	}{
+		{
+			name: "fixed length",
+			schema: testSchema(-1,
+				PropertyInfo{Name: "Image", InType: winapi.TdhInTypeUnicodestring},
+				PropertyInfo{Name: "Unknown", InType: unknownInType, Length: 4},
+				PropertyInfo{Name: "Size", InType: winapi.TdhInTypeUint32},
+			),
+			userData:   userData(utf16z("a.exe"), uint32(0xffffffff), uint32(7)),
+			failed:     "Unknown",
+			properties: []string{"Image", "Size"},
+		},
+		{
+			name: "length of a decoded property",
+			schema: testSchema(-1,
+				PropertyInfo{Name: "Length", InType: winapi.TdhInTypeUint16},
+				PropertyInfo{Name: "Unknown", InType: unknownInType, Flags: winapi.PropertyParamLength, Length: 0},
+				PropertyInfo{Name: "Size", InType: winapi.TdhInTypeUint32},
+			),
+			userData:   userData(uint16(3), []byte{1, 2, 3}, uint32(7)),
+			failed:     "Unknown",
+			properties: []string{"Length", "Size"},
+		},
+		{
+			name: "fixed size structures counted by a decoded property",
+			schema: testSchema(3,
+				PropertyInfo{Name: "Count", InType: winapi.TdhInTypeUint16},
+				PropertyInfo{Name: "Endpoints", Flags: winapi.PropertyStruct | winapi.PropertyParamCount, Count: 0, StructStartIndex: 3, NumOfStructMembers: 2},
+				PropertyInfo{Name: "Size", InType: winapi.TdhInTypeUint32},
+				PropertyInfo{Name: "Port", InType: winapi.TdhInTypeUint16, OutType: winapi.TdhOutTypePort},
+				PropertyInfo{Name: "Unknown", InType: unknownInType, Length: 2},
+			),
+			userData:   userData(uint16(2), []byte{0x01, 0xbb, 0, 0, 0x00, 0x50, 0, 0}, uint32(7)),
+			failed:     "Endpoints[0].Unknown",
+			properties: []string{"Count", "Size"},
+		},
+		{
+			name: "prefixed array of fixed size values",
+			schema: testSchema(-1,
+				PropertyInfo{Name: "Unknown", InType: unknownInType, Flags: PropertyParamCountPrefix, Length: 2},
+				PropertyInfo{Name: "Size", InType: winapi.TdhInTypeUint32},
+			),
+			userData:   userData(uint16(3), []byte{1, 2, 3, 4, 5, 6}, uint32(7)),
+			failed:     "Unknown[0]",
+			properties: []string{"Size"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event, err := DecodeRecord(testRecord(test.userData), NewSchemaBackend(test.schema))
+			if !errors.Is(err, ErrUnsupportedInType) {
+				t.Fatalf("err = %v, want %v", err, ErrUnsupportedInType)
+			}
+			if len(event.DecodeErrors) != 1 || event.DecodeErrors[0].Path != test.failed {
+				t.Errorf("DecodeErrors = %v, want a failure of %s", event.DecodeErrors, test.failed)
+			}
+
+			var names []string
+			for _, property := range event.Properties {
+				names = append(names, property.Name)
+			}
+			if !reflect.DeepEqual(names, test.properties) {
+				t.Errorf("properties = %v, want %v", names, test.properties)
+			}
+			if size, _ := event.Property("Size"); size.Uint() != 7 {
+				t.Errorf("Size = %v, want 7 decoded after the failure", size)
+			}
+			if _, ok := event.EventData[test.failed]; ok {
+				t.Errorf("EventData holds the failed property: %v", event.EventData)
+			}
+		})
+	}
+}
+
+func TestDecodeRecordStopsAtUnknownSize(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema *Schema
+	}{
+		{"unknown length", testSchema(-1,
+			PropertyInfo{Name: "Unknown", InType: unknownInType},
+			PropertyInfo{Name: "Size", InType: winapi.TdhInTypeUint32},
+			PropertyInfo{Name: "Status", InType: winapi.TdhInTypeUint32},
+		)},
+		{"length of a failed property", testSchema(-1,
+			PropertyInfo{Name: "Unknown", InType: unknownInType, Length: 1},
+			PropertyInfo{Name: "Size", InType: unknownInType, Flags: winapi.PropertyParamLength, Length: 0},
+			PropertyInfo{Name: "Status", InType: winapi.TdhInTypeUint32},
+		)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event, err := DecodeRecord(testRecord(userData(uint32(7), uint32(0))), NewSchemaBackend(test.schema))
+			if err == nil {
+				t.Fatal("no error")
+			}
+			last := event.DecodeErrors[len(event.DecodeErrors)-1]
+			if last.Path != "Status" || !errors.Is(last.Err, ErrPropertySkipped) {
+				t.Errorf("DecodeErrors = %v, want Status skipped", event.DecodeErrors)
+			}
+			if _, ok := event.Property("Status"); ok {
+				t.Error("Status decoded after a failure of unknown size")
+			}
+		})
+	}
+}
+
+func TestSkippedSize(t *testing.T) {
+	tests := []struct {
+		property PropertyInfo
+		data     []byte
+		size     int
+		known    bool
+	}{
+		{PropertyInfo{InType: winapi.TdhInTypeUint8}, nil, 1, true},
+		{PropertyInfo{InType: winapi.TdhInTypeUnicodechar}, nil, 2, true},
+		{PropertyInfo{InType: winapi.TdhInTypeBoolean}, nil, 4, true},
+		{PropertyInfo{InType: winapi.TdhInTypeFiletime}, nil, 8, true},
+		{PropertyInfo{InType: winapi.TdhInTypeSystemtime}, nil, 16, true},
+		{PropertyInfo{InType: winapi.TdhInTypePointer}, nil, 8, true},
+		{PropertyInfo{InType: winapi.TdhInTypeBinary, OutType: winapi.TdhOutTypeIpv6}, nil, 16, true},
+		{PropertyInfo{InType: winapi.TdhInTypeUnicodestring, Length: 3}, nil, 6, true},
+		{PropertyInfo{InType: winapi.TdhInTypeUnicodestring}, nil, 0, false},
+		{PropertyInfo{InType: winapi.TdhInTypeAnsistring}, nil, 0, false},
+		{PropertyInfo{InType: winapi.TdhInTypeSid}, nil, 0, false},
+		{PropertyInfo{InType: winapi.TdhInTypeCountedstring}, []byte{4, 0, 1, 2, 3, 4}, 6, true},
+		{PropertyInfo{InType: winapi.TdhInTypeReversedcountedstring}, []byte{0, 2, 1, 2}, 4, true},
+		{PropertyInfo{InType: winapi.TdhInTypeCountedstring}, []byte{9, 0, 1}, 0, false},
+		{PropertyInfo{InType: winapi.TdhInTypeUint32, Flags: winapi.PropertyParamFixedCount, Count: 3}, nil, 12, true},
+		{PropertyInfo{InType: winapi.TdhInTypeUint32, Flags: winapi.PropertyParamCount, Count: 0}, nil, 8, true},
+		{PropertyInfo{InType: winapi.TdhInTypeUint32, Flags: winapi.PropertyParamLength, Length: 1}, nil, 4, true},
+		{PropertyInfo{InType: winapi.TdhInTypeBinary, Flags: winapi.PropertyParamLength, Length: 0}, nil, 2, true},
+		{PropertyInfo{InType: unknownInType, Flags: winapi.PropertyParamLength, Length: 1}, nil, 0, false},
+	}
+
+	for _, test := range tests {
+		schema := testSchema(1, PropertyInfo{Name: "Count", InType: winapi.TdhInTypeUint16}, test.property)
+		parser := EventRecordParser{Record: testRecord(nil), Schema: schema, values: make([]Value, 2)}
+		parser.values[0] = NewValue(uint16(2), winapi.TdhInTypeUint16, 0)
+
+		data := test.data
+		if data == nil {
+			data = make([]byte, 32)
+		}
+		size, known := parser.skippedSize(1, data, 0)
+		if size != test.size || known != test.known {
+			t.Errorf("%+v: skippedSize = %d, %v, want %d, %v", test.property, size, known, test.size, test.known)
+		}
+	}
+}
+
+func TestDecodeRecordSystem(t *testing.T) {
+	schema := testSchema(-1)
+	schema.EventDescriptor = winapi.EventDescriptor{Id: 1, Version: 2, Channel: 16, Level: 4, Opcode: 1, Task: 0x1234, Keyword: 0x8000000000000001}
+	schema.ActivityIDName = "Activity"
+	schema.RelatedActivityIDName = "Parent"
+
+	record := testRecord(nil)
+	record.EventHeader.EventDescriptor = schema.EventDescriptor
+	record.EventHeader.Flags = winapi.EVENT_HEADER_FLAG_64_BIT_HEADER | winapi.EVENT_HEADER_FLAG_PROCESSOR_INDEX | winapi.EVENT_HEADER_FLAG_EXTENDED_INFO
+	record.EventHeader.EventProperty = winapi.EVENT_HEADER_PROPERTY_XML
+	record.EventHeader.Time = 0x0000000500000003
+	record.EventHeader.ActivityId = *winguid.MustParse("{11111111-2222-3333-4444-555555555555}")
+	record.BufferContext = winapi.BufferContext{Union: 300, LoggerId: 9}
+	relatedActivityID := winguid.MustParse("{66666666-7777-8888-9999-AAAAAAAAAAAA}")
+	record.ExtendedData = []ExtendedDataItem{{ExtType: winapi.EVENT_HEADER_EXT_TYPE_RELATED_ACTIVITYID, Data: winguid.ToBytes(relatedActivityID)}}
+
+	event, err := DecodeRecord(record, NewSchemaBackend(schema))
+	if err != nil {
+		t.Fatalf("DecodeRecord: %v", err)
+	}
+
+	system := event.System
+	tests := []struct {
+		field string
+		got   interface{}
+		want  interface{}
+	}{
+		{"EventID", system.EventID, uint16(1)},
+		{"Version", system.Version, uint8(2)},
+		{"ChannelValue", system.ChannelValue, uint8(16)},
+		{"Level", system.Level.Value, uint8(4)},
+		{"Opcode", system.Opcode.Value, uint8(1)},
+		{"Task", system.Task.Value, uint16(0x1234)},
+		{"Keywords", system.Keywords.Value, uint64(0x8000000000000001)},
+		{"ActivityID", system.Correlation.ActivityID, "{11111111-2222-3333-4444-555555555555}"},
+		{"ActivityIDName", system.Correlation.ActivityIDName, "Activity"},
+		{"RelatedActivityID", system.Correlation.RelatedActivityID, "{66666666-7777-8888-9999-AAAAAAAAAAAA}"},
+		{"RelatedActivityIDName", system.Correlation.RelatedActivityIDName, "Parent"},
+		{"ProcessorID", system.Execution.ProcessorID, uint16(300)},
+		{"KernelTime", system.Execution.KernelTime, uint32(3)},
+		{"UserTime", system.Execution.UserTime, uint32(5)},
+		{"ProcessorTime", system.Execution.ProcessorTime, uint64(0)},
+		{"Flags.HasProcessorIndex", system.Flags.HasProcessorIndex(), true},
+		{"Flags.Is64BitHeader", system.Flags.Is64BitHeader(), true},
+		{"EventProperty.IsXML", system.EventProperty.IsXML(), true},
+		{"LoggerID", system.LoggerID, uint16(9)},
+		{"Timestamp", system.Timestamp, int64(133000000000000000)},
+		{"TimestampUTC", system.TimestampUTC, winapi.FiletimeToTime(133000000000000000)},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("System.%s = %v, want %v", test.field, test.got, test.want)
+		}
+	}
+}
+
+func TestDecodeRecordSystemPrivateSession(t *testing.T) {
+	record := testRecord(nil)
+	record.EventHeader.Flags = winapi.EVENT_HEADER_FLAG_PRIVATE_SESSION
+	record.EventHeader.Time = 0x0000000500000003
+	record.BufferContext = winapi.BufferContext{Union: 0x0802}
+
+	event, err := DecodeRecord(record, NewSchemaBackend(testSchema(-1)))
+	if err != nil {
+		t.Fatalf("DecodeRecord: %v", err)
+	}
+	execution := event.System.Execution
+	if execution.ProcessorTime != 0x0000000500000003 || execution.KernelTime != 0 || execution.UserTime != 0 {
+		t.Errorf("CPU times = %d/%d/%d, want the processor time only", execution.KernelTime, execution.UserTime, execution.ProcessorTime)
+	}
+	if execution.ProcessorID != 2 {
+		t.Errorf("ProcessorID = %d, want the processor number 2", execution.ProcessorID)
+	}
+	if event.System.Correlation.RelatedActivityID != "" {
+		t.Errorf("RelatedActivityID = %q without extended data item", event.System.Correlation.RelatedActivityID)
+	}
+}
@@ -15,6 +15,10 @@ import (
 // https://learn.microsoft.com/en-us/windows/win32/etw/lost-event
 var realTimeSessionLostEventGuid = winguid.MustParse("{6A399AE0-4BC6-4DE9-870B-3657F8947E7E}")
 
+var (
+	ErrDecodingPanic = fmt.Errorf("panic while decoding event")
+)
+
 type EventSender struct {
 	Dropped uint64
 }
@@ -28,27 +32,55 @@ func (e *EventSender) Forward(channel chan<- *Event, event *Event) {
 	return
 }
 
+func (e *EventSender) ForwardLazy(channel chan<- *LazyEvent, event *LazyEvent) {
+	select {
+	case channel <- event: // sent
+	default:
+		e.Dropped++
+	}
+}
+
 type EventCallback struct {
 	ctx       context.Context
 	waitGroup sync.WaitGroup
 
 	Events      chan *Event
+	LazyEvents  chan *LazyEvent // receives the events instead of Events, when Lazy
 	traceHandle syscall.Handle
 	LostEvents  uint64
 
 	Sender EventSender
 
+	// Backend provides event schemas: TraceLogging metadata, kernel MOF layouts, or TDH behind a SchemaCache by default.
+	Backend DecoderBackend
+
+	// DecodePolicy applies to the events that fail to decode, delivered partially decoded by default,
+	// and to LazyEvents, see NewLazyEventWith.
+	DecodePolicy DecodePolicy
+
+	// Lazy forwards the events undecoded to LazyEvents, to decode only those that pass the filters of the consumer.
+	Lazy bool
+
 	lastError error
 }
 
 func NewEventCallback(ctx context.Context) *EventCallback {
 	return &EventCallback{
-		ctx:    ctx,
-		Events: make(chan *Event, 4096),
-		Sender: EventSender{},
+		ctx:     ctx,
+		Events:  make(chan *Event, 4096),
+		Sender:  EventSender{},
+		Backend: NewTraceLoggingBackend(NewMOFBackend(NewSchemaCache(&TdhBackend{}, DefaultSchemaCacheSize))),
 	}
 }
 
+// NewLazyEventCallback returns an EventCallback forwarding LazyEvent to LazyEvents.
+func NewLazyEventCallback(ctx context.Context) *EventCallback {
+	eventCallback := NewEventCallback(ctx)
+	eventCallback.Lazy = true
+	eventCallback.LazyEvents = make(chan *LazyEvent, cap(eventCallback.Events))
+	return eventCallback
+}
+
 func (e *EventCallback) eventBufferCallback(*winapi.EventTraceLogfile) uintptr {
 	if e.ctx.Err() != nil {
 		return 0 // stop processing
@@ -57,19 +89,29 @@ func (e *EventCallback) eventBufferCallback(*winapi.EventTraceLogfile) uintptr {
 }
 
 func (e *EventCallback) eventRecordCallback(eventRecord *winapi.EventRecord) uintptr {
+	defer func() { // a panic would unwind through ProcessTrace
+		if r := recover(); r != nil {
+			e.lastError = fmt.Errorf("%w: %v", ErrDecodingPanic, r)
+		}
+	}()
+
 	if winguid.Equals(&eventRecord.EventHeader.ProviderId, realTimeSessionLostEventGuid) {
 		e.LostEvents++
 	}
 
-	eventParser, newEventErr := newEventParser(eventRecord)
-	if newEventErr != nil {
-		e.lastError = newEventErr // TODO LOG
+	if e.Lazy {
+		lazyEvent, lazyEventErr := NewLazyEventWith(newRecord(eventRecord), e.Backend, e.DecodePolicy)
+		if lazyEvent == nil {
+			e.lastError = lazyEventErr
+			return 0
+		}
+		e.Sender.ForwardLazy(e.LazyEvents, lazyEvent)
 		return 0
 	}
 
-	event, buildEventErr := eventParser.buildEvent()
-	if buildEventErr != nil {
-		e.lastError = buildEventErr // TODO LOG
+	event, decodeErr := DecodeRecordWith(newRecord(eventRecord), e.Backend, e.DecodePolicy)
+	if event == nil {
+		e.lastError = decodeErr // TODO LOG
 		return 0
 	}
 
@@ -140,6 +182,9 @@ func (e *EventCallback) Stop() error {
 
 	e.waitGroup.Wait()
 	close(e.Events)
+	if e.LazyEvents != nil {
+		close(e.LazyEvents)
+	}
 
 	return err
 }
@@ -51,12 +51,29 @@ func (e *EventTracingSession) StartTrace() error {
 // https://learn.microsoft.com/en-us/windows/win32/api/evntrace/nf-evntrace-enabletraceex2#parameters
 // https://learn.microsoft.com/en-us/windows/win32/wes/defining-keywords-used-to-classify-types-of-events
 const (
-	maxVerbosity           = uint8(255)
-	defaultMatchAnyKeyword = uint64(0)
-	defaultMatchAllKeyword = uint64(0)
+	maxVerbosity = uint8(255)
 )
 
+// ProviderOptions are the enable parameters of a provider in a session.
+type ProviderOptions struct {
+	Level           uint8 // 0 enables every level
+	MatchAnyKeyword uint64
+	MatchAllKeyword uint64
+
+	// StackTrace requests the call stack of every event, decoded into Event.StackTrace.
+	StackTrace bool
+	// EnableProperty holds further EVENT_ENABLE_PROPERTY flags, such as winapi.EVENT_ENABLE_PROPERTY_SID.
+	EnableProperty uint32
+}
+
+// EnableTrace enables every event of the provider.
 func (e *EventTracingSession) EnableTrace(providerGUID *syscall.GUID) error {
+	return e.EnableProvider(providerGUID, ProviderOptions{})
+}
+
+// EnableProvider enables the provider with options, starting the session first if need be.
+// A provider already enabled in the session is updated.
+func (e *EventTracingSession) EnableProvider(providerGUID *syscall.GUID, options ProviderOptions) error {
 	var err error
 
 	if !e.IsStarted() {
@@ -65,16 +82,27 @@ func (e *EventTracingSession) EnableTrace(providerGUID *syscall.GUID) error {
 		}
 	}
 
-	enableTraceParameters := winapi.EnableTraceParameters{Version: 2}
+	level := options.Level
+	if level == 0 {
+		level = maxVerbosity
+	}
+
+	enableTraceParameters := winapi.EnableTraceParameters{
+		Version:        winapi.ENABLE_TRACE_PARAMETERS_VERSION_2,
+		EnableProperty: options.EnableProperty,
+	}
+	if options.StackTrace {
+		enableTraceParameters.EnableProperty |= winapi.EVENT_ENABLE_PROPERTY_STACK_TRACE
+	}
 
 	timeout := uint32(0)
 	enableTraceErr := winapi.EnableTraceEx2(
 		e.handle,
 		providerGUID,
 		winapi.EVENT_CONTROL_CODE_ENABLE_PROVIDER,
-		maxVerbosity,
-		defaultMatchAnyKeyword,
-		defaultMatchAllKeyword,
+		level,
+		options.MatchAnyKeyword,
+		options.MatchAllKeyword,
 		timeout,
 		&enableTraceParameters,
 	)
@@ -0,0 +1,470 @@
+package etw
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventschema-schema
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventschema-systempropertiestype-complextype
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventschema-renderingtype-complextype
+
+// EventXMLNamespace is the namespace of the Event element of the Windows Event Log.
+const EventXMLNamespace = "http://schemas.microsoft.com/win/2004/08/events/event"
+
+// defaultUserDataName wraps the properties of UserData templates when Event.UserDataName is empty.
+const defaultUserDataName = "EventXML"
+
+var (
+	ErrEventXML = fmt.Errorf("invalid event XML")
+)
+
+const zeroGUID = "{00000000-0000-0000-0000-000000000000}"
+
+// MarshalEventXML renders the event as wevtutil qe /f:xml does: without whitespace, attributes in single quotes
+// and empty elements self-closed. Properties go under EventData, or under UserData for UserData templates,
+// wrapped in the UserDataName element of the UserDataNamespace namespace, which the schema defines but TDH
+// does not report. Values are rendered raw, mapped values by their number, structures as ComplexData elements
+// under EventData, and array elements as repeated elements. GUIDs are cased as wevtutil does: the provider GUID
+// and GUID values in lowercase, the activity IDs of Correlation in uppercase.
+//
+// Only the process and thread IDs of Execution are rendered, the event log does not keep CPU times.
+func (e *Event) MarshalEventXML() []byte {
+	var w eventXMLWriter
+	e.writeXML(&w, false, "")
+	return w.Bytes()
+}
+
+// MarshalRenderedEventXML renders the event as wevtutil qe /f:RenderedXml does, adding the RenderingInfo element
+// of culture, such as en-US, with the message and names of the event.
+func (e *Event) MarshalRenderedEventXML(culture string) []byte {
+	var w eventXMLWriter
+	e.writeXML(&w, true, culture)
+	return w.Bytes()
+}
+
+func (e *Event) writeXML(w *eventXMLWriter, rendered bool, culture string) {
+	w.WriteString("<Event xmlns='" + EventXMLNamespace + "'>")
+	e.writeSystemXML(w)
+
+	if e.UserDataTemplate {
+		name := e.UserDataName
+		if name == "" {
+			name = defaultUserDataName
+		}
+		w.WriteString("<UserData>")
+		w.startElement(name, "xmlns", e.UserDataNamespace)
+		for _, property := range e.Properties {
+			w.writeUserData(property.Name, property.Value)
+		}
+		w.WriteString("</" + name + "></UserData>")
+	} else if len(e.Properties) > 0 {
+		w.WriteString("<EventData>")
+		for _, property := range e.Properties {
+			w.writeEventData(property.Name, property.Value)
+		}
+		w.WriteString("</EventData>")
+	}
+
+	if rendered {
+		e.writeRenderingInfoXML(w, culture)
+	}
+	w.WriteString("</Event>")
+}
+
+func (e *Event) writeSystemXML(w *eventXMLWriter) {
+	system := &e.System
+
+	w.WriteString("<System>")
+	w.emptyElement("Provider", "Name", system.Provider.Name, "Guid", strings.ToLower(system.Provider.Guid))
+	w.element("EventID", strconv.FormatUint(uint64(system.EventID), 10))
+	w.element("Version", strconv.FormatUint(uint64(system.Version), 10))
+	w.element("Level", strconv.FormatUint(uint64(system.Level.Value), 10))
+	w.element("Task", strconv.FormatUint(uint64(system.Task.Value), 10))
+	w.element("Opcode", strconv.FormatUint(uint64(system.Opcode.Value), 10))
+	w.element("Keywords", "0x"+strconv.FormatUint(system.Keywords.Value, 16))
+	w.emptyElement("TimeCreated", "SystemTime", system.TimestampUTC.UTC().Format(TimeLayout))
+	w.element("EventRecordID", strconv.FormatUint(system.EventRecordID, 10))
+
+	activityID := system.Correlation.ActivityID
+	if activityID == zeroGUID {
+		activityID = ""
+	}
+	w.emptyElement("Correlation", "ActivityID", strings.ToUpper(activityID), "RelatedActivityID", strings.ToUpper(system.Correlation.RelatedActivityID))
+	w.emptyElement("Execution",
+		"ProcessID", strconv.FormatUint(uint64(system.Execution.ProcessID), 10),
+		"ThreadID", strconv.FormatUint(uint64(system.Execution.ThreadID), 10))
+	w.element("Channel", system.Channel)
+	w.element("Computer", system.Computer)
+
+	var userID string
+	if e.UserSID != nil {
+		userID = e.UserSID.String()
+	}
+	w.emptyElement("Security", "UserID", userID)
+	w.WriteString("</System>")
+}
+
+func (e *Event) writeRenderingInfoXML(w *eventXMLWriter, culture string) {
+	system := &e.System
+
+	w.startElement("RenderingInfo", "Culture", culture)
+	w.element("Message", e.Message)
+	w.element("Level", system.Level.Name)
+	w.element("Task", system.Task.Name)
+	w.element("Opcode", system.Opcode.Name)
+	w.element("Channel", system.Channel)
+	w.element("Provider", system.Provider.Name)
+	if system.Keywords.Name == "" {
+		w.WriteString("<Keywords/>")
+	} else {
+		w.WriteString("<Keywords>")
+		for _, keyword := range strings.Split(system.Keywords.Name, ", ") {
+			w.element("Keyword", keyword)
+		}
+		w.WriteString("</Keywords>")
+	}
+	w.WriteString("</RenderingInfo>")
+}
+
+// eventXMLWriter writes the event XML the way the event log renders it.
+type eventXMLWriter struct {
+	bytes.Buffer
+}
+
+// startElement writes the start tag of name, with the non-empty attributes of attributes, name and value pairs.
+func (w *eventXMLWriter) startElement(name string, attributes ...string) {
+	w.writeTag(name, attributes)
+	w.WriteByte('>')
+}
+
+func (w *eventXMLWriter) emptyElement(name string, attributes ...string) {
+	w.writeTag(name, attributes)
+	w.WriteString("/>")
+}
+
+func (w *eventXMLWriter) writeTag(name string, attributes []string) {
+	w.WriteString("<" + name)
+	for i := 0; i+1 < len(attributes); i += 2 {
+		if attributes[i+1] == "" {
+			continue
+		}
+		w.WriteString(" " + attributes[i] + "='")
+		w.escape(attributes[i+1], true)
+		w.WriteByte('\'')
+	}
+}
+
+// element writes a text element, self-closed when empty.
+func (w *eventXMLWriter) element(name string, text string, attributes ...string) {
+	if text == "" {
+		w.emptyElement(name, attributes...)
+		return
+	}
+	w.startElement(name, attributes...)
+	w.escape(text, false)
+	w.WriteString("</" + name + ">")
+}
+
+func (w *eventXMLWriter) escape(s string, attribute bool) {
+	for _, r := range s {
+		switch {
+		case r == '&':
+			w.WriteString("&amp;")
+		case r == '<':
+			w.WriteString("&lt;")
+		case r == '>':
+			w.WriteString("&gt;")
+		case r == '\'' && attribute:
+			w.WriteString("&apos;")
+		case r == '"' && attribute:
+			w.WriteString("&quot;")
+		case r < ' ' && r != '\t' && r != '\n' && r != '\r':
+			fmt.Fprintf(w, "&#x%X;", r)
+		default:
+			w.WriteRune(r)
+		}
+	}
+}
+
+// writeEventData writes a property as Data elements named by their Name attribute.
+func (w *eventXMLWriter) writeEventData(name string, value Value) {
+	switch value.Kind {
+	case KindArray:
+		for _, element := range value.Elements() {
+			w.writeEventData(name, element)
+		}
+	case KindStruct:
+		w.startElement("ComplexData", "Name", name)
+		for _, field := range value.Fields() {
+			w.writeEventData(field.Name, field.Value)
+		}
+		w.WriteString("</ComplexData>")
+	default:
+		w.element("Data", xmlValueString(value), "Name", name)
+	}
+}
+
+// writeUserData writes a property as an element of its own name.
+func (w *eventXMLWriter) writeUserData(name string, value Value) {
+	switch value.Kind {
+	case KindArray:
+		for _, element := range value.Elements() {
+			w.writeUserData(name, element)
+		}
+	case KindStruct:
+		w.WriteString("<" + name + ">")
+		for _, field := range value.Fields() {
+			w.writeUserData(field.Name, field.Value)
+		}
+		w.WriteString("</" + name + ">")
+	default:
+		w.element(name, xmlValueString(value))
+	}
+}
+
+// xmlValueString renders the raw value as the event log does: binary data in uppercase hexadecimal without prefix,
+// hexadecimal integers and GUIDs in lowercase.
+func xmlValueString(value Value) string {
+	raw := value.Raw()
+	switch {
+	case raw.Kind == KindBinary:
+		return strings.ToUpper(hex.EncodeToString(raw.Bytes()))
+	case raw.Kind == KindGUID:
+		return strings.ToLower(raw.String())
+	case raw.IsInteger() && isHexOutput(raw.InType, raw.OutType):
+		return "0x" + strconv.FormatUint(raw.unsignedBits(), 16)
+	}
+	return raw.String()
+}
+
+// eventXML and the types below read the elements of the event XML, unknown ones are ignored.
+type eventXML struct {
+	XMLName       xml.Name          `xml:"Event"`
+	System        systemXML         `xml:"System"`
+	EventData     *dataXML          `xml:"EventData"`
+	UserData      *dataXML          `xml:"UserData"`
+	RenderingInfo *renderingInfoXML `xml:"RenderingInfo"`
+}
+
+type systemXML struct {
+	Provider struct {
+		Name string `xml:"Name,attr"`
+		Guid string `xml:"Guid,attr"`
+	} `xml:"Provider"`
+	EventID     string `xml:"EventID"`
+	Version     string `xml:"Version"`
+	Level       string `xml:"Level"`
+	Task        string `xml:"Task"`
+	Opcode      string `xml:"Opcode"`
+	Keywords    string `xml:"Keywords"`
+	TimeCreated struct {
+		SystemTime string `xml:"SystemTime,attr"`
+	} `xml:"TimeCreated"`
+	EventRecordID string `xml:"EventRecordID"`
+	Correlation   struct {
+		ActivityID        string `xml:"ActivityID,attr"`
+		RelatedActivityID string `xml:"RelatedActivityID,attr"`
+	} `xml:"Correlation"`
+	Execution struct {
+		ProcessID   string `xml:"ProcessID,attr"`
+		ThreadID    string `xml:"ThreadID,attr"`
+		ProcessorID string `xml:"ProcessorID,attr"`
+		KernelTime  string `xml:"KernelTime,attr"`
+		UserTime    string `xml:"UserTime,attr"`
+	} `xml:"Execution"`
+	Channel  string `xml:"Channel"`
+	Computer string `xml:"Computer"`
+	Security struct {
+		UserID string `xml:"UserID,attr"`
+	} `xml:"Security"`
+}
+
+// dataXML is any element of EventData or UserData, or one of them.
+type dataXML struct {
+	XMLName xml.Name
+	Name    string    `xml:"Name,attr"`
+	Text    string    `xml:",chardata"`
+	Items   []dataXML `xml:",any"`
+}
+
+type renderingInfoXML struct {
+	Message  string   `xml:"Message"`
+	Level    string   `xml:"Level"`
+	Task     string   `xml:"Task"`
+	Opcode   string   `xml:"Opcode"`
+	Channel  string   `xml:"Channel"`
+	Provider string   `xml:"Provider"`
+	Keywords []string `xml:"Keywords>Keyword"`
+}
+
+// UnmarshalEventXML reads an Event element of the Windows Event Log, rendered or not, such as written by
+// MarshalEventXML. Property values are strings, the XML does not tell their types: repeated elements
+// make arrays and elements with children structures. GUIDs are kept as written.
+func UnmarshalEventXML(data []byte) (*Event, error) {
+	var event Event
+	if err := xml.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// UnmarshalXML implements xml.Unmarshaler, to read events within other documents, see UnmarshalEventXML.
+func (e *Event) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	var decoded eventXML
+	if err := decoder.DecodeElement(&decoded, &start); err != nil {
+		return err
+	}
+
+	*e = Event{
+		EventData:        make(map[string]string),
+		EventDataArrays:  make(map[string][]string),
+		EventDataStructs: make(map[string][]map[string]string),
+	}
+	if err := e.loadSystemXML(&decoded.System); err != nil {
+		return err
+	}
+
+	var items []dataXML
+	if decoded.UserData != nil {
+		e.UserDataTemplate = true
+		if len(decoded.UserData.Items) > 0 {
+			wrapper := &decoded.UserData.Items[0]
+			e.UserDataName = wrapper.XMLName.Local
+			if wrapper.XMLName.Space != EventXMLNamespace { // inherited
+				e.UserDataNamespace = wrapper.XMLName.Space
+			}
+			items = wrapper.Items
+		}
+	} else if decoded.EventData != nil {
+		items = decoded.EventData.Items
+	}
+	e.Properties = xmlProperties(items)
+	for _, property := range e.Properties {
+		isStruct := property.Value.Kind == KindArray && len(property.Value.Elements()) > 0 &&
+			property.Value.Elements()[0].Kind == KindStruct
+		addPropertyStrings(e, property, isStruct)
+	}
+
+	if rendering := decoded.RenderingInfo; rendering != nil {
+		e.Message = rendering.Message
+		e.System.Level.Name = rendering.Level
+		e.System.Task.Name = rendering.Task
+		e.System.Opcode.Name = rendering.Opcode
+		e.System.Keywords.Name = strings.Join(rendering.Keywords, ", ")
+		if e.System.Channel == "" {
+			e.System.Channel = rendering.Channel
+		}
+		if e.System.Provider.Name == "" {
+			e.System.Provider.Name = rendering.Provider
+		}
+	}
+
+	return nil
+}
+
+func (e *Event) loadSystemXML(system *systemXML) error {
+	var err error
+	parseUint := func(field string, s string, bitSize int) uint64 {
+		if s == "" || err != nil {
+			return 0
+		}
+		var value uint64
+		value, err = strconv.ParseUint(s, 0, bitSize)
+		if err != nil {
+			err = fmt.Errorf("%w: %s: %s", ErrEventXML, field, err)
+		}
+		return value
+	}
+
+	e.System.Provider.Name = system.Provider.Name
+	e.System.Provider.Guid = system.Provider.Guid
+	e.System.EventID = uint16(parseUint("EventID", system.EventID, 16))
+	e.System.Version = uint8(parseUint("Version", system.Version, 8))
+	e.System.Level.Value = uint8(parseUint("Level", system.Level, 8))
+	e.System.Task.Value = uint16(parseUint("Task", system.Task, 16))
+	e.System.Opcode.Value = uint8(parseUint("Opcode", system.Opcode, 8))
+	e.System.Keywords.Value = parseUint("Keywords", system.Keywords, 64)
+	e.System.EventRecordID = parseUint("EventRecordID", system.EventRecordID, 64)
+	e.System.Correlation.ActivityID = system.Correlation.ActivityID
+	if e.System.Correlation.ActivityID == "" {
+		e.System.Correlation.ActivityID = zeroGUID
+	}
+	e.System.Correlation.RelatedActivityID = system.Correlation.RelatedActivityID
+	e.System.Execution.ProcessID = uint32(parseUint("ProcessID", system.Execution.ProcessID, 32))
+	e.System.Execution.ThreadID = uint32(parseUint("ThreadID", system.Execution.ThreadID, 32))
+	e.System.Execution.ProcessorID = uint16(parseUint("ProcessorID", system.Execution.ProcessorID, 16))
+	e.System.Execution.KernelTime = uint32(parseUint("KernelTime", system.Execution.KernelTime, 32))
+	e.System.Execution.UserTime = uint32(parseUint("UserTime", system.Execution.UserTime, 32))
+	e.System.Channel = system.Channel
+	e.System.Computer = system.Computer
+	if err != nil {
+		return err
+	}
+
+	if system.TimeCreated.SystemTime != "" {
+		timeCreated, timeErr := time.Parse(time.RFC3339Nano, system.TimeCreated.SystemTime)
+		if timeErr != nil {
+			return fmt.Errorf("%w: TimeCreated: %s", ErrEventXML, timeErr)
+		}
+		e.System.TimestampUTC = timeCreated.UTC()
+		e.System.Timestamp = winapi.TimeToFiletime(timeCreated)
+	}
+
+	if relatedActivityID, guidErr := winguid.Parse(e.System.Correlation.RelatedActivityID); guidErr == nil {
+		e.RelatedActivityID = *relatedActivityID
+	}
+	if system.Security.UserID != "" {
+		sid, sidErr := ParseSID(system.Security.UserID)
+		if sidErr != nil {
+			return fmt.Errorf("%w: UserID: %s", ErrEventXML, sidErr)
+		}
+		e.UserSID = &sid
+	}
+
+	return nil
+}
+
+// xmlProperties reads the Data elements of EventData by their Name attribute, and the elements of UserData
+// by their own name. Consecutive elements of the same name make arrays.
+func xmlProperties(items []dataXML) []Property {
+	var properties []Property
+	for i := 0; i < len(items); {
+		name := items[i].Name
+		if items[i].XMLName.Local != "Data" && items[i].XMLName.Local != "ComplexData" {
+			name = items[i].XMLName.Local
+		}
+
+		end := i + 1
+		for end < len(items) && items[end].XMLName == items[i].XMLName && items[end].Name == items[i].Name {
+			end++
+		}
+
+		if end-i == 1 {
+			properties = append(properties, Property{Name: name, Value: xmlValue(&items[i])})
+		} else {
+			elements := make([]Value, 0, end-i)
+			for j := i; j < end; j++ {
+				elements = append(elements, xmlValue(&items[j]))
+			}
+			properties = append(properties, Property{Name: name, Value: NewValue(elements, 0, 0)})
+		}
+		i = end
+	}
+	return properties
+}
+
+func xmlValue(item *dataXML) Value {
+	if len(item.Items) > 0 {
+		return NewValue(xmlProperties(item.Items), 0, 0)
+	}
+	return StringValue(item.Text, winapi.TdhInTypeUnicodestring, winapi.TdhOutTypeString)
+}
@@ -0,0 +1,313 @@
+package etw
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata/xml")
+
+// xmlTestEvent decodes an event of every value kind the event log renders, as wevtutil would find it
+// in the Sample-Provider/Operational channel.
+func xmlTestEvent(t *testing.T) *Event {
+	t.Helper()
+
+	schema := testSchema(9,
+		PropertyInfo{Name: "Image", InType: winapi.TdhInTypeUnicodestring},
+		PropertyInfo{Name: "LogonId", InType: winapi.TdhInTypeHexint64},
+		PropertyInfo{Name: "Status", InType: winapi.TdhInTypeUint32, OutType: winapi.TdhOutTypeNtstatus},
+		PropertyInfo{Name: "State", InType: winapi.TdhInTypeUint32, MapName: "StateMap"},
+		PropertyInfo{Name: "Session", InType: winapi.TdhInTypeGUID},
+		PropertyInfo{Name: "Created", InType: winapi.TdhInTypeFiletime},
+		PropertyInfo{Name: "Blob", InType: winapi.TdhInTypeBinary, Length: 2},
+		PropertyInfo{Name: "Ports", InType: winapi.TdhInTypeUint16, OutType: winapi.TdhOutTypePort, Flags: winapi.PropertyParamFixedCount, Count: 2},
+		PropertyInfo{Name: "Endpoint", Flags: winapi.PropertyStruct, StructStartIndex: 9, NumOfStructMembers: 2},
+		PropertyInfo{Name: "Port", InType: winapi.TdhInTypeUint16, OutType: winapi.TdhOutTypePort},
+		PropertyInfo{Name: "Address", InType: winapi.TdhInTypeUint32, OutType: winapi.TdhOutTypeIpv4},
+	)
+	schema.EventDescriptor = winapi.EventDescriptor{Id: 1, Version: 2, Channel: 16, Level: 4, Opcode: 1, Task: 7, Keyword: 0x8000000000000001}
+	schema.ChannelName = "Sample-Provider/Operational"
+	schema.LevelName = "Information"
+	schema.TaskName = "Connect"
+	schema.OpcodeName = "Start"
+	schema.KeywordsName = "Network, Response Time"
+	schema.EventMessage = "%1 connected with status %3"
+	schema.Maps = map[string]*ValueMap{"StateMap": {Name: "StateMap", Entries: []ValueMapEntry{{Value: 1, Output: "Running"}}}}
+
+	record := testRecord(userData(
+		utf16z("C:\\Windows\\a.exe"),
+		uint64(0x3e7),
+		uint32(0xc0000022),
+		uint32(1),
+		winguid.ToBytes(winguid.MustParse("{A1B2C3D4-0000-1111-2222-333344445555}")),
+		int64(133000000000000000),
+		[]byte{0xca, 0xfe},
+		[]byte{0x00, 0x50, 0x01, 0xbb},
+		[]byte{0x01, 0xbb, 10, 0, 0, 1},
+	))
+	record.EventHeader.EventDescriptor = schema.EventDescriptor
+	record.EventHeader.ActivityId = *winguid.MustParse("{11111111-2222-3333-4444-55555555AAAA}")
+
+	event, err := DecodeRecord(record, NewSchemaBackend(schema))
+	if err != nil {
+		t.Fatalf("DecodeRecord: %v", err)
+	}
+	sid, _ := ParseSID("S-1-5-18")
+	event.UserSID = &sid
+	event.System.Computer = "host.example.com"
+	event.System.EventRecordID = 42
+	return event
+}
+
+// checkGolden compares data with testdata/xml/name, which holds one event per line as wevtutil qe /f:xml prints it.
+func checkGolden(t *testing.T, name string, data []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", "xml", name)
+	if *update {
+		if err := os.WriteFile(path, append(data, '\r', '\n'), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if golden = bytes.TrimRight(golden, "\r\n"); !bytes.Equal(data, golden) {
+		t.Errorf("%s:\n got %s\nwant %s", name, data, golden)
+	}
+}
+
+func readGolden(t *testing.T, name string) []byte {
+	t.Helper()
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "xml", name))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	return bytes.TrimRight(golden, "\r\n")
+}
+
+func TestMarshalEventXML(t *testing.T) {
+	event := xmlTestEvent(t)
+	checkGolden(t, "event_data.xml", event.MarshalEventXML())
+	checkGolden(t, "event_data_rendered.xml", event.MarshalRenderedEventXML("en-US"))
+
+	event.UserDataTemplate = true
+	event.UserDataName = "ConnectInfo"
+	event.UserDataNamespace = "http://schemas.example.com/sample"
+	checkGolden(t, "user_data.xml", event.MarshalEventXML())
+}
+
+func TestXMLValueString(t *testing.T) {
+	guid := winguid.MustParse("{A1B2C3D4-0000-1111-2222-333344445555}")
+
+	tests := []struct {
+		value Value
+		want  string
+	}{
+		{NewValue(uint32(0x3e7), winapi.TdhInTypeHexint32, 0), "0x3e7"},
+		{NewValue(uint32(0xc0000022), winapi.TdhInTypeUint32, winapi.TdhOutTypeNtstatus), "0xc0000022"},
+		{NewValue(uint64(0x7ffe0000), winapi.TdhInTypePointer, 0), "0x7ffe0000"},
+		{NewValue(int8(-1), winapi.TdhInTypeInt8, winapi.TdhOutTypeHexint8), "0xff"},
+		{NewValue(*guid, winapi.TdhInTypeGUID, 0), "{a1b2c3d4-0000-1111-2222-333344445555}"},
+		{NewValue([]byte{0xca, 0xfe}, winapi.TdhInTypeBinary, 0), "CAFE"},
+		{NewValue(uint32(1), winapi.TdhInTypeUint32, 0).WithName("Running"), "1"},
+		{NewValue(uint32(1000), winapi.TdhInTypeUint32, 0), "1000"},
+	}
+	for _, test := range tests {
+		if got := xmlValueString(test.value); got != test.want {
+			t.Errorf("xmlValueString(%v) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestUnmarshalEventXML(t *testing.T) {
+	golden := readGolden(t, "event_data_rendered.xml")
+	event, err := UnmarshalEventXML(golden)
+	if err != nil {
+		t.Fatalf("UnmarshalEventXML: %v", err)
+	}
+
+	system := event.System
+	if system.Provider.Name != "Test-Provider" || system.Provider.Guid != "{5770385f-c22a-43e0-bf4c-06f5698ffbd9}" {
+		t.Errorf("provider = %s %s", system.Provider.Name, system.Provider.Guid)
+	}
+	if system.EventID != 1 || system.Version != 2 || system.Level.Value != 4 || system.Task.Value != 7 || system.Opcode.Value != 1 {
+		t.Errorf("descriptor = %d/%d/%d/%d/%d", system.EventID, system.Version, system.Level.Value, system.Task.Value, system.Opcode.Value)
+	}
+	if system.Keywords.Value != 0x8000000000000001 || system.EventRecordID != 42 || system.Computer != "host.example.com" {
+		t.Errorf("keywords %#x, record %d, computer %s", system.Keywords.Value, system.EventRecordID, system.Computer)
+	}
+	if system.Correlation.ActivityID != "{11111111-2222-3333-4444-55555555AAAA}" || system.Execution.ProcessID != 1234 || system.Execution.ThreadID != 5678 {
+		t.Errorf("correlation %s, execution %d/%d", system.Correlation.ActivityID, system.Execution.ProcessID, system.Execution.ThreadID)
+	}
+	if system.Timestamp != 133000000000000000 || system.Channel != "Sample-Provider/Operational" {
+		t.Errorf("timestamp %d, channel %s", system.Timestamp, system.Channel)
+	}
+	if event.UserSID == nil || event.UserSID.String() != "S-1-5-18" {
+		t.Errorf("UserSID = %v", event.UserSID)
+	}
+
+	if event.Message != "C:\\Windows\\a.exe connected with status 0xC0000022" || system.Level.Name != "Information" ||
+		system.Task.Name != "Connect" || system.Opcode.Name != "Start" || system.Keywords.Name != "Network, Response Time" {
+		t.Errorf("rendering = %q %s %s %s %s", event.Message, system.Level.Name, system.Task.Name, system.Opcode.Name, system.Keywords.Name)
+	}
+
+	wantData := map[string]string{
+		"Image": "C:\\Windows\\a.exe", "LogonId": "0x3e7", "Status": "0xc0000022", "State": "1",
+		"Session": "{a1b2c3d4-0000-1111-2222-333344445555}", "Created": "2022-06-18T04:26:40.0000000Z", "Blob": "CAFE",
+	}
+	if !reflect.DeepEqual(event.EventData, wantData) {
+		t.Errorf("EventData = %v, want %v", event.EventData, wantData)
+	}
+	if ports := event.EventDataArrays["Ports"]; !reflect.DeepEqual(ports, []string{"80", "443"}) {
+		t.Errorf("Ports = %v", ports)
+	}
+	wantEndpoint := []map[string]string{{"Port": "443", "Address": "10.0.0.1"}}
+	if endpoint := event.EventDataStructs["Endpoint"]; !reflect.DeepEqual(endpoint, wantEndpoint) {
+		t.Errorf("Endpoint = %v, want %v", endpoint, wantEndpoint)
+	}
+
+	if again := event.MarshalRenderedEventXML("en-US"); !bytes.Equal(again, golden) {
+		t.Errorf("marshalled again:\n got %s\nwant %s", again, golden)
+	}
+}
+
+// TestUnmarshalEventXMLGUIDs checks that the GUIDs of System are read as written, so that the activity IDs of
+// a decoded event survive an XML round trip.
+func TestUnmarshalEventXMLGUIDs(t *testing.T) {
+	event := xmlTestEvent(t)
+	event.System.Correlation.RelatedActivityID = "{66666666-7777-8888-9999-AAAAAAAAAAAA}"
+	data := event.MarshalEventXML()
+	if !bytes.Contains(data, []byte("<Correlation ActivityID='{11111111-2222-3333-4444-55555555AAAA}' RelatedActivityID='{66666666-7777-8888-9999-AAAAAAAAAAAA}'/>")) {
+		t.Errorf("Correlation not in uppercase: %s", data)
+	}
+
+	decoded, err := UnmarshalEventXML(data)
+	if err != nil {
+		t.Fatalf("UnmarshalEventXML: %v", err)
+	}
+	if decoded.System.Correlation != event.System.Correlation {
+		t.Errorf("Correlation = %+v, want %+v", decoded.System.Correlation, event.System.Correlation)
+	}
+	if decoded.System.Provider.Guid != "{5770385f-c22a-43e0-bf4c-06f5698ffbd9}" {
+		t.Errorf("Provider.Guid = %s, want the lowercase GUID of the XML", decoded.System.Provider.Guid)
+	}
+
+	data = bytes.Replace(data, []byte("Guid='{5770385f-c22a-43e0-bf4c-06f5698ffbd9}'"), []byte("Guid='{5770385F-C22A-43e0-bf4c-06f5698ffbd9}'"), 1)
+	data = bytes.Replace(data, []byte("ActivityID='{11111111-2222-3333-4444-55555555AAAA}'"), []byte("ActivityID='{11111111-2222-3333-4444-55555555aaaa}'"), 1)
+	if decoded, err = UnmarshalEventXML(data); err != nil {
+		t.Fatalf("UnmarshalEventXML: %v", err)
+	}
+	if decoded.System.Provider.Guid != "{5770385F-C22A-43e0-bf4c-06f5698ffbd9}" || decoded.System.Correlation.ActivityID != "{11111111-2222-3333-4444-55555555aaaa}" {
+		t.Errorf("GUIDs = %s %s, want them as written", decoded.System.Provider.Guid, decoded.System.Correlation.ActivityID)
+	}
+}
+
+func TestUnmarshalEventXMLUserData(t *testing.T) {
+	golden := readGolden(t, "user_data.xml")
+	event, err := UnmarshalEventXML(golden)
+	if err != nil {
+		t.Fatalf("UnmarshalEventXML: %v", err)
+	}
+	if !event.UserDataTemplate || event.UserDataName != "ConnectInfo" || event.UserDataNamespace != "http://schemas.example.com/sample" {
+		t.Errorf("user data %v %s %s", event.UserDataTemplate, event.UserDataName, event.UserDataNamespace)
+	}
+	if event.EventData["Image"] != "C:\\Windows\\a.exe" || len(event.EventDataStructs["Endpoint"]) != 1 {
+		t.Errorf("EventData = %v, EventDataStructs = %v", event.EventData, event.EventDataStructs)
+	}
+	if again := event.MarshalEventXML(); !bytes.Equal(again, golden) {
+		t.Errorf("marshalled again:\n got %s\nwant %s", again, golden)
+	}
+}
+
+func TestUnmarshalEventXMLErrors(t *testing.T) {
+	golden := readGolden(t, "event_data.xml")
+
+	tests := []struct {
+		name string
+		old  string
+		new  string
+	}{
+		{"event id", "<EventID>1</EventID>", "<EventID>x</EventID>"},
+		{"level out of range", "<Level>4</Level>", "<Level>400</Level>"},
+		{"time created", "SystemTime='2022", "SystemTime='x2022"},
+		{"user id", "UserID='S-1-5-18'", "UserID='X-1'"},
+	}
+	for _, test := range tests {
+		data := bytes.Replace(golden, []byte(test.old), []byte(test.new), 1)
+		if _, err := UnmarshalEventXML(data); !errors.Is(err, ErrEventXML) {
+			t.Errorf("%s: err = %v, want %v", test.name, err, ErrEventXML)
+		}
+	}
+	if _, err := UnmarshalEventXML([]byte("<Event")); err == nil {
+		t.Error("truncated XML: no error")
+	}
+}
+
+// sysmon_process_create.xml is a Sysmon event written in the layout of wevtutil qe /f:xml, with the values
+// the encoder does not produce itself, such as an empty Correlation.
+func TestUnmarshalEventXMLWevtutil(t *testing.T) {
+	golden := readGolden(t, "sysmon_process_create.xml")
+	event, err := UnmarshalEventXML(golden)
+	if err != nil {
+		t.Fatalf("UnmarshalEventXML: %v", err)
+	}
+
+	if event.System.Provider.Name != "Microsoft-Windows-Sysmon" || event.System.Version != 5 || event.System.EventRecordID != 1042 {
+		t.Errorf("System = %+v", event.System)
+	}
+	if event.System.Correlation.ActivityID != zeroGUID || event.System.TimestampUTC.Nanosecond() != 123456700 {
+		t.Errorf("activity %s, time %v", event.System.Correlation.ActivityID, event.System.TimestampUTC)
+	}
+	if commandLine := event.EventData["CommandLine"]; commandLine != `cmd.exe /c "echo <a> & exit"` {
+		t.Errorf("CommandLine = %q", commandLine)
+	}
+	if event.EventData["LogonId"] != "0x3e7" || event.EventData["ProcessGuid"] != "{7d3c0c43-5a1b-65e1-0b00-000000002f00}" {
+		t.Errorf("EventData = %v", event.EventData)
+	}
+
+	if again := event.MarshalEventXML(); !bytes.Equal(again, golden) {
+		t.Errorf("marshalled again:\n got %s\nwant %s", again, golden)
+	}
+}
+
+// TestUnmarshalEventXMLCaptures reads the events exported by wevtutil under testdata/xml/wevtutil, see its readme.md.
+func TestUnmarshalEventXMLCaptures(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "xml", "wevtutil", "*.xml"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Skip("no wevtutil capture in testdata/xml/wevtutil")
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		for i, line := range bytes.Split(data, []byte("\n")) {
+			if line = bytes.TrimRight(line, "\r"); len(line) == 0 {
+				continue
+			}
+			event, err := UnmarshalEventXML(line)
+			if err != nil {
+				t.Errorf("%s:%d: UnmarshalEventXML: %v", path, i+1, err)
+				continue
+			}
+			if again := event.MarshalEventXML(); !bytes.Equal(again, line) {
+				t.Errorf("%s:%d: marshalled again:\n got %s\nwant %s", path, i+1, again, line)
+			}
+		}
+	}
+}
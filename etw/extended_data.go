@@ -0,0 +1,201 @@
+package etw
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/evntcons/ns-evntcons-event_header_extended_data_item
+// https://learn.microsoft.com/en-us/windows/win32/api/relogger/ns-relogger-event_extended_item_stack_trace64
+
+var (
+	ErrInvalidExtendedData = fmt.Errorf("invalid extended data item")
+)
+
+// ExtendedData holds the extended data items of an event. Fields of absent items keep their zero value,
+// except for TerminalSessionID, whose zero is a valid session.
+type ExtendedData struct {
+	RelatedActivityID winguid.GUID  // EVENT_HEADER_EXT_TYPE_RELATED_ACTIVITYID
+	UserSID           *SID          // EVENT_HEADER_EXT_TYPE_SID
+	TerminalSessionID *uint32       // EVENT_HEADER_EXT_TYPE_TS_ID
+	InstanceInfo      *InstanceInfo // EVENT_HEADER_EXT_TYPE_INSTANCE_INFO
+	StackTrace        *StackTrace   // EVENT_HEADER_EXT_TYPE_STACK_TRACE32 and EVENT_HEADER_EXT_TYPE_STACK_TRACE64
+	StackKey          *StackKey     // EVENT_HEADER_EXT_TYPE_STACK_KEY32 and EVENT_HEADER_EXT_TYPE_STACK_KEY64
+	PEBSIndex         uint64        // EVENT_HEADER_EXT_TYPE_PEBS_INDEX
+	PMCCounters       []uint64      // EVENT_HEADER_EXT_TYPE_PMC_COUNTERS
+	PSMKey            uint64        // EVENT_HEADER_EXT_TYPE_PSM_KEY
+	ProcessStartKey   uint64        // EVENT_HEADER_EXT_TYPE_PROCESS_START_KEY
+	EventKey          uint64        // EVENT_HEADER_EXT_TYPE_EVENT_KEY
+	QPCDelta          uint64        // EVENT_HEADER_EXT_TYPE_QPC_DELTA
+	ContainerID       winguid.GUID  // EVENT_HEADER_EXT_TYPE_CONTAINER_ID
+	ControlGUID       winguid.GUID  // EVENT_HEADER_EXT_TYPE_CONTROL_GUID
+
+	// Unknown keeps the items of other types, TraceLogging metadata excepted.
+	Unknown []ExtendedDataItem
+}
+
+// InstanceInfo is the counterpart of EVENT_EXTENDED_ITEM_INSTANCE, logged by TraceEventInstance.
+type InstanceInfo struct {
+	InstanceID       uint32
+	ParentInstanceID uint32
+	ParentGUID       winguid.GUID
+}
+
+// StackTrace is the call stack captured with the event, innermost frame first.
+// MatchID pairs the kernel and user parts of a stack split in two events, 0 for complete stacks.
+type StackTrace struct {
+	MatchID   uint64   `json:"match_id"`
+	Addresses []uint64 `json:"addresses"`
+}
+
+// StackKey refers to a stack logged once in a separate event, when stack caching is enabled.
+type StackKey struct {
+	MatchID uint64 `json:"match_id"`
+	Key     uint64 `json:"key"`
+}
+
+// DecodeExtendedData decodes items. Malformed items are skipped and reported by the returned error,
+// wrapping ErrInvalidExtendedData, the others are decoded regardless.
+func DecodeExtendedData(items []ExtendedDataItem) (ExtendedData, error) {
+	extendedData, decodeErrors := decodeExtendedData(items)
+	if len(decodeErrors) > 0 {
+		return extendedData, decodeErrors[len(decodeErrors)-1].Err
+	}
+	return extendedData, nil
+}
+
+func decodeExtendedData(items []ExtendedDataItem) (ExtendedData, []DecodeError) {
+	var extendedData ExtendedData
+	var decodeErrors []DecodeError
+
+	for _, item := range items {
+		if err := extendedData.decode(item); err != nil {
+			decodeErrors = append(decodeErrors, DecodeError{Err: fmt.Errorf("%w %d: %s", ErrInvalidExtendedData, item.ExtType, err)})
+		}
+	}
+
+	return extendedData, decodeErrors
+}
+
+func (x *ExtendedData) decode(item ExtendedDataItem) error {
+	data := item.Data
+
+	switch item.ExtType {
+	case winapi.EVENT_HEADER_EXT_TYPE_RELATED_ACTIVITYID:
+		return decodeGUIDItem(data, &x.RelatedActivityID)
+
+	case winapi.EVENT_HEADER_EXT_TYPE_SID:
+		sid, _, err := parseSID(data)
+		if err != nil {
+			return err
+		}
+		x.UserSID = &sid
+
+	case winapi.EVENT_HEADER_EXT_TYPE_TS_ID:
+		if len(data) < 4 {
+			return ErrTruncatedProperty
+		}
+		sessionID := binary.LittleEndian.Uint32(data)
+		x.TerminalSessionID = &sessionID
+
+	case winapi.EVENT_HEADER_EXT_TYPE_INSTANCE_INFO:
+		if len(data) < 24 {
+			return ErrTruncatedProperty
+		}
+		x.InstanceInfo = &InstanceInfo{
+			InstanceID:       binary.LittleEndian.Uint32(data),
+			ParentInstanceID: binary.LittleEndian.Uint32(data[4:]),
+			ParentGUID:       winguid.FromBytes(data[8:24]),
+		}
+
+	case winapi.EVENT_HEADER_EXT_TYPE_STACK_TRACE32, winapi.EVENT_HEADER_EXT_TYPE_STACK_TRACE64:
+		addressSize := 8
+		if item.ExtType == winapi.EVENT_HEADER_EXT_TYPE_STACK_TRACE32 {
+			addressSize = 4
+		}
+		if len(data) < 8 || (len(data)-8)%addressSize != 0 {
+			return ErrTruncatedProperty
+		}
+		stackTrace := StackTrace{
+			MatchID:   binary.LittleEndian.Uint64(data),
+			Addresses: make([]uint64, 0, (len(data)-8)/addressSize),
+		}
+		for offset := 8; offset < len(data); offset += addressSize {
+			if addressSize == 4 {
+				stackTrace.Addresses = append(stackTrace.Addresses, uint64(binary.LittleEndian.Uint32(data[offset:])))
+			} else {
+				stackTrace.Addresses = append(stackTrace.Addresses, binary.LittleEndian.Uint64(data[offset:]))
+			}
+		}
+		x.StackTrace = &stackTrace
+
+	case winapi.EVENT_HEADER_EXT_TYPE_STACK_KEY32, winapi.EVENT_HEADER_EXT_TYPE_STACK_KEY64:
+		if len(data) < 16 {
+			return ErrTruncatedProperty
+		}
+		stackKey := StackKey{MatchID: binary.LittleEndian.Uint64(data)}
+		if item.ExtType == winapi.EVENT_HEADER_EXT_TYPE_STACK_KEY32 {
+			stackKey.Key = uint64(binary.LittleEndian.Uint32(data[8:])) // followed by padding
+		} else {
+			stackKey.Key = binary.LittleEndian.Uint64(data[8:])
+		}
+		x.StackKey = &stackKey
+
+	case winapi.EVENT_HEADER_EXT_TYPE_PEBS_INDEX:
+		return decodeUint64Item(data, &x.PEBSIndex)
+
+	case winapi.EVENT_HEADER_EXT_TYPE_PMC_COUNTERS:
+		if len(data)%8 != 0 {
+			return ErrTruncatedProperty
+		}
+		x.PMCCounters = make([]uint64, len(data)/8)
+		for i := range x.PMCCounters {
+			x.PMCCounters[i] = binary.LittleEndian.Uint64(data[8*i:])
+		}
+
+	case winapi.EVENT_HEADER_EXT_TYPE_PSM_KEY:
+		return decodeUint64Item(data, &x.PSMKey)
+
+	case winapi.EVENT_HEADER_EXT_TYPE_PROCESS_START_KEY:
+		return decodeUint64Item(data, &x.ProcessStartKey)
+
+	case winapi.EVENT_HEADER_EXT_TYPE_EVENT_KEY:
+		return decodeUint64Item(data, &x.EventKey)
+
+	case winapi.EVENT_HEADER_EXT_TYPE_QPC_DELTA:
+		return decodeUint64Item(data, &x.QPCDelta)
+
+	case winapi.EVENT_HEADER_EXT_TYPE_CONTAINER_ID:
+		return decodeGUIDItem(data, &x.ContainerID)
+
+	case winapi.EVENT_HEADER_EXT_TYPE_CONTROL_GUID:
+		return decodeGUIDItem(data, &x.ControlGUID)
+
+	case winapi.EVENT_HEADER_EXT_TYPE_EVENT_SCHEMA_TL, winapi.EVENT_HEADER_EXT_TYPE_PROV_TRAITS:
+		// decoded with the user data, see TraceLoggingBackend
+
+	default:
+		x.Unknown = append(x.Unknown, ExtendedDataItem{ExtType: item.ExtType, Data: copyBytes(data)})
+	}
+
+	return nil
+}
+
+func decodeGUIDItem(data []byte, guid *winguid.GUID) error {
+	if len(data) < 16 {
+		return ErrTruncatedProperty
+	}
+	*guid = winguid.FromBytes(data)
+	return nil
+}
+
+func decodeUint64Item(data []byte, value *uint64) error {
+	if len(data) < 8 {
+		return ErrTruncatedProperty
+	}
+	*value = binary.LittleEndian.Uint64(data)
+	return nil
+}
@@ -0,0 +1,125 @@
+package etw
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+var (
+	relatedActivityGUID = *winguid.MustParse("{11111111-2222-3333-4444-555555555555}")
+	containerGUID       = *winguid.MustParse("{66666666-7777-8888-9999-AAAAAAAAAAAA}")
+)
+
+func TestDecodeExtendedData(t *testing.T) {
+	items := []ExtendedDataItem{
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_RELATED_ACTIVITYID, Data: winguid.ToBytes(&relatedActivityGUID)},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_SID, Data: systemSID},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_TS_ID, Data: userData(uint32(0))},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_INSTANCE_INFO, Data: userData(uint32(7), uint32(3), winguid.ToBytes(&containerGUID))},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_STACK_TRACE32, Data: userData(uint64(9), uint32(0x401000), uint32(0x77001234))},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_STACK_KEY64, Data: userData(uint64(9), uint64(0xabcdef))},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_PEBS_INDEX, Data: userData(uint64(1))},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_PMC_COUNTERS, Data: userData(uint64(100), uint64(200))},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_PSM_KEY, Data: userData(uint64(2))},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_EVENT_KEY, Data: userData(uint64(3))},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_PROCESS_START_KEY, Data: userData(uint64(4))},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_QPC_DELTA, Data: userData(uint64(5))},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_CONTAINER_ID, Data: winguid.ToBytes(&containerGUID)},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_CONTROL_GUID, Data: winguid.ToBytes(&testProviderGUID)},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_EVENT_SCHEMA_TL, Data: []byte{2, 0}},
+		{ExtType: 0x0100, Data: []byte{1, 2}},
+	}
+
+	extendedData, err := DecodeExtendedData(items)
+	if err != nil {
+		t.Fatalf("DecodeExtendedData: %v", err)
+	}
+
+	sessionID := uint32(0)
+	want := ExtendedData{
+		RelatedActivityID: relatedActivityGUID,
+		TerminalSessionID: &sessionID,
+		InstanceInfo:      &InstanceInfo{InstanceID: 7, ParentInstanceID: 3, ParentGUID: containerGUID},
+		StackTrace:        &StackTrace{MatchID: 9, Addresses: []uint64{0x401000, 0x77001234}},
+		StackKey:          &StackKey{MatchID: 9, Key: 0xabcdef},
+		PEBSIndex:         1,
+		PMCCounters:       []uint64{100, 200},
+		PSMKey:            2,
+		EventKey:          3,
+		ProcessStartKey:   4,
+		QPCDelta:          5,
+		ContainerID:       containerGUID,
+		ControlGUID:       testProviderGUID,
+		Unknown:           []ExtendedDataItem{{ExtType: 0x0100, Data: []byte{1, 2}}},
+	}
+	if extendedData.UserSID == nil || extendedData.UserSID.String() != "S-1-5-18" {
+		t.Errorf("UserSID = %v, want S-1-5-18", extendedData.UserSID)
+	}
+	extendedData.UserSID = nil
+	if !reflect.DeepEqual(extendedData, want) {
+		t.Errorf("extended data =\n%+v\nwant\n%+v", extendedData, want)
+	}
+}
+
+func TestDecodeExtendedDataStackVariants(t *testing.T) {
+	extendedData, err := DecodeExtendedData([]ExtendedDataItem{
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_STACK_TRACE64, Data: userData(uint64(0), uint64(0xfffff80012345678))},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_STACK_KEY32, Data: userData(uint64(1), uint32(0x1234), uint32(0xcccccccc))},
+	})
+	if err != nil {
+		t.Fatalf("DecodeExtendedData: %v", err)
+	}
+	if !reflect.DeepEqual(extendedData.StackTrace, &StackTrace{Addresses: []uint64{0xfffff80012345678}}) {
+		t.Errorf("StackTrace = %+v", extendedData.StackTrace)
+	}
+	if *extendedData.StackKey != (StackKey{MatchID: 1, Key: 0x1234}) {
+		t.Errorf("StackKey = %+v, the padding is not part of the key", extendedData.StackKey)
+	}
+}
+
+func TestDecodeExtendedDataMalformed(t *testing.T) {
+	tests := []ExtendedDataItem{
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_RELATED_ACTIVITYID, Data: make([]byte, 15)},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_SID, Data: []byte{1, 5}},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_TS_ID, Data: []byte{1}},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_INSTANCE_INFO, Data: make([]byte, 23)},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_STACK_TRACE64, Data: make([]byte, 12)},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_STACK_KEY64, Data: make([]byte, 8)},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_PMC_COUNTERS, Data: make([]byte, 9)},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_QPC_DELTA, Data: make([]byte, 7)},
+	}
+
+	for _, item := range tests {
+		valid := ExtendedDataItem{ExtType: winapi.EVENT_HEADER_EXT_TYPE_PSM_KEY, Data: userData(uint64(42))}
+		extendedData, err := DecodeExtendedData([]ExtendedDataItem{item, valid})
+		if !errors.Is(err, ErrInvalidExtendedData) {
+			t.Errorf("item %d: err = %v, want %v", item.ExtType, err, ErrInvalidExtendedData)
+		}
+		if extendedData.PSMKey != 42 {
+			t.Errorf("item %d: the valid items are not decoded", item.ExtType)
+		}
+	}
+}
+
+func TestDecodeRecordExtendedData(t *testing.T) {
+	record := testRecord(nil)
+	record.ExtendedData = []ExtendedDataItem{
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_TS_ID, Data: userData(uint32(2))},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_STACK_TRACE64, Data: []byte{1}},
+	}
+
+	event, err := DecodeRecord(record, NewSchemaBackend(testSchema(-1)))
+	if !errors.Is(err, ErrInvalidExtendedData) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidExtendedData)
+	}
+	if event.TerminalSessionID == nil || *event.TerminalSessionID != 2 || event.StackTrace != nil {
+		t.Errorf("session %v, stack %v", event.TerminalSessionID, event.StackTrace)
+	}
+	if len(event.DecodeErrors) != 1 || !errors.Is(event.DecodeErrors[0].Err, ErrInvalidExtendedData) {
+		t.Errorf("DecodeErrors = %v", event.DecodeErrors)
+	}
+}
@@ -0,0 +1,190 @@
+package etw
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+var (
+	ErrPropertyNotFound = fmt.Errorf("property not found")
+)
+
+// LazyEvent is an event decoded on demand: it carries a copy of the record and its schema, and decodes
+// the properties when first accessed, by Get, Range or Event. Decoded properties are kept, and since properties
+// are laid out one after the other, accessing one decodes those preceding it as well.
+// Filtering on the header costs no decoding. Properties the pure-Go decoder does not handle can not be formatted
+// by the TdhBackend out of the event callback, and fail to decode.
+// LazyEvent is safe for concurrent use.
+type LazyEvent struct {
+	record    *Record
+	schema    *Schema // nil when schemaErr is set
+	schemaErr error
+	policy    DecodePolicy
+
+	mutex  sync.Mutex
+	parser *EventRecordParser
+	event  *Event // built by Event
+	err    error
+}
+
+// NewLazyEvent resolves the schema of record with backend, the only work done before properties are accessed,
+// and copies record. It fails when record has no schema, as DecodeRecord does.
+func NewLazyEvent(record *Record, backend DecoderBackend) (*LazyEvent, error) {
+	return newLazyEvent(record, backend, DecodePartial, false)
+}
+
+// NewLazyEventWith returns a LazyEvent whose Event applies policy, as DecodeRecordWith does. Records without schema
+// are kept unless policy drops them: their properties fail with the schema error, and Event returns their header.
+// It returns a nil event when the policy drops it, and the schema error in every case.
+func NewLazyEventWith(record *Record, backend DecoderBackend, policy DecodePolicy) (*LazyEvent, error) {
+	return newLazyEvent(record, backend, policy, policy != DecodeDrop)
+}
+
+func newLazyEvent(record *Record, backend DecoderBackend, policy DecodePolicy, keepUnresolved bool) (*LazyEvent, error) {
+	// the schema is resolved from record itself: TdhBackend needs the EVENT_RECORD, which the copy lacks
+	parser, err := newEventParser(record, backend)
+	if err != nil && !keepUnresolved {
+		return nil, err
+	}
+
+	copied := record.Copy()
+	parser.Record = copied
+	lazyEvent := &LazyEvent{record: copied, schema: parser.Schema, policy: policy, parser: parser}
+	if err != nil {
+		lazyEvent.schema, lazyEvent.schemaErr = nil, err
+	}
+	return lazyEvent, err
+}
+
+// Record returns the copied record, which must not be modified.
+func (l *LazyEvent) Record() *Record {
+	return l.record
+}
+
+// Schema returns the schema of the event, nil when it has none, see SchemaErr.
+func (l *LazyEvent) Schema() *Schema {
+	return l.schema
+}
+
+// SchemaErr returns the error that left the event without schema, nil for events with a schema.
+func (l *LazyEvent) SchemaErr() error {
+	return l.schemaErr
+}
+
+func (l *LazyEvent) Header() *winapi.EventHeader {
+	return &l.record.EventHeader
+}
+
+func (l *LazyEvent) EventID() uint16 {
+	if l.schema == nil {
+		return l.record.EventHeader.EventDescriptor.Id
+	}
+	return l.schema.EventID()
+}
+
+func (l *LazyEvent) ProviderGUID() winguid.GUID {
+	if l.schema == nil {
+		return l.record.EventHeader.ProviderId
+	}
+	return l.schema.ProviderGUID
+}
+
+func (l *LazyEvent) ProcessID() uint32 {
+	return l.record.EventHeader.ProcessId
+}
+
+func (l *LazyEvent) TimestampUTC() time.Time {
+	return winapi.FiletimeToTime(l.record.EventHeader.TimeStamp)
+}
+
+// Get returns the top level property called name, decoding it if need be. It returns ErrPropertyNotFound
+// when the schema has no such property, its failure, or the failure that stopped the decoding before it.
+func (l *LazyEvent) Get(name string) (Value, error) {
+	if l.schema == nil {
+		return Value{}, l.schemaErr
+	}
+
+	index := -1
+	for i := 0; i < l.schema.TopLevelPropertyCount; i++ {
+		if l.schema.Properties[i].Name == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return Value{}, fmt.Errorf("%w: %s", ErrPropertyNotFound, name)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if err := l.parser.parseUntil(index); err != nil {
+		return Value{}, err
+	}
+	return l.parser.Properties[index].Value, nil
+}
+
+// Range calls f with the top level properties in schema order, decoding each before its call, until f returns false.
+// Properties that fail are skipped. It returns the first failure, if any.
+func (l *LazyEvent) Range(f func(property Property) bool) error {
+	if l.schema == nil {
+		return l.schemaErr
+	}
+
+	var firstErr error
+	for i := 0; i < l.schema.TopLevelPropertyCount; i++ {
+		l.mutex.Lock()
+		err := l.parser.parseUntil(i)
+		var property Property
+		if err == nil {
+			property = l.parser.Properties[i]
+		}
+		stopped := i >= len(l.parser.Properties)
+		l.mutex.Unlock()
+
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if stopped {
+				return firstErr
+			}
+			continue
+		}
+		if !f(property) {
+			return firstErr
+		}
+	}
+	return firstErr
+}
+
+// Event decodes the whole event, as DecodeRecordWith does with the policy of the LazyEvent:
+// failed events are returned partially decoded by default, nil when the policy drops them.
+func (l *LazyEvent) Event() (*Event, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.event == nil && l.err == nil {
+		var event *Event
+		err := l.schemaErr
+		if l.schema != nil {
+			event, err = l.parser.buildEvent()
+		}
+		l.event, l.err = applyDecodePolicy(l.record, event, err, l.policy)
+	}
+	return l.event, l.err
+}
+
+// MarshalJSON marshals the decoded Event.
+func (l *LazyEvent) MarshalJSON() ([]byte, error) {
+	event, err := l.Event()
+	if event == nil {
+		return nil, err
+	}
+	return json.Marshal(event)
+}
@@ -0,0 +1,185 @@
+package etw
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+// recordBackend only resolves the schema of one record, as TdhBackend only resolves records
+// carrying the EVENT_RECORD they come from.
+type recordBackend struct {
+	*SchemaBackend
+	record *Record
+}
+
+func (r recordBackend) EventSchema(record *Record) (*Schema, error) {
+	if record != r.record {
+		return nil, ErrSchemaNotFound
+	}
+	return r.SchemaBackend.EventSchema(record)
+}
+
+// lazySchema describes the Image, Size and Status properties of event 1.
+func lazySchema() *Schema {
+	return testSchema(-1,
+		PropertyInfo{Name: "Image", InType: winapi.TdhInTypeUnicodestring},
+		PropertyInfo{Name: "Size", InType: winapi.TdhInTypeUint32},
+		PropertyInfo{Name: "Status", InType: winapi.TdhInTypeUint32, OutType: winapi.TdhOutTypeNtstatus},
+	)
+}
+
+func TestLazyEventResolvesBeforeCopy(t *testing.T) {
+	record := testRecord(userData(utf16z("cmd.exe"), uint32(2), uint32(0)))
+	backend := recordBackend{SchemaBackend: NewSchemaBackend(lazySchema()), record: record}
+
+	lazyEvent, err := NewLazyEvent(record, backend)
+	if err != nil {
+		t.Fatalf("NewLazyEvent: %v", err)
+	}
+	if lazyEvent.Record() == record {
+		t.Error("the record is not copied")
+	}
+	record.UserData[0] = 'X' // the caller's buffer is reused after the callback
+
+	if image, err := lazyEvent.Get("Image"); err != nil || image.String() != "cmd.exe" {
+		t.Errorf("Get(Image) = %q, %v", image.String(), err)
+	}
+}
+
+func TestLazyEvent(t *testing.T) {
+	record := testRecord(userData(utf16z("cmd.exe"), uint32(2), uint32(0xc0000022)))
+	lazyEvent, err := NewLazyEvent(record, NewSchemaBackend(lazySchema()))
+	if err != nil {
+		t.Fatalf("NewLazyEvent: %v", err)
+	}
+
+	if lazyEvent.EventID() != 1 || lazyEvent.ProviderGUID() != testProviderGUID || lazyEvent.ProcessID() != 1234 {
+		t.Errorf("header = %d %v %d", lazyEvent.EventID(), lazyEvent.ProviderGUID(), lazyEvent.ProcessID())
+	}
+	if len(lazyEvent.parser.Properties) != 0 {
+		t.Errorf("%d properties decoded before access", len(lazyEvent.parser.Properties))
+	}
+
+	size, err := lazyEvent.Get("Size")
+	if err != nil || size.Uint() != 2 {
+		t.Errorf("Get(Size) = %v, %v", size, err)
+	}
+	if len(lazyEvent.parser.Properties) != 2 {
+		t.Errorf("%d properties decoded, want those up to Size", len(lazyEvent.parser.Properties))
+	}
+	if _, err = lazyEvent.Get("Missing"); !errors.Is(err, ErrPropertyNotFound) {
+		t.Errorf("Get(Missing) err = %v, want %v", err, ErrPropertyNotFound)
+	}
+
+	var names []string
+	err = lazyEvent.Range(func(property Property) bool {
+		names = append(names, property.Name)
+		return property.Name != "Size"
+	})
+	if err != nil || !reflect.DeepEqual(names, []string{"Image", "Size"}) {
+		t.Errorf("Range = %v, %v", names, err)
+	}
+
+	event, err := lazyEvent.Event()
+	if err != nil || event.EventData["Status"] != "0xC0000022" {
+		t.Fatalf("Event = %v, %v", event, err)
+	}
+	if again, _ := lazyEvent.Event(); again != event {
+		t.Error("the event is decoded again")
+	}
+}
+
+func TestLazyEventDecodeFailure(t *testing.T) {
+	record := testRecord(userData(utf16z("cmd.exe"), []byte{1}))
+
+	tests := []struct {
+		policy     DecodePolicy
+		event      bool
+		properties int
+	}{
+		{DecodePartial, true, 1},
+		{DecodeRaw, true, 0},
+		{DecodeDrop, false, 0},
+	}
+
+	for _, test := range tests {
+		lazyEvent, err := NewLazyEventWith(record, NewSchemaBackend(lazySchema()), test.policy)
+		if err != nil {
+			t.Fatalf("policy %d: NewLazyEventWith: %v", test.policy, err)
+		}
+		if _, err = lazyEvent.Get("Status"); !errors.Is(err, ErrTruncatedProperty) {
+			t.Errorf("policy %d: Get(Status) err = %v, want %v", test.policy, err, ErrTruncatedProperty)
+		}
+
+		event, err := lazyEvent.Event()
+		if !errors.Is(err, ErrTruncatedProperty) || (event != nil) != test.event {
+			t.Errorf("policy %d: Event = %v, %v", test.policy, event, err)
+			continue
+		}
+		if event != nil && len(event.Properties) != test.properties {
+			t.Errorf("policy %d: %d properties, want %d", test.policy, len(event.Properties), test.properties)
+		}
+	}
+}
+
+func TestLazyEventWithoutSchema(t *testing.T) {
+	record := testRecord([]byte{1, 2})
+
+	if lazyEvent, err := NewLazyEvent(record, NewSchemaBackend()); lazyEvent != nil || !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("NewLazyEvent = %v, %v, want %v", lazyEvent, err, ErrSchemaNotFound)
+	}
+	if lazyEvent, err := NewLazyEventWith(record, NewSchemaBackend(), DecodeDrop); lazyEvent != nil || !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("dropped, NewLazyEventWith = %v, %v, want %v", lazyEvent, err, ErrSchemaNotFound)
+	}
+
+	lazyEvent, err := NewLazyEventWith(record, NewSchemaBackend(), DecodeRaw)
+	if lazyEvent == nil || !errors.Is(err, ErrSchemaNotFound) {
+		t.Fatalf("NewLazyEventWith = %v, %v", lazyEvent, err)
+	}
+	if lazyEvent.Schema() != nil || lazyEvent.EventID() != 1 || lazyEvent.ProviderGUID() != testProviderGUID {
+		t.Errorf("header of an event without schema = %d %v", lazyEvent.EventID(), lazyEvent.ProviderGUID())
+	}
+	if _, err = lazyEvent.Get("Image"); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("Get err = %v, want %v", err, ErrSchemaNotFound)
+	}
+	if err = lazyEvent.Range(func(Property) bool { return true }); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("Range err = %v, want %v", err, ErrSchemaNotFound)
+	}
+
+	event, err := lazyEvent.Event()
+	if event == nil || !errors.Is(err, ErrSchemaNotFound) || !reflect.DeepEqual(event.UserData, []byte{1, 2}) || event.System.EventID != 1 {
+		t.Errorf("Event = %+v, %v, want the raw event", event, err)
+	}
+}
+
+func TestLazyEventSkipsFailures(t *testing.T) {
+	schema := testSchema(-1,
+		PropertyInfo{Name: "Image", InType: winapi.TdhInTypeUnicodestring},
+		PropertyInfo{Name: "Unknown", InType: unknownInType, Length: 4},
+		PropertyInfo{Name: "Size", InType: winapi.TdhInTypeUint32},
+	)
+	record := testRecord(userData(utf16z("cmd.exe"), uint32(0), uint32(2)))
+	lazyEvent, err := NewLazyEvent(record, NewSchemaBackend(schema))
+	if err != nil {
+		t.Fatalf("NewLazyEvent: %v", err)
+	}
+
+	if _, err = lazyEvent.Get("Unknown"); !errors.Is(err, ErrUnsupportedInType) {
+		t.Errorf("Get(Unknown) err = %v, want %v", err, ErrUnsupportedInType)
+	}
+	if size, err := lazyEvent.Get("Size"); err != nil || size.Uint() != 2 {
+		t.Errorf("Get(Size) = %v, %v", size, err)
+	}
+
+	var names []string
+	err = lazyEvent.Range(func(property Property) bool {
+		names = append(names, property.Name)
+		return true
+	})
+	if !errors.Is(err, ErrUnsupportedInType) || !reflect.DeepEqual(names, []string{"Image", "Size"}) {
+		t.Errorf("Range = %v, %v", names, err)
+	}
+}
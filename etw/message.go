@@ -0,0 +1,98 @@
+package etw
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventmanifestschema-messagetype-complextype
+// https://learn.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-formatmessage#remarks
+
+const maxMessageInsertion = 99
+
+// FormatMessage renders the EventMessage of the schema with properties, the top level properties of an event
+// in schema order, as Event Viewer does:
+//   - %1 to %99 insert the nth property, with its value map applied,
+//   - %n!format! formats it with a printf specification, see Value.Format,
+//   - %n, %t, %r and %b are a line break, a tab, a carriage return and a space,
+//   - %%, %., %! and "% " escape their second character, and %0 ends the message.
+//
+// Insertions without property are left as they are. WPP messages, whose arguments are numbered from 10,
+// are not rendered: see the wpp package.
+func (s *Schema) FormatMessage(properties []Property) string {
+	message := s.EventMessage
+	if s.DecodingSource == winapi.DecodingSourceWPP {
+		return ""
+	}
+	if strings.IndexByte(message, '%') < 0 {
+		return message
+	}
+
+	var builder strings.Builder
+	builder.Grow(len(message))
+
+	for i := 0; i < len(message); i++ {
+		if message[i] != '%' || i+1 == len(message) {
+			builder.WriteByte(message[i])
+			continue
+		}
+
+		next := message[i+1]
+		if next < '0' || next > '9' {
+			switch next {
+			case 'n':
+				builder.WriteString("\r\n")
+			case 't':
+				builder.WriteByte('\t')
+			case 'r':
+				builder.WriteByte('\r')
+			case 'b':
+				builder.WriteByte(' ')
+			case '%', '.', '!', ' ':
+				builder.WriteByte(next)
+			default:
+				builder.WriteByte('%')
+				builder.WriteByte(next)
+			}
+			i++
+			continue
+		}
+
+		end := i + 1
+		for end < len(message) && end < i+3 && message[end] >= '0' && message[end] <= '9' {
+			end++
+		}
+		index, _ := strconv.Atoi(message[i+1 : end])
+		if index == 0 {
+			break
+		}
+
+		spec := ""
+		if end < len(message) && message[end] == '!' {
+			if closing := strings.IndexByte(message[end+1:], '!'); closing >= 0 {
+				spec = message[end+1 : end+1+closing]
+				end += closing + 2
+			}
+		}
+
+		if index > len(properties) || index > maxMessageInsertion {
+			builder.WriteString(message[i:end])
+		} else {
+			builder.WriteString(insertion(properties[index-1], spec))
+		}
+		i = end - 1
+	}
+
+	return builder.String()
+}
+
+// insertion renders property, with the name its value map gives it, if any.
+func insertion(property Property, spec string) string {
+	value := property.Value
+	if value.Name() != "" {
+		return value.Name()
+	}
+	return value.Format(spec)
+}
@@ -0,0 +1,66 @@
+package etw
+
+import (
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+func TestFormatMessage(t *testing.T) {
+	properties := []Property{
+		{Name: "Image", Value: NewValue("cmd.exe", winapi.TdhInTypeUnicodestring, winapi.TdhOutTypeString)},
+		{Name: "Status", Value: NewValue(uint32(0xc0000022), winapi.TdhInTypeUint32, winapi.TdhOutTypeNtstatus)},
+		{Name: "State", Value: NewValue(uint32(1), winapi.TdhInTypeUint32, winapi.TdhOutTypeNull).WithName("Running")},
+		{Name: "Count", Value: NewValue(uint16(42), winapi.TdhInTypeUint16, winapi.TdhOutTypeNull)},
+	}
+
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{"plain", "Process started", "Process started"},
+		{"insertions", "%1 failed with %2", "cmd.exe failed with 0xC0000022"},
+		{"value map name", "State is %3", "State is Running"},
+		{"reordered", "%4 of %1", "42 of cmd.exe"},
+		{"two digits", "%01 and %04", "cmd.exe and 42"},
+		{"followed by digit", "%4%4", "4242"},
+		{"printf specification", "%4!04x! %2!08X! %1!-8s!|", "002a C0000022 cmd.exe |"},
+		{"unterminated specification", "%4!x", "42!x"},
+		{"missing property", "%1 %5 %99", "cmd.exe %5 %99"},
+		{"escapes", "100%% %. %! %b", "100% . !  "},
+		{"control characters", "a%nb%tc%rd", "a\r\nb\tc\rd"},
+		{"unknown escape", "%q", "%q"},
+		{"trailing percent", "end %", "end %"},
+		{"end of message", "kept%0dropped", "kept"},
+	}
+
+	for _, test := range tests {
+		schema := &Schema{EventMessage: test.message}
+		if message := schema.FormatMessage(properties); message != test.want {
+			t.Errorf("%s: FormatMessage(%q) = %q, want %q", test.name, test.message, message, test.want)
+		}
+	}
+
+	wpp := &Schema{EventMessage: "%10!s!", DecodingSource: winapi.DecodingSourceWPP}
+	if message := wpp.FormatMessage(properties); message != "" {
+		t.Errorf("WPP message = %q, want it left to the wpp package", message)
+	}
+}
+
+func TestDecodeRecordMessage(t *testing.T) {
+	schema := testSchema(-1,
+		PropertyInfo{Name: "Image", InType: winapi.TdhInTypeUnicodestring},
+		PropertyInfo{Name: "State", InType: winapi.TdhInTypeUint32, MapName: "StateMap"},
+	)
+	schema.Maps = map[string]*ValueMap{"StateMap": {Name: "StateMap", Flag: winapi.EVENTMAP_INFO_FLAG_MANIFEST_VALUEMAP, Entries: []ValueMapEntry{{Value: 1, Output: "Running"}}}}
+	schema.EventMessage = "%1 is %2%n"
+
+	event, err := DecodeRecord(testRecord(userData(utf16z("cmd.exe"), uint32(1))), NewSchemaBackend(schema))
+	if err != nil {
+		t.Fatalf("DecodeRecord: %v", err)
+	}
+	if event.Message != "cmd.exe is Running\r\n" {
+		t.Errorf("Message = %q", event.Message)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package etw
+
+// Without TDH, records and schemas never originate from native structures.
+type (
+	nativeRecord   = struct{}
+	nativeSchema   = struct{}
+	nativeValueMap = struct{}
+)
@@ -0,0 +1,50 @@
+package etw
+
+import (
+	"unsafe"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+// Records and schemas decoded through TDH keep the structures they were built from, TDH functions need them.
+type (
+	nativeRecord   = *winapi.EventRecord
+	nativeSchema   = *winapi.TraceEventInfo
+	nativeValueMap = *winapi.EventMapInfo
+)
+
+// newRecord views eventRecord as a Record, without copying the user and extended data.
+// Unreadable extended data items are left out.
+func newRecord(eventRecord *winapi.EventRecord) *Record {
+	record := Record{
+		EventHeader:   eventRecord.EventHeader,
+		BufferContext: eventRecord.BufferContext,
+		UserData:      nativeBytes(eventRecord.UserData, eventRecord.UserDataLength),
+		native:        eventRecord,
+	}
+
+	if eventRecord.ExtendedDataCount > 0 {
+		record.ExtendedData = make([]ExtendedDataItem, 0, eventRecord.ExtendedDataCount)
+		for i := uint16(0); i < eventRecord.ExtendedDataCount; i++ {
+			item, err := eventRecord.ExtendedDataItem(i)
+			if err != nil {
+				break
+			}
+			record.ExtendedData = append(record.ExtendedData, ExtendedDataItem{
+				ExtType: item.ExtType,
+				Data:    nativeBytes(item.DataPtr, item.DataSize),
+			})
+		}
+	}
+
+	return &record
+}
+
+// nativeBytes views memory owned by ETW, it is only valid during the event callback.
+func nativeBytes(pointer uintptr, length uint16) []byte {
+	if pointer == 0 || length == 0 {
+		return nil
+	}
+	data := *(*unsafe.Pointer)(unsafe.Pointer(&pointer)) // not a Go heap pointer
+	return unsafe.Slice((*byte)(data), length)
+}
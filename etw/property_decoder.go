@@ -0,0 +1,428 @@
+package etw
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/netip"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/tdh/ne-tdh-_tdh_in_type
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventmanifestschema-inputtype-complextype
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventmanifestschema-outputtype-complextype
+
+var (
+	ErrUnsupportedInType = fmt.Errorf("unsupported property in type")
+	ErrTruncatedProperty = fmt.Errorf("truncated property data")
+)
+
+// TimeLayout is used to render FILETIME and SYSTEMTIME values, with the 100ns precision of a FILETIME.
+const TimeLayout = "2006-01-02T15:04:05.0000000Z"
+
+// https://learn.microsoft.com/en-us/windows/win32/api/ws2def/ns-ws2def-sockaddr_in
+// https://learn.microsoft.com/en-us/windows/win32/api/ws2ipdef/ns-ws2ipdef-sockaddr_in6_lh
+const (
+	addressFamilyInet  = 2
+	addressFamilyInet6 = 23
+
+	sockaddrInSize  = 16
+	sockaddrIn6Size = 28
+
+	ipv6AddressSize = 16
+)
+
+// PropertyDecoder reads raw event user data according to the TDH in and out types of a property,
+// without going through TdhFormatProperty.
+type PropertyDecoder struct {
+	PointerSize uint32 // 4 or 8, see winapi.EventRecord.PointerSize
+}
+
+// Decode returns the value of the property starting at data[0] and the number of bytes it occupies.
+// length is the property length from its EVENT_PROPERTY_INFO, or the value of its length property:
+// a byte count for binary data, a character count for strings, 0 when unspecified.
+func (d PropertyDecoder) Decode(data []byte, inType winapi.TdhInType, outType winapi.TdhOutType, length uint32) (Value, int, error) {
+	raw, size, err := d.decode(data, inType, outType, length)
+	if err != nil {
+		return Value{}, 0, err
+	}
+	return NewValue(raw, inType, outType), size, nil
+}
+
+func (d PropertyDecoder) decode(data []byte, inType winapi.TdhInType, outType winapi.TdhOutType, length uint32) (interface{}, int, error) {
+	switch inType {
+	case winapi.TdhInTypeNull:
+		return nil, 0, nil
+
+	case winapi.TdhInTypeUnicodestring:
+		return decodeUTF16String(data, length)
+
+	case winapi.TdhInTypeAnsistring:
+		return decodeAnsiString(data, length)
+
+	case winapi.TdhInTypeInt8:
+		if len(data) < 1 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		if outType == winapi.TdhOutTypeString {
+			return string(rune(data[0])), 1, nil
+		}
+		return int8(data[0]), 1, nil
+
+	case winapi.TdhInTypeUint8:
+		if len(data) < 1 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		switch outType {
+		case winapi.TdhOutTypeBoolean:
+			return data[0] != 0, 1, nil
+		case winapi.TdhOutTypeString:
+			return string(rune(data[0])), 1, nil
+		}
+		return data[0], 1, nil
+
+	case winapi.TdhInTypeInt16:
+		if len(data) < 2 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return int16(binary.LittleEndian.Uint16(data)), 2, nil
+
+	case winapi.TdhInTypeUint16:
+		if len(data) < 2 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		switch outType {
+		case winapi.TdhOutTypePort:
+			return binary.BigEndian.Uint16(data), 2, nil // network byte order
+		case winapi.TdhOutTypeString:
+			return string(utf16.Decode([]uint16{binary.LittleEndian.Uint16(data)})), 2, nil
+		}
+		return binary.LittleEndian.Uint16(data), 2, nil
+
+	case winapi.TdhInTypeInt32:
+		if len(data) < 4 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return int32(binary.LittleEndian.Uint32(data)), 4, nil
+
+	case winapi.TdhInTypeUint32, winapi.TdhInTypeHexint32:
+		if len(data) < 4 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		switch outType {
+		case winapi.TdhOutTypeIpv4:
+			return netip.AddrFrom4([4]byte{data[0], data[1], data[2], data[3]}), 4, nil
+		case winapi.TdhOutTypeBoolean:
+			return binary.LittleEndian.Uint32(data) != 0, 4, nil
+		}
+		return binary.LittleEndian.Uint32(data), 4, nil
+
+	case winapi.TdhInTypeInt64:
+		if len(data) < 8 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return int64(binary.LittleEndian.Uint64(data)), 8, nil
+
+	case winapi.TdhInTypeUint64, winapi.TdhInTypeHexint64:
+		if len(data) < 8 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return binary.LittleEndian.Uint64(data), 8, nil
+
+	case winapi.TdhInTypeFloat:
+		if len(data) < 4 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(data)), 4, nil
+
+	case winapi.TdhInTypeDouble:
+		if len(data) < 8 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), 8, nil
+
+	case winapi.TdhInTypeBoolean:
+		if len(data) < 4 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return binary.LittleEndian.Uint32(data) != 0, 4, nil // BOOL
+
+	case winapi.TdhInTypeBinary:
+		size := int(length)
+		if size == 0 && outType == winapi.TdhOutTypeIpv6 {
+			size = ipv6AddressSize
+		}
+		if len(data) < size {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return decodeBinary(data[:size], outType), size, nil
+
+	case winapi.TdhInTypeGUID:
+		if len(data) < 16 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return winguid.FromBytes(data), 16, nil
+
+	case winapi.TdhInTypePointer, winapi.TdhInTypeSizet:
+		return d.decodePointer(data)
+
+	case winapi.TdhInTypeFiletime:
+		if len(data) < 8 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return winapi.FiletimeToTime(int64(binary.LittleEndian.Uint64(data))), 8, nil
+
+	case winapi.TdhInTypeSystemtime:
+		return decodeSystemTime(data)
+
+	case winapi.TdhInTypeSid:
+		sid, size, err := parseSID(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		return sid, size, nil
+
+	case winapi.TdhInTypeWbemsid:
+		return d.decodeWbemSID(data)
+
+	case winapi.TdhInTypeManifestCountedstring, winapi.TdhInTypeCountedstring:
+		return decodeCountedString(data, binary.LittleEndian, true)
+
+	case winapi.TdhInTypeManifestCountedansistring, winapi.TdhInTypeCountedansistring:
+		return decodeCountedString(data, binary.LittleEndian, false)
+
+	case winapi.TdhInTypeReversedcountedstring:
+		return decodeCountedString(data, binary.BigEndian, true)
+
+	case winapi.TdhInTypeReversedcountedansistring:
+		return decodeCountedString(data, binary.BigEndian, false)
+
+	case winapi.TdhInTypeManifestCountedbinary:
+		if len(data) < 2 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		size := int(binary.LittleEndian.Uint16(data))
+		if len(data) < 2+size {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return decodeBinary(data[2:2+size], outType), 2 + size, nil
+
+	case winapi.TdhInTypeNonnullterminatedstring:
+		size := len(data) &^ 1
+		if length > 0 {
+			size = 2 * int(length)
+		}
+		if len(data) < size {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return utf16BytesToString(data[:size]), size, nil
+
+	case winapi.TdhInTypeNonnullterminatedansistring:
+		size := len(data)
+		if length > 0 {
+			size = int(length)
+		}
+		if len(data) < size {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return ansiToString(data[:size]), size, nil
+
+	case winapi.TdhInTypeUnicodechar:
+		if len(data) < 2 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return utf16BytesToString(data[:2]), 2, nil
+
+	case winapi.TdhInTypeAnsichar:
+		if len(data) < 1 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return ansiToString(data[:1]), 1, nil
+
+	case winapi.TdhInTypeHexdump:
+		if len(data) < 4 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		size := binary.LittleEndian.Uint32(data)
+		if uint64(len(data)) < 4+uint64(size) {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return copyBytes(data[4 : 4+size]), 4 + int(size), nil
+	}
+
+	return nil, 0, fmt.Errorf("%w %d", ErrUnsupportedInType, inType)
+}
+
+func (d PropertyDecoder) decodePointer(data []byte) (interface{}, int, error) {
+	if d.PointerSize == 4 {
+		if len(data) < 4 {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return uint64(binary.LittleEndian.Uint32(data)), 4, nil
+	}
+	if len(data) < 8 {
+		return nil, 0, ErrTruncatedProperty
+	}
+	return binary.LittleEndian.Uint64(data), 8, nil
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-token_user
+// A WBEM SID is a TOKEN_USER structure followed by the SID it points to, or a single zero ULONG when empty.
+func (d PropertyDecoder) decodeWbemSID(data []byte) (interface{}, int, error) {
+	if len(data) < 4 {
+		return nil, 0, ErrTruncatedProperty
+	}
+	if binary.LittleEndian.Uint32(data) == 0 {
+		return nil, 4, nil
+	}
+
+	tokenUserSize := 2 * int(d.pointerSize())
+	if len(data) < tokenUserSize {
+		return nil, 0, ErrTruncatedProperty
+	}
+
+	sid, size, err := parseSID(data[tokenUserSize:])
+	if err != nil {
+		return nil, 0, err
+	}
+	return sid, tokenUserSize + size, nil
+}
+
+func (d PropertyDecoder) pointerSize() uint32 {
+	if d.PointerSize == 4 {
+		return 4
+	}
+	return 8
+}
+
+func decodeBinary(data []byte, outType winapi.TdhOutType) interface{} {
+	switch outType {
+	case winapi.TdhOutTypeIpv6:
+		if len(data) == ipv6AddressSize {
+			var address [16]byte
+			copy(address[:], data)
+			return netip.AddrFrom16(address)
+		}
+	case winapi.TdhOutTypeSocketaddress:
+		if address, ok := decodeSocketAddress(data); ok {
+			return address
+		}
+	}
+	return copyBytes(data)
+}
+
+func decodeSocketAddress(data []byte) (netip.AddrPort, bool) {
+	if len(data) < 4 {
+		return netip.AddrPort{}, false
+	}
+
+	port := binary.BigEndian.Uint16(data[2:])
+	switch binary.LittleEndian.Uint16(data) {
+	case addressFamilyInet:
+		if len(data) >= sockaddrInSize {
+			return netip.AddrPortFrom(netip.AddrFrom4([4]byte{data[4], data[5], data[6], data[7]}), port), true
+		}
+	case addressFamilyInet6:
+		if len(data) >= sockaddrIn6Size {
+			var address [16]byte
+			copy(address[:], data[8:24])
+			return netip.AddrPortFrom(netip.AddrFrom16(address), port), true
+		}
+	}
+	return netip.AddrPort{}, false
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/minwinbase/ns-minwinbase-systemtime
+func decodeSystemTime(data []byte) (interface{}, int, error) {
+	if len(data) < 16 {
+		return nil, 0, ErrTruncatedProperty
+	}
+	field := func(i int) int {
+		return int(binary.LittleEndian.Uint16(data[2*i:]))
+	}
+	// fields: year, month, day of week, day, hour, minute, second, milliseconds
+	systemTime := time.Date(field(0), time.Month(field(1)), field(3), field(4), field(5), field(6), field(7)*int(time.Millisecond), time.UTC)
+	return systemTime, 16, nil
+}
+
+// Strings without a length are null terminated; the terminator is consumed but not returned.
+// When the terminator is missing, the string extends to the end of the user data.
+func decodeUTF16String(data []byte, length uint32) (interface{}, int, error) {
+	if length > 0 {
+		size := 2 * int(length)
+		if len(data) < size {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return strings.TrimRight(utf16BytesToString(data[:size]), "\x00"), size, nil
+	}
+
+	for i := 0; i+1 < len(data); i += 2 {
+		if data[i] == 0 && data[i+1] == 0 {
+			return utf16BytesToString(data[:i]), i + 2, nil
+		}
+	}
+	size := len(data) &^ 1
+	return utf16BytesToString(data[:size]), size, nil
+}
+
+func decodeAnsiString(data []byte, length uint32) (interface{}, int, error) {
+	if length > 0 {
+		if uint32(len(data)) < length {
+			return nil, 0, ErrTruncatedProperty
+		}
+		return strings.TrimRight(ansiToString(data[:length]), "\x00"), int(length), nil
+	}
+
+	for i, b := range data {
+		if b == 0 {
+			return ansiToString(data[:i]), i + 1, nil
+		}
+	}
+	return ansiToString(data), len(data), nil
+}
+
+// Counted strings are prefixed by their size in bytes.
+func decodeCountedString(data []byte, byteOrder binary.ByteOrder, wide bool) (interface{}, int, error) {
+	if len(data) < 2 {
+		return nil, 0, ErrTruncatedProperty
+	}
+	size := int(byteOrder.Uint16(data))
+	if len(data) < 2+size {
+		return nil, 0, ErrTruncatedProperty
+	}
+	if wide {
+		return utf16BytesToString(data[2 : 2+size]), 2 + size, nil
+	}
+	return ansiToString(data[2 : 2+size]), 2 + size, nil
+}
+
+func utf16BytesToString(data []byte) string {
+	codeUnits := make([]uint16, len(data)/2)
+	for i := range codeUnits {
+		codeUnits[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(codeUnits))
+}
+
+// ANSI strings use the code page of the logging machine, which is unknown here:
+// valid UTF-8 is kept as is, anything else is read as Latin-1.
+func ansiToString(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func copyBytes(data []byte) []byte {
+	return append([]byte{}, data...)
+}
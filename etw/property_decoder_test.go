@@ -0,0 +1,141 @@
+package etw
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+// utf16z encodes s as a null terminated UTF-16LE string.
+func utf16z(s string) []byte {
+	return append(utf16Bytes(s), 0, 0)
+}
+
+func utf16Bytes(s string) []byte {
+	var data []byte
+	for _, r := range s {
+		data = append(data, byte(r), byte(r>>8))
+	}
+	return data
+}
+
+func TestPropertyDecoderDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		inType  winapi.TdhInType
+		outType winapi.TdhOutType
+		length  uint32
+		pointer uint32
+
+		kind   ValueKind
+		string string
+		size   int
+	}{
+		{"null", nil, winapi.TdhInTypeNull, 0, 0, 8, KindNull, "", 0},
+		{"unicode string", append(utf16z("abc"), 0xff), winapi.TdhInTypeUnicodestring, 0, 0, 8, KindString, "abc", 8},
+		{"unicode string without terminator", utf16Bytes("ab"), winapi.TdhInTypeUnicodestring, 0, 0, 8, KindString, "ab", 4},
+		{"unicode string with length", append(utf16Bytes("ab"), 0, 0, 'c', 0), winapi.TdhInTypeUnicodestring, 0, 3, 8, KindString, "ab", 6},
+		{"ansi string", []byte("abc\x00d"), winapi.TdhInTypeAnsistring, 0, 0, 8, KindString, "abc", 4},
+		{"ansi latin-1", []byte{'c', 0xe9, 0}, winapi.TdhInTypeAnsistring, 0, 0, 8, KindString, "cé", 3},
+		{"int8", []byte{0xfe}, winapi.TdhInTypeInt8, 0, 0, 8, KindInt8, "-2", 1},
+		{"int8 as char", []byte{'A'}, winapi.TdhInTypeInt8, winapi.TdhOutTypeString, 0, 8, KindString, "A", 1},
+		{"uint8", []byte{0xfe}, winapi.TdhInTypeUint8, 0, 0, 8, KindUint8, "254", 1},
+		{"uint8 boolean", []byte{2}, winapi.TdhInTypeUint8, winapi.TdhOutTypeBoolean, 0, 8, KindBool, "true", 1},
+		{"uint8 hex", []byte{0x1f}, winapi.TdhInTypeUint8, winapi.TdhOutTypeHexint8, 0, 8, KindUint8, "0x1F", 1},
+		{"int16", []byte{0xff, 0xff}, winapi.TdhInTypeInt16, 0, 0, 8, KindInt16, "-1", 2},
+		{"uint16", []byte{0x34, 0x12}, winapi.TdhInTypeUint16, 0, 0, 8, KindUint16, "4660", 2},
+		{"uint16 port", []byte{0x01, 0xbb}, winapi.TdhInTypeUint16, winapi.TdhOutTypePort, 0, 8, KindUint16, "443", 2},
+		{"int32", []byte{0xfe, 0xff, 0xff, 0xff}, winapi.TdhInTypeInt32, 0, 0, 8, KindInt32, "-2", 4},
+		{"int32 hresult", []byte{0x05, 0x40, 0x00, 0x80}, winapi.TdhInTypeInt32, winapi.TdhOutTypeHresult, 0, 8, KindInt32, "0x80004005", 4},
+		{"uint32", []byte{0xe7, 0x03, 0, 0}, winapi.TdhInTypeUint32, 0, 0, 8, KindUint32, "999", 4},
+		{"uint32 ipv4", []byte{192, 168, 1, 2}, winapi.TdhInTypeUint32, winapi.TdhOutTypeIpv4, 0, 8, KindAddr, "192.168.1.2", 4},
+		{"hexint32", []byte{0xe7, 0x03, 0, 0}, winapi.TdhInTypeHexint32, 0, 0, 8, KindUint32, "0x3E7", 4},
+		{"int64", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, winapi.TdhInTypeInt64, 0, 0, 8, KindInt64, "-1", 8},
+		{"uint64", []byte{1, 0, 0, 0, 1, 0, 0, 0}, winapi.TdhInTypeUint64, 0, 0, 8, KindUint64, "4294967297", 8},
+		{"hexint64", []byte{0xe7, 0x03, 0, 0, 0, 0, 0, 0}, winapi.TdhInTypeHexint64, 0, 0, 8, KindUint64, "0x3E7", 8},
+		{"float", []byte{0x00, 0x00, 0xc0, 0x3f}, winapi.TdhInTypeFloat, 0, 0, 8, KindFloat32, "1.5", 4},
+		{"double", []byte{0, 0, 0, 0, 0, 0, 0x04, 0xc0}, winapi.TdhInTypeDouble, 0, 0, 8, KindFloat64, "-2.5", 8},
+		{"boolean", []byte{0, 0, 0, 0}, winapi.TdhInTypeBoolean, 0, 0, 8, KindBool, "false", 4},
+		{"binary", []byte{0xde, 0xad, 0xbe, 0xef}, winapi.TdhInTypeBinary, 0, 3, 8, KindBinary, "0xDEADBE", 3},
+		{"binary ipv6", []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, winapi.TdhInTypeBinary, winapi.TdhOutTypeIpv6, 0, 8, KindAddr, "2001:db8::1", 16},
+		{"binary sockaddr_in", []byte{2, 0, 0x00, 0x50, 10, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0}, winapi.TdhInTypeBinary, winapi.TdhOutTypeSocketaddress, 16, 8, KindAddrPort, "10.0.0.1:80", 16},
+		{"guid", []byte{0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0x78, 0x56, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}, winapi.TdhInTypeGUID, 0, 0, 8, KindGUID, "{12345678-1234-5678-0102-030405060708}", 16},
+		{"pointer 64", []byte{0x00, 0x10, 0, 0, 0, 0, 0, 0}, winapi.TdhInTypePointer, 0, 0, 8, KindUint64, "0x1000", 8},
+		{"pointer 32", []byte{0x00, 0x10, 0, 0, 0xff}, winapi.TdhInTypePointer, 0, 0, 4, KindUint64, "0x1000", 4},
+		{"size_t 32", []byte{0x10, 0, 0, 0}, winapi.TdhInTypeSizet, 0, 0, 4, KindUint64, "16", 4},
+		{"filetime", []byte{0x00, 0x80, 0x3e, 0xd5, 0xde, 0xb1, 0x9d, 0x01}, winapi.TdhInTypeFiletime, 0, 0, 8, KindTime, "1970-01-01T00:00:00.0000000Z", 8},
+		{"systemtime", []byte{0xe8, 0x07, 2, 0, 4, 0, 29, 0, 13, 0, 14, 0, 15, 0, 0xf4, 0x01}, winapi.TdhInTypeSystemtime, 0, 0, 8, KindTime, "2024-02-29T13:14:15.5000000Z", 16},
+		{"sid", []byte{1, 1, 0, 0, 0, 0, 0, 5, 18, 0, 0, 0}, winapi.TdhInTypeSid, 0, 0, 8, KindSID, "S-1-5-18", 12},
+		{"empty wbem sid", []byte{0, 0, 0, 0}, winapi.TdhInTypeWbemsid, 0, 0, 8, KindNull, "", 4},
+		{"wbem sid 32", []byte{1, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 5, 18, 0, 0, 0}, winapi.TdhInTypeWbemsid, 0, 0, 4, KindSID, "S-1-5-18", 20},
+		{"counted string", append([]byte{4, 0}, utf16Bytes("ab")...), winapi.TdhInTypeManifestCountedstring, 0, 0, 8, KindString, "ab", 6},
+		{"counted ansi string", []byte{2, 0, 'a', 'b'}, winapi.TdhInTypeCountedansistring, 0, 0, 8, KindString, "ab", 4},
+		{"reversed counted string", append([]byte{0, 4}, utf16Bytes("ab")...), winapi.TdhInTypeReversedcountedstring, 0, 0, 8, KindString, "ab", 6},
+		{"counted binary", []byte{2, 0, 0xca, 0xfe, 0xff}, winapi.TdhInTypeManifestCountedbinary, 0, 0, 8, KindBinary, "0xCAFE", 4},
+		{"non null terminated string", utf16Bytes("abc"), winapi.TdhInTypeNonnullterminatedstring, 0, 0, 8, KindString, "abc", 6},
+		{"non null terminated ansi string", []byte("abcd"), winapi.TdhInTypeNonnullterminatedansistring, 0, 2, 8, KindString, "ab", 2},
+		{"unicode char", []byte{'z', 0, 'y', 0}, winapi.TdhInTypeUnicodechar, 0, 0, 8, KindString, "z", 2},
+		{"ansi char", []byte("zy"), winapi.TdhInTypeAnsichar, 0, 0, 8, KindString, "z", 1},
+		{"hexdump", []byte{2, 0, 0, 0, 0x01, 0x02, 0x03}, winapi.TdhInTypeHexdump, 0, 0, 8, KindBinary, "0x0102", 6},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decoder := PropertyDecoder{PointerSize: test.pointer}
+			value, size, err := decoder.Decode(test.data, test.inType, test.outType, test.length)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if value.Kind != test.kind {
+				t.Errorf("kind = %s, want %s", value.Kind, test.kind)
+			}
+			if value.String() != test.string {
+				t.Errorf("String() = %q, want %q", value.String(), test.string)
+			}
+			if size != test.size {
+				t.Errorf("size = %d, want %d", size, test.size)
+			}
+			if value.InType != test.inType || value.OutType != test.outType {
+				t.Errorf("types = %d/%d, want %d/%d", value.InType, value.OutType, test.inType, test.outType)
+			}
+		})
+	}
+}
+
+func TestPropertyDecoderTruncated(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		inType winapi.TdhInType
+		length uint32
+	}{
+		{"uint16", []byte{1}, winapi.TdhInTypeUint16, 0},
+		{"uint32", []byte{1, 2, 3}, winapi.TdhInTypeUint32, 0},
+		{"uint64", []byte{1, 2, 3, 4, 5, 6, 7}, winapi.TdhInTypeUint64, 0},
+		{"guid", make([]byte, 15), winapi.TdhInTypeGUID, 0},
+		{"binary", []byte{1, 2}, winapi.TdhInTypeBinary, 3},
+		{"unicode string with length", utf16Bytes("ab"), winapi.TdhInTypeUnicodestring, 3},
+		{"sid", []byte{1, 2, 0, 0, 0, 0, 0, 5, 18, 0, 0, 0}, winapi.TdhInTypeSid, 0},
+		{"counted string", []byte{8, 0, 'a', 0}, winapi.TdhInTypeManifestCountedstring, 0},
+		{"hexdump", []byte{0xff, 0xff, 0xff, 0xff, 0}, winapi.TdhInTypeHexdump, 0},
+		{"systemtime", make([]byte, 15), winapi.TdhInTypeSystemtime, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := PropertyDecoder{PointerSize: 8}.Decode(test.data, test.inType, 0, test.length)
+			if !errors.Is(err, ErrTruncatedProperty) {
+				t.Errorf("err = %v, want %v", err, ErrTruncatedProperty)
+			}
+		})
+	}
+}
+
+func TestPropertyDecoderUnsupportedInType(t *testing.T) {
+	_, _, err := PropertyDecoder{}.Decode([]byte{0}, winapi.TdhInTypeReserved24, 0, 0)
+	if !errors.Is(err, ErrUnsupportedInType) {
+		t.Errorf("err = %v, want %v", err, ErrUnsupportedInType)
+	}
+}
@@ -0,0 +1,63 @@
+package etw
+
+import (
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+// Record is the platform neutral counterpart of winapi.EventRecord: the event header,
+// its extended data items and its user data, as byte slices rather than pointers.
+// Records built by the event callback view memory owned by ETW and are only valid during the callback.
+type Record struct {
+	EventHeader   winapi.EventHeader
+	BufferContext winapi.BufferContext
+	ExtendedData  []ExtendedDataItem
+	UserData      []byte
+
+	native nativeRecord // the originating EVENT_RECORD, on Windows only
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/evntcons/ns-evntcons-event_header_extended_data_item
+type ExtendedDataItem struct {
+	ExtType uint16 `json:"ext_type"`
+	Data    []byte `json:"data"`
+}
+
+func (r *Record) PointerSize() uint32 {
+	if r.EventHeader.Flags&winapi.EVENT_HEADER_FLAG_32_BIT_HEADER == winapi.EVENT_HEADER_FLAG_32_BIT_HEADER {
+		return 4
+	}
+	return 8
+}
+
+// IsClassic reports whether the event was logged by a MOF provider, in which case
+// EventHeader.ProviderId holds the event class GUID rather than the provider GUID.
+func (r *Record) IsClassic() bool {
+	return r.EventHeader.Flags&winapi.EVENT_HEADER_FLAG_CLASSIC_HEADER == winapi.EVENT_HEADER_FLAG_CLASSIC_HEADER
+}
+
+// ExtendedDataItem returns the data of the first extended data item of type extType.
+func (r *Record) ExtendedDataItem(extType uint16) ([]byte, bool) {
+	for _, item := range r.ExtendedData {
+		if item.ExtType == extType {
+			return item.Data, true
+		}
+	}
+	return nil, false
+}
+
+// Copy returns a deep copy of the record, valid after the event callback returns.
+// The copy loses the originating EVENT_RECORD: the TdhBackend can not decode it.
+func (r *Record) Copy() *Record {
+	record := &Record{
+		EventHeader:   r.EventHeader,
+		BufferContext: r.BufferContext,
+		UserData:      copyBytes(r.UserData),
+	}
+	if len(r.ExtendedData) > 0 {
+		record.ExtendedData = make([]ExtendedDataItem, len(r.ExtendedData))
+		for i, item := range r.ExtendedData {
+			record.ExtendedData[i] = ExtendedDataItem{ExtType: item.ExtType, Data: copyBytes(item.Data)}
+		}
+	}
+	return record
+}
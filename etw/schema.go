@@ -0,0 +1,183 @@
+package etw
+
+import (
+	"fmt"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/tdh/ns-tdh-trace_event_info
+// https://learn.microsoft.com/en-us/windows/win32/api/tdh/ns-tdh-event_property_info
+
+var (
+	ErrInvalidSchema = fmt.Errorf("invalid event schema")
+)
+
+// Schema describes the user data of one kind of event, like a TRACE_EVENT_INFO does.
+// Unlike TRACE_EVENT_INFO, it does not live in the event callback: see MarshalJSON and MarshalBinary
+// to ship schemas captured on a Windows host, and SchemaBackend to decode with them anywhere.
+type Schema struct {
+	ProviderGUID    winguid.GUID           `json:"provider_guid"`
+	EventGUID       winguid.GUID           `json:"event_guid"`
+	EventDescriptor winapi.EventDescriptor `json:"descriptor"`
+	DecodingSource  winapi.DecodingSource  `json:"decoding_source"`
+	Flags           winapi.TemplateFlags   `json:"flags"`
+	Tags            uint32                 `json:"tags,omitempty"`
+
+	ProviderName          string `json:"provider_name,omitempty"`
+	LevelName             string `json:"level_name,omitempty"`
+	ChannelName           string `json:"channel_name,omitempty"`
+	KeywordsName          string `json:"keywords_name,omitempty"`
+	TaskName              string `json:"task_name,omitempty"`
+	OpcodeName            string `json:"opcode_name,omitempty"`
+	EventMessage          string `json:"event_message,omitempty"`
+	ProviderMessage       string `json:"provider_message,omitempty"`
+	ActivityIDName        string `json:"activity_id_name,omitempty"`
+	RelatedActivityIDName string `json:"related_activity_id_name,omitempty"`
+
+	// Properties lists the top level properties first, then the structure members, as TRACE_EVENT_INFO does.
+	// Structure, count and length references are indexes in this slice.
+	Properties            []PropertyInfo `json:"properties"`
+	TopLevelPropertyCount int            `json:"top_level_property_count"`
+
+	// Maps holds the value maps referenced by PropertyInfo.MapName.
+	Maps map[string]*ValueMap `json:"maps,omitempty"`
+
+	native nativeSchema // the originating TRACE_EVENT_INFO, when decoded by TDH
+}
+
+// PropertyParamCountPrefix marks the arrays whose element count is the UINT16 preceding the elements
+// in the user data, as TraceLogging lays out variable length arrays. It is not a TDH flag.
+const PropertyParamCountPrefix = winapi.PropertyFlags(0x40000000)
+
+// PropertyInfo is the counterpart of EVENT_PROPERTY_INFO.
+type PropertyInfo struct {
+	Name    string               `json:"name"`
+	Flags   winapi.PropertyFlags `json:"flags"`
+	InType  winapi.TdhInType     `json:"in_type"`
+	OutType winapi.TdhOutType    `json:"out_type"`
+	MapName string               `json:"map_name,omitempty"`
+	Tags    uint32               `json:"tags,omitempty"`
+
+	StructStartIndex   uint16 `json:"struct_start_index,omitempty"`
+	NumOfStructMembers uint16 `json:"num_of_struct_members,omitempty"`
+
+	Count  uint16 `json:"count"`  // element count, or index of the count property with winapi.PropertyParamCount
+	Length uint16 `json:"length"` // length, or index of the length property with winapi.PropertyParamLength
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/tdh/ns-tdh-event_map_info
+
+// ValueMap is the counterpart of EVENT_MAP_INFO: the names given to the values of a property.
+type ValueMap struct {
+	Name    string          `json:"name"`
+	Flag    winapi.MapFlags `json:"flag"`
+	Entries []ValueMapEntry `json:"entries"`
+
+	native nativeValueMap // the originating EVENT_MAP_INFO, when retrieved from TDH
+}
+
+// ValueMapEntry maps Value, or Input for pattern maps and string valued WBEM maps, to Output.
+type ValueMapEntry struct {
+	Value  uint32 `json:"value"`
+	Input  string `json:"input,omitempty"`
+	Output string `json:"output"`
+}
+
+func (p *PropertyInfo) IsStruct() bool {
+	return p.Flags&winapi.PropertyStruct == winapi.PropertyStruct
+}
+
+func (p *PropertyInfo) HasParamCount() bool {
+	return p.Flags&winapi.PropertyParamCount == winapi.PropertyParamCount
+}
+
+func (p *PropertyInfo) HasParamCountPrefix() bool {
+	return p.Flags&PropertyParamCountPrefix == PropertyParamCountPrefix
+}
+
+func (p *PropertyInfo) HasParamLength() bool {
+	return p.Flags&winapi.PropertyParamLength == winapi.PropertyParamLength
+}
+
+// IsArray reports whether the property holds a variable or fixed number of elements.
+func (p *PropertyInfo) IsArray() bool {
+	return p.HasParamCount() || p.HasParamCountPrefix() || p.Flags&winapi.PropertyParamFixedCount == winapi.PropertyParamFixedCount || p.Count > 1
+}
+
+func (s *Schema) IsManagedObjectFormat() bool {
+	return s.DecodingSource == winapi.DecodingSourceWbem
+}
+
+func (s *Schema) IsTraceLogging() bool {
+	return s.DecodingSource == winapi.DecodingSourceTlg
+}
+
+// Validate checks the indexes of the schema: the top level property count, structure member ranges,
+// and count and length references, so that decoding never reads outside Properties.
+func (s *Schema) Validate() error {
+	propertyCount := len(s.Properties)
+	if s.TopLevelPropertyCount < 0 || s.TopLevelPropertyCount > propertyCount {
+		return fmt.Errorf("%w: %d top level properties of %d", ErrInvalidSchema, s.TopLevelPropertyCount, propertyCount)
+	}
+
+	for i := range s.Properties {
+		property := &s.Properties[i]
+		if property.IsStruct() && int(property.StructStartIndex)+int(property.NumOfStructMembers) > propertyCount {
+			return fmt.Errorf("%w: members %d to %d of structure %s out of %d properties", ErrInvalidSchema,
+				property.StructStartIndex, int(property.StructStartIndex)+int(property.NumOfStructMembers), property.Name, propertyCount)
+		}
+		if property.HasParamCount() && int(property.Count) >= propertyCount {
+			return fmt.Errorf("%w: count of %s references property %d of %d", ErrInvalidSchema, property.Name, property.Count, propertyCount)
+		}
+		if property.HasParamLength() && int(property.Length) >= propertyCount {
+			return fmt.Errorf("%w: length of %s references property %d of %d", ErrInvalidSchema, property.Name, property.Length, propertyCount)
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) EventID() uint16 {
+	return s.EventDescriptor.Id
+}
+
+// SchemaKey identifies the schema of an event. Manifest based events are identified by their provider,
+// MOF events by their class GUID, since their records carry no provider GUID.
+type SchemaKey struct {
+	ProviderGUID winguid.GUID
+	EventGUID    winguid.GUID
+	ID           uint16
+	Version      uint8
+	Opcode       uint8
+}
+
+func (s *Schema) Key() SchemaKey {
+	key := SchemaKey{
+		ID:      s.EventDescriptor.Id,
+		Version: s.EventDescriptor.Version,
+		Opcode:  s.EventDescriptor.Opcode,
+	}
+	if s.IsManagedObjectFormat() {
+		key.EventGUID = s.EventGUID
+	} else {
+		key.ProviderGUID = s.ProviderGUID
+	}
+	return key
+}
+
+// RecordSchemaKey returns the key of the schema that decodes record.
+func RecordSchemaKey(record *Record) SchemaKey {
+	key := SchemaKey{
+		ID:      record.EventHeader.EventDescriptor.Id,
+		Version: record.EventHeader.EventDescriptor.Version,
+		Opcode:  record.EventHeader.EventDescriptor.Opcode,
+	}
+	if record.IsClassic() {
+		key.EventGUID = record.EventHeader.ProviderId
+	} else {
+		key.ProviderGUID = record.EventHeader.ProviderId
+	}
+	return key
+}
@@ -0,0 +1,158 @@
+package etw
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+const DefaultSchemaCacheSize = 4096 // in schemas
+
+// SchemaCache keeps the schemas resolved by a DecoderBackend, value maps included,
+// so that TdhGetEventInformation and TdhGetEventMapInformation run once per kind of event rather than once per event.
+// It holds at most Size schemas, evicting the least recently used, and is safe for concurrent use.
+//
+// TraceLogging events carry their own schema and share event IDs, they are never cached.
+type SchemaCache struct {
+	DecoderBackend
+
+	size int
+
+	mutex   sync.Mutex
+	entries map[SchemaKey]*list.Element
+	lru     *list.List // of *schemaCacheEntry, most recently used first
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type schemaCacheEntry struct {
+	key    SchemaKey
+	schema *Schema
+}
+
+// SchemaCacheStats counts lookups since the cache creation.
+type SchemaCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+}
+
+// NewSchemaCache caches the schemas of backend, up to size schemas, DefaultSchemaCacheSize if size is not positive.
+func NewSchemaCache(backend DecoderBackend, size int) *SchemaCache {
+	if size <= 0 {
+		size = DefaultSchemaCacheSize
+	}
+	return &SchemaCache{
+		DecoderBackend: backend,
+		size:           size,
+		entries:        make(map[SchemaKey]*list.Element),
+		lru:            list.New(),
+	}
+}
+
+func (s *SchemaCache) EventSchema(record *Record) (*Schema, error) {
+	if _, ok := record.ExtendedDataItem(winapi.EVENT_HEADER_EXT_TYPE_EVENT_SCHEMA_TL); ok {
+		return s.DecoderBackend.EventSchema(record)
+	}
+
+	key := RecordSchemaKey(record)
+	if schema, ok := s.get(key); ok {
+		s.hits.Add(1)
+		return schema, nil
+	}
+	s.misses.Add(1)
+
+	// Concurrent misses on the same key may both query the backend, the last one wins.
+	schema, err := s.DecoderBackend.EventSchema(record)
+	if err != nil {
+		return nil, err
+	}
+	s.put(key, schema)
+
+	return schema, nil
+}
+
+func (s *SchemaCache) get(key SchemaKey) (*Schema, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.lru.MoveToFront(element)
+	return element.Value.(*schemaCacheEntry).schema, true
+}
+
+func (s *SchemaCache) put(key SchemaKey, schema *Schema) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		element.Value.(*schemaCacheEntry).schema = schema
+		s.lru.MoveToFront(element)
+		return
+	}
+
+	s.entries[key] = s.lru.PushFront(&schemaCacheEntry{key: key, schema: schema})
+	for s.lru.Len() > s.size {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*schemaCacheEntry).key)
+		s.evictions.Add(1)
+	}
+}
+
+// Stats returns the hit, miss and eviction counters, and the number of cached schemas.
+func (s *SchemaCache) Stats() SchemaCacheStats {
+	s.mutex.Lock()
+	size := s.lru.Len()
+	s.mutex.Unlock()
+
+	return SchemaCacheStats{
+		Hits:      s.hits.Load(),
+		Misses:    s.misses.Load(),
+		Evictions: s.evictions.Load(),
+		Size:      size,
+	}
+}
+
+// Invalidate drops the schema cached under key.
+func (s *SchemaCache) Invalidate(key SchemaKey) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.lru.Remove(element)
+		delete(s.entries, key)
+	}
+}
+
+// InvalidateProvider drops every schema of a provider, for instance after its manifest was registered again.
+// For MOF providers, guid is an event class GUID.
+func (s *SchemaCache) InvalidateProvider(guid *winguid.GUID) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key, element := range s.entries {
+		if winguid.Equals(&key.ProviderGUID, guid) || winguid.Equals(&key.EventGUID, guid) {
+			s.lru.Remove(element)
+			delete(s.entries, key)
+		}
+	}
+}
+
+// Purge drops every cached schema.
+func (s *SchemaCache) Purge() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries = make(map[SchemaKey]*list.Element)
+	s.lru.Init()
+}
@@ -0,0 +1,123 @@
+package etw
+
+import (
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// countingBackend counts the schema lookups reaching its SchemaBackend.
+type countingBackend struct {
+	*SchemaBackend
+	lookups int
+}
+
+func (c *countingBackend) EventSchema(record *Record) (*Schema, error) {
+	c.lookups++
+	return c.SchemaBackend.EventSchema(record)
+}
+
+// versionedRecord is a record of event id, version 0, from testProviderGUID.
+func versionedRecord(id uint16) *Record {
+	record := testRecord(nil)
+	record.EventHeader.EventDescriptor = winapi.EventDescriptor{Id: id}
+	return record
+}
+
+func versionedSchema(id uint16) *Schema {
+	schema := testSchema(-1)
+	schema.EventDescriptor = winapi.EventDescriptor{Id: id}
+	return schema
+}
+
+func TestSchemaCache(t *testing.T) {
+	backend := &countingBackend{SchemaBackend: NewSchemaBackend(versionedSchema(1), versionedSchema(2), versionedSchema(3))}
+	cache := NewSchemaCache(backend, 2)
+
+	for _, id := range []uint16{1, 1, 2, 1, 3, 2} {
+		schema, err := cache.EventSchema(versionedRecord(id))
+		if err != nil {
+			t.Fatalf("EventSchema(%d): %v", id, err)
+		}
+		if schema.EventID() != id {
+			t.Fatalf("EventSchema(%d) returned event %d", id, schema.EventID())
+		}
+	}
+
+	// 1 miss, 1 hit, 2 miss, 1 hit, 3 miss evicting 2, 2 miss evicting 1
+	want := SchemaCacheStats{Hits: 2, Misses: 4, Evictions: 2, Size: 2}
+	if stats := cache.Stats(); stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+	if backend.lookups != 4 {
+		t.Errorf("backend lookups = %d, want 4", backend.lookups)
+	}
+}
+
+func TestSchemaCacheMissesAreNotCached(t *testing.T) {
+	backend := &countingBackend{SchemaBackend: NewSchemaBackend()}
+	cache := NewSchemaCache(backend, 0)
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.EventSchema(versionedRecord(1)); err != ErrSchemaNotFound {
+			t.Fatalf("err = %v, want %v", err, ErrSchemaNotFound)
+		}
+	}
+	if backend.lookups != 2 || cache.Stats().Size != 0 {
+		t.Errorf("lookups = %d, size = %d, want 2 and 0", backend.lookups, cache.Stats().Size)
+	}
+}
+
+func TestSchemaCacheTraceLoggingBypass(t *testing.T) {
+	backend := &countingBackend{SchemaBackend: NewSchemaBackend(versionedSchema(1))}
+	cache := NewSchemaCache(backend, 0)
+
+	record := versionedRecord(1)
+	record.ExtendedData = []ExtendedDataItem{{ExtType: winapi.EVENT_HEADER_EXT_TYPE_EVENT_SCHEMA_TL}}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.EventSchema(record); err != nil {
+			t.Fatalf("EventSchema: %v", err)
+		}
+	}
+	if backend.lookups != 2 || cache.Stats().Size != 0 {
+		t.Errorf("lookups = %d, size = %d, want 2 and 0", backend.lookups, cache.Stats().Size)
+	}
+}
+
+func TestSchemaCacheInvalidate(t *testing.T) {
+	backend := &countingBackend{SchemaBackend: NewSchemaBackend(versionedSchema(1), versionedSchema(2))}
+	cache := NewSchemaCache(backend, 0)
+
+	lookup := func(id uint16) {
+		if _, err := cache.EventSchema(versionedRecord(id)); err != nil {
+			t.Fatalf("EventSchema(%d): %v", id, err)
+		}
+	}
+
+	lookup(1)
+	lookup(2)
+	cache.Invalidate(RecordSchemaKey(versionedRecord(1)))
+	if size := cache.Stats().Size; size != 1 {
+		t.Errorf("after Invalidate, size = %d, want 1", size)
+	}
+
+	lookup(1)
+	cache.InvalidateProvider(winguid.MustParse("{00000000-0000-0000-0000-000000000001}"))
+	if size := cache.Stats().Size; size != 2 {
+		t.Errorf("after InvalidateProvider of another provider, size = %d, want 2", size)
+	}
+	cache.InvalidateProvider(&testProviderGUID)
+	if size := cache.Stats().Size; size != 0 {
+		t.Errorf("after InvalidateProvider, size = %d, want 0", size)
+	}
+
+	lookup(1)
+	cache.Purge()
+	if size := cache.Stats().Size; size != 0 {
+		t.Errorf("after Purge, size = %d, want 0", size)
+	}
+	if backend.lookups != 4 {
+		t.Errorf("backend lookups = %d, want 4", backend.lookups)
+	}
+}
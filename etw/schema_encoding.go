@@ -0,0 +1,422 @@
+package etw
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+var (
+	ErrSchemaEncoding = fmt.Errorf("invalid schema encoding")
+)
+
+// descriptorJSON gives EVENT_DESCRIPTOR fields stable JSON names.
+type descriptorJSON struct {
+	ID      uint16 `json:"id"`
+	Version uint8  `json:"version"`
+	Channel uint8  `json:"channel"`
+	Level   uint8  `json:"level"`
+	Opcode  uint8  `json:"opcode"`
+	Task    uint16 `json:"task"`
+	Keyword uint64 `json:"keyword"`
+}
+
+// schemaFields drops the Schema methods, to avoid recursing in MarshalJSON.
+type schemaFields Schema
+
+// schemaJSON writes GUIDs in their registry format rather than as syscall.GUID structures.
+type schemaJSON struct {
+	ProviderGUID    string         `json:"provider_guid"`
+	EventGUID       string         `json:"event_guid"`
+	EventDescriptor descriptorJSON `json:"descriptor"`
+	*schemaFields
+}
+
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	descriptor := s.EventDescriptor
+	return json.Marshal(schemaJSON{
+		ProviderGUID: winguid.ToString(&s.ProviderGUID),
+		EventGUID:    winguid.ToString(&s.EventGUID),
+		EventDescriptor: descriptorJSON{
+			ID:      descriptor.Id,
+			Version: descriptor.Version,
+			Channel: descriptor.Channel,
+			Level:   descriptor.Level,
+			Opcode:  descriptor.Opcode,
+			Task:    descriptor.Task,
+			Keyword: descriptor.Keyword,
+		},
+		schemaFields: (*schemaFields)(s),
+	})
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	decoded := schemaJSON{schemaFields: (*schemaFields)(s)}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	for _, guid := range []struct {
+		text   string
+		target *winguid.GUID
+	}{
+		{decoded.ProviderGUID, &s.ProviderGUID},
+		{decoded.EventGUID, &s.EventGUID},
+	} {
+		parsed, err := winguid.Parse(guid.text)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrSchemaEncoding, err)
+		}
+		*guid.target = *parsed
+	}
+
+	s.EventDescriptor = winapi.EventDescriptor{
+		Id:      decoded.EventDescriptor.ID,
+		Version: decoded.EventDescriptor.Version,
+		Channel: decoded.EventDescriptor.Channel,
+		Level:   decoded.EventDescriptor.Level,
+		Opcode:  decoded.EventDescriptor.Opcode,
+		Task:    decoded.EventDescriptor.Task,
+		Keyword: decoded.EventDescriptor.Keyword,
+	}
+
+	return nil
+}
+
+// Binary format, little-endian, strings and collections prefixed by their uvarint length:
+//
+//	"ETWS" version:u16
+//	provider:GUID event:GUID
+//	id:u16 version:u8 channel:u8 level:u8 opcode:u8 task:u16 keyword:u64
+//	decoding_source:u32 flags:u32 tags:u32 (from version 2)
+//	provider_name level_name channel_name keywords_name task_name opcode_name
+//	event_message provider_message activity_id_name related_activity_id_name
+//	top_level_property_count:uvarint
+//	properties: name flags:u32 in_type:u16 out_type:u16 map_name tags:u32
+//	            struct_start_index:u16 num_of_struct_members:u16 count:u16 length:u16
+//	maps, sorted by name: name flag:u32 entries: value:u32 input output
+const (
+	schemaMagic         = "ETWS"
+	schemaBundleMagic   = "ETWB"
+	schemaFormatVersion = uint16(2)
+)
+
+func (s *Schema) MarshalBinary() ([]byte, error) {
+	var encoder schemaEncoder
+	encoder.WriteString(schemaMagic)
+	encoder.writeUint16(schemaFormatVersion)
+
+	encoder.Write(winguid.ToBytes(&s.ProviderGUID))
+	encoder.Write(winguid.ToBytes(&s.EventGUID))
+
+	encoder.writeUint16(s.EventDescriptor.Id)
+	encoder.WriteByte(s.EventDescriptor.Version)
+	encoder.WriteByte(s.EventDescriptor.Channel)
+	encoder.WriteByte(s.EventDescriptor.Level)
+	encoder.WriteByte(s.EventDescriptor.Opcode)
+	encoder.writeUint16(s.EventDescriptor.Task)
+	encoder.writeUint64(s.EventDescriptor.Keyword)
+
+	encoder.writeUint32(uint32(s.DecodingSource))
+	encoder.writeUint32(uint32(s.Flags))
+	encoder.writeUint32(s.Tags)
+
+	for _, name := range s.names() {
+		encoder.writeString(*name)
+	}
+
+	encoder.writeUvarint(uint64(s.TopLevelPropertyCount))
+	encoder.writeUvarint(uint64(len(s.Properties)))
+	for i := range s.Properties {
+		property := &s.Properties[i]
+		encoder.writeString(property.Name)
+		encoder.writeUint32(uint32(property.Flags))
+		encoder.writeUint16(uint16(property.InType))
+		encoder.writeUint16(uint16(property.OutType))
+		encoder.writeString(property.MapName)
+		encoder.writeUint32(property.Tags)
+		encoder.writeUint16(property.StructStartIndex)
+		encoder.writeUint16(property.NumOfStructMembers)
+		encoder.writeUint16(property.Count)
+		encoder.writeUint16(property.Length)
+	}
+
+	mapNames := make([]string, 0, len(s.Maps))
+	for name := range s.Maps {
+		mapNames = append(mapNames, name)
+	}
+	sort.Strings(mapNames)
+
+	encoder.writeUvarint(uint64(len(mapNames)))
+	for _, name := range mapNames {
+		valueMap := s.Maps[name]
+		encoder.writeString(name)
+		encoder.writeUint32(uint32(valueMap.Flag))
+		encoder.writeUvarint(uint64(len(valueMap.Entries)))
+		for _, entry := range valueMap.Entries {
+			encoder.writeUint32(entry.Value)
+			encoder.writeString(entry.Input)
+			encoder.writeString(entry.Output)
+		}
+	}
+
+	return encoder.Bytes(), nil
+}
+
+func (s *Schema) UnmarshalBinary(data []byte) error {
+	decoder := schemaDecoder{data: data}
+
+	if string(decoder.read(len(schemaMagic))) != schemaMagic {
+		return fmt.Errorf("%w: bad magic", ErrSchemaEncoding)
+	}
+	version := decoder.readUint16()
+	if decoder.err == nil && (version == 0 || version > schemaFormatVersion) {
+		return fmt.Errorf("%w: unsupported version %d", ErrSchemaEncoding, version)
+	}
+
+	decoded := Schema{}
+	decoded.ProviderGUID = decoder.readGUID()
+	decoded.EventGUID = decoder.readGUID()
+
+	decoded.EventDescriptor.Id = decoder.readUint16()
+	decoded.EventDescriptor.Version = decoder.readByte()
+	decoded.EventDescriptor.Channel = decoder.readByte()
+	decoded.EventDescriptor.Level = decoder.readByte()
+	decoded.EventDescriptor.Opcode = decoder.readByte()
+	decoded.EventDescriptor.Task = decoder.readUint16()
+	decoded.EventDescriptor.Keyword = decoder.readUint64()
+
+	decoded.DecodingSource = winapi.DecodingSource(decoder.readUint32())
+	decoded.Flags = winapi.TemplateFlags(decoder.readUint32())
+	if version >= 2 {
+		decoded.Tags = decoder.readUint32()
+	}
+
+	for _, name := range decoded.names() {
+		*name = decoder.readString()
+	}
+
+	decoded.TopLevelPropertyCount = int(decoder.readUvarint())
+	propertyCount := decoder.readCount()
+	decoded.Properties = make([]PropertyInfo, 0, propertyCount)
+	for i := 0; i < propertyCount && decoder.err == nil; i++ {
+		decoded.Properties = append(decoded.Properties, PropertyInfo{
+			Name:               decoder.readString(),
+			Flags:              winapi.PropertyFlags(decoder.readUint32()),
+			InType:             winapi.TdhInType(decoder.readUint16()),
+			OutType:            winapi.TdhOutType(decoder.readUint16()),
+			MapName:            decoder.readString(),
+			Tags:               decoder.readUint32(),
+			StructStartIndex:   decoder.readUint16(),
+			NumOfStructMembers: decoder.readUint16(),
+			Count:              decoder.readUint16(),
+			Length:             decoder.readUint16(),
+		})
+	}
+
+	mapCount := decoder.readCount()
+	if mapCount > 0 {
+		decoded.Maps = make(map[string]*ValueMap, mapCount)
+	}
+	for i := 0; i < mapCount && decoder.err == nil; i++ {
+		valueMap := ValueMap{
+			Name: decoder.readString(),
+			Flag: winapi.MapFlags(decoder.readUint32()),
+		}
+		entryCount := decoder.readCount()
+		valueMap.Entries = make([]ValueMapEntry, 0, entryCount)
+		for j := 0; j < entryCount && decoder.err == nil; j++ {
+			valueMap.Entries = append(valueMap.Entries, ValueMapEntry{
+				Value:  decoder.readUint32(),
+				Input:  decoder.readString(),
+				Output: decoder.readString(),
+			})
+		}
+		decoded.Maps[valueMap.Name] = &valueMap
+	}
+
+	if decoder.err != nil {
+		return decoder.err
+	}
+	if decoded.TopLevelPropertyCount > len(decoded.Properties) {
+		return fmt.Errorf("%w: %d top level properties out of %d", ErrSchemaEncoding, decoded.TopLevelPropertyCount, len(decoded.Properties))
+	}
+
+	*s = decoded
+	return nil
+}
+
+// names lists the string fields, in their binary encoding order.
+func (s *Schema) names() []*string {
+	return []*string{
+		&s.ProviderName, &s.LevelName, &s.ChannelName, &s.KeywordsName, &s.TaskName, &s.OpcodeName,
+		&s.EventMessage, &s.ProviderMessage, &s.ActivityIDName, &s.RelatedActivityIDName,
+	}
+}
+
+// WriteSchemas writes a bundle of schemas in binary form.
+func WriteSchemas(w io.Writer, schemas []*Schema) error {
+	var encoder schemaEncoder
+	encoder.WriteString(schemaBundleMagic)
+	encoder.writeUvarint(uint64(len(schemas)))
+	for _, schema := range schemas {
+		data, err := schema.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		encoder.writeUvarint(uint64(len(data)))
+		encoder.Write(data)
+	}
+
+	_, err := w.Write(encoder.Bytes())
+	return err
+}
+
+// ReadSchemas reads a bundle written by WriteSchemas.
+func ReadSchemas(r io.Reader) ([]*Schema, error) {
+	reader := bufio.NewReader(r)
+
+	magic := make([]byte, len(schemaBundleMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != schemaBundleMagic {
+		return nil, fmt.Errorf("%w: bad bundle magic", ErrSchemaEncoding)
+	}
+
+	count, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSchemaEncoding, err)
+	}
+
+	schemas := make([]*Schema, 0)
+	for i := uint64(0); i < count; i++ {
+		size, sizeErr := binary.ReadUvarint(reader)
+		if sizeErr != nil {
+			return nil, fmt.Errorf("%w: %s", ErrSchemaEncoding, sizeErr)
+		}
+
+		var data bytes.Buffer
+		if _, copyErr := io.CopyN(&data, reader, int64(size)); copyErr != nil {
+			return nil, fmt.Errorf("%w: %s", ErrSchemaEncoding, copyErr)
+		}
+
+		schema := Schema{}
+		if unmarshalErr := schema.UnmarshalBinary(data.Bytes()); unmarshalErr != nil {
+			return nil, unmarshalErr
+		}
+		schemas = append(schemas, &schema)
+	}
+
+	return schemas, nil
+}
+
+type schemaEncoder struct {
+	bytes.Buffer
+}
+
+func (e *schemaEncoder) writeUint16(v uint16) {
+	e.Write(binary.LittleEndian.AppendUint16(nil, v))
+}
+
+func (e *schemaEncoder) writeUint32(v uint32) {
+	e.Write(binary.LittleEndian.AppendUint32(nil, v))
+}
+
+func (e *schemaEncoder) writeUint64(v uint64) {
+	e.Write(binary.LittleEndian.AppendUint64(nil, v))
+}
+
+func (e *schemaEncoder) writeUvarint(v uint64) {
+	e.Write(binary.AppendUvarint(nil, v))
+}
+
+func (e *schemaEncoder) writeString(s string) {
+	e.writeUvarint(uint64(len(s)))
+	e.WriteString(s)
+}
+
+// schemaDecoder reads until the first error, which is then kept and returned by the caller.
+type schemaDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *schemaDecoder) read(size int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if size < 0 || size > len(d.data) {
+		d.err = fmt.Errorf("%w: truncated data", ErrSchemaEncoding)
+		return nil
+	}
+	b := d.data[:size]
+	d.data = d.data[size:]
+	return b
+}
+
+func (d *schemaDecoder) readByte() uint8 {
+	if b := d.read(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *schemaDecoder) readUint16() uint16 {
+	if b := d.read(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *schemaDecoder) readUint32() uint32 {
+	if b := d.read(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *schemaDecoder) readUint64() uint64 {
+	if b := d.read(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *schemaDecoder) readGUID() winguid.GUID {
+	if b := d.read(16); b != nil {
+		return winguid.FromBytes(b)
+	}
+	return winguid.GUID{}
+}
+
+func (d *schemaDecoder) readUvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, size := binary.Uvarint(d.data)
+	if size <= 0 {
+		d.err = fmt.Errorf("%w: bad varint", ErrSchemaEncoding)
+		return 0
+	}
+	d.data = d.data[size:]
+	return v
+}
+
+// readCount reads a collection length, which can not exceed the remaining bytes.
+func (d *schemaDecoder) readCount() int {
+	count := d.readUvarint()
+	if count > uint64(len(d.data)) {
+		d.err = fmt.Errorf("%w: count %d exceeds data", ErrSchemaEncoding, count)
+		return 0
+	}
+	return int(count)
+}
+
+func (d *schemaDecoder) readString() string {
+	return string(d.read(d.readCount()))
+}
@@ -0,0 +1,175 @@
+package etw
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// encodingTestSchema fills every field the encodings carry.
+func encodingTestSchema() *Schema {
+	return &Schema{
+		ProviderGUID:          testProviderGUID,
+		EventGUID:             *winguid.MustParse("{3D6FA8D0-FE05-11D0-9DDA-00C04FD7BA7C}"),
+		EventDescriptor:       winapi.EventDescriptor{Id: 1, Version: 5, Channel: 16, Level: 4, Opcode: 1, Task: 1, Keyword: 0x8000000000000000},
+		DecodingSource:        winapi.DecodingSourceXMLFile,
+		Flags:                 winapi.TEMPLATE_EVENT_DATA,
+		Tags:                  3,
+		ProviderName:          "Test-Provider",
+		LevelName:             "Information",
+		ChannelName:           "Test-Provider/Operational",
+		KeywordsName:          "Keyword1",
+		TaskName:              "Task1",
+		OpcodeName:            "Start",
+		EventMessage:          "Process %1 started",
+		ProviderMessage:       "Test Provider",
+		ActivityIDName:        "Activity",
+		RelatedActivityIDName: "Related",
+		Properties: []PropertyInfo{
+			{Name: "Image", InType: winapi.TdhInTypeUnicodestring, OutType: winapi.TdhOutTypeString},
+			{Name: "State", InType: winapi.TdhInTypeUint32, MapName: "StateMap", Tags: 7},
+			{Name: "Endpoint", Flags: winapi.PropertyStruct, StructStartIndex: 3, NumOfStructMembers: 1, Count: 1},
+			{Name: "Port", InType: winapi.TdhInTypeUint16, OutType: winapi.TdhOutTypePort, Count: 1, Length: 2},
+		},
+		TopLevelPropertyCount: 3,
+		Maps: map[string]*ValueMap{
+			"StateMap": {Name: "StateMap", Flag: winapi.EVENTMAP_INFO_FLAG_MANIFEST_VALUEMAP, Entries: []ValueMapEntry{
+				{Value: 0, Output: "Stopped"},
+				{Value: 1, Output: "Running"},
+			}},
+			"PatternMap": {Name: "PatternMap", Flag: winapi.EVENTMAP_INFO_FLAG_MANIFEST_PATTERNMAP, Entries: []ValueMapEntry{
+				{Input: "*.exe", Output: "Executable"},
+			}},
+		},
+	}
+}
+
+func TestSchemaBinaryRoundTrip(t *testing.T) {
+	schema := encodingTestSchema()
+	data, err := schema.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	decoded := Schema{}
+	if err = decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, schema) {
+		t.Errorf("decoded = %+v, want %+v", decoded, *schema)
+	}
+
+	again, _ := decoded.MarshalBinary()
+	if !bytes.Equal(again, data) {
+		t.Error("encoding is not stable")
+	}
+}
+
+func TestSchemaUnmarshalBinaryErrors(t *testing.T) {
+	data, _ := encodingTestSchema().MarshalBinary()
+
+	unsupported := append([]byte{}, data...)
+	unsupported[4] = 9
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad magic", append([]byte("ETWX"), data[4:]...)},
+		{"unsupported version", unsupported},
+	}
+	for i := 5; i < len(data); i += 13 {
+		tests = append(tests, struct {
+			name string
+			data []byte
+		}{"truncated", data[:i]})
+	}
+
+	for _, test := range tests {
+		schema := Schema{}
+		if err := schema.UnmarshalBinary(test.data); !errors.Is(err, ErrSchemaEncoding) {
+			t.Errorf("%s (%d bytes): err = %v, want %v", test.name, len(test.data), err, ErrSchemaEncoding)
+		}
+	}
+}
+
+func TestSchemaJSONRoundTrip(t *testing.T) {
+	schema := encodingTestSchema()
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var fields map[string]interface{}
+	if err = json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if fields["provider_guid"] != "{5770385F-C22A-43E0-BF4C-06F5698FFBD9}" {
+		t.Errorf("provider_guid = %v", fields["provider_guid"])
+	}
+	if descriptor, _ := fields["descriptor"].(map[string]interface{}); descriptor["id"] != float64(1) {
+		t.Errorf("descriptor = %v", fields["descriptor"])
+	}
+
+	decoded := Schema{}
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, schema) {
+		t.Errorf("decoded = %+v, want %+v", decoded, *schema)
+	}
+}
+
+func TestSchemaBundleRoundTrip(t *testing.T) {
+	first := encodingTestSchema()
+	second := testSchema(-1, PropertyInfo{Name: "Value", InType: winapi.TdhInTypeUint8})
+
+	var buffer bytes.Buffer
+	if err := WriteSchemas(&buffer, []*Schema{first, second}); err != nil {
+		t.Fatalf("WriteSchemas: %v", err)
+	}
+	schemas, err := ReadSchemas(&buffer)
+	if err != nil {
+		t.Fatalf("ReadSchemas: %v", err)
+	}
+	if len(schemas) != 2 || !reflect.DeepEqual(schemas[0], first) || schemas[1].Properties[0].Name != "Value" {
+		t.Errorf("schemas = %+v", schemas)
+	}
+
+	if _, err = ReadSchemas(bytes.NewReader([]byte("ETWS"))); !errors.Is(err, ErrSchemaEncoding) {
+		t.Errorf("bad bundle: err = %v, want %v", err, ErrSchemaEncoding)
+	}
+}
+
+func FuzzSchemaUnmarshalBinary(f *testing.F) {
+	data, _ := encodingTestSchema().MarshalBinary()
+	f.Add(data)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		schema := Schema{}
+		if err := schema.UnmarshalBinary(data); err != nil {
+			if !errors.Is(err, ErrSchemaEncoding) {
+				t.Fatalf("err = %v, want %v", err, ErrSchemaEncoding)
+			}
+			return
+		}
+
+		encoded, err := schema.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary of a decoded schema: %v", err)
+		}
+		again := Schema{}
+		if err = again.UnmarshalBinary(encoded); err != nil {
+			t.Fatalf("UnmarshalBinary of a re-encoded schema: %v", err)
+		}
+		if !reflect.DeepEqual(&again, &schema) {
+			t.Errorf("re-encoded = %+v, want %+v", again, schema)
+		}
+	})
+}
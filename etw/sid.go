@@ -0,0 +1,117 @@
+package etw
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-sid
+// https://learn.microsoft.com/en-us/windows/win32/secauthz/sid-components
+
+// SID is a security identifier decoded from its binary representation.
+type SID struct {
+	Revision            uint8
+	IdentifierAuthority [6]byte
+	SubAuthorities      []uint32
+}
+
+const sidHeaderSize = 8
+
+// parseSID reads a binary SID and returns it along with the number of bytes it occupies.
+func parseSID(data []byte) (SID, int, error) {
+	if len(data) < sidHeaderSize {
+		return SID{}, 0, ErrTruncatedProperty
+	}
+
+	subAuthorityCount := int(data[1])
+	size := sidHeaderSize + 4*subAuthorityCount
+	if len(data) < size {
+		return SID{}, 0, ErrTruncatedProperty
+	}
+
+	sid := SID{
+		Revision:       data[0],
+		SubAuthorities: make([]uint32, subAuthorityCount),
+	}
+	copy(sid.IdentifierAuthority[:], data[2:8])
+	for i := range sid.SubAuthorities {
+		sid.SubAuthorities[i] = binary.LittleEndian.Uint32(data[sidHeaderSize+4*i:])
+	}
+
+	return sid, size, nil
+}
+
+// ParseSID parses the string form of a SID, such as S-1-5-18.
+func ParseSID(s string) (SID, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 3 || !strings.EqualFold(parts[0], "S") {
+		return SID{}, fmt.Errorf("bad SID format")
+	}
+
+	revision, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil {
+		return SID{}, fmt.Errorf("bad SID revision: %w", err)
+	}
+
+	authority, err := strconv.ParseUint(parts[2], 0, 48)
+	if err != nil {
+		return SID{}, fmt.Errorf("bad SID authority: %w", err)
+	}
+
+	sid := SID{
+		Revision:       uint8(revision),
+		SubAuthorities: make([]uint32, 0, len(parts)-3),
+	}
+	for i := 0; i < 6; i++ {
+		sid.IdentifierAuthority[5-i] = uint8(authority >> (8 * i))
+	}
+
+	for _, part := range parts[3:] {
+		subAuthority, parseErr := strconv.ParseUint(part, 10, 32)
+		if parseErr != nil {
+			return SID{}, fmt.Errorf("bad SID sub authority: %w", parseErr)
+		}
+		sid.SubAuthorities = append(sid.SubAuthorities, uint32(subAuthority))
+	}
+
+	return sid, nil
+}
+
+// Bytes returns the binary representation of the SID.
+func (s SID) Bytes() []byte {
+	data := make([]byte, sidHeaderSize+4*len(s.SubAuthorities))
+	data[0] = s.Revision
+	data[1] = uint8(len(s.SubAuthorities))
+	copy(data[2:8], s.IdentifierAuthority[:])
+	for i, subAuthority := range s.SubAuthorities {
+		binary.LittleEndian.PutUint32(data[sidHeaderSize+4*i:], subAuthority)
+	}
+	return data
+}
+
+// String returns the S-R-I-S... form of the SID. Authorities that do not fit in 32 bits are written in hexadecimal.
+func (s SID) String() string {
+	var builder strings.Builder
+	builder.WriteString("S-")
+	builder.WriteString(strconv.FormatUint(uint64(s.Revision), 10))
+	builder.WriteByte('-')
+
+	var authority uint64
+	for _, b := range s.IdentifierAuthority {
+		authority = authority<<8 | uint64(b)
+	}
+	if s.IdentifierAuthority[0] == 0 && s.IdentifierAuthority[1] == 0 {
+		builder.WriteString(strconv.FormatUint(authority, 10))
+	} else {
+		builder.WriteString(fmt.Sprintf("0x%012X", authority))
+	}
+
+	for _, subAuthority := range s.SubAuthorities {
+		builder.WriteByte('-')
+		builder.WriteString(strconv.FormatUint(uint64(subAuthority), 10))
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,207 @@
+package etw
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/etw/image-load
+// https://learn.microsoft.com/en-us/windows/win32/etw/process
+
+const kernelProcessProviderName = "Microsoft-Windows-Kernel-Process"
+
+// Event IDs of Microsoft-Windows-Kernel-Process, enabled with the WINEVENT_KEYWORD_PROCESS and WINEVENT_KEYWORD_IMAGE keywords.
+const (
+	kernelProcessStopEventID   = 2
+	kernelProcessImageLoadID   = 5
+	kernelProcessImageUnloadID = 6
+)
+
+// Opcodes of the kernel MOF classes Process and ImageLoad.
+const (
+	mofProcessEndOpcode   = 2
+	mofImageUnloadOpcode  = 2
+	mofImageDCStartOpcode = 3
+	mofImageLoadOpcode    = 10
+)
+
+// SystemProcessID is the process the modules loaded in kernel space are attributed to, they are shared by every process.
+const SystemProcessID = 0
+
+// Module is an image mapped in the address space of a process.
+type Module struct {
+	Name string // path of the image
+	Base uint64
+	Size uint64
+}
+
+func (m Module) contains(address uint64) bool {
+	return address >= m.Base && address-m.Base < m.Size
+}
+
+// Frame is a stack address resolved to its module.
+type Frame struct {
+	Address uint64
+	Module  string // empty when the address is in no known module
+	Offset  uint64 // from the module base
+}
+
+// String renders the frame as module+0xoffset, the module being named by the file name of its image,
+// or as the bare address.
+func (f Frame) String() string {
+	if f.Module == "" {
+		return fmt.Sprintf("0x%x", f.Address)
+	}
+	name := f.Module
+	if i := strings.LastIndexAny(name, `\/`); i >= 0 {
+		name = name[i+1:]
+	}
+	return fmt.Sprintf("%s+0x%x", name, f.Offset)
+}
+
+// Symbolizer maps stack addresses to module+offset, from the modules of each process.
+// Modules are added by hand, or observed from the image load events of the kernel ImageLoad MOF class and of
+// Microsoft-Windows-Kernel-Process, so that events are symbolized against the modules loaded when they were logged.
+type Symbolizer struct {
+	mutex   sync.RWMutex
+	modules map[uint32][]Module // by process ID, sorted by base
+}
+
+func NewSymbolizer() *Symbolizer {
+	return &Symbolizer{modules: make(map[uint32][]Module)}
+}
+
+// AddModule maps module in process processID, replacing the module previously loaded at the same base.
+func (s *Symbolizer) AddModule(processID uint32, module Module) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	modules := s.modules[processID]
+	i := sort.Search(len(modules), func(i int) bool { return modules[i].Base >= module.Base })
+	if i < len(modules) && modules[i].Base == module.Base {
+		modules[i] = module
+		return
+	}
+	modules = append(modules, Module{})
+	copy(modules[i+1:], modules[i:])
+	modules[i] = module
+	s.modules[processID] = modules
+}
+
+// RemoveModule unmaps the module loaded at base in process processID.
+func (s *Symbolizer) RemoveModule(processID uint32, base uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	modules := s.modules[processID]
+	i := sort.Search(len(modules), func(i int) bool { return modules[i].Base >= base })
+	if i < len(modules) && modules[i].Base == base {
+		s.modules[processID] = append(modules[:i], modules[i+1:]...)
+	}
+}
+
+// RemoveProcess forgets the modules of process processID.
+func (s *Symbolizer) RemoveProcess(processID uint32) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.modules, processID)
+}
+
+// Modules returns the modules of process processID, sorted by base.
+func (s *Symbolizer) Modules(processID uint32) []Module {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return append([]Module(nil), s.modules[processID]...)
+}
+
+// Symbolize resolves address in process processID, falling back to the modules of SystemProcessID.
+func (s *Symbolizer) Symbolize(processID uint32, address uint64) Frame {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if module, ok := s.lookup(processID, address); ok {
+		return Frame{Address: address, Module: module.Name, Offset: address - module.Base}
+	}
+	if processID != SystemProcessID {
+		if module, ok := s.lookup(SystemProcessID, address); ok {
+			return Frame{Address: address, Module: module.Name, Offset: address - module.Base}
+		}
+	}
+	return Frame{Address: address}
+}
+
+func (s *Symbolizer) lookup(processID uint32, address uint64) (Module, bool) {
+	modules := s.modules[processID]
+	i := sort.Search(len(modules), func(i int) bool { return modules[i].Base > address })
+	if i == 0 {
+		return Module{}, false
+	}
+	module := modules[i-1]
+	return module, module.contains(address)
+}
+
+// SymbolizeStack resolves the stack trace of event, in the process that logged it. It returns nil for events without stack.
+func (s *Symbolizer) SymbolizeStack(event *Event) []Frame {
+	if event.StackTrace == nil {
+		return nil
+	}
+	frames := make([]Frame, 0, len(event.StackTrace.Addresses))
+	for _, address := range event.StackTrace.Addresses {
+		frames = append(frames, s.Symbolize(event.System.Execution.ProcessID, address))
+	}
+	return frames
+}
+
+// Observe updates the modules from image load and unload events, and forgets the modules of terminated processes.
+// Other events are ignored.
+func (s *Symbolizer) Observe(event *Event) {
+	if event.System.Provider.Name == kernelProcessProviderName {
+		switch event.System.EventID {
+		case kernelProcessImageLoadID:
+			if module, processID, ok := eventModule(event, "ProcessID", "ImageName"); ok {
+				s.AddModule(processID, module)
+			}
+		case kernelProcessImageUnloadID:
+			if module, processID, ok := eventModule(event, "ProcessID", "ImageName"); ok {
+				s.RemoveModule(processID, module.Base)
+			}
+		case kernelProcessStopEventID:
+			if processID, ok := event.Property("ProcessID"); ok {
+				s.RemoveProcess(uint32(processID.Uint()))
+			}
+		}
+		return
+	}
+
+	class, _, _ := strings.Cut(event.System.EventType, "/") // set for MOF events, as class/opcode
+	switch {
+	case class == "ImageLoad":
+		module, processID, ok := eventModule(event, "ProcessId", "FileName")
+		if !ok {
+			return
+		}
+		switch event.System.Opcode.Value {
+		case mofImageLoadOpcode, mofImageDCStartOpcode:
+			s.AddModule(processID, module)
+		case mofImageUnloadOpcode:
+			s.RemoveModule(processID, module.Base)
+		}
+	case class == "Process" && event.System.Opcode.Value == mofProcessEndOpcode:
+		if processID, ok := event.Property("ProcessId"); ok {
+			s.RemoveProcess(uint32(processID.Uint()))
+		}
+	}
+}
+
+func eventModule(event *Event, processIDName, imageName string) (Module, uint32, bool) {
+	base, okBase := event.Property("ImageBase")
+	size, okSize := event.Property("ImageSize")
+	processID, okProcessID := event.Property(processIDName)
+	if !okBase || !okSize || !okProcessID {
+		return Module{}, 0, false
+	}
+	name, _ := event.Property(imageName)
+	return Module{Name: name.String(), Base: base.Uint(), Size: size.Uint()}, uint32(processID.Uint()), true
+}
@@ -0,0 +1,156 @@
+package etw
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+var imageLoadClassGUID = *winguid.MustParse("{2CB15D1D-5FC1-11D2-ABE1-00A0C911F518}")
+
+func TestSymbolize(t *testing.T) {
+	symbolizer := NewSymbolizer()
+	symbolizer.AddModule(100, Module{Name: `C:\Windows\System32\ntdll.dll`, Base: 0x7ff800000000, Size: 0x1f0000})
+	symbolizer.AddModule(100, Module{Name: `C:\app\app.exe`, Base: 0x400000, Size: 0x10000})
+	symbolizer.AddModule(SystemProcessID, Module{Name: `\SystemRoot\system32\ntoskrnl.exe`, Base: 0xfffff80000000000, Size: 0x1000000})
+
+	tests := []struct {
+		processID uint32
+		address   uint64
+		want      Frame
+		string    string
+	}{
+		{100, 0x401234, Frame{Address: 0x401234, Module: `C:\app\app.exe`, Offset: 0x1234}, "app.exe+0x1234"},
+		{100, 0x400000, Frame{Address: 0x400000, Module: `C:\app\app.exe`}, "app.exe+0x0"},
+		{100, 0x7ff8000a0010, Frame{Address: 0x7ff8000a0010, Module: `C:\Windows\System32\ntdll.dll`, Offset: 0xa0010}, "ntdll.dll+0xa0010"},
+		{100, 0xfffff80000123456, Frame{Address: 0xfffff80000123456, Module: `\SystemRoot\system32\ntoskrnl.exe`, Offset: 0x123456}, "ntoskrnl.exe+0x123456"},
+		{100, 0x410000, Frame{Address: 0x410000}, "0x410000"}, // right after app.exe
+		{100, 0x3fffff, Frame{Address: 0x3fffff}, "0x3fffff"},
+		{200, 0x401234, Frame{Address: 0x401234}, "0x401234"}, // modules of another process
+	}
+
+	for _, test := range tests {
+		frame := symbolizer.Symbolize(test.processID, test.address)
+		if frame != test.want || frame.String() != test.string {
+			t.Errorf("Symbolize(%d, 0x%x) = %+v %q, want %+v %q", test.processID, test.address, frame, frame.String(), test.want, test.string)
+		}
+	}
+}
+
+func TestSymbolizerModules(t *testing.T) {
+	symbolizer := NewSymbolizer()
+	symbolizer.AddModule(100, Module{Name: "b.dll", Base: 0x20000, Size: 0x1000})
+	symbolizer.AddModule(100, Module{Name: "a.dll", Base: 0x10000, Size: 0x1000})
+	symbolizer.AddModule(100, Module{Name: "c.dll", Base: 0x20000, Size: 0x2000})
+
+	want := []Module{{Name: "a.dll", Base: 0x10000, Size: 0x1000}, {Name: "c.dll", Base: 0x20000, Size: 0x2000}}
+	if modules := symbolizer.Modules(100); !reflect.DeepEqual(modules, want) {
+		t.Errorf("Modules = %+v, want %+v, sorted and replaced at the same base", modules, want)
+	}
+	if frame := symbolizer.Symbolize(100, 0x21800); frame.Module != "c.dll" {
+		t.Errorf("the replacing module is not used, got %+v", frame)
+	}
+
+	symbolizer.RemoveModule(100, 0x10000)
+	symbolizer.RemoveModule(100, 0x15000) // not a base
+	if modules := symbolizer.Modules(100); len(modules) != 1 || modules[0].Name != "c.dll" {
+		t.Errorf("after RemoveModule, Modules = %+v", modules)
+	}
+	if frame := symbolizer.Symbolize(100, 0x10010); frame.Module != "" {
+		t.Errorf("removed module still resolves: %+v", frame)
+	}
+
+	symbolizer.RemoveProcess(100)
+	if modules := symbolizer.Modules(100); len(modules) != 0 {
+		t.Errorf("after RemoveProcess, Modules = %+v", modules)
+	}
+}
+
+func TestSymbolizeStack(t *testing.T) {
+	symbolizer := NewSymbolizer()
+	symbolizer.AddModule(100, Module{Name: "app.exe", Base: 0x400000, Size: 0x10000})
+
+	event := &Event{}
+	if frames := symbolizer.SymbolizeStack(event); frames != nil {
+		t.Errorf("without stack, frames = %v", frames)
+	}
+
+	event.System.Execution.ProcessID = 100
+	event.StackTrace = &StackTrace{Addresses: []uint64{0x400010, 0x900000}}
+	want := []Frame{{Address: 0x400010, Module: "app.exe", Offset: 0x10}, {Address: 0x900000}}
+	if frames := symbolizer.SymbolizeStack(event); !reflect.DeepEqual(frames, want) {
+		t.Errorf("frames = %+v, want %+v", frames, want)
+	}
+}
+
+// imageLoadRecord is an ImageLoad MOF event of type opcode, for a module of process processID.
+func imageLoadRecord(opcode uint8, processID uint32, base uint64, name string) *Record {
+	record := testRecord(userData(
+		base, uint64(0x8000), processID, uint32(0), uint32(0), uint32(0),
+		base, uint32(0), uint32(0), uint32(0), uint32(0),
+		utf16z(name),
+	))
+	record.EventHeader.Flags = winapi.EVENT_HEADER_FLAG_CLASSIC_HEADER | winapi.EVENT_HEADER_FLAG_64_BIT_HEADER
+	record.EventHeader.ProviderId = imageLoadClassGUID
+	record.EventHeader.EventDescriptor = winapi.EventDescriptor{Opcode: opcode, Version: 2}
+	return record
+}
+
+// kernelProcessEvent is an event of Microsoft-Windows-Kernel-Process with properties.
+func kernelProcessEvent(id uint16, properties ...Property) *Event {
+	event := &Event{Properties: properties}
+	event.System.Provider.Name = kernelProcessProviderName
+	event.System.EventID = id
+	return event
+}
+
+func uint64Property(name string, value uint64) Property {
+	return Property{Name: name, Value: NewValue(value, winapi.TdhInTypeUint64, winapi.TdhOutTypeNull)}
+}
+
+func TestSymbolizerObserve(t *testing.T) {
+	symbolizer := NewSymbolizer()
+	backend := NewMOFBackend(nil)
+
+	observe := func(record *Record) {
+		event, err := DecodeRecord(record, backend)
+		if err != nil {
+			t.Fatalf("DecodeRecord: %v", err)
+		}
+		symbolizer.Observe(event)
+	}
+
+	observe(imageLoadRecord(mofImageLoadOpcode, 100, 0x400000, `C:\app\app.exe`))
+	observe(imageLoadRecord(mofImageDCStartOpcode, 100, 0x500000, `C:\app\lib.dll`))
+	if modules := symbolizer.Modules(100); len(modules) != 2 || modules[0].Name != `C:\app\app.exe` || modules[0].Size != 0x8000 {
+		t.Fatalf("after ImageLoad events, Modules = %+v", modules)
+	}
+
+	observe(imageLoadRecord(mofImageUnloadOpcode, 100, 0x500000, `C:\app\lib.dll`))
+	if frame := symbolizer.Symbolize(100, 0x500010); frame.Module != "" {
+		t.Errorf("unloaded module still resolves: %+v", frame)
+	}
+
+	symbolizer.Observe(kernelProcessEvent(kernelProcessImageLoadID,
+		uint64Property("ImageBase", 0x600000), uint64Property("ImageSize", 0x1000),
+		uint64Property("ProcessID", 200), Property{Name: "ImageName", Value: NewValue("other.dll", winapi.TdhInTypeNull, winapi.TdhOutTypeNull)},
+	))
+	if frame := symbolizer.Symbolize(200, 0x600010); frame.String() != "other.dll+0x10" {
+		t.Errorf("after Kernel-Process image load, frame = %s", frame)
+	}
+
+	symbolizer.Observe(kernelProcessEvent(kernelProcessImageLoadID, uint64Property("ImageBase", 0x700000)))
+	if modules := symbolizer.Modules(200); len(modules) != 1 {
+		t.Errorf("image load without size and process is ignored, Modules = %+v", modules)
+	}
+
+	symbolizer.Observe(kernelProcessEvent(kernelProcessStopEventID, uint64Property("ProcessID", 200)))
+	if modules := symbolizer.Modules(200); len(modules) != 0 {
+		t.Errorf("after process stop, Modules = %+v", modules)
+	}
+	if modules := symbolizer.Modules(100); len(modules) != 1 {
+		t.Errorf("other processes keep their modules, Modules = %+v", modules)
+	}
+}
@@ -0,0 +1,387 @@
+package etw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/tracelogging/trace-logging-about
+// https://github.com/microsoft/tracelogging/blob/main/etw/traceloggingprovider/TraceLoggingProvider.h
+//
+// TraceLogging events describe themselves: the EVENT_HEADER_EXT_TYPE_EVENT_SCHEMA_TL extended data item
+// holds the event metadata, and EVENT_HEADER_EXT_TYPE_PROV_TRAITS the provider name.
+//
+//	event metadata: size:u16 tags:extension name:utf8z field*
+//	field:          name:utf8z in_type:u8 [out_type:u8 [tags:extension]] [count:u16] [custom_size:u16 custom_schema]
+//	provider traits: size:u16 name:utf8z trait*
+//
+// Extensions are read until a byte without its high bit.
+
+const (
+	tlgInTypeMask = 0x1F
+	tlgInCount    = 0x60 // mask of the array flags
+	tlgInCcount   = 0x20 // constant element count, in the metadata
+	tlgInVcount   = 0x40 // variable element count, UINT16 preceding the elements
+	tlgInCustom   = 0x60 // custom serialization, with its own schema
+	tlgInChain    = 0x80 // an out type follows
+
+	tlgOutTypeMask = 0x7F
+	tlgOutChain    = 0x80 // tags follow
+
+	tlgExtensionChain = 0x80
+
+	tlgInStruct = 24 // TDH_INTYPE 24 is reserved, the out type holds the member count
+)
+
+// TraceLogging out types, numbered independently of TDH_OUTTYPE below TlgOutUTF8.
+const (
+	tlgOutNull = iota
+	tlgOutNoPrint
+	tlgOutString
+	tlgOutBoolean
+	tlgOutHex
+	tlgOutPID
+	tlgOutTID
+	tlgOutPort
+	tlgOutIPv4
+	tlgOutIPv6
+	tlgOutSocketAddress
+	tlgOutXML
+	tlgOutJSON
+	tlgOutWin32Error
+	tlgOutNTStatus
+	tlgOutHResult
+	tlgOutFiletime
+	tlgOutSigned
+	tlgOutUnsigned
+
+	tlgOutUTF8        = 35
+	tlgOutPkcs7       = 36
+	tlgOutCodePointer = 37
+	tlgOutDatetimeUTC = 38
+)
+
+var tlgOutTypes = map[uint8]winapi.TdhOutType{
+	tlgOutNull:          winapi.TdhOutTypeNull,
+	tlgOutNoPrint:       winapi.TdhOutTypeNOPRINT,
+	tlgOutString:        winapi.TdhOutTypeString,
+	tlgOutBoolean:       winapi.TdhOutTypeBoolean,
+	tlgOutPID:           winapi.TdhOutTypePid,
+	tlgOutTID:           winapi.TdhOutTypeTid,
+	tlgOutPort:          winapi.TdhOutTypePort,
+	tlgOutIPv4:          winapi.TdhOutTypeIpv4,
+	tlgOutIPv6:          winapi.TdhOutTypeIpv6,
+	tlgOutSocketAddress: winapi.TdhOutTypeSocketaddress,
+	tlgOutXML:           winapi.TdhOutTypeXML,
+	tlgOutJSON:          winapi.TdhOutTypeJSON,
+	tlgOutWin32Error:    winapi.TdhOutTypeWin32error,
+	tlgOutNTStatus:      winapi.TdhOutTypeNtstatus,
+	tlgOutHResult:       winapi.TdhOutTypeHresult,
+	tlgOutFiletime:      winapi.TdhOutTypeDatetime,
+	tlgOutUTF8:          winapi.TdhOutTypeUTF8,
+	tlgOutPkcs7:         winapi.TdhOutTypePkcs7WithTypeInfo,
+	tlgOutCodePointer:   winapi.TdhOutTypeCodePointer,
+	tlgOutDatetimeUTC:   winapi.TdhOutTypeDatetimeUTC,
+}
+
+var (
+	ErrInvalidTraceLogging = fmt.Errorf("invalid TraceLogging metadata")
+)
+
+// TraceLoggingBackend decodes TraceLogging events from the metadata they carry, without TDH,
+// and hands the other records to its DecoderBackend, when not nil.
+// Schemas are built for each record: TraceLogging events share their ID, usually 0, across event names.
+type TraceLoggingBackend struct {
+	DecoderBackend
+}
+
+func NewTraceLoggingBackend(backend DecoderBackend) *TraceLoggingBackend {
+	return &TraceLoggingBackend{DecoderBackend: backend}
+}
+
+func (t *TraceLoggingBackend) EventSchema(record *Record) (*Schema, error) {
+	if _, ok := record.ExtendedDataItem(winapi.EVENT_HEADER_EXT_TYPE_EVENT_SCHEMA_TL); ok {
+		return TraceLoggingSchema(record)
+	}
+	if t.DecoderBackend == nil {
+		return nil, ErrSchemaNotFound
+	}
+	return t.DecoderBackend.EventSchema(record)
+}
+
+func (t *TraceLoggingBackend) FormatProperty(record *Record, schema *Schema, property *PropertyInfo, length uint32, data []byte) (string, int, error) {
+	if schema.IsTraceLogging() || t.DecoderBackend == nil {
+		return "", 0, ErrFormatUnsupported
+	}
+	return t.DecoderBackend.FormatProperty(record, schema, property, length, data)
+}
+
+// TraceLoggingSchema builds the schema of record from its EVENT_HEADER_EXT_TYPE_EVENT_SCHEMA_TL item.
+// The event name is reported as the task name, as TDH does.
+func TraceLoggingSchema(record *Record) (*Schema, error) {
+	metadata, ok := record.ExtendedDataItem(winapi.EVENT_HEADER_EXT_TYPE_EVENT_SCHEMA_TL)
+	if !ok {
+		return nil, ErrSchemaNotFound
+	}
+
+	reader, err := newTlgReader(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := Schema{
+		ProviderGUID:    record.EventHeader.ProviderId,
+		EventDescriptor: record.EventHeader.EventDescriptor,
+		DecodingSource:  winapi.DecodingSourceTlg,
+		Flags:           winapi.TEMPLATE_EVENT_DATA,
+	}
+	schema.Tags = reader.extension()
+	schema.TaskName = reader.name()
+
+	var fields []*tlgField
+	for reader.err == nil && reader.remaining() > 0 {
+		fields = append(fields, reader.field(0))
+	}
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	schema.Properties, schema.TopLevelPropertyCount = layoutTlgFields(fields)
+
+	if traits, ok := record.ExtendedDataItem(winapi.EVENT_HEADER_EXT_TYPE_PROV_TRAITS); ok {
+		if traitsReader, traitsErr := newTlgReader(traits); traitsErr == nil {
+			schema.ProviderName = traitsReader.name()
+		}
+	}
+
+	return &schema, nil
+}
+
+// tlgField is a field of the metadata, with the members of structures.
+type tlgField struct {
+	info    PropertyInfo
+	members []*tlgField
+}
+
+// layoutTlgFields lists the top level fields first, then the members of each structure
+// after the members of the previous ones, as TRACE_EVENT_INFO does.
+func layoutTlgFields(fields []*tlgField) ([]PropertyInfo, int) {
+	properties := make([]PropertyInfo, 0, len(fields))
+	var structures []int // indexes in properties
+	var pending [][]*tlgField
+
+	for _, field := range fields {
+		if field.info.IsStruct() {
+			structures = append(structures, len(properties))
+			pending = append(pending, field.members)
+		}
+		properties = append(properties, field.info)
+	}
+	topLevelCount := len(properties)
+
+	for i := 0; i < len(structures); i++ {
+		properties[structures[i]].StructStartIndex = uint16(len(properties))
+		for _, member := range pending[i] {
+			if member.info.IsStruct() {
+				structures = append(structures, len(properties))
+				pending = append(pending, member.members)
+			}
+			properties = append(properties, member.info)
+		}
+	}
+
+	return properties, topLevelCount
+}
+
+// maxTlgDepth bounds the nesting of structures, each level needs at least one metadata byte anyway.
+const maxTlgDepth = 32
+
+// tlgReader reads TraceLogging metadata, the first error sticks and stops the reading.
+type tlgReader struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+func newTlgReader(data []byte) (*tlgReader, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidTraceLogging, len(data))
+	}
+	size := int(binary.LittleEndian.Uint16(data))
+	if size < 2 || size > len(data) {
+		return nil, fmt.Errorf("%w: size %d out of %d bytes", ErrInvalidTraceLogging, size, len(data))
+	}
+	return &tlgReader{data: data[:size], offset: 2}, nil
+}
+
+func (r *tlgReader) remaining() int {
+	return len(r.data) - r.offset
+}
+
+func (r *tlgReader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf("%w: %s", ErrInvalidTraceLogging, fmt.Sprintf(format, args...))
+	}
+}
+
+func (r *tlgReader) byte() uint8 {
+	if r.err != nil {
+		return 0
+	}
+	if r.remaining() < 1 {
+		r.fail("truncated at offset %d", r.offset)
+		return 0
+	}
+	b := r.data[r.offset]
+	r.offset++
+	return b
+}
+
+func (r *tlgReader) uint16() uint16 {
+	if r.err != nil {
+		return 0
+	}
+	if r.remaining() < 2 {
+		r.fail("truncated at offset %d", r.offset)
+		return 0
+	}
+	v := binary.LittleEndian.Uint16(r.data[r.offset:])
+	r.offset += 2
+	return v
+}
+
+func (r *tlgReader) name() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.data[r.offset:], 0)
+	if end < 0 {
+		r.fail("unterminated name at offset %d", r.offset)
+		return ""
+	}
+	name := string(r.data[r.offset : r.offset+end])
+	r.offset += end + 1
+	return name
+}
+
+// extension reads the extension bytes, and returns the 28 bits of tags they hold, 7 bits per byte
+// from the most significant ones. Bytes beyond the tags are skipped.
+func (r *tlgReader) extension() uint32 {
+	var tags uint32
+	shift := 21
+	for r.err == nil {
+		b := r.byte()
+		if shift >= 0 {
+			tags |= uint32(b&^tlgExtensionChain) << shift
+			shift -= 7
+		}
+		if b&tlgExtensionChain == 0 {
+			break
+		}
+	}
+	return tags
+}
+
+func (r *tlgReader) field(depth int) *tlgField {
+	field := &tlgField{}
+	field.info.Name = r.name()
+	inType := r.byte()
+	outType := uint8(0)
+	if inType&tlgInChain != 0 {
+		outType = r.byte()
+		if outType&tlgOutChain != 0 {
+			field.info.Tags = r.extension()
+			field.info.Flags |= winapi.PropertyHasTags
+		}
+	}
+
+	switch inType & tlgInCount {
+	case tlgInCcount:
+		field.info.Count = r.uint16()
+		field.info.Flags |= winapi.PropertyParamFixedCount
+	case tlgInVcount:
+		field.info.Flags |= PropertyParamCountPrefix
+	case tlgInCustom:
+		// the value is the UINT16 size of the serialized data followed by the data, the custom schema is skipped
+		schemaSize := int(r.uint16())
+		if r.err == nil && schemaSize > r.remaining() {
+			r.fail("custom schema of field %s out of metadata", field.info.Name)
+		}
+		r.offset += schemaSize
+		field.info.Flags |= winapi.PropertyHasCustomSchema
+		field.info.InType = winapi.TdhInTypeManifestCountedbinary
+		field.info.OutType = winapi.TdhOutTypeHexbinary
+		return field
+	}
+
+	if r.err != nil {
+		return field
+	}
+
+	if inType&tlgInTypeMask == tlgInStruct {
+		memberCount := int(outType & tlgOutTypeMask)
+		if memberCount == 0 || depth >= maxTlgDepth {
+			r.fail("structure %s with %d members at depth %d", field.info.Name, memberCount, depth)
+			return field
+		}
+		field.info.Flags |= winapi.PropertyStruct
+		field.info.NumOfStructMembers = uint16(memberCount)
+		for i := 0; i < memberCount && r.err == nil; i++ {
+			field.members = append(field.members, r.field(depth+1))
+		}
+		return field
+	}
+
+	field.info.InType, field.info.OutType = tlgTypes(inType&tlgInTypeMask, outType&tlgOutTypeMask)
+	return field
+}
+
+// tlgTypes converts TraceLogging types to TDH types. Binary data is prefixed by its UINT16 size, like counted binary,
+// and the hexadecimal or signedness out types depend on the in type size.
+func tlgTypes(inType uint8, outType uint8) (winapi.TdhInType, winapi.TdhOutType) {
+	tdhInType := winapi.TdhInType(inType)
+	if tdhInType == winapi.TdhInTypeBinary {
+		tdhInType = winapi.TdhInTypeManifestCountedbinary
+	}
+
+	switch outType {
+	case tlgOutHex:
+		switch tdhInType {
+		case winapi.TdhInTypeInt8, winapi.TdhInTypeUint8:
+			return tdhInType, winapi.TdhOutTypeHexint8
+		case winapi.TdhInTypeInt16, winapi.TdhInTypeUint16:
+			return tdhInType, winapi.TdhOutTypeHexint16
+		case winapi.TdhInTypeInt32, winapi.TdhInTypeUint32, winapi.TdhInTypeHexint32:
+			return tdhInType, winapi.TdhOutTypeHexint32
+		case winapi.TdhInTypeInt64, winapi.TdhInTypeUint64, winapi.TdhInTypeHexint64:
+			return tdhInType, winapi.TdhOutTypeHexint64
+		}
+		return tdhInType, winapi.TdhOutTypeHexbinary
+	case tlgOutSigned:
+		switch tdhInType {
+		case winapi.TdhInTypeInt8, winapi.TdhInTypeUint8:
+			return winapi.TdhInTypeInt8, winapi.TdhOutTypeByte
+		case winapi.TdhInTypeInt16, winapi.TdhInTypeUint16:
+			return winapi.TdhInTypeInt16, winapi.TdhOutTypeShort
+		case winapi.TdhInTypeInt32, winapi.TdhInTypeUint32, winapi.TdhInTypeHexint32:
+			return winapi.TdhInTypeInt32, winapi.TdhOutTypeInt
+		case winapi.TdhInTypeInt64, winapi.TdhInTypeUint64, winapi.TdhInTypeHexint64:
+			return winapi.TdhInTypeInt64, winapi.TdhOutTypeLong
+		}
+	case tlgOutUnsigned:
+		switch tdhInType {
+		case winapi.TdhInTypeInt8, winapi.TdhInTypeUint8:
+			return winapi.TdhInTypeUint8, winapi.TdhOutTypeUnsignedbyte
+		case winapi.TdhInTypeInt16, winapi.TdhInTypeUint16:
+			return winapi.TdhInTypeUint16, winapi.TdhOutTypeUnsignedshort
+		case winapi.TdhInTypeInt32, winapi.TdhInTypeUint32, winapi.TdhInTypeHexint32:
+			return winapi.TdhInTypeUint32, winapi.TdhOutTypeUnsignedint
+		case winapi.TdhInTypeInt64, winapi.TdhInTypeUint64, winapi.TdhInTypeHexint64:
+			return winapi.TdhInTypeUint64, winapi.TdhOutTypeUnsignedlong
+		}
+	}
+
+	return tdhInType, tlgOutTypes[outType] // unknown out types fall back to the default rendering
+}
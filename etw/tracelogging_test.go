@@ -0,0 +1,179 @@
+package etw
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+// tlgMetadata prefixes the concatenation of parts by its UINT16 size, as both the event metadata
+// and the provider traits are.
+func tlgMetadata(parts ...[]byte) []byte {
+	var data []byte
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+	return append(binary.LittleEndian.AppendUint16(nil, uint16(2+len(data))), data...)
+}
+
+func tlgName(name string) []byte {
+	return append([]byte(name), 0)
+}
+
+// tlgRecord is an event of testProviderGUID carrying TraceLogging metadata and userData.
+func tlgRecord(metadata []byte, userData []byte) *Record {
+	record := testRecord(userData)
+	record.EventHeader.EventDescriptor = winapi.EventDescriptor{Level: 4}
+	record.ExtendedData = []ExtendedDataItem{
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_PROV_TRAITS, Data: tlgMetadata(tlgName("Sample.Provider"))},
+		{ExtType: winapi.EVENT_HEADER_EXT_TYPE_EVENT_SCHEMA_TL, Data: metadata},
+	}
+	return record
+}
+
+// sampleTlgMetadata describes one field of each layout: plain, typed, variable and constant count arrays,
+// structure, binary and tagged.
+func sampleTlgMetadata() []byte {
+	return tlgMetadata(
+		[]byte{0x81, 0x00}, // event tags 0x200000
+		tlgName("Connect"),
+		tlgName("Image"), []byte{byte(winapi.TdhInTypeUnicodestring)},
+		tlgName("Count"), []byte{byte(winapi.TdhInTypeUint32) | tlgInChain, tlgOutHex},
+		tlgName("Ports"), []byte{byte(winapi.TdhInTypeUint16) | tlgInVcount | tlgInChain, tlgOutPort},
+		tlgName("Fixed"), []byte{byte(winapi.TdhInTypeUint8) | tlgInCcount}, []byte{2, 0},
+		tlgName("Point"), []byte{tlgInStruct | tlgInChain, 2},
+		tlgName("X"), []byte{byte(winapi.TdhInTypeInt32)},
+		tlgName("Y"), []byte{byte(winapi.TdhInTypeInt32)},
+		tlgName("Blob"), []byte{byte(winapi.TdhInTypeBinary)},
+		tlgName("Tagged"), []byte{byte(winapi.TdhInTypeInt8) | tlgInChain, tlgOutUnsigned | tlgOutChain, 0x01},
+	)
+}
+
+func TestTraceLoggingSchema(t *testing.T) {
+	schema, err := TraceLoggingSchema(tlgRecord(sampleTlgMetadata(), nil))
+	if err != nil {
+		t.Fatalf("TraceLoggingSchema: %v", err)
+	}
+	if !schema.IsTraceLogging() || schema.TaskName != "Connect" || schema.ProviderName != "Sample.Provider" || schema.Tags != 0x200000 {
+		t.Errorf("schema = %+v", schema)
+	}
+
+	want := []PropertyInfo{
+		{Name: "Image", InType: winapi.TdhInTypeUnicodestring},
+		{Name: "Count", InType: winapi.TdhInTypeUint32, OutType: winapi.TdhOutTypeHexint32},
+		{Name: "Ports", InType: winapi.TdhInTypeUint16, OutType: winapi.TdhOutTypePort, Flags: PropertyParamCountPrefix},
+		{Name: "Fixed", InType: winapi.TdhInTypeUint8, Flags: winapi.PropertyParamFixedCount, Count: 2},
+		{Name: "Point", Flags: winapi.PropertyStruct, StructStartIndex: 7, NumOfStructMembers: 2},
+		{Name: "Blob", InType: winapi.TdhInTypeManifestCountedbinary},
+		{Name: "Tagged", InType: winapi.TdhInTypeUint8, OutType: winapi.TdhOutTypeUnsignedbyte, Flags: winapi.PropertyHasTags, Tags: 0x200000},
+		{Name: "X", InType: winapi.TdhInTypeInt32},
+		{Name: "Y", InType: winapi.TdhInTypeInt32},
+	}
+	if !reflect.DeepEqual(schema.Properties, want) || schema.TopLevelPropertyCount != 7 {
+		t.Errorf("properties (%d top level) =\n%+v\nwant\n%+v", schema.TopLevelPropertyCount, schema.Properties, want)
+	}
+}
+
+func TestTraceLoggingDecode(t *testing.T) {
+	record := tlgRecord(sampleTlgMetadata(), userData(
+		utf16z("a.exe"),
+		uint32(0x3e7),
+		uint16(2), []byte{0x00, 0x50, 0x01, 0xbb},
+		[]byte{1, 2},
+		int32(-1), int32(2),
+		uint16(2), []byte{0xca, 0xfe},
+		uint8(200),
+	))
+
+	event, err := DecodeRecord(record, NewTraceLoggingBackend(nil))
+	if err != nil {
+		t.Fatalf("DecodeRecord: %v", err)
+	}
+
+	wantData := map[string]string{"Image": "a.exe", "Count": "0x3E7", "Blob": "0xCAFE", "Tagged": "200"}
+	if !reflect.DeepEqual(event.EventData, wantData) {
+		t.Errorf("EventData = %v, want %v", event.EventData, wantData)
+	}
+	wantArrays := map[string][]string{"Ports": {"80", "443"}, "Fixed": {"1", "2"}}
+	if !reflect.DeepEqual(event.EventDataArrays, wantArrays) {
+		t.Errorf("EventDataArrays = %v, want %v", event.EventDataArrays, wantArrays)
+	}
+	wantStructs := map[string][]map[string]string{"Point": {{"X": "-1", "Y": "2"}}}
+	if !reflect.DeepEqual(event.EventDataStructs, wantStructs) {
+		t.Errorf("EventDataStructs = %v, want %v", event.EventDataStructs, wantStructs)
+	}
+	if len(event.DecodeErrors) != 0 {
+		t.Errorf("DecodeErrors = %v", event.DecodeErrors)
+	}
+}
+
+func TestTraceLoggingBackendDelegates(t *testing.T) {
+	schema := testSchema(-1, PropertyInfo{Name: "Value", InType: winapi.TdhInTypeUint8})
+
+	if _, err := NewTraceLoggingBackend(nil).EventSchema(testRecord(nil)); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("without backend, err = %v, want %v", err, ErrSchemaNotFound)
+	}
+
+	backend := NewTraceLoggingBackend(NewSchemaBackend(schema))
+	found, err := backend.EventSchema(testRecord(nil))
+	if err != nil || found != schema {
+		t.Errorf("EventSchema = %v, %v, want the inner backend schema", found, err)
+	}
+
+	tlgSchema, _ := TraceLoggingSchema(tlgRecord(sampleTlgMetadata(), nil))
+	if _, _, err = backend.FormatProperty(nil, tlgSchema, &tlgSchema.Properties[0], 0, nil); !errors.Is(err, ErrFormatUnsupported) {
+		t.Errorf("FormatProperty of a TraceLogging schema, err = %v, want %v", err, ErrFormatUnsupported)
+	}
+}
+
+func TestTraceLoggingSchemaErrors(t *testing.T) {
+	oversized := tlgMetadata(tlgName("Event"))
+	binary.LittleEndian.PutUint16(oversized, 100)
+
+	tests := []struct {
+		name     string
+		metadata []byte
+	}{
+		{"empty", nil},
+		{"size out of data", oversized},
+		{"unterminated event name", tlgMetadata([]byte{0}, []byte("Event"))},
+		{"missing in type", tlgMetadata([]byte{0}, tlgName("Event"), tlgName("Field"))},
+		{"missing out type", tlgMetadata([]byte{0}, tlgName("Event"), tlgName("Field"), []byte{byte(winapi.TdhInTypeUint8) | tlgInChain})},
+		{"missing count", tlgMetadata([]byte{0}, tlgName("Event"), tlgName("Field"), []byte{byte(winapi.TdhInTypeUint8) | tlgInCcount, 1})},
+		{"empty structure", tlgMetadata([]byte{0}, tlgName("Event"), tlgName("Point"), []byte{tlgInStruct | tlgInChain, 0})},
+		{"missing members", tlgMetadata([]byte{0}, tlgName("Event"), tlgName("Point"), []byte{tlgInStruct | tlgInChain, 2}, tlgName("X"), []byte{7})},
+		{"custom schema out of metadata", tlgMetadata([]byte{0}, tlgName("Event"), tlgName("Custom"), []byte{tlgInCustom, 9, 0})},
+	}
+
+	for _, test := range tests {
+		if _, err := TraceLoggingSchema(tlgRecord(test.metadata, nil)); !errors.Is(err, ErrInvalidTraceLogging) {
+			t.Errorf("%s: err = %v, want %v", test.name, err, ErrInvalidTraceLogging)
+		}
+	}
+}
+
+func FuzzTraceLoggingDecode(f *testing.F) {
+	f.Add(sampleTlgMetadata(), userData(
+		utf16z("a.exe"),
+		uint32(0x3e7),
+		uint16(2), []byte{0x00, 0x50, 0x01, 0xbb},
+		[]byte{1, 2},
+		int32(-1), int32(2),
+		uint16(2), []byte{0xca, 0xfe},
+		uint8(200),
+	))
+
+	f.Fuzz(func(t *testing.T, metadata []byte, data []byte) {
+		record := tlgRecord(metadata, data)
+		if _, err := TraceLoggingSchema(record); err != nil {
+			if !errors.Is(err, ErrInvalidTraceLogging) {
+				t.Fatalf("err = %v, want %v", err, ErrInvalidTraceLogging)
+			}
+			return
+		}
+		DecodeRecord(record, NewTraceLoggingBackend(nil))
+	})
+}
@@ -0,0 +1,402 @@
+package etw
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// ValueKind identifies the Go type held by a Value.
+type ValueKind uint8
+
+const (
+	KindNull = ValueKind(iota)
+	KindString
+	KindInt8
+	KindInt16
+	KindInt32
+	KindInt64
+	KindUint8
+	KindUint16
+	KindUint32
+	KindUint64
+	KindFloat32
+	KindFloat64
+	KindBool
+	KindGUID
+	KindTime
+	KindSID
+	KindBinary
+	KindAddr
+	KindAddrPort
+	KindStruct
+	KindArray
+)
+
+var valueKindNames = [...]string{
+	KindNull:     "null",
+	KindString:   "string",
+	KindInt8:     "int8",
+	KindInt16:    "int16",
+	KindInt32:    "int32",
+	KindInt64:    "int64",
+	KindUint8:    "uint8",
+	KindUint16:   "uint16",
+	KindUint32:   "uint32",
+	KindUint64:   "uint64",
+	KindFloat32:  "float32",
+	KindFloat64:  "float64",
+	KindBool:     "bool",
+	KindGUID:     "guid",
+	KindTime:     "time",
+	KindSID:      "sid",
+	KindBinary:   "binary",
+	KindAddr:     "addr",
+	KindAddrPort: "addrport",
+	KindStruct:   "struct",
+	KindArray:    "array",
+}
+
+func (k ValueKind) String() string {
+	if int(k) < len(valueKindNames) {
+		return valueKindNames[k]
+	}
+	return "kind" + strconv.Itoa(int(k))
+}
+
+// Value is a decoded event property. It keeps the TDH in and out types it was decoded with,
+// which drive its text rendering, and the name its value map gives it, if any.
+type Value struct {
+	Kind    ValueKind
+	InType  winapi.TdhInType
+	OutType winapi.TdhOutType
+
+	number uint64      // integers, floats and booleans
+	object interface{} // strings, and every other kind
+	name   string      // resolved by a value map
+}
+
+// Property is a named Value.
+type Property struct {
+	Name  string `json:"name"`
+	Value Value  `json:"value"`
+}
+
+// NewValue wraps a Go value: string, sized integers, floats, bool, winguid.GUID, time.Time, SID, []byte,
+// netip.Addr, netip.AddrPort, []Property for structures or []Value for arrays.
+// Other types are stored as their fmt representation.
+func NewValue(v interface{}, inType winapi.TdhInType, outType winapi.TdhOutType) Value {
+	value := Value{InType: inType, OutType: outType}
+
+	switch typed := v.(type) {
+	case nil:
+		value.Kind = KindNull
+	case string:
+		value.Kind, value.object = KindString, typed
+	case int8:
+		value.Kind, value.number = KindInt8, uint64(typed)
+	case int16:
+		value.Kind, value.number = KindInt16, uint64(typed)
+	case int32:
+		value.Kind, value.number = KindInt32, uint64(typed)
+	case int64:
+		value.Kind, value.number = KindInt64, uint64(typed)
+	case uint8:
+		value.Kind, value.number = KindUint8, uint64(typed)
+	case uint16:
+		value.Kind, value.number = KindUint16, uint64(typed)
+	case uint32:
+		value.Kind, value.number = KindUint32, uint64(typed)
+	case uint64:
+		value.Kind, value.number = KindUint64, typed
+	case float32:
+		value.Kind, value.number = KindFloat32, math.Float64bits(float64(typed))
+	case float64:
+		value.Kind, value.number = KindFloat64, math.Float64bits(typed)
+	case bool:
+		value.Kind = KindBool
+		if typed {
+			value.number = 1
+		}
+	case winguid.GUID:
+		value.Kind, value.object = KindGUID, typed
+	case time.Time:
+		value.Kind, value.object = KindTime, typed
+	case SID:
+		value.Kind, value.object = KindSID, typed
+	case []byte:
+		value.Kind, value.object = KindBinary, typed
+	case netip.Addr:
+		value.Kind, value.object = KindAddr, typed
+	case netip.AddrPort:
+		value.Kind, value.object = KindAddrPort, typed
+	case []Property:
+		value.Kind, value.object = KindStruct, typed
+	case []Value:
+		value.Kind, value.object = KindArray, typed
+	default:
+		value.Kind, value.object = KindString, fmt.Sprint(typed)
+	}
+
+	return value
+}
+
+// StringValue is a shorthand for a string Value, as produced when TDH formats a property.
+func StringValue(s string, inType winapi.TdhInType, outType winapi.TdhOutType) Value {
+	return Value{Kind: KindString, InType: inType, OutType: outType, object: s}
+}
+
+// WithName returns the value named name, as resolved by a value map.
+func (v Value) WithName(name string) Value {
+	v.name = name
+	return v
+}
+
+// Name returns the name the value map of the property gives the value, empty for unmapped values.
+func (v Value) Name() string {
+	return v.name
+}
+
+// Raw returns the value without its name, rendered by String as a number.
+func (v Value) Raw() Value {
+	v.name = ""
+	return v
+}
+
+func (v Value) IsNull() bool {
+	return v.Kind == KindNull
+}
+
+func (v Value) isSigned() bool {
+	return v.Kind >= KindInt8 && v.Kind <= KindInt64
+}
+
+func (v Value) isUnsigned() bool {
+	return v.Kind >= KindUint8 && v.Kind <= KindUint64
+}
+
+// IsInteger reports whether the value holds one of the integer kinds.
+func (v Value) IsInteger() bool {
+	return v.isSigned() || v.isUnsigned()
+}
+
+// Int returns integer and boolean values as an int64, 0 for other kinds.
+func (v Value) Int() int64 {
+	switch v.Kind {
+	case KindInt8:
+		return int64(int8(v.number))
+	case KindInt16:
+		return int64(int16(v.number))
+	case KindInt32:
+		return int64(int32(v.number))
+	case KindFloat32, KindFloat64:
+		return int64(v.Float())
+	}
+	return int64(v.Uint())
+}
+
+// Uint returns integer and boolean values as an uint64, 0 for other kinds.
+func (v Value) Uint() uint64 {
+	switch {
+	case v.isSigned(), v.isUnsigned(), v.Kind == KindBool:
+		return v.number
+	case v.Kind == KindFloat32, v.Kind == KindFloat64:
+		return uint64(v.Float())
+	}
+	return 0
+}
+
+func (v Value) Float() float64 {
+	switch {
+	case v.Kind == KindFloat32, v.Kind == KindFloat64:
+		return math.Float64frombits(v.number)
+	case v.isSigned():
+		return float64(v.Int())
+	case v.isUnsigned():
+		return float64(v.number)
+	}
+	return 0
+}
+
+func (v Value) Bool() bool {
+	return (v.Kind == KindBool || v.IsInteger()) && v.number != 0
+}
+
+func (v Value) GUID() winguid.GUID {
+	guid, _ := v.object.(winguid.GUID)
+	return guid
+}
+
+func (v Value) Time() time.Time {
+	t, _ := v.object.(time.Time)
+	return t
+}
+
+func (v Value) SID() SID {
+	sid, _ := v.object.(SID)
+	return sid
+}
+
+func (v Value) Bytes() []byte {
+	b, _ := v.object.([]byte)
+	return b
+}
+
+// Addr returns the address of KindAddr and KindAddrPort values.
+func (v Value) Addr() netip.Addr {
+	switch typed := v.object.(type) {
+	case netip.Addr:
+		return typed
+	case netip.AddrPort:
+		return typed.Addr()
+	}
+	return netip.Addr{}
+}
+
+func (v Value) AddrPort() netip.AddrPort {
+	addrPort, _ := v.object.(netip.AddrPort)
+	return addrPort
+}
+
+// Fields returns the members of a structure, in schema order.
+func (v Value) Fields() []Property {
+	fields, _ := v.object.([]Property)
+	return fields
+}
+
+// Field returns the structure member called name.
+func (v Value) Field(name string) (Value, bool) {
+	for _, field := range v.Fields() {
+		if field.Name == name {
+			return field.Value, true
+		}
+	}
+	return Value{}, false
+}
+
+// Elements returns the elements of an array.
+func (v Value) Elements() []Value {
+	elements, _ := v.object.([]Value)
+	return elements
+}
+
+// Interface returns the Go value, with the type NewValue accepted for its kind.
+func (v Value) Interface() interface{} {
+	switch v.Kind {
+	case KindNull:
+		return nil
+	case KindInt8:
+		return int8(v.number)
+	case KindInt16:
+		return int16(v.number)
+	case KindInt32:
+		return int32(v.number)
+	case KindInt64:
+		return int64(v.number)
+	case KindUint8:
+		return uint8(v.number)
+	case KindUint16:
+		return uint16(v.number)
+	case KindUint32:
+		return uint32(v.number)
+	case KindUint64:
+		return v.number
+	case KindFloat32:
+		return float32(v.Float())
+	case KindFloat64:
+		return v.Float()
+	case KindBool:
+		return v.Bool()
+	}
+	return v.object
+}
+
+// String renders the value the way TdhFormatProperty would, except that the output never depends on the locale.
+// Mapped values are rendered by their name.
+func (v Value) String() string {
+	if v.name != "" {
+		return v.name
+	}
+
+	switch v.Kind {
+	case KindNull:
+		return ""
+	case KindString:
+		s, _ := v.object.(string)
+		return s
+	case KindBool:
+		return strconv.FormatBool(v.Bool())
+	case KindFloat32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32)
+	case KindFloat64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case KindGUID:
+		guid := v.GUID()
+		return winguid.ToString(&guid)
+	case KindTime:
+		return v.Time().Format(TimeLayout)
+	case KindSID:
+		return v.SID().String()
+	case KindBinary:
+		return "0x" + strings.ToUpper(hex.EncodeToString(v.Bytes()))
+	case KindAddr:
+		return v.Addr().String()
+	case KindAddrPort:
+		return v.AddrPort().String()
+	case KindStruct:
+		fields := v.Fields()
+		rendered := make([]string, len(fields))
+		for i, field := range fields {
+			rendered[i] = field.Name + "=" + field.Value.String()
+		}
+		return "{" + strings.Join(rendered, ", ") + "}"
+	case KindArray:
+		elements := v.Elements()
+		rendered := make([]string, len(elements))
+		for i, element := range elements {
+			rendered[i] = element.String()
+		}
+		return "[" + strings.Join(rendered, ", ") + "]"
+	}
+
+	if isHexOutput(v.InType, v.OutType) {
+		return "0x" + strings.ToUpper(strconv.FormatUint(v.unsignedBits(), 16))
+	}
+	if v.isSigned() {
+		return strconv.FormatInt(v.Int(), 10)
+	}
+	return strconv.FormatUint(v.number, 10)
+}
+
+// unsignedBits truncates sign extended integers, so that hexadecimal output shows their two's complement form.
+func (v Value) unsignedBits() uint64 {
+	switch v.Kind {
+	case KindInt8:
+		return uint64(uint8(v.number))
+	case KindInt16:
+		return uint64(uint16(v.number))
+	case KindInt32:
+		return uint64(uint32(v.number))
+	}
+	return v.number
+}
+
+func isHexOutput(inType winapi.TdhInType, outType winapi.TdhOutType) bool {
+	switch outType {
+	case winapi.TdhOutTypeHexint8, winapi.TdhOutTypeHexint16, winapi.TdhOutTypeHexint32, winapi.TdhOutTypeHexint64,
+		winapi.TdhOutTypeErrorcode, winapi.TdhOutTypeWin32error, winapi.TdhOutTypeNtstatus, winapi.TdhOutTypeHresult:
+		return true
+	}
+	switch inType {
+	case winapi.TdhInTypeHexint32, winapi.TdhInTypeHexint64, winapi.TdhInTypePointer:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package etw
+
+import (
+	"fmt"
+	"strings"
+)
+
+// https://learn.microsoft.com/en-us/cpp/c-runtime-library/format-specification-syntax-printf-and-wprintf-functions
+// https://learn.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-formatmessage
+
+// Format renders the value with a printf conversion specification, without its leading %, such as
+// "d", "08X", "I64u", "-10s" or "p": the syntax of the !format! insertions of event messages and WPP traces.
+// Length modifiers are ignored, the value carries its size. Values that do not fit the conversion,
+// and unknown conversions, are rendered by String.
+func (v Value) Format(spec string) string {
+	if spec == "" {
+		return v.String()
+	}
+
+	i := 0
+	for i < len(spec) && strings.IndexByte("-+ #0", spec[i]) >= 0 {
+		i++
+	}
+	for i < len(spec) && (spec[i] >= '0' && spec[i] <= '9' || spec[i] == '.') {
+		i++
+	}
+	flags := spec[:i] // flags, width and precision, the same in Go
+
+	conversion := strings.TrimLeft(spec[i:], "hlLIjztw0123456789")
+	if len(conversion) != 1 {
+		return v.String()
+	}
+
+	switch conversion[0] {
+	case 'd', 'i':
+		if v.IsInteger() || v.Kind == KindBool {
+			return fmt.Sprintf("%"+flags+"d", v.signedBits())
+		}
+	case 'u':
+		if v.IsInteger() || v.Kind == KindBool {
+			return fmt.Sprintf("%"+flags+"d", v.unsignedBits())
+		}
+	case 'x', 'X', 'o':
+		if v.IsInteger() || v.Kind == KindBool {
+			return fmt.Sprintf("%"+flags+conversion, v.unsignedBits())
+		}
+		if v.Kind == KindBinary {
+			return fmt.Sprintf("%"+flags+conversion, v.Bytes())
+		}
+	case 'p':
+		if v.IsInteger() {
+			return fmt.Sprintf("%0*X", 2*v.size(), v.unsignedBits()) // as Windows, without prefix
+		}
+	case 'c', 'C':
+		if v.IsInteger() {
+			return fmt.Sprintf("%"+flags+"c", rune(v.unsignedBits()))
+		}
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		if v.Kind == KindFloat32 || v.Kind == KindFloat64 || v.IsInteger() {
+			return fmt.Sprintf("%"+flags+conversion, v.Float())
+		}
+	case 's', 'S', 'Z':
+		return fmt.Sprintf("%"+flags+"s", v.String())
+	}
+
+	return v.String()
+}
+
+// signedBits sign extends unsigned integers from their own size, as C conversions do with %d.
+func (v Value) signedBits() int64 {
+	switch v.Kind {
+	case KindUint8:
+		return int64(int8(v.number))
+	case KindUint16:
+		return int64(int16(v.number))
+	case KindUint32:
+		return int64(int32(v.number))
+	}
+	return v.Int()
+}
+
+// size returns the size in bytes of integer values.
+func (v Value) size() int {
+	switch v.Kind {
+	case KindInt8, KindUint8:
+		return 1
+	case KindInt16, KindUint16:
+		return 2
+	case KindInt32, KindUint32:
+		return 4
+	}
+	return 8
+}
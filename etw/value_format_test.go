@@ -0,0 +1,45 @@
+package etw
+
+import (
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+func TestValueFormat(t *testing.T) {
+	tests := []struct {
+		value Value
+		spec  string
+		want  string
+	}{
+		{NewValue(int32(-2), 0, 0), "", "-2"},
+		{NewValue(int32(-2), 0, 0), "d", "-2"},
+		{NewValue(uint32(0xfffffffe), 0, 0), "d", "-2"},
+		{NewValue(uint32(0xfffffffe), 0, 0), "u", "4294967294"},
+		{NewValue(int8(-1), 0, 0), "u", "255"},
+		{NewValue(uint16(0xbeef), 0, 0), "x", "beef"},
+		{NewValue(uint16(0xbeef), 0, 0), "#X", "0XBEEF"},
+		{NewValue(uint32(10), 0, 0), "08o", "00000012"},
+		{NewValue(uint64(10), 0, 0), "I64x", "a"},
+		{NewValue(uint32(10), 0, 0), "lu", "10"},
+		{NewValue(uint32(0x1234), 0, 0), "p", "00001234"},
+		{NewValue(uint64(0x1234), 0, 0), "p", "0000000000001234"},
+		{NewValue(uint8('A'), 0, 0), "c", "A"},
+		{NewValue(1.5, 0, 0), ".3f", "1.500"},
+		{NewValue(uint32(3), 0, 0), "e", "3.000000e+00"},
+		{NewValue("text", winapi.TdhInTypeUnicodestring, 0), "-6s", "text  "},
+		{NewValue("text", winapi.TdhInTypeUnicodestring, 0), "ws", "text"},
+		{NewValue(true, 0, 0), "d", "1"},
+		{NewValue([]byte{0xca, 0xfe}, 0, 0), "x", "cafe"},
+		{NewValue("text", 0, 0), "d", "text"},     // mismatched conversion
+		{NewValue(uint32(7), 0, 0), "y", "7"},     // unknown conversion
+		{NewValue(uint32(7), 0, 0), "lld!x", "7"}, // not a single conversion
+		{NewValue(uint32(7), 0, 0).WithName("Seven"), "x", "7"},
+	}
+
+	for _, test := range tests {
+		if formatted := test.value.Format(test.spec); formatted != test.want {
+			t.Errorf("%s %v Format(%q) = %q, want %q", test.value.Kind, test.value.Interface(), test.spec, formatted, test.want)
+		}
+	}
+}
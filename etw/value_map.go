@@ -0,0 +1,128 @@
+package etw
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/tdh/ns-tdh-event_map_info
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventmanifestschema-mapstype-complextype
+// https://learn.microsoft.com/en-us/windows/win32/wmisdk/standard-qualifiers (ValueMap, BitMap)
+
+// IsBitmap reports whether the entries of the map name flags rather than values.
+func (m *ValueMap) IsBitmap() bool {
+	return m.Flag&winapi.EVENTMAP_INFO_FLAG_MANIFEST_BITMAP != 0 || m.Flag&winapi.EVENTMAP_INFO_FLAG_WBEM_BITMAP != 0 ||
+		m.Flag&(winapi.EVENTMAP_INFO_FLAG_WBEM_VALUEMAP|winapi.EVENTMAP_INFO_FLAG_WBEM_FLAG) == winapi.EVENTMAP_INFO_FLAG_WBEM_VALUEMAP|winapi.EVENTMAP_INFO_FLAG_WBEM_FLAG
+}
+
+// Resolve returns the name the map gives value, as TdhFormatProperty would:
+//   - value maps name the entry equal to the value,
+//   - bitmaps join with | the names of the flags set in the value, followed by the remaining bits in hexadecimal.
+//     WBEM BitMap entries give bit positions rather than masks,
+//   - pattern maps, and WBEM maps keyed by strings, name the first entry whose input matches the string value,
+//     pattern inputs supporting the * and ? wildcards.
+//
+// It returns false when no entry applies.
+func (m *ValueMap) Resolve(value Value) (string, bool) {
+	if m.Flag&winapi.EVENTMAP_INFO_FLAG_WBEM_NO_MAP != 0 {
+		return "", false
+	}
+
+	if value.Kind == KindString {
+		return m.resolveString(value.Raw().String())
+	}
+	if !value.IsInteger() && value.Kind != KindBool {
+		return "", false
+	}
+
+	bits := value.unsignedBits()
+	if m.IsBitmap() {
+		return m.resolveBitmap(bits)
+	}
+	for _, entry := range m.Entries {
+		if entry.Input == "" && uint64(entry.Value) == bits {
+			return entry.Output, true
+		}
+	}
+	return "", false
+}
+
+func (m *ValueMap) resolveBitmap(bits uint64) (string, bool) {
+	positions := m.Flag&winapi.EVENTMAP_INFO_FLAG_WBEM_BITMAP != 0
+
+	var names []string
+	remaining := bits
+	for _, entry := range m.Entries {
+		if entry.Input != "" {
+			continue
+		}
+		mask := uint64(entry.Value)
+		if positions {
+			if entry.Value >= 64 {
+				continue
+			}
+			mask = 1 << entry.Value
+		}
+		if mask == 0 {
+			if bits == 0 {
+				return entry.Output, true
+			}
+			continue
+		}
+		if bits&mask == mask {
+			names = append(names, entry.Output)
+			remaining &^= mask
+		}
+	}
+
+	if len(names) == 0 {
+		return "", false
+	}
+	if remaining != 0 {
+		names = append(names, "0x"+strings.ToUpper(strconv.FormatUint(remaining, 16)))
+	}
+	return strings.Join(names, "|"), true
+}
+
+func (m *ValueMap) resolveString(s string) (string, bool) {
+	pattern := m.Flag&winapi.EVENTMAP_INFO_FLAG_MANIFEST_PATTERNMAP != 0
+	for _, entry := range m.Entries {
+		if entry.Input == "" {
+			continue
+		}
+		if pattern && matchWildcard(strings.ToLower(entry.Input), strings.ToLower(s)) ||
+			!pattern && strings.EqualFold(entry.Input, s) {
+			return entry.Output, true
+		}
+	}
+	return "", false
+}
+
+// matchWildcard matches s against pattern, where * matches any run of characters and ? any character.
+func matchWildcard(pattern, s string) bool {
+	p, r := []rune(pattern), []rune(s)
+	star, backtrack := -1, 0
+	pi, si := 0, 0
+	for si < len(r) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == r[si]):
+			pi++
+			si++
+		case pi < len(p) && p[pi] == '*':
+			star, backtrack = pi, si
+			pi++
+		case star >= 0:
+			pi = star + 1
+			backtrack++
+			si = backtrack
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
@@ -0,0 +1,126 @@
+package etw
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+func TestValueMapResolve(t *testing.T) {
+	valueMap := &ValueMap{Flag: winapi.EVENTMAP_INFO_FLAG_MANIFEST_VALUEMAP, Entries: []ValueMapEntry{
+		{Value: 0, Output: "Stopped"},
+		{Value: 1, Output: "Running"},
+		{Value: 0xffffffff, Output: "Unknown"},
+	}}
+	bitmap := &ValueMap{Flag: winapi.EVENTMAP_INFO_FLAG_MANIFEST_BITMAP, Entries: []ValueMapEntry{
+		{Value: 0, Output: "None"},
+		{Value: 0x1, Output: "Read"},
+		{Value: 0x2, Output: "Write"},
+		{Value: 0x6, Output: "Execute"},
+	}}
+	wbemBitmap := &ValueMap{Flag: winapi.EVENTMAP_INFO_FLAG_WBEM_BITMAP, Entries: []ValueMapEntry{
+		{Value: 0, Output: "Bit0"},
+		{Value: 3, Output: "Bit3"},
+		{Value: 64, Output: "Ignored"},
+	}}
+	wbemFlags := &ValueMap{Flag: winapi.EVENTMAP_INFO_FLAG_WBEM_VALUEMAP | winapi.EVENTMAP_INFO_FLAG_WBEM_FLAG, Entries: []ValueMapEntry{
+		{Value: 0x10, Output: "Sixteen"},
+	}}
+	patterns := &ValueMap{Flag: winapi.EVENTMAP_INFO_FLAG_MANIFEST_PATTERNMAP, Entries: []ValueMapEntry{
+		{Input: "*.exe", Output: "Executable"},
+		{Input: "lib?.dll", Output: "Library"},
+		{Input: "*", Output: "Other"},
+	}}
+	wbemStrings := &ValueMap{Flag: winapi.EVENTMAP_INFO_FLAG_WBEM_VALUEMAP, Entries: []ValueMapEntry{
+		{Input: "TCP", Output: "Transmission Control Protocol"},
+	}}
+	noMap := &ValueMap{Flag: winapi.EVENTMAP_INFO_FLAG_WBEM_VALUEMAP | winapi.EVENTMAP_INFO_FLAG_WBEM_NO_MAP, Entries: []ValueMapEntry{
+		{Value: 1, Output: "One"},
+	}}
+
+	tests := []struct {
+		name     string
+		valueMap *ValueMap
+		value    Value
+		want     string
+		ok       bool
+	}{
+		{"value", valueMap, NewValue(uint32(1), 0, 0), "Running", true},
+		{"value zero", valueMap, NewValue(uint8(0), 0, 0), "Stopped", true},
+		{"value sign extended", valueMap, NewValue(int32(-1), 0, 0), "Unknown", true},
+		{"value boolean", valueMap, NewValue(true, 0, 0), "Running", true},
+		{"value missing", valueMap, NewValue(uint32(2), 0, 0), "", false},
+		{"value named already", valueMap, NewValue(uint32(1), 0, 0).WithName("Other"), "Running", true},
+		{"value of a float", valueMap, NewValue(1.0, 0, 0), "", false},
+		{"bitmap flags", bitmap, NewValue(uint32(0x3), 0, 0), "Read|Write", true},
+		{"bitmap multi-bit mask", bitmap, NewValue(uint32(0x7), 0, 0), "Read|Write|Execute", true},
+		{"bitmap remaining bits", bitmap, NewValue(uint32(0x19), 0, 0), "Read|0x18", true},
+		{"bitmap zero", bitmap, NewValue(uint32(0), 0, 0), "None", true},
+		{"bitmap no flag", bitmap, NewValue(uint32(0x8), 0, 0), "", false},
+		{"WBEM bitmap positions", wbemBitmap, NewValue(uint32(0x9), 0, 0), "Bit0|Bit3", true},
+		{"WBEM flag value map", wbemFlags, NewValue(uint32(0x11), 0, 0), "Sixteen|0x1", true},
+		{"pattern", patterns, NewValue("C:\\CMD.EXE", 0, 0), "Executable", true},
+		{"pattern single character", patterns, NewValue("libc.dll", 0, 0), "Library", true},
+		{"pattern fallback", patterns, NewValue("libcc.dll", 0, 0), "Other", true},
+		{"pattern of an integer", patterns, NewValue(uint32(1), 0, 0), "", false},
+		{"WBEM string", wbemStrings, NewValue("tcp", 0, 0), "Transmission Control Protocol", true},
+		{"WBEM string missing", wbemStrings, NewValue("udp", 0, 0), "", false},
+		{"WBEM no map", noMap, NewValue(uint32(1), 0, 0), "", false},
+	}
+
+	for _, test := range tests {
+		name, ok := test.valueMap.Resolve(test.value)
+		if name != test.want || ok != test.ok {
+			t.Errorf("%s: Resolve = %q, %v, want %q, %v", test.name, name, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestMatchWildcard(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"*", "", true},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+		{"?", "é", true},
+		{"*?", "", false},
+		{"**x", "abx", true},
+	}
+	for _, test := range tests {
+		if got := matchWildcard(test.pattern, test.s); got != test.want {
+			t.Errorf("matchWildcard(%q, %q) = %v, want %v", test.pattern, test.s, got, test.want)
+		}
+	}
+}
+
+func TestDecodeRecordValueMaps(t *testing.T) {
+	schema := testSchema(-1,
+		PropertyInfo{Name: "Access", InType: winapi.TdhInTypeUint32, MapName: "AccessMap"},
+		PropertyInfo{Name: "State", InType: winapi.TdhInTypeUint32, MapName: "StateMap"},
+		PropertyInfo{Name: "Missing", InType: winapi.TdhInTypeUint32, MapName: "MissingMap"},
+	)
+	schema.Maps = map[string]*ValueMap{
+		"AccessMap": {Name: "AccessMap", Flag: winapi.EVENTMAP_INFO_FLAG_MANIFEST_BITMAP, Entries: []ValueMapEntry{{Value: 1, Output: "Read"}, {Value: 2, Output: "Write"}}},
+		"StateMap":  {Name: "StateMap", Flag: winapi.EVENTMAP_INFO_FLAG_MANIFEST_VALUEMAP, Entries: []ValueMapEntry{{Value: 1, Output: "Running"}}},
+	}
+
+	event, err := DecodeRecord(testRecord(userData(uint32(3), uint32(5), uint32(7))), NewSchemaBackend(schema))
+	if err != nil {
+		t.Fatalf("DecodeRecord: %v", err)
+	}
+
+	want := map[string]string{"Access": "Read|Write", "State": "5", "Missing": "7"}
+	if !reflect.DeepEqual(event.EventData, want) {
+		t.Errorf("EventData = %v, want %v", event.EventData, want)
+	}
+	access := event.Properties[0].Value
+	if access.Name() != "Read|Write" || access.Uint() != 3 || access.Raw().String() != "3" {
+		t.Errorf("Access = %q, raw %d %q, the raw value is kept", access.Name(), access.Uint(), access.Raw().String())
+	}
+}
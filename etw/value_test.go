@@ -0,0 +1,149 @@
+package etw
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+func TestNewValueInterface(t *testing.T) {
+	guid := *winguid.MustParse("{5770385F-C22A-43E0-BF4C-06F5698FFBD9}")
+	timestamp := time.Date(2024, 5, 6, 7, 8, 9, 100, time.UTC)
+	sid := SID{Revision: 1, IdentifierAuthority: [6]byte{0, 0, 0, 0, 0, 5}, SubAuthorities: []uint32{18}}
+	fields := []Property{{Name: "a", Value: NewValue(uint8(1), 0, 0)}}
+	elements := []Value{NewValue("x", 0, 0)}
+
+	tests := []struct {
+		value interface{}
+		kind  ValueKind
+	}{
+		{nil, KindNull},
+		{"s", KindString},
+		{int8(-1), KindInt8},
+		{int16(-2), KindInt16},
+		{int32(-3), KindInt32},
+		{int64(-4), KindInt64},
+		{uint8(1), KindUint8},
+		{uint16(2), KindUint16},
+		{uint32(3), KindUint32},
+		{uint64(4), KindUint64},
+		{float32(1.5), KindFloat32},
+		{2.5, KindFloat64},
+		{true, KindBool},
+		{guid, KindGUID},
+		{timestamp, KindTime},
+		{sid, KindSID},
+		{[]byte{1, 2}, KindBinary},
+		{netip.MustParseAddr("::1"), KindAddr},
+		{netip.MustParseAddrPort("1.2.3.4:5"), KindAddrPort},
+		{fields, KindStruct},
+		{elements, KindArray},
+	}
+
+	for _, test := range tests {
+		t.Run(test.kind.String(), func(t *testing.T) {
+			value := NewValue(test.value, 0, 0)
+			if value.Kind != test.kind {
+				t.Errorf("kind = %s, want %s", value.Kind, test.kind)
+			}
+			if !reflect.DeepEqual(value.Interface(), test.value) {
+				t.Errorf("Interface() = %#v, want %#v", value.Interface(), test.value)
+			}
+		})
+	}
+}
+
+func TestNewValueOtherType(t *testing.T) {
+	value := NewValue(struct{ A int }{7}, 0, 0)
+	if value.Kind != KindString || value.String() != "{7}" {
+		t.Errorf("value = %s %q, want string {7}", value.Kind, value.String())
+	}
+}
+
+func TestValueNumbers(t *testing.T) {
+	tests := []struct {
+		value Value
+		int   int64
+		uint  uint64
+		float float64
+	}{
+		{NewValue(int8(-1), 0, 0), -1, 0xffffffffffffffff, -1},
+		{NewValue(int32(-2), 0, 0), -2, 0xfffffffffffffffe, -2},
+		{NewValue(uint16(65535), 0, 0), 65535, 65535, 65535},
+		{NewValue(float64(3.75), 0, 0), 3, 3, 3.75},
+		{NewValue(true, 0, 0), 1, 1, 0},
+		{NewValue("12", 0, 0), 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		if got := test.value.Int(); got != test.int {
+			t.Errorf("%s Int() = %d, want %d", test.value.Kind, got, test.int)
+		}
+		if got := test.value.Uint(); got != test.uint {
+			t.Errorf("%s Uint() = %d, want %d", test.value.Kind, got, test.uint)
+		}
+		if got := test.value.Float(); got != test.float {
+			t.Errorf("%s Float() = %g, want %g", test.value.Kind, got, test.float)
+		}
+	}
+}
+
+func TestValueString(t *testing.T) {
+	structure := NewValue([]Property{
+		{Name: "Port", Value: NewValue(uint16(80), 0, 0)},
+		{Name: "Flags", Value: NewValue(uint32(0x10), winapi.TdhInTypeHexint32, 0)},
+	}, 0, 0)
+
+	tests := []struct {
+		name  string
+		value Value
+		want  string
+	}{
+		{"signed hex", NewValue(int16(-1), winapi.TdhInTypeInt16, winapi.TdhOutTypeHexint16), "0xFFFF"},
+		{"win32 error", NewValue(uint32(5), winapi.TdhInTypeUint32, winapi.TdhOutTypeWin32error), "0x5"},
+		{"float32", NewValue(float32(0.1), 0, 0), "0.1"},
+		{"time", NewValue(time.Date(2024, 1, 2, 3, 4, 5, 1234500, time.UTC), 0, 0), "2024-01-02T03:04:05.0012345Z"},
+		{"binary", NewValue([]byte{0xab, 0x01}, 0, 0), "0xAB01"},
+		{"struct", structure, "{Port=80, Flags=0x10}"},
+		{"array", NewValue([]Value{NewValue("a", 0, 0), NewValue(int8(-1), 0, 0)}, 0, 0), "[a, -1]"},
+		{"named", NewValue(uint32(2), 0, 0).WithName("Running"), "Running"},
+		{"raw", NewValue(uint32(2), 0, 0).WithName("Running").Raw(), "2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.value.String(); got != test.want {
+				t.Errorf("String() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValueField(t *testing.T) {
+	structure := NewValue([]Property{{Name: "Port", Value: NewValue(uint16(80), 0, 0)}}, 0, 0)
+
+	port, ok := structure.Field("Port")
+	if !ok || port.Uint() != 80 {
+		t.Errorf("Field(Port) = %v %t, want 80 true", port, ok)
+	}
+	if _, ok = structure.Field("Missing"); ok {
+		t.Error("Field(Missing) found")
+	}
+	if NewValue("s", 0, 0).Fields() != nil {
+		t.Error("Fields of a string not nil")
+	}
+}
+
+func TestValueAddr(t *testing.T) {
+	addrPort := NewValue(netip.MustParseAddrPort("[::1]:443"), 0, 0)
+	if addrPort.Addr() != netip.MustParseAddr("::1") {
+		t.Errorf("Addr() = %s, want ::1", addrPort.Addr())
+	}
+	if addrPort.AddrPort().Port() != 443 {
+		t.Errorf("AddrPort().Port() = %d, want 443", addrPort.AddrPort().Port())
+	}
+}
@@ -2,7 +2,4 @@ module github.com/quentin-nozomi/microsoft-etw
 
 go 1.19
 
-require (
-	golang.org/x/exp v0.0.0-20240506185415-9bf2ced13842
-	golang.org/x/sys v0.20.0
-)
+require golang.org/x/sys v0.20.0
@@ -0,0 +1,191 @@
+package mapping
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+)
+
+// Converter sets the values of a property value under target, and reports whether the value converted.
+// Values that do not are kept with the unconverted properties, see SetUnconverted.
+type Converter func(object Object, target string, value etw.Value) bool
+
+// SysmonTimeLayout is the layout of the UtcTime fields of Sysmon events.
+const SysmonTimeLayout = "2006-01-02 15:04:05.000"
+
+// IsEmpty tells the values Sysmon writes for missing data.
+func IsEmpty(s string) bool {
+	return s == "" || s == "-"
+}
+
+// String sets the string of the value, unless empty or -.
+func String(object Object, target string, value etw.Value) bool {
+	s := value.String()
+	if IsEmpty(s) {
+		return false
+	}
+	object.Set(target, s)
+	return true
+}
+
+// Lowercase sets the string of the value in lower case, such as the transport of Sysmon protocols.
+func Lowercase(object Object, target string, value etw.Value) bool {
+	s := value.String()
+	if IsEmpty(s) {
+		return false
+	}
+	object.Set(target, strings.ToLower(s))
+	return true
+}
+
+// Integer sets integers, as int64 when signed and uint64 otherwise, and strings of integers in decimal
+// or hexadecimal with 0x prefix, as int64 unless above math.MaxInt64.
+func Integer(object Object, target string, value etw.Value) bool {
+	if value.IsInteger() && value.Name() == "" {
+		if value.Kind >= etw.KindInt8 && value.Kind <= etw.KindInt64 {
+			object.Set(target, value.Int())
+		} else {
+			object.Set(target, value.Uint())
+		}
+		return true
+	}
+	s := value.Raw().String()
+	if integer, err := strconv.ParseInt(s, 0, 64); err == nil {
+		object.Set(target, integer)
+		return true
+	}
+	if integer, err := strconv.ParseUint(s, 0, 64); err == nil {
+		object.Set(target, integer)
+		return true
+	}
+	return false
+}
+
+// Bool sets booleans, and the strings true and false.
+func Bool(object Object, target string, value etw.Value) bool {
+	if value.Kind == etw.KindBool {
+		object.Set(target, value.Bool())
+		return true
+	}
+	b, err := strconv.ParseBool(value.String())
+	if err != nil {
+		return false
+	}
+	object.Set(target, b)
+	return true
+}
+
+// SID sets the security identifier of the value, such as the UserSID of classic kernel process events.
+func SID(object Object, target string, value etw.Value) bool {
+	s := value.String()
+	if !strings.HasPrefix(s, "S-") {
+		return false
+	}
+	object.Set(target, s)
+	return true
+}
+
+// ParseTime returns times, and strings in the layout of Sysmon UtcTime fields or RFC 3339 in UTC.
+func ParseTime(value etw.Value) (time.Time, bool) {
+	if value.Kind == etw.KindTime {
+		return value.Time(), true
+	}
+	s := value.String()
+	for _, layout := range []string{SysmonTimeLayout, time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseAddr returns addresses, and strings of addresses.
+func ParseAddr(value etw.Value) (netip.Addr, bool) {
+	if addr := value.Addr(); addr.IsValid() {
+		return addr, true
+	}
+	addr, err := netip.ParseAddr(value.String())
+	return addr, err == nil
+}
+
+// SplitPath splits a Windows path after its last separator, the directory is empty for names without one.
+func SplitPath(path string) (directory string, name string) {
+	separator := strings.LastIndexAny(path, `\/`)
+	if separator < 0 {
+		return "", path
+	}
+	return strings.TrimRight(path[:separator], `\/`), path[separator+1:]
+}
+
+// SplitUser splits DOMAIN\name, the domain is empty for names without one.
+func SplitUser(s string) (domain string, name string) {
+	if separator := strings.IndexByte(s, '\\'); separator >= 0 {
+		return s[:separator], s[separator+1:]
+	}
+	return "", s
+}
+
+// Hash is a digest of the hash lists of Sysmon.
+type Hash struct {
+	Algorithm string // as written, such as SHA256 or IMPHASH
+	Digest    string // in lower case
+}
+
+// ParseHashes returns the hashes of the ALGORITHM=digest lists of Sysmon, separated by commas, skipping the
+// malformed ones.
+func ParseHashes(s string) []Hash {
+	var hashes []Hash
+	for _, hash := range strings.Split(s, ",") {
+		algorithm, digest, ok := strings.Cut(strings.TrimSpace(hash), "=")
+		if !ok || digest == "" {
+			continue
+		}
+		hashes = append(hashes, Hash{Algorithm: algorithm, Digest: strings.ToLower(digest)})
+	}
+	return hashes
+}
+
+// Answer is a DNS answer of the query results of Sysmon.
+type Answer struct {
+	Type string     // record type, such as CNAME, by its number when unknown
+	Data string     // name or address of the record
+	Addr netip.Addr // address of A and AAAA records, invalid for the other types
+}
+
+// dnsRecordTypes names the numbers of the DNS record types Sysmon writes in query results.
+var dnsRecordTypes = map[string]string{
+	"1": "A", "2": "NS", "5": "CNAME", "6": "SOA", "12": "PTR", "15": "MX", "16": "TXT", "28": "AAAA", "33": "SRV",
+}
+
+// ParseAnswers returns the answers of the QueryResults of Sysmon DNS events, addresses and records of type,
+// such as "type:  5 example.com", separated by semicolons. IPv4-mapped addresses are unmapped.
+func ParseAnswers(results string) []Answer {
+	var answers []Answer
+	for _, result := range strings.Split(results, ";") {
+		result = strings.TrimSpace(result)
+		if result == "" {
+			continue
+		}
+		if fields := strings.Fields(result); len(fields) == 3 && fields[0] == "type:" {
+			recordType, ok := dnsRecordTypes[fields[1]]
+			if !ok {
+				recordType = fields[1]
+			}
+			answers = append(answers, Answer{Type: recordType, Data: fields[2]})
+			continue
+		}
+		addr, err := netip.ParseAddr(strings.TrimPrefix(result, "::ffff:"))
+		if err != nil {
+			continue
+		}
+		recordType := "A"
+		if addr.Is6() {
+			recordType = "AAAA"
+		}
+		answers = append(answers, Answer{Type: recordType, Data: addr.String(), Addr: addr})
+	}
+	return answers
+}
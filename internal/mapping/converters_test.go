@@ -0,0 +1,103 @@
+package mapping
+
+import (
+	"math"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+func TestInteger(t *testing.T) {
+	text := func(s string) etw.Value { return etw.StringValue(s, winapi.TdhInTypeUnicodestring, 0) }
+
+	tests := []struct {
+		name  string
+		value etw.Value
+		want  interface{} // nil when the value does not convert
+	}{
+		{"int8", etw.NewValue(int8(-5), winapi.TdhInTypeInt8, 0), int64(-5)},
+		{"min int64", etw.NewValue(int64(math.MinInt64), winapi.TdhInTypeInt64, 0), int64(math.MinInt64)},
+		{"uint32", etw.NewValue(uint32(4242), winapi.TdhInTypeUint32, 0), uint64(4242)},
+		{"max uint64", etw.NewValue(uint64(math.MaxUint64), winapi.TdhInTypeUint64, 0), uint64(math.MaxUint64)},
+		{"mapped", etw.NewValue(uint32(6), winapi.TdhInTypeUint32, 0).WithName("TCP"), int64(6)},
+		{"string", text("-12"), int64(-12)},
+		{"hex string", text("0x1F"), int64(31)},
+		{"max int64 string", text("9223372036854775807"), int64(math.MaxInt64)},
+		{"max uint64 string", text("18446744073709551615"), uint64(math.MaxUint64)},
+		{"max uint64 hex string", text("0xFFFFFFFFFFFFFFFF"), uint64(math.MaxUint64)},
+		{"overflow", text("18446744073709551616"), nil},
+		{"invalid", text("ten"), nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			object := Object{}
+			if converted := Integer(object, "a", test.value); converted != (test.want != nil) {
+				t.Fatalf("converted = %t, want %t", converted, test.want != nil)
+			}
+			if got := object["a"]; got != test.want {
+				t.Errorf("a = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		value etw.Value
+		want  time.Time
+	}{
+		{etw.StringValue("2024-05-06 07:08:09.123", 0, 0), time.Date(2024, 5, 6, 7, 8, 9, 123000000, time.UTC)},
+		{etw.StringValue("2024-05-06T09:08:09.5+02:00", 0, 0), time.Date(2024, 5, 6, 7, 8, 9, 500000000, time.UTC)},
+		{etw.NewValue(time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), winapi.TdhInTypeFiletime, 0), time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		if got, ok := ParseTime(test.value); !ok || !got.Equal(test.want) || got.Location() != time.UTC {
+			t.Errorf("ParseTime(%v) = %v, %t, want %v", test.value, got, ok, test.want)
+		}
+	}
+	if _, ok := ParseTime(etw.StringValue("yesterday", 0, 0)); ok {
+		t.Error("ParseTime(yesterday) converted")
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path, directory, name string
+	}{
+		{`C:\Windows\System32\cmd.exe`, `C:\Windows\System32`, "cmd.exe"},
+		{`\Device\HarddiskVolume3\a.txt`, `\Device\HarddiskVolume3`, "a.txt"},
+		{"C:/x//a.txt", "C:/x", "a.txt"},
+		{"cmd.exe", "", "cmd.exe"},
+	}
+	for _, test := range tests {
+		if directory, name := SplitPath(test.path); directory != test.directory || name != test.name {
+			t.Errorf("SplitPath(%q) = %q, %q, want %q, %q", test.path, directory, name, test.directory, test.name)
+		}
+	}
+}
+
+func TestParseHashes(t *testing.T) {
+	got := ParseHashes("SHA1=AB, IMPHASH=CD,MD5=,SHA256")
+	want := []Hash{{Algorithm: "SHA1", Digest: "ab"}, {Algorithm: "IMPHASH", Digest: "cd"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseHashes = %v, want %v", got, want)
+	}
+}
+
+func TestParseAnswers(t *testing.T) {
+	got := ParseAnswers("type:  5 edge.example.net;::ffff:93.184.216.34;2001:db8::1;type:  99 x;bad;")
+	want := []Answer{
+		{Type: "CNAME", Data: "edge.example.net"},
+		{Type: "A", Data: "93.184.216.34", Addr: netip.MustParseAddr("93.184.216.34")},
+		{Type: "AAAA", Data: "2001:db8::1", Addr: netip.MustParseAddr("2001:db8::1")},
+		{Type: "99", Data: "x"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAnswers = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+package mapping
+
+import (
+	"strings"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+)
+
+// Key identifies the events a mapping applies to.
+type Key struct {
+	Provider  string // GUID in registry format, empty for classic kernel events
+	EventID   uint16
+	EventType string // class and event type of classic kernel events, such as Process/Start, see Event.System.EventType
+}
+
+// KeyOf returns the key of event.
+func KeyOf(event *etw.Event) Key {
+	if event.System.EventType != "" {
+		return Key{EventType: event.System.EventType}
+	}
+	return Key{Provider: strings.ToUpper(event.System.Provider.Guid), EventID: event.System.EventID}
+}
+
+// Field maps a property of the event to the path Target. Convert sets the values under Target from the value,
+// when nil the string of the value is set as is.
+type Field struct {
+	Property string
+	Target   string
+	Convert  Converter
+}
+
+// Registry holds mappings of type M by the key of the events they apply to.
+type Registry[M any] struct {
+	mappings map[Key]M
+}
+
+func NewRegistry[M any]() *Registry[M] {
+	return &Registry[M]{mappings: make(map[Key]M)}
+}
+
+// Register maps the events of key with mapping, replacing the mapping registered before, if any.
+func (r *Registry[M]) Register(key Key, mapping M) {
+	key.Provider = strings.ToUpper(key.Provider)
+	r.mappings[key] = mapping
+}
+
+// Mapping returns the mapping registered for key.
+func (r *Registry[M]) Mapping(key Key) (M, bool) {
+	mapping, ok := r.mappings[key]
+	return mapping, ok
+}
+
+// Apply sets values as is, then the fields of the properties of event, and returns the properties that converted.
+func Apply(object Object, event *etw.Event, values map[string]interface{}, fields []Field) map[string]bool {
+	for path, value := range values {
+		object.Set(path, value)
+	}
+
+	converted := make(map[string]bool)
+	for _, field := range fields {
+		value, ok := PropertyValue(event, field.Property)
+		if !ok {
+			continue
+		}
+		convert := field.Convert
+		if convert == nil {
+			convert = String
+		}
+		if convert(object, field.Target, value) {
+			converted[field.Property] = true
+		}
+	}
+	return converted
+}
+
+// SetUnconverted sets the strings of the properties of event missing from converted under prefix, such as
+// winlog.event_data.
+func SetUnconverted(object Object, event *etw.Event, prefix string, converted map[string]bool) {
+	for _, property := range event.Properties {
+		if !converted[property.Name] {
+			object.Set(prefix+property.Name, property.Value.String())
+		}
+	}
+	if len(event.Properties) == 0 {
+		for name, value := range event.EventData {
+			object.Set(prefix+name, value)
+		}
+	}
+}
+
+// PropertyValue returns the top level property called name, or its string in EventData for events without
+// Properties.
+func PropertyValue(event *etw.Event, name string) (etw.Value, bool) {
+	if value, ok := event.Property(name); ok {
+		return value, true
+	}
+	if s, ok := event.EventData[name]; ok {
+		return etw.StringValue(s, 0, 0), true
+	}
+	return etw.Value{}, false
+}
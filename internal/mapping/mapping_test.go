@@ -0,0 +1,78 @@
+package mapping
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+func TestKeyOf(t *testing.T) {
+	event := &etw.Event{}
+	event.System.Provider.Guid = "{5770385f-c22a-43e0-bf4c-06f5698ffbd9}"
+	event.System.EventID = 11
+	if got, want := KeyOf(event), (Key{Provider: "{5770385F-C22A-43E0-BF4C-06F5698FFBD9}", EventID: 11}); got != want {
+		t.Errorf("KeyOf = %+v, want %+v", got, want)
+	}
+
+	event.System.Provider.Guid = "{9E814AAD-3204-11D2-9A82-006008A86939}"
+	event.System.EventType = "Process/Start"
+	if got, want := KeyOf(event), (Key{EventType: "Process/Start"}); got != want {
+		t.Errorf("KeyOf classic = %+v, want %+v", got, want)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry[string]()
+	registry.Register(Key{Provider: "{5770385f-c22a-43e0-bf4c-06f5698ffbd9}", EventID: 11}, "file")
+	registry.Register(Key{EventType: "Process/Start"}, "process")
+
+	if got, ok := registry.Mapping(Key{Provider: "{5770385F-C22A-43E0-BF4C-06F5698FFBD9}", EventID: 11}); !ok || got != "file" {
+		t.Errorf("Mapping = %q, want the mapping of the lowercase provider", got)
+	}
+	if got, ok := registry.Mapping(Key{EventType: "Process/Start"}); !ok || got != "process" {
+		t.Errorf("Mapping classic = %q, want process", got)
+	}
+	if _, ok := registry.Mapping(Key{EventID: 11}); ok {
+		t.Error("Mapping of an unregistered key")
+	}
+}
+
+func TestApply(t *testing.T) {
+	event := &etw.Event{Properties: []etw.Property{
+		{Name: "Image", Value: etw.StringValue(`C:\a.exe`, winapi.TdhInTypeUnicodestring, 0)},
+		{Name: "ProcessId", Value: etw.NewValue(uint32(4), winapi.TdhInTypeUint32, 0)},
+		{Name: "User", Value: etw.StringValue("-", winapi.TdhInTypeUnicodestring, 0)},
+		{Name: "Other", Value: etw.StringValue("x", winapi.TdhInTypeUnicodestring, 0)},
+	}}
+	fields := []Field{
+		{Property: "Image", Target: "process.executable"},
+		{Property: "ProcessId", Target: "process.pid", Convert: Integer},
+		{Property: "User", Target: "user.name"},
+		{Property: "Missing", Target: "missing"},
+	}
+
+	object := Object{}
+	converted := Apply(object, event, map[string]interface{}{"event.kind": "event"}, fields)
+	if want := map[string]bool{"Image": true, "ProcessId": true}; !reflect.DeepEqual(converted, want) {
+		t.Errorf("converted = %v, want %v", converted, want)
+	}
+	SetUnconverted(object, event, "unmapped.", converted)
+
+	want := Object{
+		"event":    Object{"kind": "event"},
+		"process":  Object{"executable": `C:\a.exe`, "pid": uint64(4)},
+		"unmapped": Object{"User": "-", "Other": "x"},
+	}
+	if !reflect.DeepEqual(object, want) {
+		t.Errorf("object = %v, want %v", object, want)
+	}
+
+	// events read from XML hold EventData only
+	object = Object{}
+	SetUnconverted(object, &etw.Event{EventData: map[string]string{"Image": `C:\a.exe`}}, "unmapped.", nil)
+	if got, _ := object.Get("unmapped.Image"); got != `C:\a.exe` {
+		t.Errorf("unmapped.Image = %v", got)
+	}
+}
@@ -0,0 +1,51 @@
+package mapping
+
+import (
+	"strings"
+)
+
+// Object is the output of the ECS and OCSF mappers, nested objects as maps, as marshalled to JSON.
+type Object map[string]interface{}
+
+// Set sets the value at a dotted path, such as process.parent.pid, creating the objects on its way.
+// A value already at a prefix of the path is replaced by an object.
+func (o Object) Set(path string, value interface{}) {
+	object := o
+	names := strings.Split(path, ".")
+	for _, name := range names[:len(names)-1] {
+		child, ok := object[name].(Object)
+		if !ok {
+			child = Object{}
+			object[name] = child
+		}
+		object = child
+	}
+	object[names[len(names)-1]] = value
+}
+
+// Get returns the value at a dotted path.
+func (o Object) Get(path string) (interface{}, bool) {
+	object := o
+	names := strings.Split(path, ".")
+	for _, name := range names[:len(names)-1] {
+		child, ok := object[name].(Object)
+		if !ok {
+			return nil, false
+		}
+		object = child
+	}
+	value, ok := object[names[len(names)-1]]
+	return value, ok
+}
+
+// Append adds value to the string list at a dotted path, such as related.ip, unless it already holds it.
+func (o Object) Append(path string, value string) {
+	values, _ := o.Get(path)
+	list, _ := values.([]string)
+	for _, listed := range list {
+		if listed == value {
+			return
+		}
+	}
+	o.Set(path, append(list, value))
+}
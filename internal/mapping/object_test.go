@@ -0,0 +1,23 @@
+package mapping
+
+import (
+	"testing"
+)
+
+func TestObject(t *testing.T) {
+	object := Object{}
+	object.Set("process", "cmd.exe")
+	object.Set("process.pid", 4)
+	if got, ok := object.Get("process.pid"); !ok || got != 4 {
+		t.Errorf("process.pid = %v, want 4", got)
+	}
+	if _, ok := object.Get("process.pid.value"); ok {
+		t.Errorf("found a value under a number")
+	}
+
+	object.Append("related.ip", "10.0.0.1")
+	object.Append("related.ip", "10.0.0.1")
+	if got, _ := object.Get("related.ip"); len(got.([]string)) != 1 {
+		t.Errorf("related.ip = %v, want a single address", got)
+	}
+}
@@ -0,0 +1,523 @@
+package manifest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+var (
+	ErrInvalidManifest = fmt.Errorf("invalid instrumentation manifest")
+)
+
+// DefaultCulture is used when the requested culture has no string table.
+const DefaultCulture = "en-US"
+
+// Manifest holds the schemas of the events declared by an instrumentation manifest,
+// as TdhGetEventInformation would return them once the manifest is registered with wevtutil.
+type Manifest struct {
+	Providers []*Provider
+}
+
+type Provider struct {
+	Name    string
+	GUID    winguid.GUID
+	Message string
+	Schemas []*etw.Schema
+}
+
+// ParseFile parses the manifest at path, see Parse.
+func ParseFile(path string, culture string) (*Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Parse(file, culture)
+}
+
+// Parse reads an instrumentation manifest, resolving $(string.id) references with the string table of culture.
+func Parse(r io.Reader, culture string) (*Manifest, error) {
+	var document instrumentationManifest
+	if err := xml.NewDecoder(r).Decode(&document); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidManifest, err)
+	}
+
+	stringTable := selectStringTable(document.Localization, culture)
+
+	manifest := Manifest{}
+	for i := range document.Providers {
+		provider, err := newProviderBuilder(&document.Providers[i], stringTable).build()
+		if err != nil {
+			return nil, err
+		}
+		manifest.Providers = append(manifest.Providers, provider)
+	}
+
+	return &manifest, nil
+}
+
+// Schemas returns the schemas of every provider.
+func (m *Manifest) Schemas() []*etw.Schema {
+	var schemas []*etw.Schema
+	for _, provider := range m.Providers {
+		schemas = append(schemas, provider.Schemas...)
+	}
+	return schemas
+}
+
+// Backend returns a DecoderBackend decoding the events of the manifest.
+func (m *Manifest) Backend() *etw.SchemaBackend {
+	return etw.NewSchemaBackend(m.Schemas()...)
+}
+
+func selectStringTable(resources []resourcesElement, culture string) map[string]string {
+	var selected *resourcesElement
+	for _, candidate := range []string{culture, DefaultCulture} {
+		for i := range resources {
+			if strings.EqualFold(resources[i].Culture, candidate) {
+				selected = &resources[i]
+				break
+			}
+		}
+		if selected != nil {
+			break
+		}
+	}
+	if selected == nil && len(resources) > 0 {
+		selected = &resources[0]
+	}
+
+	stringTable := make(map[string]string)
+	if selected != nil {
+		for _, s := range selected.Strings {
+			stringTable[s.ID] = s.Value
+		}
+	}
+	return stringTable
+}
+
+// definition is a named value of the manifest: level, task, opcode, keyword or channel.
+type definition struct {
+	name    string
+	value   uint64
+	message string
+}
+
+type providerBuilder struct {
+	element     *providerElement
+	stringTable map[string]string
+
+	levels    map[string]definition
+	tasks     map[string]definition
+	opcodes   map[string]definition
+	keywords  map[string]definition
+	channels  map[string]definition // by chid and by name
+	taskGUIDs map[string]winguid.GUID
+
+	taskOpcodes map[string]map[string]definition // opcodes declared inside a task
+	maps        map[string]*etw.ValueMap
+	templates   map[string]*templateElement
+}
+
+func newProviderBuilder(element *providerElement, stringTable map[string]string) *providerBuilder {
+	return &providerBuilder{
+		element:     element,
+		stringTable: stringTable,
+		levels:      make(map[string]definition),
+		tasks:       make(map[string]definition),
+		opcodes:     make(map[string]definition),
+		keywords:    make(map[string]definition),
+		channels:    make(map[string]definition),
+		taskGUIDs:   make(map[string]winguid.GUID),
+		taskOpcodes: make(map[string]map[string]definition),
+		maps:        make(map[string]*etw.ValueMap),
+		templates:   make(map[string]*templateElement),
+	}
+}
+
+// message resolves $(string.id) references, other messages are literal.
+func (b *providerBuilder) message(message string) string {
+	if strings.HasPrefix(message, "$(string.") && strings.HasSuffix(message, ")") {
+		if resolved, ok := b.stringTable[message[len("$(string."):len(message)-1]]; ok {
+			return resolved
+		}
+	}
+	return message
+}
+
+func (b *providerBuilder) definition(name string, value string, message string, bitSize int) (definition, error) {
+	parsed, err := strconv.ParseUint(value, 0, bitSize)
+	if err != nil {
+		return definition{}, fmt.Errorf("%w: %s value %q", ErrInvalidManifest, name, value)
+	}
+	return definition{name: name, value: parsed, message: b.message(message)}, nil
+}
+
+func (b *providerBuilder) build() (*Provider, error) {
+	guid, err := winguid.Parse(b.element.GUID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: provider %s guid: %s", ErrInvalidManifest, b.element.Name, err)
+	}
+
+	provider := Provider{
+		Name:    b.element.Name,
+		GUID:    *guid,
+		Message: b.message(b.element.Message),
+	}
+
+	if err = b.loadDefinitions(); err != nil {
+		return nil, err
+	}
+	if err = b.loadMaps(); err != nil {
+		return nil, err
+	}
+	for i := range b.element.Templates {
+		b.templates[b.element.Templates[i].TID] = &b.element.Templates[i]
+	}
+
+	for i := range b.element.Events {
+		schema, schemaErr := b.eventSchema(&provider, &b.element.Events[i])
+		if schemaErr != nil {
+			return nil, schemaErr
+		}
+		provider.Schemas = append(provider.Schemas, schema)
+	}
+
+	return &provider, nil
+}
+
+func (b *providerBuilder) loadDefinitions() error {
+	for _, level := range b.element.Levels {
+		d, err := b.definition(level.Name, level.Value, level.Message, 8)
+		if err != nil {
+			return err
+		}
+		b.levels[level.Name] = d
+	}
+
+	for _, opcode := range b.element.Opcodes {
+		d, err := b.definition(opcode.Name, opcode.Value, opcode.Message, 8)
+		if err != nil {
+			return err
+		}
+		b.opcodes[opcode.Name] = d
+	}
+
+	for _, task := range b.element.Tasks {
+		d, err := b.definition(task.Name, task.Value, task.Message, 16)
+		if err != nil {
+			return err
+		}
+		b.tasks[task.Name] = d
+
+		if task.EventGUID != "" {
+			eventGUID, guidErr := winguid.Parse(task.EventGUID)
+			if guidErr != nil {
+				return fmt.Errorf("%w: task %s eventGUID: %s", ErrInvalidManifest, task.Name, guidErr)
+			}
+			b.taskGUIDs[task.Name] = *eventGUID
+		}
+
+		opcodes := make(map[string]definition)
+		for _, opcode := range task.Opcodes {
+			if opcodes[opcode.Name], err = b.definition(opcode.Name, opcode.Value, opcode.Message, 8); err != nil {
+				return err
+			}
+		}
+		b.taskOpcodes[task.Name] = opcodes
+	}
+
+	for _, keyword := range b.element.Keywords {
+		d, err := b.definition(keyword.Name, keyword.Mask, keyword.Message, 64)
+		if err != nil {
+			return err
+		}
+		b.keywords[keyword.Name] = d
+	}
+
+	for _, channel := range b.element.Channels.ImportChannels {
+		imported, ok := winmetaChannels[channel.Name]
+		if !ok {
+			return fmt.Errorf("%w: unknown imported channel %s", ErrInvalidManifest, channel.Name)
+		}
+		b.addChannel(channel, definition{name: channel.Name, value: imported.value, message: b.message(channel.Message)})
+	}
+
+	nextChannelValue := uint64(firstChannelValue)
+	for _, channel := range b.element.Channels.Channels {
+		d := definition{name: channel.Name, value: nextChannelValue, message: b.message(channel.Message)}
+		if channel.Value != "" {
+			var err error
+			if d, err = b.definition(channel.Name, channel.Value, channel.Message, 8); err != nil {
+				return err
+			}
+		}
+		nextChannelValue = d.value + 1
+		b.addChannel(channel, d)
+	}
+
+	return nil
+}
+
+func (b *providerBuilder) addChannel(channel channelElement, d definition) {
+	b.channels[channel.Name] = d
+	if channel.ChID != "" {
+		b.channels[channel.ChID] = d
+	}
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventmanifestschema-maps-definition
+func (b *providerBuilder) loadMaps() error {
+	for _, maps := range []struct {
+		elements []mapElement
+		flag     winapi.MapFlags
+	}{
+		{b.element.Maps.ValueMaps, winapi.EVENTMAP_INFO_FLAG_MANIFEST_VALUEMAP},
+		{b.element.Maps.BitMaps, winapi.EVENTMAP_INFO_FLAG_MANIFEST_BITMAP},
+	} {
+		for _, element := range maps.elements {
+			valueMap := etw.ValueMap{
+				Name:    element.Name,
+				Flag:    maps.flag,
+				Entries: make([]etw.ValueMapEntry, 0, len(element.Entries)),
+			}
+			for _, entry := range element.Entries {
+				value, err := strconv.ParseUint(entry.Value, 0, 32)
+				if err != nil {
+					return fmt.Errorf("%w: map %s value %q", ErrInvalidManifest, element.Name, entry.Value)
+				}
+				valueMap.Entries = append(valueMap.Entries, etw.ValueMapEntry{
+					Value:  uint32(value),
+					Output: strings.TrimRight(b.message(entry.Message), " "),
+				})
+			}
+			b.maps[element.Name] = &valueMap
+		}
+	}
+	return nil
+}
+
+// lookup returns the definition called name in the provider, or in winmeta.xml.
+func (b *providerBuilder) lookup(kind string, name string, definitions map[string]definition, winmeta map[string]winmetaDefinition) (definition, error) {
+	if d, ok := definitions[name]; ok {
+		if d.message == "" {
+			d.message = d.name
+		}
+		return d, nil
+	}
+	if d, ok := winmeta[name]; ok {
+		return definition{name: name, value: d.value, message: d.message}, nil
+	}
+	return definition{}, fmt.Errorf("%w: unknown %s %s", ErrInvalidManifest, kind, name)
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventmanifestschema-eventdefinitiontype-complextype
+func (b *providerBuilder) eventSchema(provider *Provider, event *eventElement) (*etw.Schema, error) {
+	schema := etw.Schema{
+		ProviderGUID:    provider.GUID,
+		DecodingSource:  winapi.DecodingSourceXMLFile,
+		ProviderName:    provider.Name,
+		ProviderMessage: provider.Message,
+		EventMessage:    b.message(event.Message),
+	}
+
+	id, err := strconv.ParseUint(event.Value, 0, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: event value %q", ErrInvalidManifest, event.Value)
+	}
+	schema.EventDescriptor.Id = uint16(id)
+
+	if event.Version != "" {
+		version, versionErr := strconv.ParseUint(event.Version, 0, 8)
+		if versionErr != nil {
+			return nil, fmt.Errorf("%w: event %d version %q", ErrInvalidManifest, id, event.Version)
+		}
+		schema.EventDescriptor.Version = uint8(version)
+	}
+
+	if event.Level != "" {
+		level, levelErr := b.lookup("level", event.Level, b.levels, winmetaLevels)
+		if levelErr != nil {
+			return nil, levelErr
+		}
+		schema.EventDescriptor.Level = uint8(level.value)
+		schema.LevelName = level.message
+	}
+
+	if event.Task != "" {
+		task, taskErr := b.lookup("task", event.Task, b.tasks, winmetaTasks)
+		if taskErr != nil {
+			return nil, taskErr
+		}
+		schema.EventDescriptor.Task = uint16(task.value)
+		schema.TaskName = task.message
+		schema.EventGUID = b.taskGUIDs[event.Task]
+	}
+
+	if event.Opcode != "" {
+		opcodes := b.opcodes
+		if taskOpcodes, ok := b.taskOpcodes[event.Task]; ok {
+			if _, ok = taskOpcodes[event.Opcode]; ok {
+				opcodes = taskOpcodes
+			}
+		}
+		opcode, opcodeErr := b.lookup("opcode", event.Opcode, opcodes, winmetaOpcodes)
+		if opcodeErr != nil {
+			return nil, opcodeErr
+		}
+		schema.EventDescriptor.Opcode = uint8(opcode.value)
+		schema.OpcodeName = opcode.message
+	}
+
+	keywordNames := make([]string, 0)
+	for _, name := range strings.Fields(event.Keywords) {
+		keyword, keywordErr := b.lookup("keyword", name, b.keywords, winmetaKeywords)
+		if keywordErr != nil {
+			return nil, keywordErr
+		}
+		schema.EventDescriptor.Keyword |= keyword.value
+		keywordNames = append(keywordNames, keyword.message)
+	}
+	schema.KeywordsName = strings.Join(keywordNames, ", ")
+
+	if event.Channel != "" {
+		channel, ok := b.channels[event.Channel]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown channel %s", ErrInvalidManifest, event.Channel)
+		}
+		schema.EventDescriptor.Channel = uint8(channel.value)
+		schema.ChannelName = channel.name
+	}
+
+	if event.Template != "" {
+		template, ok := b.templates[event.Template]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown template %s", ErrInvalidManifest, event.Template)
+		}
+		if err = b.templateProperties(&schema, template); err != nil {
+			return nil, err
+		}
+	}
+
+	return &schema, nil
+}
+
+// templateProperties lays the template out like TRACE_EVENT_INFO: top level items first, then structure members.
+// Length and count references resolve within the structure first, then among the top level items.
+//
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventmanifestschema-templateitemtype-complextype
+func (b *providerBuilder) templateProperties(schema *etw.Schema, template *templateElement) error {
+	items := make([]*templateItem, 0, len(template.Items))
+	for i := range template.Items {
+		switch template.Items[i].XMLName.Local {
+		case "data", "struct":
+			items = append(items, &template.Items[i])
+		case "UserData":
+			schema.Flags |= winapi.TEMPLATE_USER_DATA
+		}
+	}
+	if len(items) > 0 {
+		schema.Flags |= winapi.TEMPLATE_EVENT_DATA
+	}
+
+	schema.TopLevelPropertyCount = len(items)
+	schema.Properties = make([]etw.PropertyInfo, len(items))
+	topLevel := make(map[string]uint16, len(items))
+
+	for i, item := range items {
+		property, err := b.itemProperty(schema, item, topLevel, nil)
+		if err != nil {
+			return fmt.Errorf("%w (template %s)", err, template.TID)
+		}
+		schema.Properties[i] = property
+		topLevel[item.Name] = uint16(i)
+	}
+
+	for i, item := range items {
+		if item.XMLName.Local != "struct" {
+			continue
+		}
+
+		schema.Properties[i].StructStartIndex = uint16(len(schema.Properties))
+		schema.Properties[i].NumOfStructMembers = uint16(len(item.Members))
+
+		members := make(map[string]uint16, len(item.Members))
+		for j := range item.Members {
+			member := &item.Members[j]
+			property, err := b.itemProperty(schema, member, topLevel, members)
+			if err != nil {
+				return fmt.Errorf("%w (template %s, struct %s)", err, template.TID, item.Name)
+			}
+			members[member.Name] = uint16(len(schema.Properties))
+			schema.Properties = append(schema.Properties, property)
+		}
+	}
+
+	return nil
+}
+
+func (b *providerBuilder) itemProperty(schema *etw.Schema, item *templateItem, topLevel map[string]uint16, members map[string]uint16) (etw.PropertyInfo, error) {
+	property := etw.PropertyInfo{Name: item.Name}
+
+	if item.XMLName.Local == "struct" {
+		property.Flags |= winapi.PropertyStruct
+	} else {
+		inType, ok := inTypes[item.InType]
+		if !ok {
+			return property, fmt.Errorf("%w: %s has unknown inType %q", ErrInvalidManifest, item.Name, item.InType)
+		}
+		property.InType = inType
+		property.OutType = outTypes[item.OutType] // TDH picks the default output of the input type for unknown values too
+
+		if item.Map != "" {
+			valueMap, mapOk := b.maps[item.Map]
+			if !mapOk {
+				return property, fmt.Errorf("%w: %s has unknown map %s", ErrInvalidManifest, item.Name, item.Map)
+			}
+			if schema.Maps == nil {
+				schema.Maps = make(map[string]*etw.ValueMap)
+			}
+			schema.Maps[item.Map] = valueMap
+			property.MapName = item.Map
+		}
+	}
+
+	resolve := func(attribute string, reference string, fixed winapi.PropertyFlags, param winapi.PropertyFlags) (uint16, error) {
+		if value, err := strconv.ParseUint(reference, 0, 16); err == nil {
+			property.Flags |= fixed
+			return uint16(value), nil
+		}
+		if index, ok := members[reference]; ok {
+			property.Flags |= param
+			return index, nil
+		}
+		if index, ok := topLevel[reference]; ok {
+			property.Flags |= param
+			return index, nil
+		}
+		return 0, fmt.Errorf("%w: %s %s references unknown %s", ErrInvalidManifest, item.Name, attribute, reference)
+	}
+
+	var err error
+	if item.Length != "" {
+		if property.Length, err = resolve("length", item.Length, winapi.PropertyParamFixedLength, winapi.PropertyParamLength); err != nil {
+			return property, err
+		}
+	}
+	if item.Count != "" {
+		if property.Count, err = resolve("count", item.Count, winapi.PropertyParamFixedCount, winapi.PropertyParamCount); err != nil {
+			return property, err
+		}
+	}
+
+	return property, nil
+}
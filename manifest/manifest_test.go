@@ -0,0 +1,218 @@
+package manifest
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+var sampleManifest = filepath.Join("testdata", "sample.man")
+
+func sampleSchemas(t *testing.T, culture string) map[uint16]*etw.Schema {
+	t.Helper()
+
+	manifest, err := ParseFile(sampleManifest, culture)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	if len(manifest.Providers) != 1 {
+		t.Fatalf("%d providers, want 1", len(manifest.Providers))
+	}
+
+	schemas := make(map[uint16]*etw.Schema)
+	for _, schema := range manifest.Schemas() {
+		schemas[schema.EventID()] = schema
+	}
+	return schemas
+}
+
+func TestParseDescriptors(t *testing.T) {
+	schemas := sampleSchemas(t, "en-US")
+	if len(schemas) != 3 {
+		t.Fatalf("%d schemas, want 3", len(schemas))
+	}
+
+	connect := schemas[1]
+	wantDescriptor := winapi.EventDescriptor{Id: 1, Version: 1, Channel: 16, Level: 4, Opcode: 1, Task: 7, Keyword: 0x01000000000001}
+	if connect.EventDescriptor != wantDescriptor {
+		t.Errorf("descriptor = %+v, want %+v", connect.EventDescriptor, wantDescriptor)
+	}
+	if connect.ProviderGUID != *winguid.MustParse("{5770385F-C22A-43E0-BF4C-06F5698FFBD9}") || connect.ProviderName != "Sample-Provider" {
+		t.Errorf("provider = %v %s", connect.ProviderGUID, connect.ProviderName)
+	}
+	if connect.EventGUID != *winguid.MustParse("{A1B2C3D4-0000-1111-2222-333344445555}") {
+		t.Errorf("event GUID = %v", connect.EventGUID)
+	}
+	names := []string{connect.ProviderMessage, connect.ChannelName, connect.LevelName, connect.TaskName, connect.OpcodeName, connect.KeywordsName, connect.EventMessage}
+	wantNames := []string{"Sample Provider", "Sample-Provider/Operational", "Information", "Connect", "Start", "Network, Response Time", "%1 connected"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("names = %q, want %q", names, wantNames)
+	}
+
+	retry := schemas[2]
+	if retry.EventDescriptor.Channel != 17 || retry.EventDescriptor.Level != 16 || retry.EventDescriptor.Opcode != 10 {
+		t.Errorf("retry descriptor = %+v", retry.EventDescriptor)
+	}
+	if retry.LevelName != "Fatal" || retry.OpcodeName != "Retry" || retry.TopLevelPropertyCount != 0 {
+		t.Errorf("retry = %+v", retry)
+	}
+
+	userData := schemas[3]
+	if userData.EventDescriptor.Channel != 9 || userData.ChannelName != "Application" || userData.Flags != winapi.TEMPLATE_USER_DATA {
+		t.Errorf("user data channel %d %s, flags %d", userData.EventDescriptor.Channel, userData.ChannelName, userData.Flags)
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	connect := sampleSchemas(t, "en-US")[1]
+
+	want := []etw.PropertyInfo{
+		{Name: "Image", InType: winapi.TdhInTypeUnicodestring, OutType: winapi.TdhOutTypeString},
+		{Name: "State", InType: winapi.TdhInTypeUint32, MapName: "StateMap"},
+		{Name: "Flags", InType: winapi.TdhInTypeUint32, MapName: "FlagsMap"},
+		{Name: "Size", InType: winapi.TdhInTypeUint32},
+		{Name: "Blob", InType: winapi.TdhInTypeBinary, Flags: winapi.PropertyParamLength, Length: 3},
+		{Name: "EndpointCount", InType: winapi.TdhInTypeUint16},
+		{Name: "Endpoints", Flags: winapi.PropertyStruct | winapi.PropertyParamCount, Count: 5, StructStartIndex: 8, NumOfStructMembers: 2},
+		{Name: "Padding", InType: winapi.TdhInTypeUint8, Flags: winapi.PropertyParamFixedCount, Count: 2},
+		{Name: "Port", InType: winapi.TdhInTypeUint16, OutType: winapi.TdhOutTypePort},
+		{Name: "Address", InType: winapi.TdhInTypeUint32, OutType: winapi.TdhOutTypeIpv4},
+	}
+	if !reflect.DeepEqual(connect.Properties, want) {
+		t.Errorf("properties =\n%+v\nwant\n%+v", connect.Properties, want)
+	}
+	if connect.TopLevelPropertyCount != 8 || connect.Flags != winapi.TEMPLATE_EVENT_DATA {
+		t.Errorf("top level = %d, flags = %d", connect.TopLevelPropertyCount, connect.Flags)
+	}
+	if err := connect.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	stateMap := connect.Maps["StateMap"]
+	if stateMap == nil || stateMap.Flag != winapi.EVENTMAP_INFO_FLAG_MANIFEST_VALUEMAP || stateMap.Entries[0].Output != "Stopped" {
+		t.Errorf("StateMap = %+v", stateMap)
+	}
+	if flagsMap := connect.Maps["FlagsMap"]; flagsMap == nil || !flagsMap.IsBitmap() {
+		t.Errorf("FlagsMap = %+v", flagsMap)
+	}
+}
+
+func TestParseCulture(t *testing.T) {
+	french := sampleSchemas(t, "fr-FR")[1]
+	if french.ProviderMessage != "Fournisseur exemple" || french.EventMessage != "Connexion de %1" {
+		t.Errorf("fr-FR messages = %q %q", french.ProviderMessage, french.EventMessage)
+	}
+	if french.TaskName != "$(string.Task.Connect)" {
+		t.Errorf("fr-FR keeps references missing from its string table, task = %q", french.TaskName)
+	}
+
+	fallback := sampleSchemas(t, "de-DE")[1]
+	if fallback.ProviderMessage != "Sample Provider" {
+		t.Errorf("de-DE falls back to en-US, provider message = %q", fallback.ProviderMessage)
+	}
+}
+
+func TestBackendDecodes(t *testing.T) {
+	manifest, err := ParseFile(sampleManifest, DefaultCulture)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+
+	var userData []byte
+	for _, r := range "a.exe\x00" {
+		userData = append(userData, byte(r), 0)
+	}
+	userData = binary.LittleEndian.AppendUint32(userData, 1)
+	userData = binary.LittleEndian.AppendUint32(userData, 7)
+	userData = binary.LittleEndian.AppendUint32(userData, 2)
+	userData = append(userData, 0xca, 0xfe)
+	userData = binary.LittleEndian.AppendUint16(userData, 1)
+	userData = append(userData, 0x01, 0xbb, 10, 0, 0, 1)
+	userData = append(userData, 0, 0)
+
+	record := &etw.Record{UserData: userData}
+	record.EventHeader.ProviderId = manifest.Providers[0].GUID
+	record.EventHeader.EventDescriptor = winapi.EventDescriptor{Id: 1, Version: 1, Opcode: 1}
+
+	event, err := etw.DecodeRecord(record, manifest.Backend())
+	if err != nil {
+		t.Fatalf("DecodeRecord: %v", err)
+	}
+
+	want := map[string]string{"Image": "a.exe", "State": "Running", "Flags": "Read|Write|0x4", "Size": "2", "Blob": "0xCAFE", "EndpointCount": "1"}
+	if !reflect.DeepEqual(event.EventData, want) {
+		t.Errorf("EventData = %v, want %v", event.EventData, want)
+	}
+	wantStructs := []map[string]string{{"Port": "443", "Address": "10.0.0.1"}}
+	if !reflect.DeepEqual(event.EventDataStructs["Endpoints"], wantStructs) {
+		t.Errorf("Endpoints = %v, want %v", event.EventDataStructs["Endpoints"], wantStructs)
+	}
+	if event.Message != "a.exe connected" {
+		t.Errorf("Message = %q", event.Message)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	provider := func(body string) string {
+		return `<instrumentationManifest><instrumentation><events>
+			<provider name="P" guid="{5770385F-C22A-43E0-BF4C-06F5698FFBD9}">` + body + `</provider>
+			</events></instrumentation></instrumentationManifest>`
+	}
+
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{"not xml", "<instrumentationManifest"},
+		{"bad guid", strings.Replace(provider(""), "{5770385F", "{XYZ", 1)},
+		{"bad event value", provider(`<events><event value="x"/></events>`)},
+		{"unknown level", provider(`<events><event value="1" level="Missing"/></events>`)},
+		{"unknown channel", provider(`<events><event value="1" channel="Missing"/></events>`)},
+		{"unknown template", provider(`<events><event value="1" template="Missing"/></events>`)},
+		{"unknown in type", provider(`<events><event value="1" template="T"/></events>
+			<templates><template tid="T"><data name="A" inType="win:Missing"/></template></templates>`)},
+		{"unknown length reference", provider(`<events><event value="1" template="T"/></events>
+			<templates><template tid="T"><data name="A" inType="win:Binary" length="Missing"/></template></templates>`)},
+		{"unknown map", provider(`<events><event value="1" template="T"/></events>
+			<templates><template tid="T"><data name="A" inType="win:UInt32" map="Missing"/></template></templates>`)},
+		{"bad map value", provider(`<maps><valueMap name="M"><map value="x" message="X"/></valueMap></maps>`)},
+		{"unknown imported channel", provider(`<channels><importChannel name="Missing" chid="C"/></channels>`)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(test.manifest), DefaultCulture); !errors.Is(err, ErrInvalidManifest) {
+				t.Errorf("err = %v, want %v", err, ErrInvalidManifest)
+			}
+		})
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	sample, err := os.ReadFile(sampleManifest)
+	if err != nil {
+		f.Fatalf("ReadFile: %v", err)
+	}
+	f.Add(string(sample))
+
+	f.Fuzz(func(t *testing.T, text string) {
+		manifest, err := Parse(strings.NewReader(text), DefaultCulture)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidManifest) {
+				t.Fatalf("err = %v, want %v", err, ErrInvalidManifest)
+			}
+			return
+		}
+		for _, schema := range manifest.Schemas() {
+			schema.Validate()
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package manifest
+
+import (
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+// Definitions of winmeta.xml, which manifests reference without declaring them.
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventmanifestschema-leveltype-complextype#remarks
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventmanifestschema-opcodetype-complextype#remarks
+
+type winmetaDefinition struct {
+	value   uint64
+	message string
+}
+
+var (
+	winmetaLevels = map[string]winmetaDefinition{
+		"win:LogAlways":     {0, "Log Always"},
+		"win:Critical":      {1, "Critical"},
+		"win:Error":         {2, "Error"},
+		"win:Warning":       {3, "Warning"},
+		"win:Informational": {4, "Information"},
+		"win:Verbose":       {5, "Verbose"},
+	}
+
+	winmetaOpcodes = map[string]winmetaDefinition{
+		"win:Info":      {0, "Info"},
+		"win:Start":     {1, "Start"},
+		"win:Stop":      {2, "Stop"},
+		"win:DC_Start":  {3, "DCStart"},
+		"win:DC_Stop":   {4, "DCStop"},
+		"win:Extension": {5, "Extension"},
+		"win:Reply":     {6, "Reply"},
+		"win:Resume":    {7, "Resume"},
+		"win:Suspend":   {8, "Suspend"},
+		"win:Send":      {9, "Send"},
+		"win:Receive":   {240, "Receive"},
+	}
+
+	winmetaTasks = map[string]winmetaDefinition{
+		"win:None": {0, "None"},
+	}
+
+	winmetaKeywords = map[string]winmetaDefinition{
+		"win:ResponseTime":    {0x01000000000000, "Response Time"},
+		"win:WDIContext":      {0x02000000000000, "WDI Context"},
+		"win:WDIDiag":         {0x04000000000000, "WDI Diag"},
+		"win:SQM":             {0x08000000000000, "SQM"},
+		"win:AuditFailure":    {0x10000000000000, "Audit Failure"},
+		"win:CorrelationHint": {0x10000000000000, "Correlation Hint"},
+		"win:AuditSuccess":    {0x20000000000000, "Audit Success"},
+		"win:EventlogClassic": {0x80000000000000, "Classic"},
+	}
+
+	// https://learn.microsoft.com/en-us/windows/win32/wes/eventmanifestschema-importchanneltype-complextype
+	winmetaChannels = map[string]winmetaDefinition{
+		"TraceClassic":     {0, "TraceClassic"},
+		"System":           {8, "System"},
+		"Application":      {9, "Application"},
+		"Security":         {10, "Security"},
+		"TraceLogging":     {11, "TraceLogging"},
+		"ProviderMetadata": {12, "ProviderMetadata"},
+	}
+)
+
+// firstChannelValue is the value the message compiler gives the first channel a provider defines without value.
+const firstChannelValue = 16
+
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventmanifestschema-inputtype-complextype
+var inTypes = map[string]winapi.TdhInType{
+	"win:UnicodeString":        winapi.TdhInTypeUnicodestring,
+	"win:AnsiString":           winapi.TdhInTypeAnsistring,
+	"win:Int8":                 winapi.TdhInTypeInt8,
+	"win:UInt8":                winapi.TdhInTypeUint8,
+	"win:Int16":                winapi.TdhInTypeInt16,
+	"win:UInt16":               winapi.TdhInTypeUint16,
+	"win:Int32":                winapi.TdhInTypeInt32,
+	"win:UInt32":               winapi.TdhInTypeUint32,
+	"win:Int64":                winapi.TdhInTypeInt64,
+	"win:UInt64":               winapi.TdhInTypeUint64,
+	"win:Float":                winapi.TdhInTypeFloat,
+	"win:Double":               winapi.TdhInTypeDouble,
+	"win:Boolean":              winapi.TdhInTypeBoolean,
+	"win:Binary":               winapi.TdhInTypeBinary,
+	"win:GUID":                 winapi.TdhInTypeGUID,
+	"win:Pointer":              winapi.TdhInTypePointer,
+	"win:FILETIME":             winapi.TdhInTypeFiletime,
+	"win:SYSTEMTIME":           winapi.TdhInTypeSystemtime,
+	"win:SID":                  winapi.TdhInTypeSid,
+	"win:HexInt32":             winapi.TdhInTypeHexint32,
+	"win:HexInt64":             winapi.TdhInTypeHexint64,
+	"win:CountedUnicodeString": winapi.TdhInTypeManifestCountedstring,
+	"win:CountedAnsiString":    winapi.TdhInTypeManifestCountedansistring,
+	"win:CountedBinary":        winapi.TdhInTypeManifestCountedbinary,
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventmanifestschema-outputtype-complextype
+var outTypes = map[string]winapi.TdhOutType{
+	"xs:string":                      winapi.TdhOutTypeString,
+	"xs:dateTime":                    winapi.TdhOutTypeDatetime,
+	"xs:byte":                        winapi.TdhOutTypeByte,
+	"xs:unsignedByte":                winapi.TdhOutTypeUnsignedbyte,
+	"xs:short":                       winapi.TdhOutTypeShort,
+	"xs:unsignedShort":               winapi.TdhOutTypeUnsignedshort,
+	"xs:int":                         winapi.TdhOutTypeInt,
+	"xs:unsignedInt":                 winapi.TdhOutTypeUnsignedint,
+	"xs:long":                        winapi.TdhOutTypeLong,
+	"xs:unsignedLong":                winapi.TdhOutTypeUnsignedlong,
+	"xs:float":                       winapi.TdhOutTypeFloat,
+	"xs:double":                      winapi.TdhOutTypeDouble,
+	"xs:boolean":                     winapi.TdhOutTypeBoolean,
+	"xs:GUID":                        winapi.TdhOutTypeGUID,
+	"xs:hexBinary":                   winapi.TdhOutTypeHexbinary,
+	"win:HexInt8":                    winapi.TdhOutTypeHexint8,
+	"win:HexInt16":                   winapi.TdhOutTypeHexint16,
+	"win:HexInt32":                   winapi.TdhOutTypeHexint32,
+	"win:HexInt64":                   winapi.TdhOutTypeHexint64,
+	"win:PID":                        winapi.TdhOutTypePid,
+	"win:TID":                        winapi.TdhOutTypeTid,
+	"win:Port":                       winapi.TdhOutTypePort,
+	"win:IPv4":                       winapi.TdhOutTypeIpv4,
+	"win:IPv6":                       winapi.TdhOutTypeIpv6,
+	"win:SocketAddress":              winapi.TdhOutTypeSocketaddress,
+	"win:CIMDateTime":                winapi.TdhOutTypeCimdatetime,
+	"win:ETWTIME":                    winapi.TdhOutTypeEtwtime,
+	"win:Xml":                        winapi.TdhOutTypeXML,
+	"win:ErrorCode":                  winapi.TdhOutTypeErrorcode,
+	"win:Win32Error":                 winapi.TdhOutTypeWin32error,
+	"win:NTSTATUS":                   winapi.TdhOutTypeNtstatus,
+	"win:HResult":                    winapi.TdhOutTypeHresult,
+	"win:DateTimeCultureInsensitive": winapi.TdhOutTypeCultureInsensitiveDatetime,
+	"win:Json":                       winapi.TdhOutTypeJSON,
+	"win:Utf8":                       winapi.TdhOutTypeUTF8,
+	"win:Pkcs7WithTypeInfo":          winapi.TdhOutTypePkcs7WithTypeInfo,
+	"win:CodePointer":                winapi.TdhOutTypeCodePointer,
+	"win:DateTimeUtc":                winapi.TdhOutTypeDatetimeUTC,
+}
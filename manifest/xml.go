@@ -0,0 +1,122 @@
+package manifest
+
+import (
+	"encoding/xml"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventmanifestschema-schema
+
+type instrumentationManifest struct {
+	XMLName      xml.Name           `xml:"instrumentationManifest"`
+	Providers    []providerElement  `xml:"instrumentation>events>provider"`
+	Localization []resourcesElement `xml:"localization>resources"`
+}
+
+type providerElement struct {
+	Name      string            `xml:"name,attr"`
+	GUID      string            `xml:"guid,attr"`
+	Symbol    string            `xml:"symbol,attr"`
+	Message   string            `xml:"message,attr"`
+	Events    []eventElement    `xml:"events>event"`
+	Levels    []levelElement    `xml:"levels>level"`
+	Tasks     []taskElement     `xml:"tasks>task"`
+	Opcodes   []opcodeElement   `xml:"opcodes>opcode"`
+	Keywords  []keywordElement  `xml:"keywords>keyword"`
+	Channels  channelsElement   `xml:"channels"`
+	Maps      mapsElement       `xml:"maps"`
+	Templates []templateElement `xml:"templates>template"`
+}
+
+type eventElement struct {
+	Value    string `xml:"value,attr"`
+	Version  string `xml:"version,attr"`
+	Channel  string `xml:"channel,attr"`
+	Level    string `xml:"level,attr"`
+	Task     string `xml:"task,attr"`
+	Opcode   string `xml:"opcode,attr"`
+	Keywords string `xml:"keywords,attr"`
+	Template string `xml:"template,attr"`
+	Message  string `xml:"message,attr"`
+	Symbol   string `xml:"symbol,attr"`
+}
+
+type levelElement struct {
+	Name    string `xml:"name,attr"`
+	Value   string `xml:"value,attr"`
+	Message string `xml:"message,attr"`
+}
+
+type taskElement struct {
+	Name      string          `xml:"name,attr"`
+	Value     string          `xml:"value,attr"`
+	Message   string          `xml:"message,attr"`
+	EventGUID string          `xml:"eventGUID,attr"`
+	Opcodes   []opcodeElement `xml:"opcodes>opcode"`
+}
+
+type opcodeElement struct {
+	Name    string `xml:"name,attr"`
+	Value   string `xml:"value,attr"`
+	Message string `xml:"message,attr"`
+}
+
+type keywordElement struct {
+	Name    string `xml:"name,attr"`
+	Mask    string `xml:"mask,attr"`
+	Message string `xml:"message,attr"`
+}
+
+type channelsElement struct {
+	Channels       []channelElement `xml:"channel"`
+	ImportChannels []channelElement `xml:"importChannel"`
+}
+
+type channelElement struct {
+	Name    string `xml:"name,attr"`
+	ChID    string `xml:"chid,attr"`
+	Value   string `xml:"value,attr"`
+	Message string `xml:"message,attr"`
+}
+
+type mapsElement struct {
+	ValueMaps []mapElement `xml:"valueMap"`
+	BitMaps   []mapElement `xml:"bitMap"`
+}
+
+type mapElement struct {
+	Name    string            `xml:"name,attr"`
+	Entries []mapEntryElement `xml:"map"`
+}
+
+type mapEntryElement struct {
+	Value   string `xml:"value,attr"`
+	Message string `xml:"message,attr"`
+}
+
+// templateElement keeps the data and struct items in document order.
+type templateElement struct {
+	TID   string         `xml:"tid,attr"`
+	Items []templateItem `xml:",any"`
+}
+
+// templateItem is a <data>, a <struct> or a <UserData> element.
+type templateItem struct {
+	XMLName xml.Name
+	Name    string         `xml:"name,attr"`
+	InType  string         `xml:"inType,attr"`
+	OutType string         `xml:"outType,attr"`
+	Length  string         `xml:"length,attr"`
+	Count   string         `xml:"count,attr"`
+	Map     string         `xml:"map,attr"`
+	Members []templateItem `xml:"data"`
+}
+
+type resourcesElement struct {
+	Culture string          `xml:"culture,attr"`
+	Strings []stringElement `xml:"stringTable>string"`
+}
+
+type stringElement struct {
+	ID    string `xml:"id,attr"`
+	Value string `xml:"value,attr"`
+}
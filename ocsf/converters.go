@@ -0,0 +1,233 @@
+package ocsf
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/internal/mapping"
+)
+
+// Converter sets the OCSF attributes of a property value under target, and reports whether the value converted.
+// Values that do not are kept under unmapped.
+type Converter = mapping.Converter
+
+// Converters shared with the ECS mapper.
+var (
+	// String sets the string of the value, unless empty or -.
+	String Converter = mapping.String
+	// Lowercase sets the string of the value in lower case, such as the protocol_name of Sysmon protocols.
+	Lowercase Converter = mapping.Lowercase
+	// Integer sets integers, as int64 when signed and uint64 otherwise, and strings of integers in decimal
+	// or hexadecimal with 0x prefix.
+	Integer Converter = mapping.Integer
+	// SID sets the security identifier of the value, such as the process.user.uid of classic kernel process events.
+	SID Converter = mapping.SID
+)
+
+// Timestamp sets times as milliseconds since the Unix epoch, the OCSF timestamp_t, from times and strings in
+// the layout of Sysmon UtcTime fields or RFC 3339.
+func Timestamp(event Event, target string, value etw.Value) bool {
+	t, ok := mapping.ParseTime(value)
+	if !ok {
+		return false
+	}
+	event.Set(target, t.UnixMilli())
+	return true
+}
+
+// IP sets the address of the value.
+func IP(event Event, target string, value etw.Value) bool {
+	addr, ok := mapping.ParseAddr(value)
+	if !ok {
+		return false
+	}
+	event.Set(target, addr.String())
+	return true
+}
+
+// File sets the path, name and parent_folder attributes of the file object at target, such as process.file.
+func File(event Event, target string, value etw.Value) bool {
+	path := value.String()
+	if mapping.IsEmpty(path) {
+		return false
+	}
+	directory, name := mapping.SplitPath(path)
+	event.Set(target+".path", path)
+	event.Set(target+".name", name)
+	if directory != "" {
+		event.Set(target+".parent_folder", directory)
+	}
+	event.Set(target+".type_id", 1) // Regular File
+	return true
+}
+
+// Process sets the name and file of the process object at target from an image path.
+func Process(event Event, target string, value etw.Value) bool {
+	if !File(event, target+".file", value) {
+		return false
+	}
+	name, _ := event.Get(target + ".file.name")
+	event.Set(target+".name", name)
+	return true
+}
+
+// User sets the domain and name attributes of the user object at target from DOMAIN\name.
+func User(event Event, target string, value etw.Value) bool {
+	s := value.String()
+	if mapping.IsEmpty(s) {
+		return false
+	}
+	domain, name := mapping.SplitUser(s)
+	if domain != "" {
+		event.Set(target+".domain", domain)
+	}
+	event.Set(target+".name", name)
+	return true
+}
+
+// hashAlgorithms gives the algorithm_id of the fingerprints of Sysmon hash algorithms.
+var hashAlgorithms = map[string]int{
+	"MD5":    1,
+	"SHA1":   2,
+	"SHA256": 3,
+	"SHA512": 4,
+}
+
+// Hashes sets the hashes of the file object at target from the ALGORITHM=digest lists of Sysmon, separated by commas.
+// IMPHASH, which OCSF does not define, is an Other fingerprint.
+func Hashes(event Event, target string, value etw.Value) bool {
+	s := value.String()
+	if mapping.IsEmpty(s) {
+		return false
+	}
+	var fingerprints []Event
+	for _, hash := range mapping.ParseHashes(s) {
+		algorithm := strings.ToUpper(hash.Algorithm)
+		algorithmID, known := hashAlgorithms[algorithm]
+		if !known {
+			algorithmID = 99
+		}
+		fingerprints = append(fingerprints, Event{"algorithm_id": algorithmID, "algorithm": algorithm, "value": hash.Digest})
+	}
+	if len(fingerprints) == 0 {
+		return false
+	}
+	event.Set(target+".hashes", fingerprints)
+	return true
+}
+
+// Direction sets the connection_info direction of the Initiated field of Sysmon network events.
+func Direction(event Event, target string, value etw.Value) bool {
+	initiated, err := strconv.ParseBool(value.String())
+	if err != nil {
+		return false
+	}
+	if initiated {
+		event.Set(target+".direction_id", 2)
+		event.Set(target+".direction", "Outbound")
+	} else {
+		event.Set(target+".direction_id", 1)
+		event.Set(target+".direction", "Inbound")
+	}
+	return true
+}
+
+// ProtocolVersion sets the connection_info protocol version of the IsIpv6 fields of Sysmon network events.
+func ProtocolVersion(event Event, target string, value etw.Value) bool {
+	isIPv6, err := strconv.ParseBool(value.String())
+	if err != nil {
+		return false
+	}
+	if isIPv6 {
+		event.Set(target+".protocol_ver_id", 6)
+		event.Set(target+".protocol_ver", "Internet Protocol version 6 (IPv6)")
+	} else {
+		event.Set(target+".protocol_ver_id", 4)
+		event.Set(target+".protocol_ver", "Internet Protocol version 4 (IPv4)")
+	}
+	return true
+}
+
+// RegistryValue sets the path and name attributes of the registry value object at target.
+func RegistryValue(event Event, target string, value etw.Value) bool {
+	path := value.String()
+	if mapping.IsEmpty(path) {
+		return false
+	}
+	event.Set(target+".path", path)
+	event.Set(target+".name", path[strings.LastIndexByte(path, '\\')+1:])
+	return true
+}
+
+// registryActivities gives the activity of the EventType of Sysmon registry events.
+var registryActivities = map[string]struct {
+	id   int
+	name string
+}{
+	"CreateKey":   {RegistryKeyCreate, "Create"},
+	"DeleteKey":   {RegistryKeyDelete, "Delete"},
+	"DeleteValue": {RegistryKeyModify, "Modify"},
+	"RenameKey":   {RegistryKeyRename, "Rename"},
+	"SetValue":    {RegistryValueSet, "Set"},
+}
+
+// RegistryActivity sets the activity of the EventType of Sysmon registry events, unchanged for other types.
+func RegistryActivity(event Event, target string, value etw.Value) bool {
+	activity, ok := registryActivities[value.String()]
+	if !ok {
+		return false
+	}
+	event.Set("activity_id", activity.id)
+	event.Set("activity_name", activity.name)
+	return true
+}
+
+// dnsResponseCodes gives the rcode_id and rcode of the Win32 status codes of Sysmon DNS queries.
+var dnsResponseCodes = map[int64]struct {
+	id   int
+	name string
+}{
+	0:    {0, "NoError"},
+	9001: {1, "FormError"},
+	9002: {2, "ServError"},
+	9003: {3, "NXDomain"},
+	9004: {4, "NotImp"},
+	9005: {5, "Refused"},
+	9501: {0, "NoError"}, // DNS_INFO_NO_RECORDS
+}
+
+// ResponseCode sets the rcode_id at target, and the rcode next to it, of the QueryStatus of Sysmon DNS events.
+func ResponseCode(event Event, target string, value etw.Value) bool {
+	status, err := strconv.ParseInt(value.String(), 0, 64)
+	if err != nil {
+		return false
+	}
+	code, ok := dnsResponseCodes[status]
+	if !ok {
+		return false
+	}
+	event.Set(target, code.id)
+	event.Set(strings.TrimSuffix(target, "_id"), code.name)
+	return true
+}
+
+// Answers sets the DNS answers at target from the QueryResults of Sysmon DNS events, addresses and records
+// of type, such as "type:  5 example.com", separated by semicolons.
+func Answers(event Event, target string, value etw.Value) bool {
+	results := value.String()
+	if mapping.IsEmpty(results) {
+		return false
+	}
+
+	var answers []Event
+	for _, answer := range mapping.ParseAnswers(results) {
+		answers = append(answers, Event{"type": answer.Type, "rdata": answer.Data})
+	}
+
+	if len(answers) == 0 {
+		return false
+	}
+	event.Set(target, answers)
+	return true
+}
@@ -0,0 +1,77 @@
+package ocsf
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+func TestConverters(t *testing.T) {
+	text := func(s string) etw.Value { return etw.StringValue(s, winapi.TdhInTypeUnicodestring, 0) }
+
+	tests := []struct {
+		name    string
+		convert Converter
+		target  string
+		value   etw.Value
+		want    string // event as JSON, empty when the value does not convert
+	}{
+		{"string", String, "a", text("x"), `{"a":"x"}`},
+		{"string missing", String, "a", text("-"), ``},
+		{"integer", Integer, "a", etw.NewValue(uint16(443), winapi.TdhInTypeUint16, winapi.TdhOutTypePort), `{"a":443}`},
+		{"integer signed", Integer, "a", etw.NewValue(int64(-1), winapi.TdhInTypeInt64, 0), `{"a":-1}`},
+		{"integer max uint64", Integer, "a", etw.NewValue(uint64(math.MaxUint64), winapi.TdhInTypeUint64, 0), `{"a":18446744073709551615}`},
+		{"integer max uint64 string", Integer, "a", text("0xFFFFFFFFFFFFFFFF"), `{"a":18446744073709551615}`},
+		{"integer hex string", Integer, "a", text("0x10"), `{"a":16}`},
+		{"integer invalid", Integer, "a", text("ten"), ``},
+		{"timestamp sysmon", Timestamp, "time", text("2024-05-06 07:08:09.123"), `{"time":1714979289123}`},
+		{"timestamp invalid", Timestamp, "time", text("yesterday"), ``},
+		{"ip", IP, "src_endpoint.ip", text("fe80::1"), `{"src_endpoint":{"ip":"fe80::1"}}`},
+		{"file", File, "file", text(`C:\x\a.txt`), `{"file":{"name":"a.txt","parent_folder":"C:\\x","path":"C:\\x\\a.txt","type_id":1}}`},
+		{"process", Process, "process", text("cmd.exe"), `{"process":{"file":{"name":"cmd.exe","path":"cmd.exe","type_id":1},"name":"cmd.exe"}}`},
+		{"process missing", Process, "process", text(""), ``},
+		{"sid", SID, "process.user.uid", text("S-1-5-18"), `{"process":{"user":{"uid":"S-1-5-18"}}}`},
+		{"user", User, "actor.user", text(`NT AUTHORITY\SYSTEM`), `{"actor":{"user":{"domain":"NT AUTHORITY","name":"SYSTEM"}}}`},
+		{"hashes", Hashes, "file", text("SHA1=AB,SHA512=CD"),
+			`{"file":{"hashes":[{"algorithm":"SHA1","algorithm_id":2,"value":"ab"},{"algorithm":"SHA512","algorithm_id":4,"value":"cd"}]}}`},
+		{"hashes malformed", Hashes, "file", text("SHA1="), ``},
+		{"direction", Direction, "connection_info", text("false"), `{"connection_info":{"direction":"Inbound","direction_id":1}}`},
+		{"protocol version", ProtocolVersion, "connection_info", text("true"),
+			`{"connection_info":{"protocol_ver":"Internet Protocol version 6 (IPv6)","protocol_ver_id":6}}`},
+		{"lowercase", Lowercase, "connection_info.protocol_name", text("TCP"), `{"connection_info":{"protocol_name":"tcp"}}`},
+		{"registry value", RegistryValue, "reg_value", text(`HKU\S-1-5-18\Run\evil`), `{"reg_value":{"name":"evil","path":"HKU\\S-1-5-18\\Run\\evil"}}`},
+		{"registry activity", RegistryActivity, "activity_id", text("RenameKey"), `{"activity_id":5,"activity_name":"Rename"}`},
+		{"registry activity unknown", RegistryActivity, "activity_id", text("Other"), ``},
+		{"response code", ResponseCode, "rcode_id", text("9003"), `{"rcode":"NXDomain","rcode_id":3}`},
+		{"response code unknown", ResponseCode, "rcode_id", text("1"), ``},
+		{"answers", Answers, "answers", text("type:  33 _sip.example.com;2001:db8::1"),
+			`{"answers":[{"rdata":"_sip.example.com","type":"SRV"},{"rdata":"2001:db8::1","type":"AAAA"}]}`},
+		{"answers none", Answers, "answers", text(";;"), ``},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := Event{}
+			converted := test.convert(event, test.target, test.value)
+			if converted != (test.want != "") {
+				t.Fatalf("converted = %t, want %t", converted, test.want != "")
+			}
+			if !converted {
+				if len(event) != 0 {
+					t.Errorf("event = %v, want empty", event)
+				}
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(data) != test.want {
+				t.Errorf("event = %s, want %s", data, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package ocsf
+
+import (
+	"github.com/quentin-nozomi/microsoft-etw/internal/mapping"
+)
+
+// https://schema.ocsf.io/1.1.0/classes
+
+// Version is the OCSF schema version of the events, written as metadata.version.
+const Version = "1.1.0"
+
+// Event is an OCSF event, nested objects as maps, as marshalled to JSON. Set and Get take the dotted paths
+// of attributes, such as actor.process.pid.
+type Event = mapping.Object
+
+// Class is an OCSF event class, with its category.
+type Class struct {
+	UID          int
+	Name         string
+	CategoryUID  int
+	CategoryName string
+}
+
+var (
+	BaseEvent               = Class{UID: 0, Name: "Base Event", CategoryUID: 0, CategoryName: "Uncategorized"}
+	FileSystemActivity      = Class{UID: 1001, Name: "File System Activity", CategoryUID: 1, CategoryName: "System Activity"}
+	KernelExtensionActivity = Class{UID: 1002, Name: "Kernel Extension Activity", CategoryUID: 1, CategoryName: "System Activity"}
+	ModuleActivity          = Class{UID: 1005, Name: "Module Activity", CategoryUID: 1, CategoryName: "System Activity"}
+	ProcessActivity         = Class{UID: 1007, Name: "Process Activity", CategoryUID: 1, CategoryName: "System Activity"}
+	NetworkActivity         = Class{UID: 4001, Name: "Network Activity", CategoryUID: 4, CategoryName: "Network Activity"}
+	DNSActivity             = Class{UID: 4003, Name: "DNS Activity", CategoryUID: 4, CategoryName: "Network Activity"}
+	// classes of the Windows extension
+	RegistryKeyActivity   = Class{UID: 201001, Name: "Registry Key Activity", CategoryUID: 1, CategoryName: "System Activity"}
+	RegistryValueActivity = Class{UID: 201004, Name: "Registry Value Activity", CategoryUID: 1, CategoryName: "System Activity"}
+)
+
+// Activity IDs of the classes, 0 is Unknown and 99 Other in every class.
+const (
+	ActivityUnknown = 0
+	ActivityOther   = 99
+
+	FileCreate        = 1
+	FileRead          = 2
+	FileUpdate        = 3
+	FileDelete        = 4
+	FileRename        = 5
+	FileSetAttributes = 6
+	FileOpen          = 14
+
+	KernelExtensionLoad = 1
+
+	ModuleLoad = 1
+
+	ProcessLaunch    = 1
+	ProcessTerminate = 2
+	ProcessOpen      = 3
+	ProcessInject    = 4
+
+	NetworkOpen    = 1
+	NetworkTraffic = 6
+
+	DNSQuery   = 1
+	DNSTraffic = 6
+
+	RegistryKeyCreate = 1
+	RegistryKeyModify = 3
+	RegistryKeyDelete = 4
+	RegistryKeyRename = 5
+
+	RegistryValueSet    = 2
+	RegistryValueDelete = 4
+)
+
+// Severity IDs.
+const (
+	SeverityUnknown       = 0
+	SeverityInformational = 1
+	SeverityLow           = 2
+	SeverityMedium        = 3
+	SeverityHigh          = 4
+	SeverityCritical      = 5
+	SeverityFatal         = 6
+)
+
+var severityNames = [...]string{
+	SeverityUnknown:       "Unknown",
+	SeverityInformational: "Informational",
+	SeverityLow:           "Low",
+	SeverityMedium:        "Medium",
+	SeverityHigh:          "High",
+	SeverityCritical:      "Critical",
+	SeverityFatal:         "Fatal",
+}
+
+// SeverityID returns the severity of an ETW level: critical, error, warning, then informational and verbose.
+// Level 0, logged regardless of the session level, has no severity.
+func SeverityID(level uint8) int {
+	switch level {
+	case 1:
+		return SeverityCritical
+	case 2:
+		return SeverityHigh
+	case 3:
+		return SeverityMedium
+	case 4, 5:
+		return SeverityInformational
+	}
+	return SeverityUnknown
+}
@@ -0,0 +1,149 @@
+package ocsf
+
+// https://learn.microsoft.com/en-us/windows/win32/etw/process
+// https://learn.microsoft.com/en-us/windows/win32/etw/tcpip
+// https://learn.microsoft.com/en-us/windows/win32/etw/fileio
+
+// GUIDs of the manifest-based kernel providers.
+const (
+	KernelProcessProvider = "{22FB2CD6-0E7B-422B-A0C7-2FAD1FD0E716}" // Microsoft-Windows-Kernel-Process
+	KernelNetworkProvider = "{7DD42A49-5329-4832-8DFD-43D979153A88}" // Microsoft-Windows-Kernel-Network
+	KernelFileProvider    = "{EDD08927-9CC4-4E65-B970-C2560FB5C289}" // Microsoft-Windows-Kernel-File
+)
+
+// Endpoints of kernel TCP/IP events, whose daddr is the remote address and saddr the local one:
+// connections the process initiates go from saddr to daddr, accepted ones the other way.
+var (
+	kernelOutbound = []Field{
+		{Property: "PID", Target: "actor.process.pid", Convert: Integer},
+		{Property: "saddr", Target: "src_endpoint.ip", Convert: IP},
+		{Property: "sport", Target: "src_endpoint.port", Convert: Integer},
+		{Property: "daddr", Target: "dst_endpoint.ip", Convert: IP},
+		{Property: "dport", Target: "dst_endpoint.port", Convert: Integer},
+	}
+	kernelInbound = []Field{
+		{Property: "PID", Target: "actor.process.pid", Convert: Integer},
+		{Property: "daddr", Target: "src_endpoint.ip", Convert: IP},
+		{Property: "dport", Target: "src_endpoint.port", Convert: Integer},
+		{Property: "saddr", Target: "dst_endpoint.ip", Convert: IP},
+		{Property: "sport", Target: "dst_endpoint.port", Convert: Integer},
+	}
+)
+
+// kernelConnection maps TCP connections of the direction of endpoints, see kernelOutbound and kernelInbound.
+func kernelConnection(directionID int, direction string, endpoints []Field) *Mapping {
+	return &Mapping{
+		Class: NetworkActivity, ActivityID: NetworkOpen, Activity: "Open",
+		Values: map[string]interface{}{
+			"connection_info.protocol_name": "tcp",
+			"connection_info.direction_id":  directionID,
+			"connection_info.direction":     direction,
+		},
+		Fields: endpoints,
+	}
+}
+
+func kernelDatagram(endpoints []Field) *Mapping {
+	return &Mapping{
+		Class: NetworkActivity, ActivityID: NetworkTraffic, Activity: "Traffic",
+		Values: map[string]interface{}{"connection_info.protocol_name": "udp"},
+		Fields: endpoints,
+	}
+}
+
+func kernelFile(activityID int, activity, property string) *Mapping {
+	return &Mapping{
+		Class: FileSystemActivity, ActivityID: activityID, Activity: activity,
+		Fields: []Field{{Property: property, Target: "file", Convert: File}},
+	}
+}
+
+func kernelKey(provider string, eventID uint16) Key {
+	return Key{Provider: provider, EventID: eventID}
+}
+
+func classicKey(eventType string) Key {
+	return Key{EventType: eventType}
+}
+
+// KernelMappings maps the process, network and file events of the kernel providers, and of the classic
+// NT Kernel Logger.
+var KernelMappings = map[Key]*Mapping{
+	kernelKey(KernelProcessProvider, 1): {
+		Class: ProcessActivity, ActivityID: ProcessLaunch, Activity: "Launch",
+		Fields: []Field{
+			{Property: "ProcessID", Target: "process.pid", Convert: Integer},
+			{Property: "ParentProcessID", Target: "actor.process.pid", Convert: Integer},
+			{Property: "ImageName", Target: "process", Convert: Process},
+			{Property: "CreateTime", Target: "process.created_time", Convert: Timestamp},
+		},
+	},
+	kernelKey(KernelProcessProvider, 2): {
+		Class: ProcessActivity, ActivityID: ProcessTerminate, Activity: "Terminate",
+		Fields: []Field{
+			{Property: "ProcessID", Target: "process.pid", Convert: Integer},
+			{Property: "ImageName", Target: "process", Convert: Process},
+			{Property: "CreateTime", Target: "process.created_time", Convert: Timestamp},
+			{Property: "ExitTime", Target: "process.terminated_time", Convert: Timestamp},
+			{Property: "ExitCode", Target: "exit_code", Convert: Integer},
+		},
+	},
+	kernelKey(KernelProcessProvider, 5): {
+		Class: ModuleActivity, ActivityID: ModuleLoad, Activity: "Load",
+		Fields: []Field{
+			{Property: "ProcessID", Target: "actor.process.pid", Convert: Integer},
+			{Property: "ImageName", Target: "module.file", Convert: File},
+		},
+	},
+
+	kernelKey(KernelNetworkProvider, 12): kernelConnection(2, "Outbound", kernelOutbound),
+	kernelKey(KernelNetworkProvider, 15): kernelConnection(1, "Inbound", kernelInbound),
+	kernelKey(KernelNetworkProvider, 28): kernelConnection(2, "Outbound", kernelOutbound),
+	kernelKey(KernelNetworkProvider, 31): kernelConnection(1, "Inbound", kernelInbound),
+	kernelKey(KernelNetworkProvider, 42): kernelDatagram(kernelOutbound),
+	kernelKey(KernelNetworkProvider, 43): kernelDatagram(kernelInbound),
+	kernelKey(KernelNetworkProvider, 58): kernelDatagram(kernelOutbound),
+	kernelKey(KernelNetworkProvider, 59): kernelDatagram(kernelInbound),
+
+	kernelKey(KernelFileProvider, 12): kernelFile(FileOpen, "Open", "FileName"),
+	kernelKey(KernelFileProvider, 26): kernelFile(FileDelete, "Delete", "FilePath"),
+	kernelKey(KernelFileProvider, 27): kernelFile(FileRename, "Rename", "FilePath"),
+	kernelKey(KernelFileProvider, 30): kernelFile(FileCreate, "Create", "FileName"),
+
+	classicKey("Process/Start"): {
+		Class: ProcessActivity, ActivityID: ProcessLaunch, Activity: "Launch",
+		Fields: []Field{
+			{Property: "ProcessId", Target: "process.pid", Convert: Integer},
+			{Property: "ParentId", Target: "actor.process.pid", Convert: Integer},
+			{Property: "ImageFileName", Target: "process.name"},
+			{Property: "CommandLine", Target: "process.cmd_line"},
+			{Property: "UserSID", Target: "process.user.uid", Convert: SID},
+		},
+	},
+	classicKey("Process/End"): {
+		Class: ProcessActivity, ActivityID: ProcessTerminate, Activity: "Terminate",
+		Fields: []Field{
+			{Property: "ProcessId", Target: "process.pid", Convert: Integer},
+			{Property: "ParentId", Target: "actor.process.pid", Convert: Integer},
+			{Property: "ImageFileName", Target: "process.name"},
+			{Property: "ExitStatus", Target: "exit_code", Convert: Integer},
+			{Property: "UserSID", Target: "process.user.uid", Convert: SID},
+		},
+	},
+	classicKey("ImageLoad/Load"): {
+		Class: ModuleActivity, ActivityID: ModuleLoad, Activity: "Load",
+		Fields: []Field{
+			{Property: "ProcessId", Target: "actor.process.pid", Convert: Integer},
+			{Property: "FileName", Target: "module.file", Convert: File},
+		},
+	},
+	classicKey("TcpIp/ConnectIPV4"): kernelConnection(2, "Outbound", kernelOutbound),
+	classicKey("TcpIp/AcceptIPV4"):  kernelConnection(1, "Inbound", kernelInbound),
+	classicKey("TcpIp/ConnectIPV6"): kernelConnection(2, "Outbound", kernelOutbound),
+	classicKey("TcpIp/AcceptIPV6"):  kernelConnection(1, "Inbound", kernelInbound),
+	classicKey("UdpIp/SendIPV4"):    kernelDatagram(kernelOutbound),
+	classicKey("UdpIp/RecvIPV4"):    kernelDatagram(kernelInbound),
+	classicKey("UdpIp/SendIPV6"):    kernelDatagram(kernelOutbound),
+	classicKey("UdpIp/RecvIPV6"):    kernelDatagram(kernelInbound),
+	classicKey("FileIo/Create"):     kernelFile(FileOpen, "Open", "OpenPath"),
+}
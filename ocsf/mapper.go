@@ -0,0 +1,124 @@
+package ocsf
+
+import (
+	"strconv"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/internal/mapping"
+)
+
+// Key identifies the events a Mapping applies to: by provider GUID and event ID, or by EventType for classic
+// kernel events, such as Process/Start.
+type Key = mapping.Key
+
+// KeyOf returns the key of event.
+func KeyOf(event *etw.Event) Key {
+	return mapping.KeyOf(event)
+}
+
+// Mapping maps an event to an OCSF class and activity, and the attributes its properties set.
+type Mapping struct {
+	Class      Class
+	ActivityID int
+	Activity   string                 // activity_name
+	Values     map[string]interface{} // attributes set as is, such as connection_info.protocol_name
+	Fields     []Field
+}
+
+// Field maps a property of the event to the OCSF attribute Target. Convert sets the attributes under Target
+// from the value, when nil the string of the value is set as is.
+type Field = mapping.Field
+
+// Mapper maps events to OCSF events with the Mapping registered for their key, and to a Base Event otherwise.
+type Mapper struct {
+	registry *mapping.Registry[*Mapping]
+}
+
+// NewMapper returns a Mapper with the mappings of Sysmon and kernel events, see SysmonMappings and KernelMappings.
+func NewMapper() *Mapper {
+	mapper := &Mapper{registry: mapping.NewRegistry[*Mapping]()}
+	for key, mapping := range SysmonMappings {
+		mapper.Register(key, mapping)
+	}
+	for key, mapping := range KernelMappings {
+		mapper.Register(key, mapping)
+	}
+	return mapper
+}
+
+// Register maps the events of key with mapping, replacing the mapping registered before, if any.
+func (m *Mapper) Register(key Key, mapping *Mapping) {
+	m.registry.Register(key, mapping)
+}
+
+// Mapping returns the mapping registered for key.
+func (m *Mapper) Mapping(key Key) (*Mapping, bool) {
+	return m.registry.Mapping(key)
+}
+
+// genericMapping maps the events without mapping.
+var genericMapping = &Mapping{Class: BaseEvent, ActivityID: ActivityUnknown, Activity: "Unknown"}
+
+// Map converts event to an OCSF event, and reports whether a mapping applies to it. Events without mapping
+// are Base Events of unknown activity. Every event holds the header of the event under metadata, and the
+// properties no mapping converts, or that fail to convert, under unmapped.
+func (m *Mapper) Map(event *etw.Event) (Event, bool) {
+	eventMapping, mapped := m.registry.Mapping(KeyOf(event))
+	if !mapped {
+		eventMapping = genericMapping
+	}
+
+	ocsfEvent := baseEvent(event)
+	ocsfEvent.Set("class_uid", eventMapping.Class.UID)
+	ocsfEvent.Set("class_name", eventMapping.Class.Name)
+	ocsfEvent.Set("category_uid", eventMapping.Class.CategoryUID)
+	ocsfEvent.Set("category_name", eventMapping.Class.CategoryName)
+	ocsfEvent.Set("activity_id", eventMapping.ActivityID)
+	ocsfEvent.Set("activity_name", eventMapping.Activity)
+	converted := mapping.Apply(ocsfEvent, event, eventMapping.Values, eventMapping.Fields)
+
+	// converters may change the activity
+	activityID, _ := ocsfEvent.Get("activity_id")
+	activityName, _ := ocsfEvent.Get("activity_name")
+	ocsfEvent.Set("type_uid", eventMapping.Class.UID*100+activityID.(int))
+	ocsfEvent.Set("type_name", eventMapping.Class.Name+": "+activityName.(string))
+
+	mapping.SetUnconverted(ocsfEvent, event, "unmapped.", converted)
+
+	return ocsfEvent, mapped
+}
+
+func baseEvent(event *etw.Event) Event {
+	system := &event.System
+
+	severityID := SeverityID(system.Level.Value)
+	ocsfEvent := Event{}
+	ocsfEvent.Set("time", system.TimestampUTC.UnixMilli())
+	ocsfEvent.Set("severity_id", severityID)
+	ocsfEvent.Set("severity", severityNames[severityID])
+	if event.Message != "" {
+		ocsfEvent.Set("message", event.Message)
+	}
+	if system.Computer != "" {
+		ocsfEvent.Set("device.hostname", system.Computer)
+	}
+	if event.UserSID != nil {
+		ocsfEvent.Set("actor.user.uid", event.UserSID.String())
+	}
+
+	ocsfEvent.Set("metadata.version", Version)
+	ocsfEvent.Set("metadata.event_code", strconv.FormatUint(uint64(system.EventID), 10))
+	ocsfEvent.Set("metadata.product.uid", system.Provider.Guid)
+	ocsfEvent.Set("metadata.product.vendor_name", "Microsoft")
+	if system.Provider.Name != "" {
+		ocsfEvent.Set("metadata.product.name", system.Provider.Name)
+	}
+	if system.Channel != "" {
+		ocsfEvent.Set("metadata.log_name", system.Channel)
+	}
+	if system.EventRecordID != 0 {
+		ocsfEvent.Set("metadata.uid", strconv.FormatUint(system.EventRecordID, 10))
+	}
+
+	return ocsfEvent
+}
@@ -0,0 +1,224 @@
+package ocsf
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+// loadEvent reads an event of the fixtures shared by the mappers, in the JSON representation of etw.Event.
+func loadEvent(t *testing.T, name string) *etw.Event {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "events", name+".json"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var event etw.Event
+	if err = json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Unmarshal %s: %v", name, err)
+	}
+	return &event
+}
+
+func checkGolden(t *testing.T, name string, event Event) {
+	t.Helper()
+
+	data, err := json.MarshalIndent(event, "", "  ")
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	path := filepath.Join("testdata", name+".json")
+	if *update {
+		if err = os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if golden = bytes.TrimRight(golden, "\n"); !bytes.Equal(data, golden) {
+		t.Errorf("%s:\n got %s\nwant %s", name, data, golden)
+	}
+}
+
+func TestMapFixtures(t *testing.T) {
+	tests := []struct {
+		name     string
+		mapped   bool
+		typeUID  int
+		typeName string
+	}{
+		{"sysmon_process_create", true, 100701, "Process Activity: Launch"},
+		{"sysmon_network_connect", true, 400101, "Network Activity: Open"},
+		{"sysmon_file_create", true, 100101, "File System Activity: Create"},
+		{"kernel_process_start", true, 100701, "Process Activity: Launch"},
+		{"kernel_tcp_connect", true, 400101, "Network Activity: Open"},
+		{"kernel_file_create", true, 100114, "File System Activity: Open"},
+		{"classic_process_start", true, 100701, "Process Activity: Launch"},
+	}
+
+	mapper := NewMapper()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event, mapped := mapper.Map(loadEvent(t, test.name))
+			if mapped != test.mapped {
+				t.Errorf("mapped = %t, want %t", mapped, test.mapped)
+			}
+			if typeUID, _ := event.Get("type_uid"); typeUID != test.typeUID {
+				t.Errorf("type_uid = %v, want %d", typeUID, test.typeUID)
+			}
+			if typeName, _ := event.Get("type_name"); typeName != test.typeName {
+				t.Errorf("type_name = %v, want %s", typeName, test.typeName)
+			}
+			checkGolden(t, test.name, event)
+		})
+	}
+}
+
+// TestMapAttributes asserts the attributes of process, network and file activities, as set from the typed
+// values of the fixtures.
+func TestMapAttributes(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes map[string]interface{}
+	}{
+		{"sysmon_process_create", map[string]interface{}{
+			"time":                   int64(1714979289123),
+			"process.uid":            "{8F3A1C2B-4D5E-6F70-8192-A3B4C5D6E7F8}",
+			"process.pid":            uint64(4242),
+			"process.name":           "cmd.exe",
+			"process.file.path":      `C:\Windows\System32\cmd.exe`,
+			"process.cmd_line":       "cmd.exe /c whoami",
+			"process.integrity":      "Medium",
+			"process.user.domain":    "CONTOSO",
+			"actor.process.pid":      uint64(1000),
+			"actor.process.name":     "explorer.exe",
+			"actor.process.cmd_line": `C:\Windows\Explorer.EXE`,
+			"actor.user.uid":         "S-1-5-18",
+			"actor.user.name":        "alice",
+			"process.file.hashes": []Event{
+				{"algorithm_id": 3, "algorithm": "SHA256", "value": "b99d61d874728edc0918ca0eb10eab93d381e7367e377406e65963366c874450"},
+				{"algorithm_id": 99, "algorithm": "IMPHASH", "value": "272245e2988e1e430500b852c4fb5e18"},
+			},
+		}},
+		{"sysmon_network_connect", map[string]interface{}{
+			"connection_info.protocol_name":   "tcp",
+			"connection_info.direction_id":    2,
+			"connection_info.protocol_ver_id": 4,
+			"src_endpoint.ip":                 "10.0.0.5",
+			"src_endpoint.port":               uint64(50123),
+			"dst_endpoint.ip":                 "93.184.216.34",
+			"dst_endpoint.hostname":           "example.com",
+			"dst_endpoint.port":               uint64(443),
+			"actor.process.name":              "firefox.exe",
+		}},
+		{"sysmon_file_create", map[string]interface{}{
+			"file.path":          `C:\Users\alice\AppData\Local\Temp\payload.PS1`,
+			"file.name":          "payload.PS1",
+			"file.parent_folder": `C:\Users\alice\AppData\Local\Temp`,
+			"file.type_id":       1,
+			"actor.process.pid":  uint64(4242),
+		}},
+		{"kernel_process_start", map[string]interface{}{
+			"process.pid":          uint64(4242),
+			"process.created_time": int64(1714979289123),
+			"actor.process.pid":    uint64(1000),
+			"process.file.path":    `\Device\HarddiskVolume3\Windows\System32\cmd.exe`,
+		}},
+		{"kernel_tcp_connect", map[string]interface{}{
+			"connection_info.protocol_name": "tcp",
+			"connection_info.direction":     "Outbound",
+			"src_endpoint.port":             uint64(50123),
+			"dst_endpoint.ip":               "93.184.216.34",
+			"dst_endpoint.port":             uint64(443),
+		}},
+		{"kernel_file_create", map[string]interface{}{
+			"file.name":          "Report.DOCX",
+			"file.parent_folder": `\Device\HarddiskVolume3\Users\alice\Documents`,
+		}},
+		{"classic_process_start", map[string]interface{}{
+			"process.pid":       uint64(4242),
+			"process.name":      "cmd.exe",
+			"process.cmd_line":  "cmd.exe /c whoami",
+			"process.user.uid":  "S-1-5-21-1004336348-1177238915-682003330-1001",
+			"actor.process.pid": uint64(1000),
+		}},
+	}
+
+	mapper := NewMapper()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event, _ := mapper.Map(loadEvent(t, test.name))
+			for attribute, want := range test.attributes {
+				if got, _ := event.Get(attribute); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s = %#v, want %#v", attribute, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMapEventData(t *testing.T) {
+	event := &etw.Event{EventData: map[string]string{"TargetFilename": `C:\temp\a.txt`, "Unknown": "x"}}
+	event.System.EventID = 11
+	event.System.Provider.Guid = "{5770385f-c22a-43e0-bf4c-06f5698ffbd9}"
+
+	ocsfEvent, mapped := NewMapper().Map(event)
+	if !mapped {
+		t.Fatalf("no mapping applies to the lowercase provider GUID")
+	}
+	if got, _ := ocsfEvent.Get("file.name"); got != "a.txt" {
+		t.Errorf("file.name = %v, want a.txt", got)
+	}
+	if got, _ := ocsfEvent.Get("unmapped.Unknown"); got != "x" {
+		t.Errorf("unmapped.Unknown = %v, want x", got)
+	}
+}
+
+func TestRegistryActivity(t *testing.T) {
+	event := loadEvent(t, "sysmon_file_create")
+	event.System.EventID = 12
+	event.Properties = append(event.Properties,
+		etw.Property{Name: "EventType", Value: etw.StringValue("DeleteKey", 0, 0)},
+		etw.Property{Name: "TargetObject", Value: etw.StringValue(`HKLM\SOFTWARE\Run`, 0, 0)},
+	)
+
+	ocsfEvent, _ := NewMapper().Map(event)
+	if got, _ := ocsfEvent.Get("type_uid"); got != RegistryKeyActivity.UID*100+RegistryKeyDelete {
+		t.Errorf("type_uid = %v, want %d", got, RegistryKeyActivity.UID*100+RegistryKeyDelete)
+	}
+	if got, _ := ocsfEvent.Get("type_name"); got != "Registry Key Activity: Delete" {
+		t.Errorf("type_name = %v, want Registry Key Activity: Delete", got)
+	}
+}
+
+func TestSeverityID(t *testing.T) {
+	tests := []struct {
+		level uint8
+		want  int
+	}{
+		{0, SeverityUnknown},
+		{1, SeverityCritical},
+		{2, SeverityHigh},
+		{3, SeverityMedium},
+		{4, SeverityInformational},
+		{5, SeverityInformational},
+		{6, SeverityUnknown},
+	}
+
+	for _, test := range tests {
+		if got := SeverityID(test.level); got != test.want {
+			t.Errorf("SeverityID(%d) = %d, want %d", test.level, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package ocsf
+
+// https://learn.microsoft.com/en-us/sysinternals/downloads/sysmon#events
+
+// SysmonProvider is the GUID of the Microsoft-Windows-Sysmon provider.
+const SysmonProvider = "{5770385F-C22A-43E0-BF4C-06F5698FFBD9}"
+
+// Fields common to the Sysmon events, the process of sysmonActor being the actor of the activity.
+var (
+	sysmonTime = []Field{
+		{Property: "UtcTime", Target: "time", Convert: Timestamp},
+	}
+	sysmonActor = []Field{
+		{Property: "ProcessGuid", Target: "actor.process.uid"},
+		{Property: "ProcessId", Target: "actor.process.pid", Convert: Integer},
+		{Property: "Image", Target: "actor.process", Convert: Process},
+		{Property: "User", Target: "actor.user", Convert: User},
+	}
+	sysmonTargetFile = []Field{
+		{Property: "TargetFilename", Target: "file", Convert: File},
+		{Property: "Hashes", Target: "file", Convert: Hashes},
+	}
+	sysmonRegistry = []Field{
+		{Property: "EventType", Target: "activity_id", Convert: RegistryActivity},
+		{Property: "TargetObject", Target: "reg_key.path"},
+	}
+)
+
+func fields(groups ...[]Field) []Field {
+	var all []Field
+	for _, group := range groups {
+		all = append(all, group...)
+	}
+	return all
+}
+
+func sysmonKey(eventID uint16) Key {
+	return Key{Provider: SysmonProvider, EventID: eventID}
+}
+
+func sysmonFileActivity(activityID int, activity string) *Mapping {
+	return &Mapping{
+		Class: FileSystemActivity, ActivityID: activityID, Activity: activity,
+		Fields: fields(sysmonTime, sysmonActor, sysmonTargetFile),
+	}
+}
+
+// SysmonMappings maps the process, file, network, registry and DNS events of Sysmon. The configuration,
+// WMI and clipboard events have no mapping, and are Base Events.
+var SysmonMappings = map[Key]*Mapping{
+	sysmonKey(1): {
+		Class: ProcessActivity, ActivityID: ProcessLaunch, Activity: "Launch",
+		Fields: fields(sysmonTime, []Field{
+			{Property: "ProcessGuid", Target: "process.uid"},
+			{Property: "ProcessId", Target: "process.pid", Convert: Integer},
+			{Property: "Image", Target: "process", Convert: Process},
+			{Property: "Hashes", Target: "process.file", Convert: Hashes},
+			{Property: "CommandLine", Target: "process.cmd_line"},
+			{Property: "User", Target: "process.user", Convert: User},
+			{Property: "IntegrityLevel", Target: "process.integrity"},
+			{Property: "FileVersion", Target: "process.file.version"},
+			{Property: "Company", Target: "process.file.company_name"},
+			{Property: "Product", Target: "process.file.product.name"},
+			{Property: "Description", Target: "process.file.desc"},
+			{Property: "ParentProcessGuid", Target: "actor.process.uid"},
+			{Property: "ParentProcessId", Target: "actor.process.pid", Convert: Integer},
+			{Property: "ParentImage", Target: "actor.process", Convert: Process},
+			{Property: "ParentCommandLine", Target: "actor.process.cmd_line"},
+			{Property: "ParentUser", Target: "actor.user", Convert: User},
+		}),
+	},
+	sysmonKey(2): sysmonFileActivity(FileSetAttributes, "Set Attributes"),
+	sysmonKey(3): {
+		Class: NetworkActivity, ActivityID: NetworkOpen, Activity: "Open",
+		Fields: fields(sysmonTime, sysmonActor, []Field{
+			{Property: "Protocol", Target: "connection_info.protocol_name", Convert: Lowercase},
+			{Property: "Initiated", Target: "connection_info", Convert: Direction},
+			{Property: "SourceIsIpv6", Target: "connection_info", Convert: ProtocolVersion},
+			{Property: "SourceIp", Target: "src_endpoint.ip", Convert: IP},
+			{Property: "SourceHostname", Target: "src_endpoint.hostname"},
+			{Property: "SourcePort", Target: "src_endpoint.port", Convert: Integer},
+			{Property: "DestinationIp", Target: "dst_endpoint.ip", Convert: IP},
+			{Property: "DestinationHostname", Target: "dst_endpoint.hostname"},
+			{Property: "DestinationPort", Target: "dst_endpoint.port", Convert: Integer},
+		}),
+	},
+	sysmonKey(5): {
+		Class: ProcessActivity, ActivityID: ProcessTerminate, Activity: "Terminate",
+		Fields: fields(sysmonTime, []Field{
+			{Property: "ProcessGuid", Target: "process.uid"},
+			{Property: "ProcessId", Target: "process.pid", Convert: Integer},
+			{Property: "Image", Target: "process", Convert: Process},
+			{Property: "User", Target: "process.user", Convert: User},
+		}),
+	},
+	sysmonKey(6): {
+		Class: KernelExtensionActivity, ActivityID: KernelExtensionLoad, Activity: "Load",
+		Fields: fields(sysmonTime, []Field{
+			{Property: "ImageLoaded", Target: "driver.file", Convert: File},
+			{Property: "Hashes", Target: "driver.file", Convert: Hashes},
+			{Property: "Signature", Target: "driver.file.signature.certificate.subject"},
+		}),
+	},
+	sysmonKey(7): {
+		Class: ModuleActivity, ActivityID: ModuleLoad, Activity: "Load",
+		Fields: fields(sysmonTime, sysmonActor, []Field{
+			{Property: "ImageLoaded", Target: "module.file", Convert: File},
+			{Property: "Hashes", Target: "module.file", Convert: Hashes},
+			{Property: "Signature", Target: "module.file.signature.certificate.subject"},
+		}),
+	},
+	sysmonKey(8): {
+		Class: ProcessActivity, ActivityID: ProcessInject, Activity: "Inject",
+		Fields: fields(sysmonTime, []Field{
+			{Property: "SourceProcessGuid", Target: "actor.process.uid"},
+			{Property: "SourceProcessId", Target: "actor.process.pid", Convert: Integer},
+			{Property: "SourceImage", Target: "actor.process", Convert: Process},
+			{Property: "SourceUser", Target: "actor.user", Convert: User},
+			{Property: "TargetProcessGuid", Target: "process.uid"},
+			{Property: "TargetProcessId", Target: "process.pid", Convert: Integer},
+			{Property: "TargetImage", Target: "process", Convert: Process},
+			{Property: "TargetUser", Target: "process.user", Convert: User},
+		}),
+	},
+	sysmonKey(9): {
+		Class: FileSystemActivity, ActivityID: FileRead, Activity: "Read",
+		Fields: fields(sysmonTime, sysmonActor, []Field{
+			{Property: "Device", Target: "file", Convert: File},
+		}),
+	},
+	sysmonKey(10): {
+		Class: ProcessActivity, ActivityID: ProcessOpen, Activity: "Open",
+		Fields: fields(sysmonTime, []Field{
+			{Property: "SourceProcessGUID", Target: "actor.process.uid"},
+			{Property: "SourceProcessId", Target: "actor.process.pid", Convert: Integer},
+			{Property: "SourceImage", Target: "actor.process", Convert: Process},
+			{Property: "SourceUser", Target: "actor.user", Convert: User},
+			{Property: "TargetProcessGUID", Target: "process.uid"},
+			{Property: "TargetProcessId", Target: "process.pid", Convert: Integer},
+			{Property: "TargetImage", Target: "process", Convert: Process},
+			{Property: "TargetUser", Target: "process.user", Convert: User},
+		}),
+	},
+	sysmonKey(11): sysmonFileActivity(FileCreate, "Create"),
+	sysmonKey(12): {
+		Class: RegistryKeyActivity, ActivityID: RegistryKeyCreate, Activity: "Create",
+		Fields: fields(sysmonTime, sysmonActor, sysmonRegistry),
+	},
+	sysmonKey(13): {
+		Class: RegistryValueActivity, ActivityID: RegistryValueSet, Activity: "Set",
+		Fields: fields(sysmonTime, sysmonActor, []Field{
+			{Property: "TargetObject", Target: "reg_value", Convert: RegistryValue},
+			{Property: "Details", Target: "reg_value.data"},
+		}),
+	},
+	sysmonKey(14): {
+		Class: RegistryKeyActivity, ActivityID: RegistryKeyRename, Activity: "Rename",
+		Fields: fields(sysmonTime, sysmonActor, sysmonRegistry),
+	},
+	sysmonKey(15): {
+		Class: FileSystemActivity, ActivityID: FileCreate, Activity: "Create",
+		Fields: fields(sysmonTime, sysmonActor, []Field{
+			{Property: "TargetFilename", Target: "file", Convert: File},
+			{Property: "Hash", Target: "file", Convert: Hashes},
+		}),
+	},
+	sysmonKey(17): {
+		Class: FileSystemActivity, ActivityID: FileCreate, Activity: "Create",
+		Fields: fields(sysmonTime, sysmonActor, []Field{{Property: "PipeName", Target: "file.name"}}),
+		Values: map[string]interface{}{"file.type_id": 6}, // Named Pipe
+	},
+	sysmonKey(18): {
+		Class: FileSystemActivity, ActivityID: FileOpen, Activity: "Open",
+		Fields: fields(sysmonTime, sysmonActor, []Field{{Property: "PipeName", Target: "file.name"}}),
+		Values: map[string]interface{}{"file.type_id": 6},
+	},
+	sysmonKey(22): {
+		Class: DNSActivity, ActivityID: DNSTraffic, Activity: "Traffic",
+		Fields: fields(sysmonTime, sysmonActor, []Field{
+			{Property: "QueryName", Target: "query.hostname"},
+			{Property: "QueryStatus", Target: "rcode_id", Convert: ResponseCode},
+			{Property: "QueryResults", Target: "answers", Convert: Answers},
+		}),
+	},
+	sysmonKey(23): sysmonFileActivity(FileDelete, "Delete"),
+	sysmonKey(25): {
+		Class: ProcessActivity, ActivityID: ActivityOther, Activity: "Other",
+		Fields: fields(sysmonTime, []Field{
+			{Property: "ProcessGuid", Target: "process.uid"},
+			{Property: "ProcessId", Target: "process.pid", Convert: Integer},
+			{Property: "Image", Target: "process", Convert: Process},
+			{Property: "User", Target: "process.user", Convert: User},
+		}),
+	},
+	sysmonKey(26): sysmonFileActivity(FileDelete, "Delete"),
+	sysmonKey(27): sysmonFileActivity(FileCreate, "Create"),
+	sysmonKey(28): sysmonFileActivity(FileDelete, "Delete"),
+	sysmonKey(29): sysmonFileActivity(FileCreate, "Create"),
+}
@@ -0,0 +1,149 @@
+package sysmon
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// structFields returns the fields of the struct v by the property they are tagged with, those of embedded
+// structs included.
+func structFields(v reflect.Value, fields map[string]reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			structFields(v.Field(i), fields)
+			continue
+		}
+		if property, ok := field.Tag.Lookup("sysmon"); ok {
+			fields[property] = v.Field(i)
+		}
+	}
+}
+
+// decode sets the fields of sysmonEvent from the top level properties of event, or their strings in EventData
+// for events without Properties.
+func decode(sysmonEvent Event, event *etw.Event) *SchemaError {
+	fields := make(map[string]reflect.Value)
+	structFields(reflect.ValueOf(sysmonEvent).Elem(), fields)
+
+	properties := event.Properties
+	if len(properties) == 0 {
+		names := make([]string, 0, len(event.EventData))
+		for name := range event.EventData {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			properties = append(properties, etw.Property{Name: name, Value: etw.StringValue(event.EventData[name], 0, 0)})
+		}
+	}
+
+	var schemaErr *SchemaError
+	mismatch := func() *SchemaError {
+		if schemaErr == nil {
+			schemaErr = &SchemaError{EventID: event.System.EventID, Version: event.System.Version}
+		}
+		return schemaErr
+	}
+	for _, property := range properties {
+		field, ok := fields[property.Name]
+		if !ok {
+			mismatch().Unknown = append(mismatch().Unknown, property.Name)
+			continue
+		}
+		if err := decodeField(field, property.Value); err != nil {
+			mismatch().Fields = append(mismatch().Fields, FieldError{
+				Property: property.Name,
+				Value:    property.Value.String(),
+				Err:      err,
+			})
+		}
+	}
+	return schemaErr
+}
+
+// decodeField sets field from value, from its typed value when decoded with the Sysmon manifest, and from its
+// string otherwise. Sysmon writes - for missing data, which leaves fields other than strings to zero.
+func decodeField(field reflect.Value, value etw.Value) error {
+	s := value.String()
+	if field.Kind() != reflect.String && (s == "" || s == "-") {
+		return nil
+	}
+
+	switch target := field.Addr().Interface().(type) {
+	case *string:
+		*target = s
+	case *bool:
+		if value.Kind == etw.KindBool || value.IsInteger() {
+			*target = value.Bool()
+			return nil
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		*target = b
+	case *winguid.GUID:
+		if value.Kind == etw.KindGUID {
+			*target = value.GUID()
+			return nil
+		}
+		guid, err := winguid.Parse(s)
+		if err != nil {
+			return err
+		}
+		*target = *guid
+	case *time.Time:
+		if value.Kind == etw.KindTime {
+			*target = value.Time().UTC()
+			return nil
+		}
+		t, err := time.Parse(TimeLayout, s)
+		if err != nil {
+			return err
+		}
+		*target = t
+	case *netip.Addr:
+		if addr := value.Addr(); addr.IsValid() {
+			*target = addr
+			return nil
+		}
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return err
+		}
+		*target = addr
+	case *Hashes:
+		hashes, err := ParseHashes(s)
+		if err != nil {
+			return err
+		}
+		*target = hashes
+	default:
+		if field.Kind() < reflect.Uint || field.Kind() > reflect.Uint64 {
+			return fmt.Errorf("unsupported field type %s", field.Type())
+		}
+		if value.IsInteger() {
+			if field.OverflowUint(value.Uint()) {
+				return strconv.ErrRange
+			}
+			field.SetUint(value.Uint())
+			return nil
+		}
+		// decimal, or hexadecimal with 0x prefix such as LogonId and GrantedAccess
+		n, err := strconv.ParseUint(s, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	}
+	return nil
+}
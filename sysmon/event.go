@@ -0,0 +1,155 @@
+package sysmon
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+)
+
+// https://learn.microsoft.com/en-us/sysinternals/downloads/sysmon#events
+
+// Provider is the GUID of the Microsoft-Windows-Sysmon provider.
+const Provider = "{5770385F-C22A-43E0-BF4C-06F5698FFBD9}"
+
+// TimeLayout is the layout of the UtcTime fields, in UTC.
+const TimeLayout = "2006-01-02 15:04:05.000"
+
+var (
+	ErrNotSysmon      = fmt.Errorf("not a Sysmon event")
+	ErrUnknownEvent   = fmt.Errorf("unknown Sysmon event ID")
+	ErrUnknownVersion = fmt.Errorf("unknown Sysmon event version")
+	ErrSchemaMismatch = fmt.Errorf("event does not match the Sysmon schema")
+	ErrInvalidHashes  = fmt.Errorf("invalid Sysmon hashes")
+)
+
+// Event is one of the structs of the Sysmon events, such as *ProcessCreate.
+type Event interface {
+	EventHeader() *Header
+}
+
+// Header holds the fields common to Sysmon events. ID and Version come from the header of the ETW event,
+// the fields of a struct that the schema version of the event does not define are left to zero.
+type Header struct {
+	ID       uint16
+	Version  uint8
+	RuleName string    `sysmon:"RuleName"`
+	UtcTime  time.Time `sysmon:"UtcTime"`
+}
+
+func (h *Header) EventHeader() *Header {
+	return h
+}
+
+// Hashes holds the digests of the Hashes fields, in hexadecimal as Sysmon writes them. The algorithms the
+// configuration does not enable are empty.
+type Hashes struct {
+	MD5     string
+	SHA1    string
+	SHA256  string
+	IMPHASH string
+}
+
+// ParseHashes parses the ALGORITHM=digest lists of Sysmon, separated by commas.
+func ParseHashes(s string) (Hashes, error) {
+	var hashes Hashes
+	for _, hash := range strings.Split(s, ",") {
+		algorithm, digest, ok := strings.Cut(strings.TrimSpace(hash), "=")
+		if !ok {
+			return hashes, fmt.Errorf("%w: %q", ErrInvalidHashes, hash)
+		}
+		switch strings.ToUpper(algorithm) {
+		case "MD5":
+			hashes.MD5 = digest
+		case "SHA1":
+			hashes.SHA1 = digest
+		case "SHA256":
+			hashes.SHA256 = digest
+		case "IMPHASH":
+			hashes.IMPHASH = digest
+		default:
+			return hashes, fmt.Errorf("%w: unknown algorithm %s", ErrInvalidHashes, algorithm)
+		}
+	}
+	return hashes, nil
+}
+
+// FieldError records a property that does not parse to the type of its field.
+type FieldError struct {
+	Property string
+	Value    string
+	Err      error
+}
+
+func (f *FieldError) Error() string {
+	return fmt.Sprintf("%s %q: %s", f.Property, f.Value, f.Err)
+}
+
+func (f *FieldError) Unwrap() error {
+	return f.Err
+}
+
+// SchemaError lists the mismatches between an event and the struct of its ID and version: the properties the
+// struct has no field for, and the properties that fail to parse. UnknownVersion tells that the struct is the one
+// of another version of the event, whose properties may differ. It matches ErrSchemaMismatch, and
+// ErrUnknownVersion when UnknownVersion is set.
+type SchemaError struct {
+	EventID        uint16
+	Version        uint8
+	UnknownVersion bool
+	Unknown        []string
+	Fields         []FieldError
+}
+
+func (s *SchemaError) Error() string {
+	var mismatches []string
+	if s.UnknownVersion {
+		mismatches = append(mismatches, "unknown version")
+	}
+	if len(s.Unknown) > 0 {
+		mismatches = append(mismatches, "unknown properties "+strings.Join(s.Unknown, ", "))
+	}
+	for i := range s.Fields {
+		mismatches = append(mismatches, s.Fields[i].Error())
+	}
+	return fmt.Sprintf("%s: event %d version %d: %s", ErrSchemaMismatch, s.EventID, s.Version, strings.Join(mismatches, "; "))
+}
+
+func (s *SchemaError) Is(target error) bool {
+	return target == ErrSchemaMismatch || s.UnknownVersion && target == ErrUnknownVersion
+}
+
+// FromEvent converts a Sysmon event to the struct of its ID and version. Events of a version without struct
+// convert to the struct of the newest version of their ID, with a *SchemaError matching ErrUnknownVersion that
+// lists their properties the struct has no field for. When the event does not match the struct, it returns
+// the struct with the fields that parsed, and a *SchemaError.
+func FromEvent(event *etw.Event) (Event, error) {
+	if !strings.EqualFold(event.System.Provider.Guid, Provider) {
+		return nil, fmt.Errorf("%w: provider %s", ErrNotSysmon, event.System.Provider.Guid)
+	}
+	newEvent, knownVersion := eventTypes[eventKey{ID: event.System.EventID, Version: event.System.Version}]
+	if !knownVersion {
+		var ok bool
+		if newEvent, ok = newestEventType(event.System.EventID); !ok {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownEvent, event.System.EventID)
+		}
+	}
+
+	sysmonEvent := newEvent()
+	header := sysmonEvent.EventHeader()
+	header.ID = event.System.EventID
+	header.Version = event.System.Version
+
+	schemaErr := decode(sysmonEvent, event)
+	if !knownVersion {
+		if schemaErr == nil {
+			schemaErr = &SchemaError{EventID: event.System.EventID, Version: event.System.Version}
+		}
+		schemaErr.UnknownVersion = true
+	}
+	if schemaErr != nil {
+		return sysmonEvent, schemaErr
+	}
+	return sysmonEvent, nil
+}
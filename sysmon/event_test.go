@@ -0,0 +1,280 @@
+package sysmon
+
+import (
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// loadEvent reads an event of the fixtures shared with the mappers, in the JSON representation of etw.Event.
+func loadEvent(t *testing.T, name string) *etw.Event {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "events", name+".json"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var event etw.Event
+	if err = json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Unmarshal %s: %v", name, err)
+	}
+	return &event
+}
+
+func TestFromEvent(t *testing.T) {
+	processGUID := *winguid.MustParse("{8F3A1C2B-4D5E-6F70-8192-A3B4C5D6E7F8}")
+	header := func(id uint16, version uint8, utcTime string) Header {
+		parsed, _ := time.Parse(TimeLayout, utcTime)
+		return Header{ID: id, Version: version, RuleName: "-", UtcTime: parsed}
+	}
+
+	tests := []struct {
+		name string
+		want Event
+	}{
+		{"sysmon_process_create", &ProcessCreate{
+			Header:            header(1, 5, "2024-05-06 07:08:09.123"),
+			ProcessGUID:       processGUID,
+			ProcessID:         4242,
+			Image:             `C:\Windows\System32\cmd.exe`,
+			FileVersion:       "10.0.22621.1 (WinBuild.160101.0800)",
+			Description:       "Windows Command Processor",
+			Product:           "Microsoft® Windows® Operating System",
+			Company:           "Microsoft Corporation",
+			OriginalFileName:  "Cmd.Exe",
+			CommandLine:       "cmd.exe /c whoami",
+			CurrentDirectory:  `C:\Users\alice\`,
+			User:              `CONTOSO\alice`,
+			LogonGUID:         *winguid.MustParse("{8F3A1C2B-0000-0000-0000-000000000001}"),
+			LogonID:           0x47B8B,
+			TerminalSessionID: 1,
+			IntegrityLevel:    "Medium",
+			Hashes: Hashes{
+				SHA256:  "B99D61D874728EDC0918CA0EB10EAB93D381E7367E377406E65963366C874450",
+				IMPHASH: "272245E2988E1E430500B852C4FB5E18",
+			},
+			ParentProcessGUID: *winguid.MustParse("{8F3A1C2B-4D5E-6F70-8192-000000000100}"),
+			ParentProcessID:   1000,
+			ParentImage:       `C:\Windows\explorer.exe`,
+			ParentCommandLine: `C:\Windows\Explorer.EXE`,
+			ParentUser:        `CONTOSO\alice`,
+		}},
+		{"sysmon_network_connect", &NetworkConnect{
+			Header: Header{ID: 3, Version: 5, RuleName: "technique_id=T1071,technique_name=Web Protocols",
+				UtcTime: time.Date(2024, 5, 6, 7, 8, 10, 456000000, time.UTC)},
+			ProcessGUID:         processGUID,
+			ProcessID:           4242,
+			Image:               `C:\Program Files\Mozilla Firefox\firefox.exe`,
+			User:                `CONTOSO\alice`,
+			Protocol:            "tcp",
+			Initiated:           true,
+			SourceIP:            netip.MustParseAddr("10.0.0.5"),
+			SourceHostname:      "WS01.contoso.com",
+			SourcePort:          50123,
+			SourcePortName:      "-",
+			DestinationIP:       netip.MustParseAddr("93.184.216.34"),
+			DestinationHostname: "example.com",
+			DestinationPort:     443,
+			DestinationPortName: "https",
+		}},
+		{"sysmon_file_create", &FileCreate{
+			Header:          header(11, 2, "2024-05-06 07:08:11.789"),
+			ProcessGUID:     processGUID,
+			ProcessID:       4242,
+			Image:           `C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`,
+			TargetFilename:  `C:\Users\alice\AppData\Local\Temp\payload.PS1`,
+			CreationUtcTime: time.Date(2024, 5, 6, 7, 8, 11, 700000000, time.UTC),
+			User:            `CONTOSO\alice`,
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := FromEvent(loadEvent(t, test.name))
+			if err != nil {
+				t.Fatalf("FromEvent: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("FromEvent = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFromEventUnknownVersion(t *testing.T) {
+	event := loadEvent(t, "sysmon_file_create")
+	event.System.Version = 3
+	event.Properties = append(event.Properties, etw.Property{Name: "Archived", Value: etw.StringValue("true", 0, 0)})
+
+	sysmonEvent, err := FromEvent(event)
+	if !errors.Is(err, ErrUnknownVersion) || !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("FromEvent err = %v, want %v and %v", err, ErrUnknownVersion, ErrSchemaMismatch)
+	}
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) || !reflect.DeepEqual(schemaErr.Unknown, []string{"Archived"}) {
+		t.Errorf("unknown properties = %v, want [Archived]", schemaErr.Unknown)
+	}
+	if want := "event does not match the Sysmon schema: event 11 version 3: unknown version; unknown properties Archived"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	fileCreate, ok := sysmonEvent.(*FileCreate)
+	if !ok {
+		t.Fatalf("FromEvent = %T, want *FileCreate", sysmonEvent)
+	}
+	if fileCreate.Version != 3 || fileCreate.TargetFilename != `C:\Users\alice\AppData\Local\Temp\payload.PS1` {
+		t.Errorf("FromEvent = %+v, want the fields of version 2 decoded", fileCreate)
+	}
+
+	// an older version with no extra property still reports the version
+	event = loadEvent(t, "sysmon_file_create")
+	event.System.Version = 1
+	if _, err = FromEvent(event); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("FromEvent err = %v, want %v", err, ErrUnknownVersion)
+	}
+}
+
+// TestEventTypes checks that the structs cover every event ID of Sysmon, the older versions of an ID with the
+// struct of its newest version, and that their sysmon tags are unique.
+func TestEventTypes(t *testing.T) {
+	versions := make(map[uint16]int)
+	for key, newEvent := range eventTypes {
+		versions[key.ID]++
+
+		newest, _ := newestEventType(key.ID)
+		if got, want := reflect.TypeOf(newEvent()), reflect.TypeOf(newest()); got != want {
+			t.Errorf("event %d version %d: struct %v, want %v of the newest version", key.ID, key.Version, got, want)
+		}
+
+		tags := make(map[string]bool)
+		value := reflect.ValueOf(newEvent()).Elem()
+		var walk func(reflect.Type)
+		walk = func(structType reflect.Type) {
+			for i := 0; i < structType.NumField(); i++ {
+				field := structType.Field(i)
+				if field.Anonymous {
+					walk(field.Type)
+					continue
+				}
+				if tag, ok := field.Tag.Lookup("sysmon"); ok {
+					if tags[tag] {
+						t.Errorf("event %d: property %s tagged twice", key.ID, tag)
+					}
+					tags[tag] = true
+				}
+			}
+		}
+		walk(value.Type())
+	}
+
+	for id := uint16(1); id <= 29; id++ {
+		if versions[id] == 0 {
+			t.Errorf("event %d has no struct", id)
+		}
+	}
+	if versions[255] == 0 {
+		t.Error("event 255 has no struct")
+	}
+}
+
+// TestFromEventOlderVersion decodes a process creation of version 3, which predates OriginalFileName and
+// ParentUser.
+func TestFromEventOlderVersion(t *testing.T) {
+	event := loadEvent(t, "sysmon_process_create")
+	event.System.Version = 3
+	var properties []etw.Property
+	for _, property := range event.Properties {
+		if property.Name != "OriginalFileName" && property.Name != "ParentUser" {
+			properties = append(properties, property)
+		}
+	}
+	event.Properties = properties
+
+	sysmonEvent, err := FromEvent(event)
+	if err != nil {
+		t.Fatalf("FromEvent: %v", err)
+	}
+	processCreate := sysmonEvent.(*ProcessCreate)
+	if processCreate.Version != 3 || processCreate.Image != `C:\Windows\System32\cmd.exe` {
+		t.Errorf("FromEvent = %+v", processCreate)
+	}
+	if processCreate.OriginalFileName != "" || processCreate.ParentUser != "" {
+		t.Errorf("OriginalFileName = %q, ParentUser = %q, want them empty", processCreate.OriginalFileName, processCreate.ParentUser)
+	}
+}
+
+func TestFromEventErrors(t *testing.T) {
+	event := loadEvent(t, "kernel_process_start")
+	if _, err := FromEvent(event); !errors.Is(err, ErrNotSysmon) {
+		t.Errorf("kernel event err = %v, want %v", err, ErrNotSysmon)
+	}
+
+	event = loadEvent(t, "sysmon_file_create")
+	event.System.EventID = 100
+	if _, err := FromEvent(event); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("event 100 err = %v, want %v", err, ErrUnknownEvent)
+	}
+}
+
+func TestFromEventData(t *testing.T) {
+	event := &etw.Event{EventData: map[string]string{
+		"UtcTime":           "2024-05-06 07:08:09.123",
+		"ProcessGuid":       "{8F3A1C2B-4D5E-6F70-8192-A3B4C5D6E7F8}",
+		"ProcessId":         "4242",
+		"LogonId":           "0x47b8b",
+		"TerminalSessionId": "-",
+		"ParentProcessId":   "not a number",
+		"Hashes":            "MD5=00,SHA3=11",
+	}}
+	event.System.Provider.Guid = "{5770385f-c22a-43e0-bf4c-06f5698ffbd9}"
+	event.System.EventID = 1
+	event.System.Version = 5
+
+	sysmonEvent, err := FromEvent(event)
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) || errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("FromEvent err = %v, want a *SchemaError of a known version", err)
+	}
+	var failed []string
+	for _, fieldErr := range schemaErr.Fields {
+		failed = append(failed, fieldErr.Property)
+	}
+	if want := []string{"Hashes", "ParentProcessId"}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("failed fields = %v, want %v", failed, want)
+	}
+	if !errors.Is(&schemaErr.Fields[0], ErrInvalidHashes) {
+		t.Errorf("Hashes err = %v, want %v", schemaErr.Fields[0].Err, ErrInvalidHashes)
+	}
+
+	processCreate := sysmonEvent.(*ProcessCreate)
+	if processCreate.ProcessID != 4242 || processCreate.LogonID != 0x47B8B || processCreate.TerminalSessionID != 0 {
+		t.Errorf("FromEvent = %+v", processCreate)
+	}
+	if processCreate.UtcTime != time.Date(2024, 5, 6, 7, 8, 9, 123000000, time.UTC) {
+		t.Errorf("UtcTime = %v", processCreate.UtcTime)
+	}
+}
+
+func TestParseHashes(t *testing.T) {
+	hashes, err := ParseHashes("MD5=01,SHA1=02, sha256=03,IMPHASH=04")
+	if err != nil {
+		t.Fatalf("ParseHashes: %v", err)
+	}
+	if want := (Hashes{MD5: "01", SHA1: "02", SHA256: "03", IMPHASH: "04"}); hashes != want {
+		t.Errorf("ParseHashes = %+v, want %+v", hashes, want)
+	}
+
+	for _, s := range []string{"MD5", "SHA3=01"} {
+		if _, err = ParseHashes(s); !errors.Is(err, ErrInvalidHashes) {
+			t.Errorf("ParseHashes(%q) err = %v, want %v", s, err, ErrInvalidHashes)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package sysmon
+
+import (
+	"net/netip"
+	"time"
+
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// Structs of the Sysmon events, named after their rule names, tagged with the names of their properties.
+
+// ProcessCreate is event 1.
+type ProcessCreate struct {
+	Header
+	ProcessGUID       winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID         uint32       `sysmon:"ProcessId"`
+	Image             string       `sysmon:"Image"`
+	FileVersion       string       `sysmon:"FileVersion"`
+	Description       string       `sysmon:"Description"`
+	Product           string       `sysmon:"Product"`
+	Company           string       `sysmon:"Company"`
+	OriginalFileName  string       `sysmon:"OriginalFileName"`
+	CommandLine       string       `sysmon:"CommandLine"`
+	CurrentDirectory  string       `sysmon:"CurrentDirectory"`
+	User              string       `sysmon:"User"`
+	LogonGUID         winguid.GUID `sysmon:"LogonGuid"`
+	LogonID           uint64       `sysmon:"LogonId"`
+	TerminalSessionID uint32       `sysmon:"TerminalSessionId"`
+	IntegrityLevel    string       `sysmon:"IntegrityLevel"`
+	Hashes            Hashes       `sysmon:"Hashes"`
+	ParentProcessGUID winguid.GUID `sysmon:"ParentProcessGuid"`
+	ParentProcessID   uint32       `sysmon:"ParentProcessId"`
+	ParentImage       string       `sysmon:"ParentImage"`
+	ParentCommandLine string       `sysmon:"ParentCommandLine"`
+	ParentUser        string       `sysmon:"ParentUser"`
+}
+
+// FileCreateTime is event 2, a process changing the creation time of a file.
+type FileCreateTime struct {
+	Header
+	ProcessGUID             winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID               uint32       `sysmon:"ProcessId"`
+	Image                   string       `sysmon:"Image"`
+	TargetFilename          string       `sysmon:"TargetFilename"`
+	CreationUtcTime         time.Time    `sysmon:"CreationUtcTime"`
+	PreviousCreationUtcTime time.Time    `sysmon:"PreviousCreationUtcTime"`
+	User                    string       `sysmon:"User"`
+}
+
+// NetworkConnect is event 3.
+type NetworkConnect struct {
+	Header
+	ProcessGUID         winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID           uint32       `sysmon:"ProcessId"`
+	Image               string       `sysmon:"Image"`
+	User                string       `sysmon:"User"`
+	Protocol            string       `sysmon:"Protocol"`
+	Initiated           bool         `sysmon:"Initiated"`
+	SourceIsIPv6        bool         `sysmon:"SourceIsIpv6"`
+	SourceIP            netip.Addr   `sysmon:"SourceIp"`
+	SourceHostname      string       `sysmon:"SourceHostname"`
+	SourcePort          uint16       `sysmon:"SourcePort"`
+	SourcePortName      string       `sysmon:"SourcePortName"`
+	DestinationIsIPv6   bool         `sysmon:"DestinationIsIpv6"`
+	DestinationIP       netip.Addr   `sysmon:"DestinationIp"`
+	DestinationHostname string       `sysmon:"DestinationHostname"`
+	DestinationPort     uint16       `sysmon:"DestinationPort"`
+	DestinationPortName string       `sysmon:"DestinationPortName"`
+}
+
+// ServiceStateChange is event 4, the Sysmon service starting or stopping.
+type ServiceStateChange struct {
+	Header
+	State         string `sysmon:"State"`
+	SysmonVersion string `sysmon:"Version"`
+	SchemaVersion string `sysmon:"SchemaVersion"`
+}
+
+// ProcessTerminate is event 5.
+type ProcessTerminate struct {
+	Header
+	ProcessGUID winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID   uint32       `sysmon:"ProcessId"`
+	Image       string       `sysmon:"Image"`
+	User        string       `sysmon:"User"`
+}
+
+// DriverLoad is event 6.
+type DriverLoad struct {
+	Header
+	ImageLoaded     string `sysmon:"ImageLoaded"`
+	Hashes          Hashes `sysmon:"Hashes"`
+	Signed          bool   `sysmon:"Signed"`
+	Signature       string `sysmon:"Signature"`
+	SignatureStatus string `sysmon:"SignatureStatus"`
+}
+
+// ImageLoad is event 7.
+type ImageLoad struct {
+	Header
+	ProcessGUID      winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID        uint32       `sysmon:"ProcessId"`
+	Image            string       `sysmon:"Image"`
+	ImageLoaded      string       `sysmon:"ImageLoaded"`
+	FileVersion      string       `sysmon:"FileVersion"`
+	Description      string       `sysmon:"Description"`
+	Product          string       `sysmon:"Product"`
+	Company          string       `sysmon:"Company"`
+	OriginalFileName string       `sysmon:"OriginalFileName"`
+	Hashes           Hashes       `sysmon:"Hashes"`
+	Signed           bool         `sysmon:"Signed"`
+	Signature        string       `sysmon:"Signature"`
+	SignatureStatus  string       `sysmon:"SignatureStatus"`
+	User             string       `sysmon:"User"`
+}
+
+// CreateRemoteThread is event 8.
+type CreateRemoteThread struct {
+	Header
+	SourceProcessGUID winguid.GUID `sysmon:"SourceProcessGuid"`
+	SourceProcessID   uint32       `sysmon:"SourceProcessId"`
+	SourceImage       string       `sysmon:"SourceImage"`
+	TargetProcessGUID winguid.GUID `sysmon:"TargetProcessGuid"`
+	TargetProcessID   uint32       `sysmon:"TargetProcessId"`
+	TargetImage       string       `sysmon:"TargetImage"`
+	NewThreadID       uint32       `sysmon:"NewThreadId"`
+	StartAddress      uint64       `sysmon:"StartAddress"`
+	StartModule       string       `sysmon:"StartModule"`
+	StartFunction     string       `sysmon:"StartFunction"`
+	SourceUser        string       `sysmon:"SourceUser"`
+	TargetUser        string       `sysmon:"TargetUser"`
+}
+
+// RawAccessRead is event 9.
+type RawAccessRead struct {
+	Header
+	ProcessGUID winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID   uint32       `sysmon:"ProcessId"`
+	Image       string       `sysmon:"Image"`
+	Device      string       `sysmon:"Device"`
+	User        string       `sysmon:"User"`
+}
+
+// ProcessAccess is event 10.
+type ProcessAccess struct {
+	Header
+	SourceProcessGUID winguid.GUID `sysmon:"SourceProcessGUID"`
+	SourceProcessID   uint32       `sysmon:"SourceProcessId"`
+	SourceThreadID    uint32       `sysmon:"SourceThreadId"`
+	SourceImage       string       `sysmon:"SourceImage"`
+	TargetProcessGUID winguid.GUID `sysmon:"TargetProcessGUID"`
+	TargetProcessID   uint32       `sysmon:"TargetProcessId"`
+	TargetImage       string       `sysmon:"TargetImage"`
+	GrantedAccess     uint32       `sysmon:"GrantedAccess"`
+	CallTrace         string       `sysmon:"CallTrace"`
+	SourceUser        string       `sysmon:"SourceUser"`
+	TargetUser        string       `sysmon:"TargetUser"`
+}
+
+// FileCreate is event 11.
+type FileCreate struct {
+	Header
+	ProcessGUID     winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID       uint32       `sysmon:"ProcessId"`
+	Image           string       `sysmon:"Image"`
+	TargetFilename  string       `sysmon:"TargetFilename"`
+	CreationUtcTime time.Time    `sysmon:"CreationUtcTime"`
+	User            string       `sysmon:"User"`
+}
+
+// RegistryEvent is events 12, a key or value created or deleted, 13, a value set, and 14, a key or value renamed.
+// EventType tells the operation, such as CreateKey or SetValue.
+type RegistryEvent struct {
+	Header
+	EventType    string       `sysmon:"EventType"`
+	ProcessGUID  winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID    uint32       `sysmon:"ProcessId"`
+	Image        string       `sysmon:"Image"`
+	TargetObject string       `sysmon:"TargetObject"`
+	Details      string       `sysmon:"Details"` // data of the value set by event 13
+	NewName      string       `sysmon:"NewName"` // of event 14
+	User         string       `sysmon:"User"`
+}
+
+// FileCreateStreamHash is event 15, an alternate data stream created.
+type FileCreateStreamHash struct {
+	Header
+	ProcessGUID     winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID       uint32       `sysmon:"ProcessId"`
+	Image           string       `sysmon:"Image"`
+	TargetFilename  string       `sysmon:"TargetFilename"`
+	CreationUtcTime time.Time    `sysmon:"CreationUtcTime"`
+	Hash            Hashes       `sysmon:"Hash"`
+	Contents        string       `sysmon:"Contents"`
+	User            string       `sysmon:"User"`
+}
+
+// ServiceConfigurationChange is event 16, the Sysmon configuration changed.
+type ServiceConfigurationChange struct {
+	Header
+	Configuration         string `sysmon:"Configuration"`
+	ConfigurationFileHash string `sysmon:"ConfigurationFileHash"`
+}
+
+// PipeEvent is events 17, a named pipe created, and 18, a named pipe connected.
+type PipeEvent struct {
+	Header
+	EventType   string       `sysmon:"EventType"`
+	ProcessGUID winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID   uint32       `sysmon:"ProcessId"`
+	PipeName    string       `sysmon:"PipeName"`
+	Image       string       `sysmon:"Image"`
+	User        string       `sysmon:"User"`
+}
+
+// WmiEvent is events 19, a WMI event filter registered, 20, a WMI event consumer registered, and 21, a consumer
+// bound to a filter.
+type WmiEvent struct {
+	Header
+	EventType      string `sysmon:"EventType"`
+	Operation      string `sysmon:"Operation"`
+	User           string `sysmon:"User"`
+	EventNamespace string `sysmon:"EventNamespace"` // of event 19
+	Name           string `sysmon:"Name"`           // of events 19 and 20
+	Query          string `sysmon:"Query"`          // of event 19
+	Type           string `sysmon:"Type"`           // of event 20
+	Destination    string `sysmon:"Destination"`    // of event 20
+	Consumer       string `sysmon:"Consumer"`       // of event 21
+	Filter         string `sysmon:"Filter"`         // of event 21
+}
+
+// DnsQuery is event 22.
+type DnsQuery struct {
+	Header
+	ProcessGUID  winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID    uint32       `sysmon:"ProcessId"`
+	QueryName    string       `sysmon:"QueryName"`
+	QueryStatus  uint32       `sysmon:"QueryStatus"` // Win32 error code, 0 on success
+	QueryResults string       `sysmon:"QueryResults"`
+	Image        string       `sysmon:"Image"`
+	User         string       `sysmon:"User"`
+}
+
+// FileDelete is event 23, a file deleted and archived.
+type FileDelete struct {
+	Header
+	ProcessGUID    winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID      uint32       `sysmon:"ProcessId"`
+	User           string       `sysmon:"User"`
+	Image          string       `sysmon:"Image"`
+	TargetFilename string       `sysmon:"TargetFilename"`
+	Hashes         Hashes       `sysmon:"Hashes"`
+	IsExecutable   bool         `sysmon:"IsExecutable"`
+	Archived       bool         `sysmon:"Archived"`
+}
+
+// ClipboardChange is event 24.
+type ClipboardChange struct {
+	Header
+	ProcessGUID winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID   uint32       `sysmon:"ProcessId"`
+	Image       string       `sysmon:"Image"`
+	Session     uint32       `sysmon:"Session"`
+	ClientInfo  string       `sysmon:"ClientInfo"`
+	Hashes      Hashes       `sysmon:"Hashes"`
+	Archived    bool         `sysmon:"Archived"`
+	User        string       `sysmon:"User"`
+}
+
+// ProcessTampering is event 25.
+type ProcessTampering struct {
+	Header
+	ProcessGUID winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID   uint32       `sysmon:"ProcessId"`
+	Image       string       `sysmon:"Image"`
+	Type        string       `sysmon:"Type"`
+	User        string       `sysmon:"User"`
+}
+
+// FileDeleteDetected is event 26, a file deleted without archiving.
+type FileDeleteDetected struct {
+	Header
+	ProcessGUID    winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID      uint32       `sysmon:"ProcessId"`
+	User           string       `sysmon:"User"`
+	Image          string       `sysmon:"Image"`
+	TargetFilename string       `sysmon:"TargetFilename"`
+	Hashes         Hashes       `sysmon:"Hashes"`
+	IsExecutable   bool         `sysmon:"IsExecutable"`
+}
+
+// FileBlockExecutable is event 27, the creation of an executable file blocked.
+type FileBlockExecutable struct {
+	Header
+	ProcessGUID    winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID      uint32       `sysmon:"ProcessId"`
+	User           string       `sysmon:"User"`
+	Image          string       `sysmon:"Image"`
+	TargetFilename string       `sysmon:"TargetFilename"`
+	Hashes         Hashes       `sysmon:"Hashes"`
+}
+
+// FileBlockShredding is event 28, the shredding of a file blocked.
+type FileBlockShredding struct {
+	Header
+	ProcessGUID    winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID      uint32       `sysmon:"ProcessId"`
+	User           string       `sysmon:"User"`
+	Image          string       `sysmon:"Image"`
+	TargetFilename string       `sysmon:"TargetFilename"`
+	Hashes         Hashes       `sysmon:"Hashes"`
+	IsExecutable   bool         `sysmon:"IsExecutable"`
+}
+
+// FileExecutableDetected is event 29.
+type FileExecutableDetected struct {
+	Header
+	ProcessGUID    winguid.GUID `sysmon:"ProcessGuid"`
+	ProcessID      uint32       `sysmon:"ProcessId"`
+	User           string       `sysmon:"User"`
+	Image          string       `sysmon:"Image"`
+	TargetFilename string       `sysmon:"TargetFilename"`
+	Hashes         Hashes       `sysmon:"Hashes"`
+}
+
+// Error is event 255, an error of Sysmon.
+type Error struct {
+	Header
+	ErrorID     string `sysmon:"ID"`
+	Description string `sysmon:"Description"`
+}
+
+// eventKey identifies the schema of an event: its ID and version.
+type eventKey struct {
+	ID      uint16
+	Version uint8
+}
+
+// eventTypes gives the struct of each event ID and version. The newest versions are those of the Sysmon 15
+// manifest, schema 4.90, whose properties the structs hold. Older versions are listed when their properties
+// are a subset of those of the struct, the fields they lack being left to zero: the process creation, network
+// connection and DNS query events of earlier Sysmon releases. Events of other versions decode with the struct
+// of the newest version of their ID, see FromEvent. Supporting another version whose properties a struct holds
+// is adding its key.
+var eventTypes = map[eventKey]func() Event{
+	{1, 3}:   func() Event { return &ProcessCreate{} },
+	{1, 4}:   func() Event { return &ProcessCreate{} },
+	{1, 5}:   func() Event { return &ProcessCreate{} },
+	{2, 5}:   func() Event { return &FileCreateTime{} },
+	{3, 4}:   func() Event { return &NetworkConnect{} },
+	{3, 5}:   func() Event { return &NetworkConnect{} },
+	{4, 3}:   func() Event { return &ServiceStateChange{} },
+	{5, 3}:   func() Event { return &ProcessTerminate{} },
+	{6, 4}:   func() Event { return &DriverLoad{} },
+	{7, 3}:   func() Event { return &ImageLoad{} },
+	{8, 2}:   func() Event { return &CreateRemoteThread{} },
+	{9, 2}:   func() Event { return &RawAccessRead{} },
+	{10, 3}:  func() Event { return &ProcessAccess{} },
+	{11, 2}:  func() Event { return &FileCreate{} },
+	{12, 2}:  func() Event { return &RegistryEvent{} },
+	{13, 2}:  func() Event { return &RegistryEvent{} },
+	{14, 2}:  func() Event { return &RegistryEvent{} },
+	{15, 2}:  func() Event { return &FileCreateStreamHash{} },
+	{16, 3}:  func() Event { return &ServiceConfigurationChange{} },
+	{17, 1}:  func() Event { return &PipeEvent{} },
+	{18, 1}:  func() Event { return &PipeEvent{} },
+	{19, 3}:  func() Event { return &WmiEvent{} },
+	{20, 3}:  func() Event { return &WmiEvent{} },
+	{21, 3}:  func() Event { return &WmiEvent{} },
+	{22, 3}:  func() Event { return &DnsQuery{} },
+	{22, 4}:  func() Event { return &DnsQuery{} },
+	{22, 5}:  func() Event { return &DnsQuery{} },
+	{23, 5}:  func() Event { return &FileDelete{} },
+	{24, 5}:  func() Event { return &ClipboardChange{} },
+	{25, 5}:  func() Event { return &ProcessTampering{} },
+	{26, 5}:  func() Event { return &FileDeleteDetected{} },
+	{27, 5}:  func() Event { return &FileBlockExecutable{} },
+	{28, 5}:  func() Event { return &FileBlockShredding{} },
+	{29, 5}:  func() Event { return &FileExecutableDetected{} },
+	{255, 3}: func() Event { return &Error{} },
+}
+
+// newestEventType returns the struct of the newest version of the event ID.
+func newestEventType(id uint16) (func() Event, bool) {
+	var newest func() Event
+	var newestVersion uint8
+	for key, newEvent := range eventTypes {
+		if key.ID == id && (newest == nil || key.Version > newestVersion) {
+			newest, newestVersion = newEvent, key.Version
+		}
+	}
+	return newest, newest != nil
+}
@@ -0,0 +1,106 @@
+package wevt
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-message_resource_data
+// https://learn.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-message_resource_block
+// https://learn.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-message_resource_entry
+
+const (
+	messageResourceBlockSize = 12
+	messageResourceUnicode   = 0x0001
+)
+
+// MessageTable maps message identifiers to their text, as read from a RT_MESSAGETABLE resource.
+type MessageTable map[uint32]string
+
+// ReadMessageTable reads the message tables of file, usually the .mui file of a provider.
+// When the image holds several languages, the first one wins.
+func ReadMessageTable(file *pe.File) (MessageTable, error) {
+	section, err := newResourceSection(file)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := section.resources(RT_MESSAGETABLE)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make(MessageTable)
+	for _, resource := range resources {
+		if err = messages.parse(resource); err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
+func (m MessageTable) parse(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("%w: message table of %d bytes", ErrInvalidTemplate, len(data))
+	}
+	blockCount := binary.LittleEndian.Uint32(data)
+	if uint64(blockCount)*messageResourceBlockSize > uint64(len(data)-4) {
+		return fmt.Errorf("%w: %d message blocks", ErrInvalidTemplate, blockCount)
+	}
+
+	for i := uint32(0); i < blockCount; i++ {
+		block := data[4+i*messageResourceBlockSize:]
+		lowID := binary.LittleEndian.Uint32(block)
+		highID := binary.LittleEndian.Uint32(block[4:])
+		offset := binary.LittleEndian.Uint32(block[8:])
+
+		for id := lowID; id <= highID; id++ {
+			if uint64(offset)+4 > uint64(len(data)) {
+				return fmt.Errorf("%w: message %d out of table", ErrInvalidTemplate, id)
+			}
+			length := uint32(binary.LittleEndian.Uint16(data[offset:]))
+			flags := binary.LittleEndian.Uint16(data[offset+2:])
+			if length < 4 || uint64(offset)+uint64(length) > uint64(len(data)) {
+				return fmt.Errorf("%w: message %d of %d bytes", ErrInvalidTemplate, id, length)
+			}
+
+			if _, ok := m[id]; !ok {
+				m[id] = messageText(data[offset+4:offset+length], flags&messageResourceUnicode != 0)
+			}
+			offset += length
+
+			if id == highID { // highID may be 0xFFFFFFFF
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func messageText(data []byte, unicode bool) string {
+	inType := winapi.TdhInTypeAnsistring
+	if unicode {
+		inType = winapi.TdhInTypeUnicodestring
+	}
+	value, _, err := etw.PropertyDecoder{}.Decode(data, inType, winapi.TdhOutTypeString, 0)
+	if err != nil {
+		return ""
+	}
+	return value.String()
+}
+
+// noMessage is the message identifier of elements without message.
+const noMessage = 0xFFFFFFFF
+
+// text returns the message id, without the line break message compilers append, or fallback.
+func (m MessageTable) text(id uint32, fallback string) string {
+	if message, ok := m[id]; ok && id != noMessage {
+		return strings.TrimRight(message, "\r\n ")
+	}
+	return fallback
+}
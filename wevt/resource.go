@@ -0,0 +1,168 @@
+package wevt
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/debug/pe-format#the-rsrc-section
+
+const (
+	imageDirectoryEntryResource = 2
+
+	resourceDirectorySize      = 16
+	resourceDirectoryEntrySize = 8
+	resourceDataEntrySize      = 16
+
+	resourceSubdirectoryFlag = 0x80000000
+	resourceNameFlag         = 0x80000000
+
+	maxResourceDepth = 3 // type, name, language
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/menurc/resource-types
+const (
+	RT_MESSAGETABLE = 11
+)
+
+const wevtTemplateResourceType = "WEVT_TEMPLATE"
+
+var (
+	ErrNoResource = fmt.Errorf("resource not found")
+)
+
+// resourceSection is the resource directory of an image, addressed by RVA.
+type resourceSection struct {
+	data           []byte
+	virtualAddress uint32 // of data[0]
+	directory      uint32 // RVA of the root directory
+}
+
+func newResourceSection(file *pe.File) (*resourceSection, error) {
+	var directory pe.DataDirectory
+	switch header := file.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if header.NumberOfRvaAndSizes <= imageDirectoryEntryResource {
+			return nil, ErrNoResource
+		}
+		directory = header.DataDirectory[imageDirectoryEntryResource]
+	case *pe.OptionalHeader64:
+		if header.NumberOfRvaAndSizes <= imageDirectoryEntryResource {
+			return nil, ErrNoResource
+		}
+		directory = header.DataDirectory[imageDirectoryEntryResource]
+	default:
+		return nil, ErrNoResource
+	}
+	if directory.VirtualAddress == 0 {
+		return nil, ErrNoResource
+	}
+
+	for _, section := range file.Sections {
+		if directory.VirtualAddress < section.VirtualAddress || directory.VirtualAddress >= section.VirtualAddress+section.Size {
+			continue
+		}
+		data, err := section.Data()
+		if err != nil {
+			return nil, err
+		}
+		return &resourceSection{data: data, virtualAddress: section.VirtualAddress, directory: directory.VirtualAddress}, nil
+	}
+
+	return nil, ErrNoResource
+}
+
+// at returns size bytes at rva, nil when out of the section.
+func (r *resourceSection) at(rva uint32, size uint32) []byte {
+	if rva < r.virtualAddress {
+		return nil
+	}
+	offset := uint64(rva - r.virtualAddress)
+	if offset+uint64(size) > uint64(len(r.data)) {
+		return nil
+	}
+	return r.data[offset : offset+uint64(size)]
+}
+
+// entryMatches compares a directory entry name with a resource type given as a string or as an integer ID.
+func (r *resourceSection) entryMatches(name uint32, resourceType interface{}) bool {
+	switch typed := resourceType.(type) {
+	case int:
+		return name&resourceNameFlag == 0 && name == uint32(typed)
+	case string:
+		if name&resourceNameFlag == 0 {
+			return false
+		}
+		nameRVA := r.directory + name&^resourceNameFlag
+		lengthBytes := r.at(nameRVA, 2)
+		if lengthBytes == nil {
+			return false
+		}
+		length := uint32(binary.LittleEndian.Uint16(lengthBytes))
+		characters := r.at(nameRVA+2, 2*length)
+		if characters == nil {
+			return false
+		}
+		codeUnits := make([]uint16, length)
+		for i := range codeUnits {
+			codeUnits[i] = binary.LittleEndian.Uint16(characters[2*i:])
+		}
+		return string(utf16.Decode(codeUnits)) == typed
+	}
+	return false
+}
+
+// resources returns the data of every resource of resourceType, whatever their name and language.
+func (r *resourceSection) resources(resourceType interface{}) ([][]byte, error) {
+	var resources [][]byte
+	err := r.walk(r.directory, 0, resourceType, &resources)
+	if err == nil && len(resources) == 0 {
+		err = ErrNoResource
+	}
+	return resources, err
+}
+
+func (r *resourceSection) walk(directoryRVA uint32, depth int, resourceType interface{}, resources *[][]byte) error {
+	header := r.at(directoryRVA, resourceDirectorySize)
+	if header == nil {
+		return fmt.Errorf("%w: resource directory out of section", ErrInvalidTemplate)
+	}
+	entryCount := uint32(binary.LittleEndian.Uint16(header[12:])) + uint32(binary.LittleEndian.Uint16(header[14:]))
+
+	for i := uint32(0); i < entryCount; i++ {
+		entry := r.at(directoryRVA+resourceDirectorySize+i*resourceDirectoryEntrySize, resourceDirectoryEntrySize)
+		if entry == nil {
+			return fmt.Errorf("%w: resource directory entry out of section", ErrInvalidTemplate)
+		}
+		name := binary.LittleEndian.Uint32(entry)
+		offset := binary.LittleEndian.Uint32(entry[4:])
+
+		if depth == 0 && !r.entryMatches(name, resourceType) {
+			continue
+		}
+
+		if offset&resourceSubdirectoryFlag != 0 {
+			if depth+1 >= maxResourceDepth {
+				return fmt.Errorf("%w: resource directory too deep", ErrInvalidTemplate)
+			}
+			if err := r.walk(r.directory+offset&^resourceSubdirectoryFlag, depth+1, resourceType, resources); err != nil {
+				return err
+			}
+			continue
+		}
+
+		dataEntry := r.at(r.directory+offset, resourceDataEntrySize)
+		if dataEntry == nil {
+			return fmt.Errorf("%w: resource data entry out of section", ErrInvalidTemplate)
+		}
+		data := r.at(binary.LittleEndian.Uint32(dataEntry), binary.LittleEndian.Uint32(dataEntry[4:]))
+		if data == nil {
+			return fmt.Errorf("%w: resource data out of section", ErrInvalidTemplate)
+		}
+		*resources = append(*resources, data)
+	}
+
+	return nil
+}
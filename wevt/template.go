@@ -0,0 +1,486 @@
+package wevt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// The WEVT_TEMPLATE resource is the binary form of the instrumentation manifest, produced by the message compiler.
+// All offsets are relative to the start of the CRIM header.
+// https://github.com/libyal/libfwevt/blob/main/documentation/Windows%20Event%20manifest%20binary%20format.asciidoc
+
+var (
+	ErrInvalidTemplate = fmt.Errorf("invalid WEVT_TEMPLATE")
+)
+
+const (
+	crimHeaderSize        = 16
+	providerEntrySize     = 20
+	wevtHeaderSize        = 20
+	elementDescriptorSize = 8
+
+	channelDefinitionSize = 16
+	eventDefinitionSize   = 48
+	keywordDefinitionSize = 16
+	levelDefinitionSize   = 12
+	opcodeDefinitionSize  = 12
+	taskDefinitionSize    = 28
+	mapEntrySize          = 8
+	templateHeaderSize    = 40
+	templateItemSize      = 20
+)
+
+// templateData reads the resource, remembering the first out of bounds access.
+type templateData struct {
+	data []byte
+	err  error
+}
+
+func (t *templateData) slice(offset uint32, size uint32) []byte {
+	if t.err != nil {
+		return nil
+	}
+	if uint64(offset)+uint64(size) > uint64(len(t.data)) {
+		t.err = fmt.Errorf("%w: %d bytes at offset %d out of %d", ErrInvalidTemplate, size, offset, len(t.data))
+		return nil
+	}
+	return t.data[offset : offset+size]
+}
+
+func (t *templateData) uint8At(offset uint32) uint8 {
+	if b := t.slice(offset, 1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (t *templateData) uint16At(offset uint32) uint16 {
+	if b := t.slice(offset, 2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (t *templateData) uint32At(offset uint32) uint32 {
+	if b := t.slice(offset, 4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (t *templateData) uint64At(offset uint32) uint64 {
+	if b := t.slice(offset, 8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (t *templateData) guidAt(offset uint32) winguid.GUID {
+	if b := t.slice(offset, 16); b != nil {
+		return winguid.FromBytes(b)
+	}
+	return winguid.GUID{}
+}
+
+func (t *templateData) signatureAt(offset uint32, signature string) bool {
+	if b := t.slice(offset, 4); b != nil && string(b) == signature {
+		return true
+	}
+	if t.err == nil {
+		t.err = fmt.Errorf("%w: expected %s at offset %d", ErrInvalidTemplate, signature, offset)
+	}
+	return false
+}
+
+// stringAt reads a name: its size in bytes, the size field included, then UTF-16 characters.
+func (t *templateData) stringAt(offset uint32) string {
+	if offset == 0 {
+		return ""
+	}
+	size := t.uint32At(offset)
+	if size < 4 {
+		return ""
+	}
+	characters := t.slice(offset+4, size-4)
+	codeUnits := make([]uint16, len(characters)/2)
+	for i := range codeUnits {
+		codeUnits[i] = binary.LittleEndian.Uint16(characters[2*i:])
+	}
+	return strings.TrimRight(string(utf16.Decode(codeUnits)), "\x00")
+}
+
+// Provider is a provider declared in a WEVT_TEMPLATE resource. Compiled manifests do not keep provider names,
+// Name is the provider message, when the message table has it.
+type Provider struct {
+	GUID    winguid.GUID
+	Name    string
+	Schemas []*etw.Schema
+}
+
+// named is a level, task, opcode, keyword or channel definition.
+type named struct {
+	value   uint64
+	name    string
+	message string
+	guid    winguid.GUID
+}
+
+// displayName is what TDH reports: the localized message, or the name without message.
+func (n named) displayName() string {
+	if n.message != "" {
+		return n.message
+	}
+	return n.name
+}
+
+type providerDefinitions struct {
+	channels  map[uint64]named
+	levels    map[uint64]named
+	tasks     map[uint64]named
+	opcodes   map[uint64]named // task << 16 | opcode
+	keywords  []named
+	maps      map[uint32]*etw.ValueMap // by offset, as properties reference them
+	templates map[uint32]*template     // by offset, as events reference them
+}
+
+type template struct {
+	flags                 winapi.TemplateFlags
+	properties            []etw.PropertyInfo
+	topLevelPropertyCount int
+	maps                  map[string]*etw.ValueMap
+}
+
+// ParseTemplate decodes the CRIM blob of a WEVT_TEMPLATE resource. Messages come from messages, which may be nil.
+func ParseTemplate(data []byte, messages MessageTable) ([]*Provider, error) {
+	t := &templateData{data: data}
+	if !t.signatureAt(0, "CRIM") {
+		return nil, t.err
+	}
+
+	providerCount := t.uint32At(12)
+	if uint64(providerCount)*providerEntrySize > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: %d providers", ErrInvalidTemplate, providerCount)
+	}
+
+	providers := make([]*Provider, 0, providerCount)
+	for i := uint32(0); i < providerCount; i++ {
+		entry := crimHeaderSize + i*providerEntrySize
+		guid := t.guidAt(entry)
+		offset := t.uint32At(entry + 16)
+		if t.err != nil {
+			return nil, t.err
+		}
+
+		provider, err := parseProvider(t, offset, guid, messages)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+func parseProvider(t *templateData, offset uint32, guid winguid.GUID, messages MessageTable) (*Provider, error) {
+	if !t.signatureAt(offset, "WEVT") {
+		return nil, t.err
+	}
+
+	provider := Provider{
+		GUID: guid,
+		Name: messages.text(t.uint32At(offset+8), ""),
+	}
+	definitions := providerDefinitions{
+		channels:  make(map[uint64]named),
+		levels:    make(map[uint64]named),
+		tasks:     make(map[uint64]named),
+		opcodes:   make(map[uint64]named),
+		maps:      make(map[uint32]*etw.ValueMap),
+		templates: make(map[uint32]*template),
+	}
+
+	descriptorCount := t.uint32At(offset + 12)
+	if uint64(descriptorCount)*elementDescriptorSize > uint64(len(t.data)) {
+		return nil, fmt.Errorf("%w: %d provider elements", ErrInvalidTemplate, descriptorCount)
+	}
+
+	// events reference the other elements, they are read last
+	var eventsOffset uint32
+	for i := uint32(0); i < descriptorCount && t.err == nil; i++ {
+		elementOffset := t.uint32At(offset + wevtHeaderSize + i*elementDescriptorSize)
+		signature := t.slice(elementOffset, 4)
+		if signature == nil {
+			break
+		}
+
+		switch string(signature) {
+		case "CHAN":
+			byValue(parseNamedList(t, elementOffset, channelDefinitionSize, func(d uint32) named {
+				return named{value: uint64(t.uint32At(d)), name: t.stringAt(t.uint32At(d + 4)), message: messages.text(t.uint32At(d+12), "")}
+			}), definitions.channels)
+		case "LEVL":
+			byValue(parseNamedList(t, elementOffset, levelDefinitionSize, func(d uint32) named {
+				return named{value: uint64(t.uint32At(d)), message: messages.text(t.uint32At(d+4), ""), name: t.stringAt(t.uint32At(d + 8))}
+			}), definitions.levels)
+		case "OPCO":
+			byValue(parseNamedList(t, elementOffset, opcodeDefinitionSize, func(d uint32) named {
+				return named{value: uint64(t.uint32At(d)), message: messages.text(t.uint32At(d+4), ""), name: t.stringAt(t.uint32At(d + 8))}
+			}), definitions.opcodes)
+		case "TASK":
+			byValue(parseNamedList(t, elementOffset, taskDefinitionSize, func(d uint32) named {
+				return named{value: uint64(t.uint32At(d)), message: messages.text(t.uint32At(d+4), ""), guid: t.guidAt(d + 8), name: t.stringAt(t.uint32At(d + 24))}
+			}), definitions.tasks)
+		case "KEYW":
+			definitions.keywords = parseNamedList(t, elementOffset, keywordDefinitionSize, func(d uint32) named {
+				return named{value: t.uint64At(d), message: messages.text(t.uint32At(d+8), ""), name: t.stringAt(t.uint32At(d + 12))}
+			})
+		case "MAPS":
+			parseMaps(t, elementOffset, messages, definitions.maps)
+		case "TTBL":
+			parseTemplates(t, elementOffset, definitions)
+		case "EVNT":
+			eventsOffset = elementOffset
+		}
+	}
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	if eventsOffset != 0 {
+		provider.Schemas = parseEvents(t, eventsOffset, &provider, &definitions, messages)
+	}
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	return &provider, nil
+}
+
+// parseNamedList reads the definitions of CHAN, LEVL, OPCO, TASK and KEYW elements:
+// signature, size, count, then fixed size definitions.
+func parseNamedList(t *templateData, offset uint32, definitionSize uint32, read func(uint32) named) []named {
+	count := t.uint32At(offset + 8)
+	if uint64(count)*uint64(definitionSize) > uint64(len(t.data)) {
+		t.err = fmt.Errorf("%w: %d definitions", ErrInvalidTemplate, count)
+		return nil
+	}
+
+	definitions := make([]named, 0, count)
+	for i := uint32(0); i < count && t.err == nil; i++ {
+		definitions = append(definitions, read(offset+12+i*definitionSize))
+	}
+	return definitions
+}
+
+func byValue(definitions []named, values map[uint64]named) {
+	for _, definition := range definitions {
+		values[definition.value] = definition
+	}
+}
+
+// parseMaps reads the VMAP and BMAP elements listed by a MAPS element.
+func parseMaps(t *templateData, offset uint32, messages MessageTable, maps map[uint32]*etw.ValueMap) {
+	count := t.uint32At(offset + 8)
+	if uint64(count)*4 > uint64(len(t.data)) {
+		t.err = fmt.Errorf("%w: %d maps", ErrInvalidTemplate, count)
+		return
+	}
+
+	for i := uint32(0); i < count && t.err == nil; i++ {
+		mapOffset := t.uint32At(offset + 12 + i*4)
+
+		valueMap := etw.ValueMap{}
+		switch string(t.slice(mapOffset, 4)) {
+		case "VMAP":
+			valueMap.Flag = winapi.EVENTMAP_INFO_FLAG_MANIFEST_VALUEMAP
+		case "BMAP":
+			valueMap.Flag = winapi.EVENTMAP_INFO_FLAG_MANIFEST_BITMAP
+		default:
+			if t.err == nil {
+				t.err = fmt.Errorf("%w: unknown map at offset %d", ErrInvalidTemplate, mapOffset)
+			}
+			return
+		}
+		valueMap.Name = t.stringAt(t.uint32At(mapOffset + 8))
+
+		entryCount := t.uint32At(mapOffset + 16)
+		if uint64(entryCount)*mapEntrySize > uint64(len(t.data)) {
+			t.err = fmt.Errorf("%w: map %s of %d entries", ErrInvalidTemplate, valueMap.Name, entryCount)
+			return
+		}
+		valueMap.Entries = make([]etw.ValueMapEntry, 0, entryCount)
+		for j := uint32(0); j < entryCount && t.err == nil; j++ {
+			entry := mapOffset + 20 + j*mapEntrySize
+			valueMap.Entries = append(valueMap.Entries, etw.ValueMapEntry{
+				Value:  t.uint32At(entry),
+				Output: messages.text(t.uint32At(entry+4), ""),
+			})
+		}
+
+		maps[mapOffset] = &valueMap
+	}
+}
+
+// parseTemplates reads the TEMP elements of a TTBL element. Template items are laid out like EVENT_PROPERTY_INFO:
+// flags, in and out types or structure members, map name offset, count, length and name offset.
+func parseTemplates(t *templateData, offset uint32, definitions providerDefinitions) {
+	count := t.uint32At(offset + 8)
+	templateOffset := offset + 12
+
+	mapsByName := make(map[string]*etw.ValueMap, len(definitions.maps))
+	for _, valueMap := range definitions.maps {
+		mapsByName[valueMap.Name] = valueMap
+	}
+
+	for i := uint32(0); i < count && t.err == nil; i++ {
+		if !t.signatureAt(templateOffset, "TEMP") {
+			return
+		}
+		size := t.uint32At(templateOffset + 4)
+		itemCount := t.uint32At(templateOffset + 8)
+		itemsOffset := t.uint32At(templateOffset + 16)
+		if size < templateHeaderSize || uint64(itemCount)*templateItemSize > uint64(len(t.data)) {
+			t.err = fmt.Errorf("%w: template at offset %d", ErrInvalidTemplate, templateOffset)
+			return
+		}
+
+		parsed := template{
+			flags:      winapi.TEMPLATE_EVENT_DATA,
+			properties: make([]etw.PropertyInfo, 0, itemCount),
+		}
+		if binXMLOffset := templateOffset + templateHeaderSize; itemsOffset > binXMLOffset {
+			parsed.flags = templateFlags(t.slice(binXMLOffset, itemsOffset-binXMLOffset))
+		}
+
+		structMembers := 0
+		for j := uint32(0); j < itemCount && t.err == nil; j++ {
+			item := itemsOffset + j*templateItemSize
+			property := etw.PropertyInfo{
+				Flags:  winapi.PropertyFlags(t.uint32At(item)),
+				Count:  t.uint16At(item + 12),
+				Length: t.uint16At(item + 14),
+				Name:   t.stringAt(t.uint32At(item + 16)),
+			}
+
+			if property.IsStruct() {
+				property.StructStartIndex = t.uint16At(item + 4)
+				property.NumOfStructMembers = t.uint16At(item + 6)
+				structMembers += int(property.NumOfStructMembers)
+			} else {
+				property.InType = winapi.TdhInType(t.uint8At(item + 4))
+				property.OutType = winapi.TdhOutType(t.uint8At(item + 5))
+				if mapName := t.stringAt(t.uint32At(item + 8)); mapName != "" {
+					property.MapName = mapName
+					if valueMap, ok := mapsByName[mapName]; ok {
+						if parsed.maps == nil {
+							parsed.maps = make(map[string]*etw.ValueMap)
+						}
+						parsed.maps[mapName] = valueMap
+					}
+				}
+			}
+
+			parsed.properties = append(parsed.properties, property)
+		}
+
+		parsed.topLevelPropertyCount = len(parsed.properties) - structMembers
+		if parsed.topLevelPropertyCount < 0 {
+			t.err = fmt.Errorf("%w: template at offset %d has more structure members than items", ErrInvalidTemplate, templateOffset)
+			return
+		}
+
+		definitions.templates[templateOffset] = &parsed
+		templateOffset += size
+	}
+}
+
+// templateFlags tells EventData templates from UserData ones, by the root element of their BinXML fragment.
+// EventData templates compile to <EventData><Data Name="...">, UserData ones start with their own element.
+func templateFlags(binXML []byte) winapi.TemplateFlags {
+	eventData := make([]byte, 0, 2*len("EventData"))
+	for _, c := range "EventData" {
+		eventData = append(eventData, byte(c), 0)
+	}
+
+	if index := bytes.Index(binXML, eventData); index >= 0 && index < 64 {
+		return winapi.TEMPLATE_EVENT_DATA
+	}
+	return winapi.TEMPLATE_USER_DATA
+}
+
+func parseEvents(t *templateData, offset uint32, provider *Provider, definitions *providerDefinitions, messages MessageTable) []*etw.Schema {
+	count := t.uint32At(offset + 8)
+	if uint64(count)*eventDefinitionSize > uint64(len(t.data)) {
+		t.err = fmt.Errorf("%w: %d events", ErrInvalidTemplate, count)
+		return nil
+	}
+
+	schemas := make([]*etw.Schema, 0, count)
+	for i := uint32(0); i < count && t.err == nil; i++ {
+		event := offset + 16 + i*eventDefinitionSize
+
+		schema := etw.Schema{
+			ProviderGUID:   provider.GUID,
+			DecodingSource: winapi.DecodingSourceXMLFile,
+			ProviderName:   provider.Name,
+			EventDescriptor: winapi.EventDescriptor{
+				Id:      t.uint16At(event),
+				Version: t.uint8At(event + 2),
+				Channel: t.uint8At(event + 3),
+				Level:   t.uint8At(event + 4),
+				Opcode:  t.uint8At(event + 5),
+				Task:    t.uint16At(event + 6),
+				Keyword: t.uint64At(event + 8),
+			},
+			EventMessage: messages.text(t.uint32At(event+16), ""),
+		}
+		descriptor := &schema.EventDescriptor
+
+		if channel, ok := definitions.channels[uint64(descriptor.Channel)]; ok {
+			schema.ChannelName = channel.name
+		}
+		if level, ok := definitions.levels[uint64(descriptor.Level)]; ok {
+			schema.LevelName = level.displayName()
+		}
+		if task, ok := definitions.tasks[uint64(descriptor.Task)]; ok {
+			schema.TaskName = task.displayName()
+			schema.EventGUID = task.guid
+		}
+		if opcode, ok := definitions.opcodes[uint64(descriptor.Task)<<16|uint64(descriptor.Opcode)]; ok {
+			schema.OpcodeName = opcode.displayName()
+		} else if opcode, ok = definitions.opcodes[uint64(descriptor.Opcode)]; ok {
+			schema.OpcodeName = opcode.displayName()
+		}
+
+		keywordNames := make([]string, 0)
+		for _, keyword := range definitions.keywords {
+			if keyword.value != 0 && descriptor.Keyword&keyword.value == keyword.value {
+				keywordNames = append(keywordNames, keyword.displayName())
+			}
+		}
+		schema.KeywordsName = strings.Join(keywordNames, ", ")
+
+		if templateOffset := t.uint32At(event + 20); templateOffset != 0 {
+			eventTemplate, ok := definitions.templates[templateOffset]
+			if !ok {
+				t.err = fmt.Errorf("%w: event %d references no template", ErrInvalidTemplate, descriptor.Id)
+				return nil
+			}
+			schema.Flags = eventTemplate.flags
+			schema.Properties = eventTemplate.properties
+			schema.TopLevelPropertyCount = eventTemplate.topLevelPropertyCount
+			schema.Maps = eventTemplate.maps
+		}
+
+		schemas = append(schemas, &schema)
+	}
+
+	return schemas
+}
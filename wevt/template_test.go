@@ -0,0 +1,418 @@
+package wevt
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+var (
+	testProviderGUID = *winguid.MustParse("{5770385F-C22A-43E0-BF4C-06F5698FFBD9}")
+	testTaskGUID     = *winguid.MustParse("{A1B2C3D4-0000-1111-2222-333344445555}")
+)
+
+// crimBuilder lays out a WEVT_TEMPLATE resource. Names are written at the end, by build.
+type crimBuilder struct {
+	data    []byte
+	strings map[uint32]string // offset of a name offset field, name
+}
+
+func (b *crimBuilder) offset() uint32 {
+	return uint32(len(b.data))
+}
+
+func (b *crimBuilder) bytes(data ...byte) {
+	b.data = append(b.data, data...)
+}
+
+func (b *crimBuilder) u8(v uint8) {
+	b.data = append(b.data, v)
+}
+
+func (b *crimBuilder) u16(v uint16) {
+	b.data = binary.LittleEndian.AppendUint16(b.data, v)
+}
+
+func (b *crimBuilder) u32(v uint32) {
+	b.data = binary.LittleEndian.AppendUint32(b.data, v)
+}
+
+func (b *crimBuilder) u64(v uint64) {
+	b.data = binary.LittleEndian.AppendUint64(b.data, v)
+}
+
+func (b *crimBuilder) guid(guid winguid.GUID) {
+	b.data = append(b.data, winguid.ToBytes(&guid)...)
+}
+
+// name writes the offset of s, 0 for empty names.
+func (b *crimBuilder) name(s string) {
+	if s != "" {
+		b.strings[b.offset()] = s
+	}
+	b.u32(0)
+}
+
+func (b *crimBuilder) patch(at uint32, v uint32) {
+	binary.LittleEndian.PutUint32(b.data[at:], v)
+}
+
+// element starts a CHAN, LEVL, OPCO, TASK, KEYW or EVNT element of count definitions.
+func (b *crimBuilder) element(signature string, count uint32) uint32 {
+	offset := b.offset()
+	b.bytes([]byte(signature)...)
+	b.u32(0) // size, unused
+	b.u32(count)
+	if signature == "EVNT" {
+		b.u32(0)
+	}
+	return offset
+}
+
+func (b *crimBuilder) build() []byte {
+	for at, s := range b.strings {
+		b.patch(at, b.offset())
+		var characters []byte
+		for _, r := range s + "\x00" {
+			characters = binary.LittleEndian.AppendUint16(characters, uint16(r))
+		}
+		b.u32(uint32(4 + len(characters)))
+		b.bytes(characters...)
+	}
+	return b.data
+}
+
+// sampleTemplate compiles one provider with every kind of element, and two events:
+// event 1 with a template holding a mapped value and a structure, event 2 without template.
+func sampleTemplate() []byte {
+	b := &crimBuilder{strings: make(map[uint32]string)}
+
+	b.bytes('C', 'R', 'I', 'M')
+	b.u32(0)
+	b.u16(3)
+	b.u16(1)
+	b.u32(1) // providers
+	b.guid(testProviderGUID)
+	providerOffsetField := b.offset()
+	b.u32(0)
+
+	provider := b.offset()
+	b.patch(providerOffsetField, provider)
+	b.bytes('W', 'E', 'V', 'T')
+	b.u32(0)
+	b.u32(100) // provider message
+	elements := []string{"CHAN", "LEVL", "OPCO", "TASK", "KEYW", "MAPS", "TTBL", "EVNT"}
+	b.u32(uint32(len(elements)))
+	b.u32(0xFFFFFFFF)
+	descriptors := b.offset()
+	for range elements {
+		b.u32(0)
+		b.u32(0)
+	}
+	descriptor := func(i int) {
+		b.patch(descriptors+uint32(i)*elementDescriptorSize, b.offset())
+	}
+
+	descriptor(0)
+	b.element("CHAN", 1)
+	b.u32(16)
+	b.name("Sample-Provider/Operational")
+	b.u32(0)
+	b.u32(noMessage)
+
+	descriptor(1)
+	b.element("LEVL", 1)
+	b.u32(4)
+	b.u32(200)
+	b.name("win:Informational")
+
+	descriptor(2)
+	b.element("OPCO", 2)
+	b.u32(1)
+	b.u32(noMessage)
+	b.name("win:Start")
+	b.u32(7<<16 | 10)
+	b.u32(201)
+	b.name("Retry")
+
+	descriptor(3)
+	b.element("TASK", 1)
+	b.u32(7)
+	b.u32(202)
+	b.guid(testTaskGUID)
+	b.name("Connect")
+
+	descriptor(4)
+	b.element("KEYW", 2)
+	b.u64(0x1)
+	b.u32(203)
+	b.name("Network")
+	b.u64(0x2)
+	b.u32(noMessage)
+	b.name("Disk")
+
+	descriptor(5)
+	b.element("MAPS", 1)
+	mapOffsetField := b.offset()
+	b.u32(0)
+	b.patch(mapOffsetField, b.offset())
+	b.bytes('V', 'M', 'A', 'P')
+	b.u32(0)
+	b.name("StateMap")
+	b.u32(0)
+	b.u32(2)
+	b.u32(0)
+	b.u32(300)
+	b.u32(1)
+	b.u32(301)
+
+	descriptor(6)
+	b.element("TTBL", 1)
+	template := b.offset()
+	binXML := []byte{0x0f, 0x01, 0x01, 0x00}
+	for _, c := range "EventData" {
+		binXML = append(binXML, byte(c), 0)
+	}
+	items := template + templateHeaderSize + uint32(len(binXML))
+	b.bytes('T', 'E', 'M', 'P')
+	b.u32(items + 4*templateItemSize - template)
+	b.u32(4) // items
+	b.u32(3) // names
+	b.u32(items)
+	b.u32(1)
+	b.bytes(make([]byte, 16)...) // template GUID
+	b.bytes(binXML...)
+
+	b.u32(0) // State
+	b.u8(uint8(winapi.TdhInTypeUint32))
+	b.u8(uint8(winapi.TdhOutTypeUnsignedint))
+	b.u16(0)
+	b.name("StateMap")
+	b.u16(1)
+	b.u16(4)
+	b.name("State")
+
+	b.u32(uint32(winapi.PropertyStruct)) // Endpoint
+	b.u16(2)
+	b.u16(2)
+	b.u32(0)
+	b.u16(1)
+	b.u16(0)
+	b.name("Endpoint")
+
+	b.u32(0) // Port
+	b.u8(uint8(winapi.TdhInTypeUint16))
+	b.u8(uint8(winapi.TdhOutTypePort))
+	b.u16(0)
+	b.u32(0)
+	b.u16(1)
+	b.u16(2)
+	b.name("Port")
+
+	b.u32(0) // Address
+	b.u8(uint8(winapi.TdhInTypeUint32))
+	b.u8(uint8(winapi.TdhOutTypeIpv4))
+	b.u16(0)
+	b.u32(0)
+	b.u16(1)
+	b.u16(4)
+	b.name("Address")
+
+	descriptor(7)
+	b.element("EVNT", 2)
+	for _, event := range []struct {
+		id       uint16
+		opcode   uint8
+		message  uint32
+		template uint32
+	}{
+		{1, 1, 400, template},
+		{2, 10, noMessage, 0},
+	} {
+		b.u16(event.id)
+		b.u8(1)  // version
+		b.u8(16) // channel
+		b.u8(4)  // level
+		b.u8(event.opcode)
+		b.u16(7) // task
+		b.u64(0x3)
+		b.u32(event.message)
+		b.u32(event.template)
+		b.bytes(make([]byte, eventDefinitionSize-24)...)
+	}
+
+	return b.build()
+}
+
+var sampleMessages = MessageTable{
+	100: "Sample Provider\r\n",
+	200: "Information\r\n",
+	201: "Retry\r\n",
+	202: "Connect\r\n",
+	203: "Network\r\n",
+	300: "Stopped\r\n",
+	301: "Running\r\n",
+	400: "State %1\r\n",
+}
+
+func TestParseTemplate(t *testing.T) {
+	providers, err := ParseTemplate(sampleTemplate(), sampleMessages)
+	if err != nil {
+		t.Fatalf("ParseTemplate: %v", err)
+	}
+	if len(providers) != 1 || providers[0].GUID != testProviderGUID || providers[0].Name != "Sample Provider" {
+		t.Fatalf("providers = %+v", providers)
+	}
+	schemas := providers[0].Schemas
+	if len(schemas) != 2 {
+		t.Fatalf("%d schemas, want 2", len(schemas))
+	}
+
+	connect := schemas[0]
+	wantDescriptor := winapi.EventDescriptor{Id: 1, Version: 1, Channel: 16, Level: 4, Opcode: 1, Task: 7, Keyword: 0x3}
+	if connect.EventDescriptor != wantDescriptor {
+		t.Errorf("descriptor = %+v, want %+v", connect.EventDescriptor, wantDescriptor)
+	}
+	names := []string{connect.ProviderName, connect.ChannelName, connect.LevelName, connect.TaskName, connect.OpcodeName, connect.KeywordsName, connect.EventMessage}
+	wantNames := []string{"Sample Provider", "Sample-Provider/Operational", "Information", "Connect", "win:Start", "Network, Disk", "State %1"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("names = %q, want %q", names, wantNames)
+	}
+	if connect.EventGUID != testTaskGUID || connect.Flags != winapi.TEMPLATE_EVENT_DATA {
+		t.Errorf("event GUID %v, flags %d", connect.EventGUID, connect.Flags)
+	}
+
+	wantProperties := []etw.PropertyInfo{
+		{Name: "State", InType: winapi.TdhInTypeUint32, OutType: winapi.TdhOutTypeUnsignedint, MapName: "StateMap", Count: 1, Length: 4},
+		{Name: "Endpoint", Flags: winapi.PropertyStruct, StructStartIndex: 2, NumOfStructMembers: 2, Count: 1},
+		{Name: "Port", InType: winapi.TdhInTypeUint16, OutType: winapi.TdhOutTypePort, Count: 1, Length: 2},
+		{Name: "Address", InType: winapi.TdhInTypeUint32, OutType: winapi.TdhOutTypeIpv4, Count: 1, Length: 4},
+	}
+	if !reflect.DeepEqual(connect.Properties, wantProperties) || connect.TopLevelPropertyCount != 2 {
+		t.Errorf("properties (%d top level) =\n%+v\nwant\n%+v", connect.TopLevelPropertyCount, connect.Properties, wantProperties)
+	}
+	wantMap := &etw.ValueMap{Name: "StateMap", Flag: winapi.EVENTMAP_INFO_FLAG_MANIFEST_VALUEMAP, Entries: []etw.ValueMapEntry{{Value: 0, Output: "Stopped"}, {Value: 1, Output: "Running"}}}
+	if !reflect.DeepEqual(connect.Maps["StateMap"], wantMap) {
+		t.Errorf("StateMap = %+v, want %+v", connect.Maps["StateMap"], wantMap)
+	}
+
+	retry := schemas[1]
+	if retry.OpcodeName != "Retry" || retry.EventMessage != "" || len(retry.Properties) != 0 {
+		t.Errorf("retry = %+v", retry)
+	}
+
+	record := &etw.Record{UserData: []byte{1, 0, 0, 0, 0x01, 0xbb, 10, 0, 0, 1}}
+	record.EventHeader.ProviderId = testProviderGUID
+	record.EventHeader.EventDescriptor = wantDescriptor
+	event, err := etw.DecodeRecord(record, etw.NewSchemaBackend(Schemas(providers)...))
+	if err != nil {
+		t.Fatalf("DecodeRecord: %v", err)
+	}
+	if event.EventData["State"] != "Running" || event.Message != "State Running" {
+		t.Errorf("EventData = %v, Message = %q", event.EventData, event.Message)
+	}
+	if endpoint := event.EventDataStructs["Endpoint"]; len(endpoint) != 1 || endpoint[0]["Port"] != "443" || endpoint[0]["Address"] != "10.0.0.1" {
+		t.Errorf("Endpoint = %v", endpoint)
+	}
+}
+
+func TestParseTemplateWithoutMessages(t *testing.T) {
+	providers, err := ParseTemplate(sampleTemplate(), nil)
+	if err != nil {
+		t.Fatalf("ParseTemplate: %v", err)
+	}
+	connect := providers[0].Schemas[0]
+	if providers[0].Name != "" || connect.LevelName != "win:Informational" || connect.TaskName != "Connect" || connect.EventMessage != "" {
+		t.Errorf("names without messages = %q %q %q %q", providers[0].Name, connect.LevelName, connect.TaskName, connect.EventMessage)
+	}
+}
+
+func TestParseTemplateErrors(t *testing.T) {
+	sample := sampleTemplate()
+
+	badSignature := append([]byte{}, sample...)
+	copy(badSignature[crimHeaderSize+providerEntrySize:], "XXXX")
+
+	manyProviders := append([]byte{}, sample...)
+	binary.LittleEndian.PutUint32(manyProviders[12:], 0xFFFFFFFF)
+
+	badProviderOffset := append([]byte{}, sample...)
+	binary.LittleEndian.PutUint32(badProviderOffset[crimHeaderSize+16:], uint32(len(sample)))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not CRIM", []byte("MIRC")},
+		{"bad WEVT signature", badSignature},
+		{"too many providers", manyProviders},
+		{"provider out of range", badProviderOffset},
+	}
+	for size := 20; size < len(sample); size += 37 {
+		tests = append(tests, struct {
+			name string
+			data []byte
+		}{"truncated", sample[:size]})
+	}
+
+	for _, test := range tests {
+		if _, err := ParseTemplate(test.data, nil); !errors.Is(err, ErrInvalidTemplate) {
+			t.Errorf("%s (%d bytes): err = %v, want %v", test.name, len(test.data), err, ErrInvalidTemplate)
+		}
+	}
+}
+
+func TestMessageTableParse(t *testing.T) {
+	var data []byte
+	data = binary.LittleEndian.AppendUint32(data, 2) // blocks
+	data = binary.LittleEndian.AppendUint32(data, 10)
+	data = binary.LittleEndian.AppendUint32(data, 11)
+	data = binary.LittleEndian.AppendUint32(data, 28)
+	data = binary.LittleEndian.AppendUint32(data, 0xFFFFFFFF)
+	data = binary.LittleEndian.AppendUint32(data, 0xFFFFFFFF)
+	data = binary.LittleEndian.AppendUint32(data, 52)
+
+	entry := func(text []byte, unicode bool) {
+		flags := uint16(0)
+		if unicode {
+			flags = messageResourceUnicode
+		}
+		data = binary.LittleEndian.AppendUint16(data, uint16(4+len(text)))
+		data = binary.LittleEndian.AppendUint16(data, flags)
+		data = append(data, text...)
+	}
+	entry([]byte("Ansi\r\n\x00\x00"), false)
+	entry([]byte{'W', 0, 'i', 0, 'd', 0, 'e', 0}, true)
+	entry([]byte("Last\x00\x00\x00\x00"), false)
+
+	messages := make(MessageTable)
+	if err := messages.parse(data); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if messages.text(10, "") != "Ansi" || messages.text(11, "") != "Wide" || messages[0xFFFFFFFF] != "Last" {
+		t.Errorf("messages = %q", messages)
+	}
+	if messages.text(noMessage, "fallback") != "fallback" || messages.text(12, "fallback") != "fallback" {
+		t.Error("text does not fall back")
+	}
+
+	if err := make(MessageTable).parse(data[:30]); !errors.Is(err, ErrInvalidTemplate) {
+		t.Errorf("truncated table: err = %v, want %v", err, ErrInvalidTemplate)
+	}
+}
+
+func FuzzParseTemplate(f *testing.F) {
+	f.Add(sampleTemplate())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if _, err := ParseTemplate(data, sampleMessages); err != nil && !errors.Is(err, ErrInvalidTemplate) {
+			t.Fatalf("err = %v, want %v", err, ErrInvalidTemplate)
+		}
+	})
+}
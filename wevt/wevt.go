@@ -0,0 +1,65 @@
+package wevt
+
+import (
+	"debug/pe"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+)
+
+// Open reads the providers declared by the WEVT_TEMPLATE resource of the image at path.
+// Messages are read from the image itself, or from muiPath when not empty: system binaries keep
+// their message tables in language specific files, such as en-US\wevtsvc.dll.mui.
+func Open(path string, muiPath string) ([]*Provider, error) {
+	file, err := pe.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	messageFile := file
+	if muiPath != "" {
+		if messageFile, err = pe.Open(muiPath); err != nil {
+			return nil, err
+		}
+		defer messageFile.Close()
+	}
+
+	return Parse(file, messageFile)
+}
+
+// Parse reads the providers declared by the WEVT_TEMPLATE resource of file, with the messages of messageFile, if any.
+func Parse(file *pe.File, messageFile *pe.File) ([]*Provider, error) {
+	section, err := newResourceSection(file)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := section.resources(wevtTemplateResourceType)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages MessageTable
+	if messageFile != nil {
+		// images without message table still decode, without names
+		messages, _ = ReadMessageTable(messageFile)
+	}
+
+	var providers []*Provider
+	for _, resource := range resources {
+		resourceProviders, parseErr := ParseTemplate(resource, messages)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		providers = append(providers, resourceProviders...)
+	}
+	return providers, nil
+}
+
+// Schemas returns the schemas of providers, for instance to bundle them with etw.WriteSchemas.
+func Schemas(providers []*Provider) []*etw.Schema {
+	var schemas []*etw.Schema
+	for _, provider := range providers {
+		schemas = append(schemas, provider.Schemas...)
+	}
+	return schemas
+}
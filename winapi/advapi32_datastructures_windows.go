@@ -1,6 +1,7 @@
 package winapi
 
 import (
+	"fmt"
 	"syscall"
 	"time"
 	"unsafe"
@@ -21,13 +22,29 @@ const (
 	EVENT_CONTROL_CODE_ENABLE_PROVIDER = 1
 )
 
+// https://learn.microsoft.com/en-us/windows/win32/api/evntrace/ns-evntrace-enable_trace_parameters#members
 const (
-	PROCESS_TRACE_MODE_REAL_TIME    = 0x00000100
-	PROCESS_TRACE_MODE_EVENT_RECORD = 0x10000000
+	EVENT_ENABLE_PROPERTY_SID                       = 0x00000001
+	EVENT_ENABLE_PROPERTY_TS_ID                     = 0x00000002
+	EVENT_ENABLE_PROPERTY_STACK_TRACE               = 0x00000004
+	EVENT_ENABLE_PROPERTY_PSM_KEY                   = 0x00000008
+	EVENT_ENABLE_PROPERTY_IGNORE_KEYWORD_0          = 0x00000010
+	EVENT_ENABLE_PROPERTY_PROVIDER_GROUP            = 0x00000020
+	EVENT_ENABLE_PROPERTY_ENABLE_KEYWORD_0          = 0x00000040
+	EVENT_ENABLE_PROPERTY_PROCESS_START_KEY         = 0x00000080
+	EVENT_ENABLE_PROPERTY_EVENT_KEY                 = 0x00000100
+	EVENT_ENABLE_PROPERTY_EXCLUDE_INPRIVATE         = 0x00000200
+	EVENT_ENABLE_PROPERTY_ENABLE_SILOS              = 0x00000400
+	EVENT_ENABLE_PROPERTY_SOURCE_CONTAINER_TRACKING = 0x00000800
 )
 
 const (
-	EVENT_HEADER_FLAG_32_BIT_HEADER = 0x0020
+	ENABLE_TRACE_PARAMETERS_VERSION_2 = 2
+)
+
+const (
+	PROCESS_TRACE_MODE_REAL_TIME    = 0x00000100
+	PROCESS_TRACE_MODE_EVENT_RECORD = 0x10000000
 )
 
 type WnodeHeader struct {
@@ -147,22 +164,27 @@ type EventRecord struct {
 	UserContext       uintptr
 }
 
-func (e *EventRecord) ExtendedDataItem(i uint16) *EventHeaderExtendedDataItem {
-	if i < e.ExtendedDataCount {
-		return (*EventHeaderExtendedDataItem)(
-			unsafe.Pointer(uintptr(unsafe.Pointer(e.ExtendedData)) + (uintptr(i) * unsafe.Sizeof(EventHeaderExtendedDataItem{}))),
-		)
+func (e *EventRecord) ExtendedDataItem(i uint16) (*EventHeaderExtendedDataItem, error) {
+	if i >= e.ExtendedDataCount || e.ExtendedData == nil {
+		return nil, fmt.Errorf("extended data item %d of %d: %w", i, e.ExtendedDataCount, ErrIndexOutOfRange)
 	}
-	panic("out of bound extended data item")
+	return (*EventHeaderExtendedDataItem)(
+		unsafe.Pointer(uintptr(unsafe.Pointer(e.ExtendedData)) + (uintptr(i) * unsafe.Sizeof(EventHeaderExtendedDataItem{}))),
+	), nil
 }
 
 const traceEventInfoDefaultBufferSize = uint32(8192)
 
 func buildTraceEventInfo(eventRecord *EventRecord, bufferSize uint32) (*TraceEventInfo, uint32, error) {
-	var traceEventInfo *TraceEventInfo
-	defaultBuffer := make([]byte, bufferSize)
-	traceEventInfo = (*TraceEventInfo)(unsafe.Pointer(&defaultBuffer[0]))
-	err := TdhGetEventInformation(eventRecord, 0, nil, traceEventInfo, &bufferSize) // returns proper bufferSize if insufficient
+	buffer := make([]byte, bufferSize)
+	err := TdhGetEventInformation(eventRecord, 0, nil, (*TraceEventInfoData)(unsafe.Pointer(&buffer[0])), &bufferSize) // returns proper bufferSize if insufficient
+	if err != nil {
+		return nil, bufferSize, err
+	}
+	if bufferSize < uint32(len(buffer)) {
+		buffer = buffer[:bufferSize]
+	}
+	traceEventInfo, err := NewTraceEventInfo(buffer)
 	return traceEventInfo, bufferSize, err
 }
 
@@ -176,22 +198,27 @@ func (e *EventRecord) GetEventInformation() (*TraceEventInfo, error) {
 	return traceEventInfo, err
 }
 
-func (e *EventRecord) GetMapInfo(pMapName *uint16, decodingSource uint32) (pMapInfo *EventMapInfo, err error) {
+func (e *EventRecord) GetMapInfo(pMapName *uint16, decodingSource uint32) (*EventMapInfo, error) {
 	mapSize := uint32(64)
 	buffer := make([]byte, mapSize)
-	pMapInfo = (*EventMapInfo)(unsafe.Pointer(&buffer[0]))
-	err = TdhGetEventMapInformation(e, pMapName, pMapInfo, &mapSize)
+	err := TdhGetEventMapInformation(e, pMapName, (*EventMapInfoData)(unsafe.Pointer(&buffer[0])), &mapSize)
 
 	if err == syscall.ERROR_INSUFFICIENT_BUFFER {
 		buffer = make([]byte, mapSize)
-		pMapInfo = (*EventMapInfo)(unsafe.Pointer(&buffer[0]))
-		err = TdhGetEventMapInformation(e, pMapName, pMapInfo, &mapSize)
+		err = TdhGetEventMapInformation(e, pMapName, (*EventMapInfoData)(unsafe.Pointer(&buffer[0])), &mapSize)
 	}
 
 	if err == syscall.ERROR_NOT_FOUND {
+		buffer = make([]byte, unsafe.Sizeof(EventMapInfoData{})) // no entry
 		err = nil
 	}
-	return
+	if err != nil {
+		return nil, err
+	}
+	if mapSize < uint32(len(buffer)) {
+		buffer = buffer[:mapSize]
+	}
+	return NewEventMapInfo(buffer)
 }
 
 func (e *EventRecord) PointerSize() uint32 {
@@ -210,21 +237,6 @@ type EventHeaderExtendedDataItem struct {
 	DataPtr        uintptr
 }
 
-// https://learn.microsoft.com/en-us/windows/win32/api/evntcons/ns-evntcons-event_header
-type EventHeader struct {
-	Size            uint16
-	HeaderType      uint16
-	Flags           uint16
-	EventProperty   uint16
-	ThreadId        uint32
-	ProcessId       uint32
-	TimeStamp       int64
-	ProviderId      syscall.GUID
-	EventDescriptor EventDescriptor
-	Time            int64
-	ActivityId      syscall.GUID
-}
-
 func ConvertInt64Timestamp(timestamp int64) time.Time {
 	lower := uint32(timestamp)
 	upper := uint32(timestamp >> 32)
@@ -235,17 +247,6 @@ func ConvertInt64Timestamp(timestamp int64) time.Time {
 	return time.Unix(0, filetime.Nanoseconds()).UTC()
 }
 
-// https://learn.microsoft.com/en-us/windows/win32/api/evntprov/ns-evntprov-event_descriptor
-type EventDescriptor struct {
-	Id      uint16
-	Version uint8
-	Channel uint8
-	Level   uint8
-	Opcode  uint8
-	Task    uint16
-	Keyword uint64
-}
-
 type EventTrace struct {
 	Header           EventTraceHeader
 	InstanceId       uint32
@@ -256,11 +257,6 @@ type EventTrace struct {
 	UnionCtx         uint32
 }
 
-type BufferContext struct {
-	Union    uint16
-	LoggerId uint16
-}
-
 type EventTraceHeader struct {
 	Size      uint16
 	Union1    uint16
@@ -0,0 +1,206 @@
+package winapi
+
+import (
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/evntcons/ns-evntcons-event_header#members
+const (
+	EVENT_HEADER_FLAG_EXTENDED_INFO   = 0x0001
+	EVENT_HEADER_FLAG_PRIVATE_SESSION = 0x0002
+	EVENT_HEADER_FLAG_STRING_ONLY     = 0x0004
+	EVENT_HEADER_FLAG_TRACE_MESSAGE   = 0x0008
+	EVENT_HEADER_FLAG_NO_CPUTIME      = 0x0010
+	EVENT_HEADER_FLAG_32_BIT_HEADER   = 0x0020
+	EVENT_HEADER_FLAG_64_BIT_HEADER   = 0x0040
+	EVENT_HEADER_FLAG_DECODE_GUID     = 0x0080
+	EVENT_HEADER_FLAG_CLASSIC_HEADER  = 0x0100
+	EVENT_HEADER_FLAG_PROCESSOR_INDEX = 0x0200
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/evntcons/ns-evntcons-event_header#members
+const (
+	EVENT_HEADER_PROPERTY_XML             = 0x0001
+	EVENT_HEADER_PROPERTY_FORWARDED_XML   = 0x0002
+	EVENT_HEADER_PROPERTY_LEGACY_EVENTLOG = 0x0004
+	EVENT_HEADER_PROPERTY_RELOGGABLE      = 0x0008
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/evntcons/ns-evntcons-event_header_extended_data_item#members
+const (
+	EVENT_HEADER_EXT_TYPE_RELATED_ACTIVITYID = 0x0001
+	EVENT_HEADER_EXT_TYPE_SID                = 0x0002
+	EVENT_HEADER_EXT_TYPE_TS_ID              = 0x0003
+	EVENT_HEADER_EXT_TYPE_INSTANCE_INFO      = 0x0004
+	EVENT_HEADER_EXT_TYPE_STACK_TRACE32      = 0x0005
+	EVENT_HEADER_EXT_TYPE_STACK_TRACE64      = 0x0006
+	EVENT_HEADER_EXT_TYPE_PEBS_INDEX         = 0x0007
+	EVENT_HEADER_EXT_TYPE_PMC_COUNTERS       = 0x0008
+	EVENT_HEADER_EXT_TYPE_PSM_KEY            = 0x0009
+	EVENT_HEADER_EXT_TYPE_EVENT_KEY          = 0x000A
+	EVENT_HEADER_EXT_TYPE_EVENT_SCHEMA_TL    = 0x000B
+	EVENT_HEADER_EXT_TYPE_PROV_TRAITS        = 0x000C
+	EVENT_HEADER_EXT_TYPE_PROCESS_START_KEY  = 0x000D
+	EVENT_HEADER_EXT_TYPE_CONTROL_GUID       = 0x000E
+	EVENT_HEADER_EXT_TYPE_QPC_DELTA          = 0x000F
+	EVENT_HEADER_EXT_TYPE_CONTAINER_ID       = 0x0010
+	EVENT_HEADER_EXT_TYPE_STACK_KEY32        = 0x0011
+	EVENT_HEADER_EXT_TYPE_STACK_KEY64        = 0x0012
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/evntcons/ns-evntcons-event_header
+type EventHeader struct {
+	Size            uint16
+	HeaderType      uint16
+	Flags           uint16
+	EventProperty   uint16
+	ThreadId        uint32
+	ProcessId       uint32
+	TimeStamp       int64
+	ProviderId      winguid.GUID
+	EventDescriptor EventDescriptor
+	Time            int64
+	ActivityId      winguid.GUID
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/evntprov/ns-evntprov-event_descriptor
+type EventDescriptor struct {
+	Id      uint16
+	Version uint8
+	Channel uint8
+	Level   uint8
+	Opcode  uint8
+	Task    uint16
+	Keyword uint64
+}
+
+type BufferContext struct {
+	Union    uint16
+	LoggerId uint16
+}
+
+// HeaderFlags are the EVENT_HEADER_FLAG bits of EventHeader.Flags.
+type HeaderFlags uint16
+
+func (f HeaderFlags) has(flag uint16) bool {
+	return uint16(f)&flag == flag
+}
+
+func (f HeaderFlags) HasExtendedInfo() bool {
+	return f.has(EVENT_HEADER_FLAG_EXTENDED_INFO)
+}
+
+// IsPrivateSession tells that the header holds the processor time rather than the kernel and user times.
+func (f HeaderFlags) IsPrivateSession() bool {
+	return f.has(EVENT_HEADER_FLAG_PRIVATE_SESSION)
+}
+
+// IsStringOnly tells that the user data is a null-terminated UTF-16 string, logged by EventWriteString.
+func (f HeaderFlags) IsStringOnly() bool {
+	return f.has(EVENT_HEADER_FLAG_STRING_ONLY)
+}
+
+// IsTraceMessage tells that the event was logged by TraceMessage, as WPP does.
+func (f HeaderFlags) IsTraceMessage() bool {
+	return f.has(EVENT_HEADER_FLAG_TRACE_MESSAGE)
+}
+
+func (f HeaderFlags) HasNoCPUTime() bool {
+	return f.has(EVENT_HEADER_FLAG_NO_CPUTIME)
+}
+
+func (f HeaderFlags) Is32BitHeader() bool {
+	return f.has(EVENT_HEADER_FLAG_32_BIT_HEADER)
+}
+
+func (f HeaderFlags) Is64BitHeader() bool {
+	return f.has(EVENT_HEADER_FLAG_64_BIT_HEADER)
+}
+
+func (f HeaderFlags) IsDecodeGUID() bool {
+	return f.has(EVENT_HEADER_FLAG_DECODE_GUID)
+}
+
+// IsClassic tells that the event was logged by a MOF provider.
+func (f HeaderFlags) IsClassic() bool {
+	return f.has(EVENT_HEADER_FLAG_CLASSIC_HEADER)
+}
+
+// HasProcessorIndex tells that BufferContext holds a 16 bits processor index.
+func (f HeaderFlags) HasProcessorIndex() bool {
+	return f.has(EVENT_HEADER_FLAG_PROCESSOR_INDEX)
+}
+
+// EventPropertyFlags are the EVENT_HEADER_PROPERTY bits of EventHeader.EventProperty.
+type EventPropertyFlags uint16
+
+func (f EventPropertyFlags) IsXML() bool {
+	return uint16(f)&EVENT_HEADER_PROPERTY_XML != 0
+}
+
+func (f EventPropertyFlags) IsForwardedXML() bool {
+	return uint16(f)&EVENT_HEADER_PROPERTY_FORWARDED_XML != 0
+}
+
+func (f EventPropertyFlags) IsLegacyEventLog() bool {
+	return uint16(f)&EVENT_HEADER_PROPERTY_LEGACY_EVENTLOG != 0
+}
+
+func (f EventPropertyFlags) IsReloggable() bool {
+	return uint16(f)&EVENT_HEADER_PROPERTY_RELOGGABLE != 0
+}
+
+func (h *EventHeader) HeaderFlags() HeaderFlags {
+	return HeaderFlags(h.Flags)
+}
+
+func (h *EventHeader) EventPropertyFlags() EventPropertyFlags {
+	return EventPropertyFlags(h.EventProperty)
+}
+
+// KernelTime and UserTime share the Time union, in CPU ticks, unless the flags tell otherwise.
+func (h *EventHeader) KernelTime() (uint32, bool) {
+	if h.HeaderFlags().HasNoCPUTime() || h.HeaderFlags().IsPrivateSession() {
+		return 0, false
+	}
+	return uint32(h.Time), true
+}
+
+func (h *EventHeader) UserTime() (uint32, bool) {
+	if h.HeaderFlags().HasNoCPUTime() || h.HeaderFlags().IsPrivateSession() {
+		return 0, false
+	}
+	return uint32(uint64(h.Time) >> 32), true
+}
+
+// ProcessorTime is the Time union of private sessions.
+func (h *EventHeader) ProcessorTime() (uint64, bool) {
+	if h.HeaderFlags().HasNoCPUTime() || !h.HeaderFlags().IsPrivateSession() {
+		return 0, false
+	}
+	return uint64(h.Time), true
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/relogger/ns-relogger-etw_buffer_context
+
+// ProcessorNumber is the processor the event was logged on, when the header has no processor index.
+func (b *BufferContext) ProcessorNumber() uint8 {
+	return uint8(b.Union)
+}
+
+func (b *BufferContext) Alignment() uint8 {
+	return uint8(b.Union >> 8)
+}
+
+// ProcessorIndex is the processor the event was logged on, with EVENT_HEADER_FLAG_PROCESSOR_INDEX.
+func (b *BufferContext) ProcessorIndex() uint16 {
+	return b.Union
+}
+
+// Processor returns the processor the event was logged on, as an index or a number depending on the flags.
+func (b *BufferContext) Processor(flags HeaderFlags) uint16 {
+	if flags.HasProcessorIndex() {
+		return b.ProcessorIndex()
+	}
+	return uint16(b.ProcessorNumber())
+}
@@ -0,0 +1,84 @@
+package winapi
+
+import "testing"
+
+func TestHeaderFlags(t *testing.T) {
+	flags := HeaderFlags(EVENT_HEADER_FLAG_STRING_ONLY | EVENT_HEADER_FLAG_32_BIT_HEADER | EVENT_HEADER_FLAG_CLASSIC_HEADER)
+
+	tests := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{"HasExtendedInfo", flags.HasExtendedInfo(), false},
+		{"IsPrivateSession", flags.IsPrivateSession(), false},
+		{"IsStringOnly", flags.IsStringOnly(), true},
+		{"IsTraceMessage", flags.IsTraceMessage(), false},
+		{"HasNoCPUTime", flags.HasNoCPUTime(), false},
+		{"Is32BitHeader", flags.Is32BitHeader(), true},
+		{"Is64BitHeader", flags.Is64BitHeader(), false},
+		{"IsDecodeGUID", flags.IsDecodeGUID(), false},
+		{"IsClassic", flags.IsClassic(), true},
+		{"HasProcessorIndex", flags.HasProcessorIndex(), false},
+		{"TraceMessage.IsTraceMessage", HeaderFlags(EVENT_HEADER_FLAG_TRACE_MESSAGE).IsTraceMessage(), true},
+		{"PrivateSession.IsPrivateSession", HeaderFlags(EVENT_HEADER_FLAG_PRIVATE_SESSION).IsPrivateSession(), true},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s() = %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestEventPropertyFlags(t *testing.T) {
+	flags := EventPropertyFlags(EVENT_HEADER_PROPERTY_XML | EVENT_HEADER_PROPERTY_RELOGGABLE)
+	if !flags.IsXML() || flags.IsForwardedXML() || flags.IsLegacyEventLog() || !flags.IsReloggable() {
+		t.Errorf("flags %#x: xml %v, forwarded %v, legacy %v, reloggable %v",
+			uint16(flags), flags.IsXML(), flags.IsForwardedXML(), flags.IsLegacyEventLog(), flags.IsReloggable())
+	}
+}
+
+func TestEventHeaderCPUTime(t *testing.T) {
+	const time = int64(0x0000000200000001)
+
+	tests := []struct {
+		name          string
+		flags         uint16
+		kernelTime    uint32
+		userTime      uint32
+		processorTime uint64
+		perThread     bool
+		private       bool
+	}{
+		{"kernel and user times", 0, 1, 2, 0, true, false},
+		{"private session", EVENT_HEADER_FLAG_PRIVATE_SESSION, 0, 0, uint64(time), false, true},
+		{"no CPU time", EVENT_HEADER_FLAG_NO_CPUTIME, 0, 0, 0, false, false},
+	}
+
+	for _, test := range tests {
+		header := EventHeader{Flags: test.flags, Time: time}
+		kernelTime, kernelOk := header.KernelTime()
+		userTime, userOk := header.UserTime()
+		processorTime, processorOk := header.ProcessorTime()
+		if kernelTime != test.kernelTime || userTime != test.userTime || kernelOk != test.perThread || userOk != test.perThread {
+			t.Errorf("%s: kernel %d %v, user %d %v", test.name, kernelTime, kernelOk, userTime, userOk)
+		}
+		if processorTime != test.processorTime || processorOk != test.private {
+			t.Errorf("%s: processor %d %v", test.name, processorTime, processorOk)
+		}
+	}
+}
+
+func TestBufferContextProcessor(t *testing.T) {
+	context := BufferContext{Union: 0x0803, LoggerId: 7}
+
+	if context.ProcessorNumber() != 3 || context.Alignment() != 8 {
+		t.Errorf("number %d, alignment %d, want 3 and 8", context.ProcessorNumber(), context.Alignment())
+	}
+	if processor := context.Processor(0); processor != 3 {
+		t.Errorf("Processor without index = %d, want 3", processor)
+	}
+	if processor := context.Processor(EVENT_HEADER_FLAG_PROCESSOR_INDEX); processor != 0x0803 {
+		t.Errorf("Processor with index = %#x, want 0x803", processor)
+	}
+}
@@ -0,0 +1,25 @@
+package winapi
+
+import (
+	"time"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/minwinbase/ns-minwinbase-filetime
+
+const (
+	filetimeTicksPerSecond = 10_000_000
+	filetimeToUnixSeconds  = 11_644_473_600 // seconds between 1601-01-01 and 1970-01-01
+)
+
+// FiletimeToTime converts a count of 100-nanosecond intervals since 1601-01-01 UTC to a UTC time.
+// Unlike ConvertInt64Timestamp, it does not depend on syscall and covers the whole FILETIME range.
+func FiletimeToTime(filetime int64) time.Time {
+	seconds := filetime / filetimeTicksPerSecond
+	nanoseconds := (filetime % filetimeTicksPerSecond) * 100
+	return time.Unix(seconds-filetimeToUnixSeconds, nanoseconds).UTC()
+}
+
+// TimeToFiletime is the inverse of FiletimeToTime.
+func TimeToFiletime(t time.Time) int64 {
+	return (t.Unix()+filetimeToUnixSeconds)*filetimeTicksPerSecond + int64(t.Nanosecond())/100
+}
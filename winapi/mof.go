@@ -0,0 +1,72 @@
+package winapi
+
+import (
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// ManagedObjectFormatClass describes a kernel MOF class. Classes with Layouts decode without the WMI repository,
+// see mof_classes.go.
+type ManagedObjectFormatClass struct {
+	Name       string
+	EventTypes map[uint8]string // event type names, by opcode
+	Layouts    []ManagedObjectFormatLayout
+}
+
+// Windows SDK // https://github.com/tpn/winsdk-10/blob/master/Include/10.0.10240.0/km/wmiguid.h
+// https://learn.microsoft.com/en-us/windows/win32/etw/event-tracing-mof-classes
+// https://learn.microsoft.com/en-us/windows/win32/etw/nt-kernel-logger-constants
+// https://learn.microsoft.com/en-us/windows/win32/wmisdk/designing-managed-object-format--mof--classes
+var (
+	ManagedObjectFormatMapping = map[uint32]ManagedObjectFormatClass{
+		1171836109: {Name: "ALPC"},
+		2026983191: {Name: "ApplicationVerifier"},
+		328690953:  {Name: "DbgPrint"},
+		1030727892: {Name: "DiskIo", EventTypes: diskIoEventTypes, Layouts: diskIoLayouts},
+		3185075665: {Name: "DiskPerf"},
+		3580666929: {Name: "DriverVerifier"},
+		2976882526: {Name: "EventLog"},
+		25508453:   {Name: "EventTraceConfig"},
+		2429279289: {Name: "FileIo", EventTypes: fileIoEventTypes, Layouts: fileIoLayouts},
+		2369794079: {Name: "GenericMessage"},
+		3901786812: {Name: "GlobalLogger"},
+		1030727890: {Name: "HardFault"},
+		749821213:  {Name: "ImageLoad", EventTypes: imageLoadEventTypes, Layouts: imageLoadLayouts},
+		2560801239: {Name: "MsSystemInformation"},
+		1030727891: {Name: "PageFault", EventTypes: pageFaultEventTypes, Layouts: pageFaultLayouts},
+		3458056116: {Name: "PerfInfo"},
+		1030727888: {Name: "Process", EventTypes: processEventTypes, Layouts: processLayouts},
+		2924704302: {Name: "Registry", EventTypes: registryEventTypes, Layouts: registryLayouts},
+		3627534994: {Name: "SplitIo"},
+		2586315456: {Name: "TcpIp", EventTypes: tcpIpEventTypes, Layouts: tcpIpLayouts},
+		2713458880: {Name: "ThermalZone"},
+		1030727889: {Name: "Thread", EventTypes: threadEventTypes, Layouts: threadLayouts},
+		964792796:  {Name: "TraceError"},
+		3208270021: {Name: "UdpIp", EventTypes: udpIpEventTypes, Layouts: udpIpLayouts},
+		1147177553: {Name: "WmiEventLogger"},
+		0x68fdd900: {Name: "EventTraceEvent"},
+	}
+)
+
+// Kernel events logged with system, compact and perfinfo headers carry a hook ID rather than a GUID.
+// Its high byte is the EVENT_TRACE_GROUP of the event, which designates the MOF class.
+// https://learn.microsoft.com/en-us/windows/win32/etw/nt-kernel-logger-constants
+var (
+	KernelEventGroupMapping = map[uint8]winguid.GUID{
+		0x00: *winguid.MustParse("{68FDD900-4A3E-11D1-84F4-0000F80464E3}"), // EventTraceEvent
+		0x01: *winguid.MustParse("{3D6FA8D4-FE05-11D0-9DDA-00C04FD7BA7C}"), // DiskIo
+		0x02: *winguid.MustParse("{3D6FA8D3-FE05-11D0-9DDA-00C04FD7BA7C}"), // PageFault
+		0x03: *winguid.MustParse("{3D6FA8D0-FE05-11D0-9DDA-00C04FD7BA7C}"), // Process
+		0x04: *winguid.MustParse("{90CBDC39-4A3E-11D1-84F4-0000F80464E3}"), // FileIo
+		0x05: *winguid.MustParse("{3D6FA8D1-FE05-11D0-9DDA-00C04FD7BA7C}"), // Thread
+		0x06: *winguid.MustParse("{9A280AC0-C8E0-11D1-84E2-00C04FB998A2}"), // TcpIp
+		0x08: *winguid.MustParse("{BF3A50C5-A9C9-4988-A005-2DF0B7C80F80}"), // UdpIp
+		0x09: *winguid.MustParse("{AE53722E-C863-11D2-8659-00C04FA321A1}"), // Registry
+		0x0A: *winguid.MustParse("{13976D09-A327-438C-950B-7F03192815C7}"), // DbgPrint
+		0x0B: *winguid.MustParse("{01853A65-418F-4F36-AEFC-DC0F1D2FD235}"), // EventTraceConfig
+		0x0F: *winguid.MustParse("{CE1DBFB4-137E-4DA6-87B0-3F59AA102CBC}"), // PerfInfo
+		0x14: *winguid.MustParse("{2CB15D1D-5FC1-11D2-ABE1-00A0C911F518}"), // ImageLoad
+		0x18: *winguid.MustParse("{DEF2FE46-7BD6-4B80-BD94-F57FE20D0CE3}"), // StackWalk
+		0x1A: *winguid.MustParse("{45D8CCCD-539F-4B72-A8B7-5C683142609A}"), // ALPC
+		0x1B: *winguid.MustParse("{D837CA92-12B9-44A5-AD6A-3A65B3578AA8}"), // SplitIo
+	}
+)
@@ -0,0 +1,579 @@
+package winapi
+
+// Layouts of the kernel MOF classes, as published on learn.microsoft.com. The MOF definitions qualify
+// pointer sized members with "pointer": they occupy 4 or 8 bytes, following EVENT_HEADER_FLAG_32_BIT_HEADER.
+// https://learn.microsoft.com/en-us/windows/win32/etw/msnt-systemtrace
+
+// ManagedObjectFormatLayout is the user data layout a class version shares between event types,
+// such as Process_V4_TypeGroup1.
+type ManagedObjectFormatLayout struct {
+	Name       string
+	Version    uint8
+	EventTypes []uint8
+	Fields     []ManagedObjectFormatField
+}
+
+type ManagedObjectFormatField struct {
+	Name    string
+	InType  TdhInType
+	OutType TdhOutType
+	Length  uint16 // of fixed size binary fields
+}
+
+// Layout returns the layout of eventType in version of the class, or with exact false and no such version,
+// in the latest earlier version.
+func (c ManagedObjectFormatClass) Layout(eventType uint8, version uint8, exact bool) (*ManagedObjectFormatLayout, bool) {
+	var closest *ManagedObjectFormatLayout
+	for i := range c.Layouts {
+		layout := &c.Layouts[i]
+		if !layout.hasEventType(eventType) || layout.Version > version {
+			continue
+		}
+		if layout.Version == version {
+			return layout, true
+		}
+		if !exact && (closest == nil || layout.Version > closest.Version) {
+			closest = layout
+		}
+	}
+	return closest, closest != nil
+}
+
+func (l *ManagedObjectFormatLayout) hasEventType(eventType uint8) bool {
+	for _, t := range l.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func mofField(name string, inType TdhInType, outType TdhOutType) ManagedObjectFormatField {
+	return ManagedObjectFormatField{Name: name, InType: inType, OutType: outType}
+}
+
+func mofPointer(name string) ManagedObjectFormatField {
+	return mofField(name, TdhInTypePointer, TdhOutTypeNull)
+}
+
+func mofUint32(name string) ManagedObjectFormatField {
+	return mofField(name, TdhInTypeUint32, TdhOutTypeNull)
+}
+
+func mofIPv6(name string) ManagedObjectFormatField {
+	return ManagedObjectFormatField{Name: name, InType: TdhInTypeBinary, OutType: TdhOutTypeIpv6, Length: 16}
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/etw/process
+var (
+	processEventTypes = map[uint8]string{
+		1: "Start", 2: "End", 3: "DCStart", 4: "DCEnd", 11: "Terminate", 39: "Defunct",
+	}
+
+	processLayouts = []ManagedObjectFormatLayout{
+		{
+			Name: "Process_V2_TypeGroup1", Version: 2, EventTypes: []uint8{1, 2, 3, 4, 39},
+			Fields: []ManagedObjectFormatField{
+				mofPointer("UniqueProcessKey"),
+				mofUint32("ProcessId"),
+				mofUint32("ParentId"),
+				mofUint32("SessionId"),
+				mofField("ExitStatus", TdhInTypeInt32, TdhOutTypeNull),
+				mofField("UserSID", TdhInTypeWbemsid, TdhOutTypeNull),
+				mofField("ImageFileName", TdhInTypeAnsistring, TdhOutTypeNull),
+				mofField("CommandLine", TdhInTypeUnicodestring, TdhOutTypeNull),
+			},
+		},
+		{
+			Name: "Process_V3_TypeGroup1", Version: 3, EventTypes: []uint8{1, 2, 3, 4, 39},
+			Fields: []ManagedObjectFormatField{
+				mofPointer("UniqueProcessKey"),
+				mofUint32("ProcessId"),
+				mofUint32("ParentId"),
+				mofUint32("SessionId"),
+				mofField("ExitStatus", TdhInTypeInt32, TdhOutTypeNull),
+				mofPointer("DirectoryTableBase"),
+				mofField("UserSID", TdhInTypeWbemsid, TdhOutTypeNull),
+				mofField("ImageFileName", TdhInTypeAnsistring, TdhOutTypeNull),
+				mofField("CommandLine", TdhInTypeUnicodestring, TdhOutTypeNull),
+			},
+		},
+		{
+			Name: "Process_V4_TypeGroup1", Version: 4, EventTypes: []uint8{1, 2, 3, 4, 39},
+			Fields: []ManagedObjectFormatField{
+				mofPointer("UniqueProcessKey"),
+				mofUint32("ProcessId"),
+				mofUint32("ParentId"),
+				mofUint32("SessionId"),
+				mofField("ExitStatus", TdhInTypeInt32, TdhOutTypeNull),
+				mofPointer("DirectoryTableBase"),
+				mofUint32("Flags"),
+				mofField("UserSID", TdhInTypeWbemsid, TdhOutTypeNull),
+				mofField("ImageFileName", TdhInTypeAnsistring, TdhOutTypeNull),
+				mofField("CommandLine", TdhInTypeUnicodestring, TdhOutTypeNull),
+				mofField("PackageFullName", TdhInTypeUnicodestring, TdhOutTypeNull),
+				mofField("ApplicationId", TdhInTypeUnicodestring, TdhOutTypeNull),
+			},
+		},
+		{
+			Name: "Process_Terminate_TypeGroup1", Version: 2, EventTypes: []uint8{11},
+			Fields: []ManagedObjectFormatField{
+				mofUint32("ProcessId"),
+			},
+		},
+	}
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/etw/thread
+var (
+	threadEventTypes = map[uint8]string{
+		1: "Start", 2: "End", 3: "DCStart", 4: "DCEnd", 36: "CSwitch", 50: "ReadyThread", 72: "SetName",
+	}
+
+	threadLayouts = []ManagedObjectFormatLayout{
+		{
+			Name: "Thread_V2_TypeGroup1", Version: 2, EventTypes: []uint8{1, 2, 3, 4},
+			Fields: []ManagedObjectFormatField{
+				mofUint32("ProcessId"),
+				mofUint32("TThreadId"),
+				mofPointer("StackBase"),
+				mofPointer("StackLimit"),
+				mofPointer("UserStackBase"),
+				mofPointer("UserStackLimit"),
+				mofPointer("StartAddr"),
+				mofPointer("Win32StartAddr"),
+				mofPointer("TebBase"),
+				mofUint32("SubProcessTag"),
+			},
+		},
+		{
+			Name: "Thread_V3_TypeGroup1", Version: 3, EventTypes: []uint8{1, 2, 3, 4},
+			Fields: []ManagedObjectFormatField{
+				mofUint32("ProcessId"),
+				mofUint32("TThreadId"),
+				mofPointer("StackBase"),
+				mofPointer("StackLimit"),
+				mofPointer("UserStackBase"),
+				mofPointer("UserStackLimit"),
+				mofPointer("Affinity"),
+				mofPointer("Win32StartAddr"),
+				mofPointer("TebBase"),
+				mofUint32("SubProcessTag"),
+				mofField("BasePriority", TdhInTypeUint8, TdhOutTypeNull),
+				mofField("PagePriority", TdhInTypeUint8, TdhOutTypeNull),
+				mofField("IoPriority", TdhInTypeUint8, TdhOutTypeNull),
+				mofField("ThreadFlags", TdhInTypeUint8, TdhOutTypeNull),
+			},
+		},
+		{
+			Name: "CSwitch", Version: 2, EventTypes: []uint8{36},
+			Fields: []ManagedObjectFormatField{
+				mofUint32("NewThreadId"),
+				mofUint32("OldThreadId"),
+				mofField("NewThreadPriority", TdhInTypeInt8, TdhOutTypeNull),
+				mofField("OldThreadPriority", TdhInTypeInt8, TdhOutTypeNull),
+				mofField("PreviousCState", TdhInTypeUint8, TdhOutTypeNull),
+				mofField("SpareByte", TdhInTypeInt8, TdhOutTypeNull),
+				mofField("OldThreadWaitReason", TdhInTypeInt8, TdhOutTypeNull),
+				mofField("OldThreadWaitMode", TdhInTypeInt8, TdhOutTypeNull),
+				mofField("OldThreadState", TdhInTypeInt8, TdhOutTypeNull),
+				mofField("OldThreadWaitIdealProcessor", TdhInTypeInt8, TdhOutTypeNull),
+				mofUint32("NewThreadWaitTime"),
+				mofUint32("Reserved"),
+			},
+		},
+		{
+			Name: "ReadyThread", Version: 2, EventTypes: []uint8{50},
+			Fields: []ManagedObjectFormatField{
+				mofUint32("TThreadId"),
+				mofField("AdjustReason", TdhInTypeInt8, TdhOutTypeNull),
+				mofField("AdjustIncrement", TdhInTypeInt8, TdhOutTypeNull),
+				mofField("Flag", TdhInTypeInt8, TdhOutTypeNull),
+				mofField("Reserved", TdhInTypeInt8, TdhOutTypeNull),
+			},
+		},
+		{
+			Name: "Thread_SetName", Version: 2, EventTypes: []uint8{72},
+			Fields: []ManagedObjectFormatField{
+				mofUint32("ProcessId"),
+				mofUint32("ThreadId"),
+				mofField("ThreadName", TdhInTypeUnicodestring, TdhOutTypeNull),
+			},
+		},
+	}
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/etw/image-load
+var (
+	imageLoadEventTypes = map[uint8]string{
+		2: "Unload", 3: "DCStart", 4: "DCEnd", 10: "Load",
+	}
+
+	imageLoadLayouts = []ManagedObjectFormatLayout{
+		{
+			Name: "Image_Load_V2", Version: 2, EventTypes: []uint8{2, 3, 4, 10},
+			Fields: []ManagedObjectFormatField{
+				mofPointer("ImageBase"),
+				mofPointer("ImageSize"),
+				mofUint32("ProcessId"),
+				mofUint32("ImageChecksum"),
+				mofUint32("TimeDateStamp"),
+				mofUint32("Reserved0"),
+				mofPointer("DefaultBase"),
+				mofUint32("Reserved1"),
+				mofUint32("Reserved2"),
+				mofUint32("Reserved3"),
+				mofUint32("Reserved4"),
+				mofField("FileName", TdhInTypeUnicodestring, TdhOutTypeNull),
+			},
+		},
+		{
+			Name: "Image_Load_V3", Version: 3, EventTypes: []uint8{2, 3, 4, 10},
+			Fields: []ManagedObjectFormatField{
+				mofPointer("ImageBase"),
+				mofPointer("ImageSize"),
+				mofUint32("ProcessId"),
+				mofUint32("ImageChecksum"),
+				mofUint32("TimeDateStamp"),
+				mofField("SignatureLevel", TdhInTypeUint8, TdhOutTypeNull),
+				mofField("SignatureType", TdhInTypeUint8, TdhOutTypeNull),
+				mofField("Reserved0", TdhInTypeUint16, TdhOutTypeNull),
+				mofPointer("DefaultBase"),
+				mofUint32("Reserved1"),
+				mofUint32("Reserved2"),
+				mofUint32("Reserved3"),
+				mofUint32("Reserved4"),
+				mofField("FileName", TdhInTypeUnicodestring, TdhOutTypeNull),
+			},
+		},
+	}
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/etw/fileio
+// Version 3 replaces the TTID pointer of version 2 by an IssuingThreadId after the file key.
+var (
+	fileIoEventTypes = map[uint8]string{
+		0: "Name", 32: "FileCreate", 35: "FileDelete", 36: "FileRundown",
+		64: "Create", 65: "Cleanup", 66: "Close", 67: "Read", 68: "Write", 69: "SetInfo", 70: "Delete",
+		71: "Rename", 72: "DirEnum", 73: "Flush", 74: "QueryInfo", 75: "FSControl", 76: "OperationEnd", 77: "DirNotify",
+	}
+
+	fileIoName = ManagedObjectFormatLayout{
+		Name: "FileIo_Name", EventTypes: []uint8{0, 32, 35, 36},
+		Fields: []ManagedObjectFormatField{
+			mofPointer("FileObject"),
+			mofField("FileName", TdhInTypeUnicodestring, TdhOutTypeNull),
+		},
+	}
+
+	fileIoOpEnd = ManagedObjectFormatLayout{
+		Name: "FileIo_OpEnd", EventTypes: []uint8{76},
+		Fields: []ManagedObjectFormatField{
+			mofPointer("IrpPtr"),
+			mofPointer("ExtraInfo"),
+			mofField("NtStatus", TdhInTypeUint32, TdhOutTypeNtstatus),
+		},
+	}
+
+	fileIoLayouts = []ManagedObjectFormatLayout{
+		withVersion(fileIoName, 2),
+		withVersion(fileIoName, 3),
+		withVersion(fileIoOpEnd, 2),
+		withVersion(fileIoOpEnd, 3),
+		{
+			Name: "FileIo_Create", Version: 2, EventTypes: []uint8{64},
+			Fields: []ManagedObjectFormatField{
+				mofPointer("IrpPtr"),
+				mofPointer("TTID"),
+				mofPointer("FileObject"),
+				mofUint32("CreateOptions"),
+				mofUint32("FileAttributes"),
+				mofUint32("ShareAccess"),
+				mofField("OpenPath", TdhInTypeUnicodestring, TdhOutTypeNull),
+			},
+		},
+		{
+			Name: "FileIo_Create", Version: 3, EventTypes: []uint8{64},
+			Fields: []ManagedObjectFormatField{
+				mofPointer("IrpPtr"),
+				mofPointer("FileObject"),
+				mofUint32("IssuingThreadId"),
+				mofUint32("CreateOptions"),
+				mofUint32("FileAttributes"),
+				mofUint32("ShareAccess"),
+				mofField("OpenPath", TdhInTypeUnicodestring, TdhOutTypeNull),
+			},
+		},
+		{
+			Name: "FileIo_ReadWrite", Version: 2, EventTypes: []uint8{67, 68},
+			Fields: []ManagedObjectFormatField{
+				mofField("Offset", TdhInTypeUint64, TdhOutTypeNull),
+				mofPointer("IrpPtr"),
+				mofPointer("TTID"),
+				mofPointer("FileObject"),
+				mofPointer("FileKey"),
+				mofUint32("IoSize"),
+				mofUint32("IoFlags"),
+			},
+		},
+		{
+			Name: "FileIo_ReadWrite", Version: 3, EventTypes: []uint8{67, 68},
+			Fields: []ManagedObjectFormatField{
+				mofField("Offset", TdhInTypeUint64, TdhOutTypeNull),
+				mofPointer("IrpPtr"),
+				mofPointer("FileObject"),
+				mofPointer("FileKey"),
+				mofUint32("IssuingThreadId"),
+				mofUint32("IoSize"),
+				mofUint32("IoFlags"),
+				mofUint32("ExtraFlags"),
+			},
+		},
+		{
+			Name: "FileIo_SimpleOp", Version: 2, EventTypes: []uint8{65, 66, 73},
+			Fields: []ManagedObjectFormatField{
+				mofPointer("IrpPtr"),
+				mofPointer("TTID"),
+				mofPointer("FileObject"),
+				mofPointer("FileKey"),
+			},
+		},
+		{
+			Name: "FileIo_SimpleOp", Version: 3, EventTypes: []uint8{65, 66, 73},
+			Fields: []ManagedObjectFormatField{
+				mofPointer("IrpPtr"),
+				mofPointer("FileObject"),
+				mofPointer("FileKey"),
+				mofUint32("IssuingThreadId"),
+			},
+		},
+		{
+			Name: "FileIo_Info", Version: 2, EventTypes: []uint8{69, 70, 71, 74, 75},
+			Fields: []ManagedObjectFormatField{
+				mofPointer("IrpPtr"),
+				mofPointer("TTID"),
+				mofPointer("FileObject"),
+				mofPointer("FileKey"),
+				mofPointer("ExtraInfo"),
+				mofUint32("InfoClass"),
+			},
+		},
+		{
+			Name: "FileIo_Info", Version: 3, EventTypes: []uint8{69, 70, 71, 74, 75},
+			Fields: []ManagedObjectFormatField{
+				mofPointer("IrpPtr"),
+				mofPointer("FileObject"),
+				mofPointer("FileKey"),
+				mofPointer("ExtraInfo"),
+				mofUint32("IssuingThreadId"),
+				mofUint32("InfoClass"),
+			},
+		},
+		{
+			Name: "FileIo_DirEnum", Version: 2, EventTypes: []uint8{72, 77},
+			Fields: []ManagedObjectFormatField{
+				mofPointer("IrpPtr"),
+				mofPointer("TTID"),
+				mofPointer("FileObject"),
+				mofPointer("FileKey"),
+				mofUint32("Length"),
+				mofUint32("InfoClass"),
+				mofUint32("FileIndex"),
+				mofField("FileName", TdhInTypeUnicodestring, TdhOutTypeNull),
+			},
+		},
+		{
+			Name: "FileIo_DirEnum", Version: 3, EventTypes: []uint8{72, 77},
+			Fields: []ManagedObjectFormatField{
+				mofPointer("IrpPtr"),
+				mofPointer("FileObject"),
+				mofPointer("FileKey"),
+				mofUint32("IssuingThreadId"),
+				mofUint32("Length"),
+				mofUint32("InfoClass"),
+				mofUint32("FileIndex"),
+				mofField("FileName", TdhInTypeUnicodestring, TdhOutTypeNull),
+			},
+		},
+	}
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/etw/tcpip
+// Addresses and ports are in network byte order.
+var (
+	tcpIpEventTypes = map[uint8]string{
+		10: "SendIPV4", 11: "RecvIPV4", 12: "ConnectIPV4", 13: "DisconnectIPV4", 14: "RetransmitIPV4",
+		15: "AcceptIPV4", 16: "ReconnectIPV4", 17: "Fail", 18: "TCPCopyIPV4",
+		26: "SendIPV6", 27: "RecvIPV6", 28: "ConnectIPV6", 29: "DisconnectIPV6", 30: "RetransmitIPV6",
+		31: "AcceptIPV6", 32: "ReconnectIPV6", 34: "TCPCopyIPV6",
+	}
+
+	ipV4Endpoints = []ManagedObjectFormatField{
+		mofUint32("PID"),
+		mofUint32("size"),
+		mofField("daddr", TdhInTypeUint32, TdhOutTypeIpv4),
+		mofField("saddr", TdhInTypeUint32, TdhOutTypeIpv4),
+		mofField("dport", TdhInTypeUint16, TdhOutTypePort),
+		mofField("sport", TdhInTypeUint16, TdhOutTypePort),
+	}
+
+	ipV6Endpoints = []ManagedObjectFormatField{
+		mofUint32("PID"),
+		mofUint32("size"),
+		mofIPv6("daddr"),
+		mofIPv6("saddr"),
+		mofField("dport", TdhInTypeUint16, TdhOutTypePort),
+		mofField("sport", TdhInTypeUint16, TdhOutTypePort),
+	}
+
+	sendTimes = []ManagedObjectFormatField{
+		mofUint32("startime"),
+		mofUint32("endtime"),
+	}
+
+	connection = []ManagedObjectFormatField{
+		mofUint32("seqnum"),
+		mofPointer("connid"),
+	}
+
+	connectionOptions = []ManagedObjectFormatField{
+		mofField("mss", TdhInTypeUint16, TdhOutTypeNull),
+		mofField("sackopt", TdhInTypeUint16, TdhOutTypeNull),
+		mofField("tsopt", TdhInTypeUint16, TdhOutTypeNull),
+		mofField("wsopt", TdhInTypeUint16, TdhOutTypeNull),
+		mofUint32("rcvwin"),
+		mofField("rcvwinscale", TdhInTypeInt16, TdhOutTypeNull),
+		mofField("sndwinscale", TdhInTypeInt16, TdhOutTypeNull),
+	}
+
+	tcpIpLayouts = []ManagedObjectFormatLayout{
+		{Name: "TcpIp_SendIPV4", Version: 2, EventTypes: []uint8{10}, Fields: mofFields(ipV4Endpoints, sendTimes, connection)},
+		{Name: "TcpIp_TypeGroup1", Version: 2, EventTypes: []uint8{11, 13, 14, 16, 18}, Fields: mofFields(ipV4Endpoints, connection)},
+		{Name: "TcpIp_TypeGroup2", Version: 2, EventTypes: []uint8{12, 15}, Fields: mofFields(ipV4Endpoints, connectionOptions, connection)},
+		{Name: "TcpIp_SendIPV6", Version: 2, EventTypes: []uint8{26}, Fields: mofFields(ipV6Endpoints, sendTimes, connection)},
+		{Name: "TcpIp_TypeGroup3", Version: 2, EventTypes: []uint8{27, 29, 30, 32, 34}, Fields: mofFields(ipV6Endpoints, connection)},
+		{Name: "TcpIp_TypeGroup4", Version: 2, EventTypes: []uint8{28, 31}, Fields: mofFields(ipV6Endpoints, connectionOptions, connection)},
+		{Name: "TcpIp_Fail", Version: 2, EventTypes: []uint8{17}, Fields: ipFailure},
+	}
+
+	ipFailure = []ManagedObjectFormatField{
+		mofField("Proto", TdhInTypeUint16, TdhOutTypeNull),
+		mofField("FailureCode", TdhInTypeUint16, TdhOutTypeNull),
+	}
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/etw/udpip
+var (
+	udpIpEventTypes = map[uint8]string{
+		10: "SendIPV4", 11: "RecvIPV4", 17: "Fail", 26: "SendIPV6", 27: "RecvIPV6",
+	}
+
+	udpIpLayouts = []ManagedObjectFormatLayout{
+		{Name: "UdpIp_TypeGroup1", Version: 2, EventTypes: []uint8{10, 11}, Fields: mofFields(ipV4Endpoints, connection)},
+		{Name: "UdpIp_TypeGroup2", Version: 2, EventTypes: []uint8{26, 27}, Fields: mofFields(ipV6Endpoints, connection)},
+		{Name: "UdpIp_Fail", Version: 2, EventTypes: []uint8{17}, Fields: ipFailure},
+	}
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/etw/registry
+var (
+	registryEventTypes = map[uint8]string{
+		10: "Create", 11: "Open", 12: "Delete", 13: "Query", 14: "SetValue", 15: "DeleteValue", 16: "QueryValue",
+		17: "EnumerateKey", 18: "EnumerateValueKey", 19: "QueryMultipleValue", 20: "SetInformation", 21: "Flush",
+		22: "KCBCreate", 23: "KCBDelete", 24: "KCBRundownBegin", 25: "KCBRundownEnd", 26: "Virtualize", 27: "Close",
+	}
+
+	registryLayouts = []ManagedObjectFormatLayout{
+		{
+			Name: "Registry_TypeGroup1", Version: 2,
+			EventTypes: []uint8{10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27},
+			Fields: []ManagedObjectFormatField{
+				mofField("InitialTime", TdhInTypeInt64, TdhOutTypeNull),
+				mofField("Status", TdhInTypeUint32, TdhOutTypeNtstatus),
+				mofUint32("Index"),
+				mofPointer("KeyHandle"),
+				mofField("KeyName", TdhInTypeUnicodestring, TdhOutTypeNull),
+			},
+		},
+	}
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/etw/diskio
+var (
+	diskIoEventTypes = map[uint8]string{
+		10: "Read", 11: "Write", 12: "ReadInit", 13: "WriteInit", 14: "FlushBuffers", 15: "FlushInit",
+	}
+
+	diskIoTransfer = []ManagedObjectFormatField{
+		mofUint32("DiskNumber"),
+		mofUint32("IrpFlags"),
+		mofUint32("TransferSize"),
+		mofUint32("Reserved"),
+		mofField("ByteOffset", TdhInTypeInt64, TdhOutTypeNull),
+		mofPointer("FileObject"),
+		mofPointer("Irp"),
+		mofField("HighResResponseTime", TdhInTypeUint64, TdhOutTypeNull),
+	}
+
+	diskIoFlush = []ManagedObjectFormatField{
+		mofUint32("DiskNumber"),
+		mofUint32("IrpFlags"),
+		mofField("HighResResponseTime", TdhInTypeUint64, TdhOutTypeNull),
+		mofPointer("Irp"),
+	}
+
+	issuingThread = []ManagedObjectFormatField{
+		mofUint32("IssuingThreadId"),
+	}
+
+	diskIoLayouts = []ManagedObjectFormatLayout{
+		{Name: "DiskIo_TypeGroup1", Version: 2, EventTypes: []uint8{10, 11}, Fields: diskIoTransfer},
+		{Name: "DiskIo_TypeGroup1", Version: 3, EventTypes: []uint8{10, 11}, Fields: mofFields(diskIoTransfer, issuingThread)},
+		{Name: "DiskIo_TypeGroup2", Version: 2, EventTypes: []uint8{12, 13, 15}, Fields: []ManagedObjectFormatField{mofPointer("Irp")}},
+		{Name: "DiskIo_TypeGroup2", Version: 3, EventTypes: []uint8{12, 13, 15}, Fields: []ManagedObjectFormatField{mofPointer("Irp"), mofUint32("IssuingThreadId")}},
+		{Name: "DiskIo_TypeGroup3", Version: 2, EventTypes: []uint8{14}, Fields: diskIoFlush},
+		{Name: "DiskIo_TypeGroup3", Version: 3, EventTypes: []uint8{14}, Fields: mofFields(diskIoFlush, issuingThread)},
+	}
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/etw/pagefault-v2
+var (
+	pageFaultEventTypes = map[uint8]string{
+		10: "TransitionFault", 11: "DemandZeroFault", 12: "CopyOnWrite", 13: "GuardPageFault",
+		14: "HardPageFault", 15: "AccessViolation", 32: "HardFault",
+	}
+
+	pageFaultLayouts = []ManagedObjectFormatLayout{
+		{
+			Name: "PageFault_TypeGroup1", Version: 2, EventTypes: []uint8{10, 11, 12, 13, 14, 15},
+			Fields: []ManagedObjectFormatField{
+				mofPointer("VirtualAddress"),
+				mofPointer("ProgramCounter"),
+			},
+		},
+		{
+			Name: "PageFault_HardFault", Version: 2, EventTypes: []uint8{32},
+			Fields: []ManagedObjectFormatField{
+				mofField("InitialTime", TdhInTypeInt64, TdhOutTypeNull),
+				mofField("ReadOffset", TdhInTypeUint64, TdhOutTypeNull),
+				mofPointer("VirtualAddress"),
+				mofPointer("FileObject"),
+				mofUint32("TThreadId"),
+				mofUint32("ByteCount"),
+			},
+		},
+	}
+)
+
+func mofFields(groups ...[]ManagedObjectFormatField) []ManagedObjectFormatField {
+	var fields []ManagedObjectFormatField
+	for _, group := range groups {
+		fields = append(fields, group...)
+	}
+	return fields
+}
+
+func withVersion(layout ManagedObjectFormatLayout, version uint8) ManagedObjectFormatLayout {
+	layout.Version = version
+	return layout
+}
@@ -1,6 +1,7 @@
 package winapi
 
 import (
+	"encoding/binary"
 	"fmt"
 	"golang.org/x/sys/windows"
 	"strings"
@@ -55,7 +56,7 @@ type TraceProviderInfo struct {
 }
 
 // https://learn.microsoft.com/en-us/windows/win32/api/tdh/ns-tdh-trace_event_info
-type TraceEventInfo struct {
+type TraceEventInfoData struct {
 	ProviderGUID                syscall.GUID
 	EventGUID                   syscall.GUID
 	EventDescriptor             EventDescriptor
@@ -78,54 +79,99 @@ type TraceEventInfo struct {
 	EventPropertyInfoArray      [1]EventPropertyInfo
 }
 
-func (t *TraceEventInfo) stringAt(offset uintptr) string {
-	if offset > 0 {
-		return windows.UTF16PtrToString((*uint16)(unsafe.Pointer(uintptr(unsafe.Pointer(t)) + offset)))
+// TraceEventInfo is a TRACE_EVENT_INFO with the buffer TDH wrote it in. The offsets and counts it holds are checked
+// against the buffer size: strings out of the buffer read as empty, and properties out of it fail with ErrIndexOutOfRange.
+type TraceEventInfo struct {
+	*TraceEventInfoData
+	buffer []byte
+}
+
+var eventPropertyInfoArrayOffset = unsafe.Offsetof(TraceEventInfoData{}.EventPropertyInfoArray)
+
+// NewTraceEventInfo views the TRACE_EVENT_INFO written in buffer.
+func NewTraceEventInfo(buffer []byte) (*TraceEventInfo, error) {
+	if uintptr(len(buffer)) < eventPropertyInfoArrayOffset {
+		return nil, fmt.Errorf("TRACE_EVENT_INFO of %d bytes: %w", len(buffer), ErrIndexOutOfRange)
+	}
+	info := &TraceEventInfo{
+		TraceEventInfoData: (*TraceEventInfoData)(unsafe.Pointer(&buffer[0])),
+		buffer:             buffer,
+	}
+	arrayEnd := uint64(eventPropertyInfoArrayOffset) + uint64(info.PropertyCount)*uint64(unsafe.Sizeof(EventPropertyInfo{}))
+	if arrayEnd > uint64(len(buffer)) {
+		return nil, fmt.Errorf("%d properties end out of the %d bytes of TRACE_EVENT_INFO: %w", info.PropertyCount, len(buffer), ErrIndexOutOfRange)
 	}
-	return ""
+	return info, nil
 }
 
-func (t *TraceEventInfo) cleanStringAt(offset uintptr) string {
-	if offset > 0 {
-		return strings.Trim(t.stringAt(offset), " ")
+// utf16StringAt reads the null terminated UTF-16 string at offset in buffer, up to the end of buffer at most.
+// Offset 0, which TDH uses for missing strings, and offsets out of buffer read as empty strings.
+func utf16StringAt(buffer []byte, offset uint32) string {
+	if offset == 0 || uint64(offset) >= uint64(len(buffer)) {
+		return ""
 	}
-	return ""
+	var characters []uint16
+	for i := int(offset); i+1 < len(buffer); i += 2 {
+		character := binary.LittleEndian.Uint16(buffer[i:])
+		if character == 0 {
+			break
+		}
+		characters = append(characters, character)
+	}
+	return windows.UTF16ToString(characters)
+}
+
+func (t *TraceEventInfo) stringAt(offset uint32) string {
+	return utf16StringAt(t.buffer, offset)
+}
+
+func (t *TraceEventInfo) cleanStringAt(offset uint32) string {
+	return strings.Trim(t.stringAt(offset), " ")
 }
 
 func (t *TraceEventInfo) EventMessage() string {
-	return t.cleanStringAt(uintptr(t.EventMessageOffset))
+	return t.cleanStringAt(t.EventMessageOffset)
+}
+
+// Tags shares the Flags field, above the 4 bits of the template flags.
+func (t *TraceEventInfo) Tags() uint32 {
+	return uint32(t.Flags) >> 4
+}
+
+func (t *TraceEventInfo) ProviderMessage() string {
+	return t.cleanStringAt(t.ProviderMessageOffset)
 }
 
 func (t *TraceEventInfo) ProviderName() string {
-	return t.cleanStringAt(uintptr(t.ProviderNameOffset))
+	return t.cleanStringAt(t.ProviderNameOffset)
 }
 
 func (t *TraceEventInfo) TaskName() string {
-	return t.cleanStringAt(uintptr(t.TaskNameOffset))
+	return t.cleanStringAt(t.TaskNameOffset)
 }
 
 func (t *TraceEventInfo) LevelName() string {
-	return t.cleanStringAt(uintptr(t.LevelNameOffset))
+	return t.cleanStringAt(t.LevelNameOffset)
 }
 
 func (t *TraceEventInfo) OpcodeName() string {
-	return t.cleanStringAt(uintptr(t.OpcodeNameOffset))
+	return t.cleanStringAt(t.OpcodeNameOffset)
 }
 
 func (t *TraceEventInfo) KeywordName() string {
-	return t.cleanStringAt(uintptr(t.KeywordsNameOffset))
+	return t.cleanStringAt(t.KeywordsNameOffset)
 }
 
 func (t *TraceEventInfo) ChannelName() string {
-	return t.cleanStringAt(uintptr(t.ChannelNameOffset))
+	return t.cleanStringAt(t.ChannelNameOffset)
 }
 
 func (t *TraceEventInfo) ActivityIDName() string {
-	return t.stringAt(uintptr(t.ActivityIDNameOffset))
+	return t.stringAt(t.ActivityIDNameOffset)
 }
 
 func (t *TraceEventInfo) RelatedActivityIDName() string {
-	return t.stringAt(uintptr(t.RelatedActivityIDNameOffset))
+	return t.stringAt(t.RelatedActivityIDNameOffset)
 }
 
 func (t *TraceEventInfo) IsManagedObjectFormat() bool {
@@ -140,35 +186,49 @@ func (t *TraceEventInfo) EventID() uint16 {
 	return t.EventDescriptor.Id
 }
 
-func (t *TraceEventInfo) GetEventPropertyInfoAt(index uint32) *EventPropertyInfo {
-	if index < t.PropertyCount {
-		offset := uintptr(index) * unsafe.Sizeof(EventPropertyInfo{})
-		return (*EventPropertyInfo)(unsafe.Pointer(uintptr(unsafe.Pointer(&t.EventPropertyInfoArray[0])) + offset))
+// GetEventPropertyInfoAt returns the EVENT_PROPERTY_INFO at index, which must be within PropertyCount and the buffer.
+func (t *TraceEventInfo) GetEventPropertyInfoAt(index uint32) (*EventPropertyInfo, error) {
+	if index >= t.PropertyCount {
+		return nil, fmt.Errorf("property %d of %d: %w", index, t.PropertyCount, ErrIndexOutOfRange)
 	}
-	panic(fmt.Errorf("index out of range"))
+	offset := uint64(eventPropertyInfoArrayOffset) + uint64(index)*uint64(unsafe.Sizeof(EventPropertyInfo{}))
+	if offset+uint64(unsafe.Sizeof(EventPropertyInfo{})) > uint64(len(t.buffer)) {
+		return nil, fmt.Errorf("property %d ends out of the %d bytes of TRACE_EVENT_INFO: %w", index, len(t.buffer), ErrIndexOutOfRange)
+	}
+	return (*EventPropertyInfo)(unsafe.Pointer(&t.buffer[offset])), nil
 }
 
-func (t *TraceEventInfo) PropertyNameOffset(index uint32) uintptr {
-	return uintptr(unsafe.Pointer(t)) + uintptr(t.GetEventPropertyInfoAt(index).NameOffset)
+// PropertyName returns the name of the property at index, empty when out of range.
+func (t *TraceEventInfo) PropertyName(index uint32) string {
+	eventPropertyInfo, err := t.GetEventPropertyInfoAt(index)
+	if err != nil {
+		return ""
+	}
+	return t.stringAt(eventPropertyInfo.NameOffset)
 }
 
-type DecodingSource int32 // https://learn.microsoft.com/en-us/windows/win32/api/tdh/ne-tdh-decoding_source
-
-const (
-	DecodingSourceXMLFile = DecodingSource(0)
-	DecodingSourceWbem    = DecodingSource(1)
-	DecodingSourceWPP     = DecodingSource(2)
-)
-
-type TemplateFlags int32
+// PropertyMapName returns the map name of the property at index, empty when out of range.
+func (t *TraceEventInfo) PropertyMapName(index uint32) string {
+	eventPropertyInfo, err := t.GetEventPropertyInfoAt(index)
+	if err != nil {
+		return ""
+	}
+	return t.stringAt(eventPropertyInfo.MapNameOffset())
+}
 
-const (
-	TEMPLATE_EVENT_DATA = TemplateFlags(1)
-	TEMPLATE_USER_DATA  = TemplateFlags(2)
-)
+func (t *TraceEventInfo) PropertyNameOffset(index uint32) (uintptr, error) {
+	eventPropertyInfo, err := t.GetEventPropertyInfoAt(index)
+	if err != nil {
+		return 0, err
+	}
+	if uint64(eventPropertyInfo.NameOffset) >= uint64(len(t.buffer)) {
+		return 0, fmt.Errorf("name of property %d out of the %d bytes of TRACE_EVENT_INFO: %w", index, len(t.buffer), ErrIndexOutOfRange)
+	}
+	return uintptr(unsafe.Pointer(&t.buffer[eventPropertyInfo.NameOffset])), nil
+}
 
 // https://learn.microsoft.com/en-us/windows/win32/api/tdh/ns-tdh-event_map_info
-type EventMapInfo struct {
+type EventMapInfoData struct {
 	NameOffset    uint32
 	Flag          MapFlags
 	EntryCount    uint32
@@ -176,30 +236,68 @@ type EventMapInfo struct {
 	MapEntryArray [1]EventMapEntry
 }
 
-func (e *EventMapInfo) GetEventMapEntryAt(i int) *EventMapEntry {
-	if uint32(i) < e.EntryCount {
-		return (*EventMapEntry)(unsafe.Pointer(uintptr(unsafe.Pointer(&e.MapEntryArray[0])) + uintptr(i)*unsafe.Sizeof(EventMapEntry{})))
+// EventMapInfo is an EVENT_MAP_INFO with the buffer TDH wrote it in, checked as TraceEventInfo is.
+type EventMapInfo struct {
+	*EventMapInfoData
+	buffer []byte
+}
+
+var mapEntryArrayOffset = unsafe.Offsetof(EventMapInfoData{}.MapEntryArray)
+
+// NewEventMapInfo views the EVENT_MAP_INFO written in buffer.
+func NewEventMapInfo(buffer []byte) (*EventMapInfo, error) {
+	if uintptr(len(buffer)) < mapEntryArrayOffset {
+		return nil, fmt.Errorf("EVENT_MAP_INFO of %d bytes: %w", len(buffer), ErrIndexOutOfRange)
+	}
+	return &EventMapInfo{
+		EventMapInfoData: (*EventMapInfoData)(unsafe.Pointer(&buffer[0])),
+		buffer:           buffer,
+	}, nil
+}
+
+// GetEventMapEntryAt returns the entry at i, which must be within EntryCount and the buffer.
+func (e *EventMapInfo) GetEventMapEntryAt(i int) (*EventMapEntry, error) {
+	if i < 0 || uint32(i) >= e.EntryCount {
+		return nil, fmt.Errorf("map entry %d of %d: %w", i, e.EntryCount, ErrIndexOutOfRange)
 	}
-	panic(fmt.Errorf("index out of range"))
+	offset := uint64(mapEntryArrayOffset) + uint64(i)*uint64(unsafe.Sizeof(EventMapEntry{}))
+	if offset+uint64(unsafe.Sizeof(EventMapEntry{})) > uint64(len(e.buffer)) {
+		return nil, fmt.Errorf("map entry %d ends out of the %d bytes of EVENT_MAP_INFO: %w", i, len(e.buffer), ErrIndexOutOfRange)
+	}
+	return (*EventMapEntry)(unsafe.Pointer(&e.buffer[offset])), nil
+}
+
+func (e *EventMapInfo) stringAt(offset uint32) string {
+	return utf16StringAt(e.buffer, offset)
 }
 
-type MapFlags int32
+func (e *EventMapInfo) Name() string {
+	return e.stringAt(e.NameOffset)
+}
 
-type MapValueType int32
+// ValueType tells whether entries are keyed by an ULONG value or by a string, for maps other than pattern maps.
+func (e *EventMapInfo) ValueType() MapValueType {
+	return MapValueType(e.Union)
+}
+
+// EntryOutput is the name the entry maps its value to.
+func (e *EventMapInfo) EntryOutput(entry *EventMapEntry) string {
+	return strings.TrimRight(e.stringAt(entry.OutputOffset), " ")
+}
+
+// EntryInput is the string key of the entry, for pattern maps and string valued maps.
+func (e *EventMapInfo) EntryInput(entry *EventMapEntry) string {
+	return e.stringAt(entry.Union)
+}
 
 type EventMapEntry struct {
 	OutputOffset uint32
 	Union        uint32
 }
 
-// https://learn.microsoft.com/en-us/windows/win32/api/tdh/ne-tdh-property_flags
-type PropertyFlags int32
-
-const (
-	PropertyStruct      = PropertyFlags(0x1)
-	PropertyParamLength = PropertyFlags(0x2)
-	PropertyParamCount  = PropertyFlags(0x4)
-)
+func (e *EventMapEntry) Value() uint32 {
+	return e.Union
+}
 
 // https://learn.microsoft.com/en-us/windows/win32/api/tdh/ns-tdh-event_property_info
 type EventPropertyInfo struct {
@@ -254,90 +352,7 @@ func (i *EventPropertyInfo) Length() uint16 {
 	return i.LengthUnion
 }
 
-type TdhInType uint32
-
-// winmeta.xml
-// https://github.com/microsoft/ETW2JSON/blob/6721e0438733b316d316d36c488166853a05f836/Deserializer/Tdh.cs
-const (
-	TdhInTypeNull = TdhInType(iota)
-	TdhInTypeUnicodestring
-	TdhInTypeAnsistring
-	TdhInTypeInt8
-	TdhInTypeUint8
-	TdhInTypeInt16
-	TdhInTypeUint16
-	TdhInTypeInt32
-	TdhInTypeUint32
-	TdhInTypeInt64
-	TdhInTypeUint64
-	TdhInTypeFloat
-	TdhInTypeDouble
-	TdhInTypeBoolean
-	TdhInTypeBinary
-	TdhInTypeGUID
-	TdhInTypePointer
-	TdhInTypeFiletime
-	TdhInTypeSystemtime
-	TdhInTypeSid
-	TdhInTypeHexint32
-	TdhInTypeHexint64
-)
-
-const (
-	TdhInTypeCountedstring = TdhInType(iota + 300)
-	TdhInTypeCountedansistring
-	TdhInTypeReversedcountedstring
-	TdhInTypeReversedcountedansistring
-	TdhInTypeNonnullterminatedstring
-	TdhInTypeNonnullterminatedansistring
-	TdhInTypeUnicodechar
-	TdhInTypeAnsichar
-	TdhInTypeSizet
-	TdhInTypeHexdump
-	TdhInTypeWbemsid
-)
-
-type TdhOutType uint32
-
-const (
-	TdhOutTypeNull = TdhOutType(iota)
-	TdhOutTypeString
-	TdhOutTypeDatetime
-	TdhOutTypeByte
-	TdhOutTypeUnsignedbyte
-	TdhOutTypeShort
-	TdhOutTypeUnsignedshort
-	TdhOutTypeInt
-	TdhOutTypeUnsignedint
-	TdhOutTypeLong
-	TdhOutTypeUnsignedlong
-	TdhOutTypeFloat
-	TdhOutTypeDouble
-	TdhOutTypeBoolean
-	TdhOutTypeGUID
-	TdhOutTypeHexbinary
-	TdhOutTypeHexint8
-	TdhOutTypeHexint16
-	TdhOutTypeHexint32
-	TdhOutTypeHexint64
-	TdhOutTypePid
-	TdhOutTypeTid
-	TdhOutTypePort
-	TdhOutTypeIpv4
-	TdhOutTypeIpv6
-	TdhOutTypeSocketaddress
-	TdhOutTypeCimdatetime
-	TdhOutTypeEtwtime
-	TdhOutTypeXML
-	TdhOutTypeErrorcode
-	TdhOutTypeWin32error
-	TdhOutTypeNtstatus
-	TdhOutTypeHresult
-	TdhOutTypeCultureInsensitiveDatetime
-	TdhOutTypeJSON
-)
-
-const (
-	TdhOutTypeREDUCEDSTRING = TdhOutType(iota + 300)
-	TdhOutTypeNOPRINT
-)
+// Tags is only set with PropertyHasTags, on 28 bits.
+func (i *EventPropertyInfo) Tags() uint32 {
+	return i.ResTagUnion & 0x0FFFFFFF
+}
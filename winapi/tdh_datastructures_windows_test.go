@@ -0,0 +1,111 @@
+package winapi
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"unicode/utf16"
+	"unsafe"
+)
+
+// traceEventInfoBuffer lays out a TRACE_EVENT_INFO of propertyCount properties, named after names,
+// followed by the provider name. extra bytes are left free at the end of the buffer.
+func traceEventInfoBuffer(propertyCount uint32, names []string, extra int) []byte {
+	propertySize := int(unsafe.Sizeof(EventPropertyInfo{}))
+	buffer := make([]byte, int(eventPropertyInfoArrayOffset)+len(names)*propertySize)
+
+	appendString := func(s string) uint32 {
+		offset := uint32(len(buffer))
+		for _, character := range utf16.Encode([]rune(s)) {
+			buffer = binary.LittleEndian.AppendUint16(buffer, character)
+		}
+		buffer = append(buffer, 0, 0)
+		return offset
+	}
+
+	nameOffsets := make([]uint32, len(names))
+	for i, name := range names {
+		nameOffsets[i] = appendString(name)
+	}
+	providerNameOffset := appendString(" Sample-Provider ")
+	buffer = append(buffer, make([]byte, extra)...)
+
+	data := (*TraceEventInfoData)(unsafe.Pointer(&buffer[0]))
+	data.PropertyCount = propertyCount
+	data.ProviderNameOffset = providerNameOffset
+	for i, offset := range nameOffsets {
+		property := (*EventPropertyInfo)(unsafe.Pointer(&buffer[int(eventPropertyInfoArrayOffset)+i*propertySize]))
+		property.NameOffset = offset
+	}
+	return buffer
+}
+
+func TestNewTraceEventInfo(t *testing.T) {
+	info, err := NewTraceEventInfo(traceEventInfoBuffer(2, []string{"Image", "Size"}, 0))
+	if err != nil {
+		t.Fatalf("NewTraceEventInfo: %v", err)
+	}
+	if name := info.ProviderName(); name != "Sample-Provider" {
+		t.Errorf("ProviderName() = %q, want %q", name, "Sample-Provider")
+	}
+	if first, second := info.PropertyName(0), info.PropertyName(1); first != "Image" || second != "Size" {
+		t.Errorf("property names = %q %q, want Image Size", first, second)
+	}
+	if _, err = info.GetEventPropertyInfoAt(2); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("GetEventPropertyInfoAt(2) err = %v, want %v", err, ErrIndexOutOfRange)
+	}
+
+	if _, err = NewTraceEventInfo(make([]byte, eventPropertyInfoArrayOffset-1)); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("truncated header err = %v, want %v", err, ErrIndexOutOfRange)
+	}
+	if _, err = NewTraceEventInfo(traceEventInfoBuffer(1000, []string{"Image"}, 0)); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("property array out of the buffer err = %v, want %v", err, ErrIndexOutOfRange)
+	}
+}
+
+func TestTraceEventInfoOffsets(t *testing.T) {
+	buffer := traceEventInfoBuffer(1, []string{"Image"}, 0)
+	info, err := NewTraceEventInfo(buffer)
+	if err != nil {
+		t.Fatalf("NewTraceEventInfo: %v", err)
+	}
+
+	info.TaskNameOffset = uint32(len(buffer))
+	if name := info.TaskName(); name != "" {
+		t.Errorf("name out of the buffer = %q, want empty", name)
+	}
+
+	// the provider name loses its terminator and runs to the end of the buffer
+	info.buffer = buffer[:len(buffer)-4]
+	if name := info.ProviderName(); name != "Sample-Provider" {
+		t.Errorf("unterminated name = %q, want %q", name, "Sample-Provider")
+	}
+
+	property, _ := info.GetEventPropertyInfoAt(0)
+	property.NameOffset = 0xFFFFFFF0
+	if name := info.PropertyName(0); name != "" {
+		t.Errorf("property name out of the buffer = %q, want empty", name)
+	}
+	if _, err = info.PropertyNameOffset(0); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("PropertyNameOffset err = %v, want %v", err, ErrIndexOutOfRange)
+	}
+}
+
+func TestEventMapInfoEntries(t *testing.T) {
+	buffer := make([]byte, int(mapEntryArrayOffset)+int(unsafe.Sizeof(EventMapEntry{})))
+	(*EventMapInfoData)(unsafe.Pointer(&buffer[0])).EntryCount = 2
+
+	info, err := NewEventMapInfo(buffer)
+	if err != nil {
+		t.Fatalf("NewEventMapInfo: %v", err)
+	}
+	if _, err = info.GetEventMapEntryAt(0); err != nil {
+		t.Errorf("GetEventMapEntryAt(0): %v", err)
+	}
+	if _, err = info.GetEventMapEntryAt(1); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("entry out of the buffer err = %v, want %v", err, ErrIndexOutOfRange)
+	}
+	if _, err = NewEventMapInfo(buffer[:mapEntryArrayOffset-1]); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("truncated header err = %v, want %v", err, ErrIndexOutOfRange)
+	}
+}
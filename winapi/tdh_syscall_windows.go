@@ -10,7 +10,7 @@ import (
 func TdhGetEventInformation(pEvent *EventRecord,
 	tdhContextCount uint32,
 	pTdhContext *TdhContext,
-	pBuffer *TraceEventInfo,
+	pBuffer *TraceEventInfoData,
 	pBufferSize *uint32,
 ) error {
 	errorCode, _, _ := tdhGetEventInformation.Call(
@@ -27,7 +27,7 @@ func TdhGetEventInformation(pEvent *EventRecord,
 
 func TdhGetEventMapInformation(pEvent *EventRecord,
 	pMapName *uint16,
-	pBuffer *EventMapInfo,
+	pBuffer *EventMapInfoData,
 	pBufferSize *uint32) error {
 	errorCode, _, _ := tdhGetEventMapInformation.Call(
 		uintptr(unsafe.Pointer(pEvent)),
@@ -93,9 +93,13 @@ func TdhFormatProperty(
 	buffer *uint16,
 	userDataConsumed *uint16,
 ) error {
+	var mapInfoData *EventMapInfoData
+	if mapInfo != nil {
+		mapInfoData = mapInfo.EventMapInfoData
+	}
 	errorCode, _, _ := tdhFormatProperty.Call(
-		uintptr(unsafe.Pointer(eventInfo)),
-		uintptr(unsafe.Pointer(mapInfo)),
+		uintptr(unsafe.Pointer(eventInfo.TraceEventInfoData)),
+		uintptr(unsafe.Pointer(mapInfoData)),
 		uintptr(pointerSize),
 		uintptr(propertyInType),
 		uintptr(propertyOutType),
@@ -0,0 +1,158 @@
+package winapi
+
+import (
+	"fmt"
+)
+
+var (
+	ErrIndexOutOfRange = fmt.Errorf("index out of range")
+)
+
+type DecodingSource int32 // https://learn.microsoft.com/en-us/windows/win32/api/tdh/ne-tdh-decoding_source
+
+const (
+	DecodingSourceXMLFile = DecodingSource(0)
+	DecodingSourceWbem    = DecodingSource(1)
+	DecodingSourceWPP     = DecodingSource(2)
+	DecodingSourceTlg     = DecodingSource(3)
+)
+
+type TemplateFlags int32
+
+const (
+	TEMPLATE_EVENT_DATA = TemplateFlags(1)
+	TEMPLATE_USER_DATA  = TemplateFlags(2)
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/tdh/ne-tdh-map_flags
+type MapFlags int32
+
+const (
+	EVENTMAP_INFO_FLAG_MANIFEST_VALUEMAP   = MapFlags(0x1)
+	EVENTMAP_INFO_FLAG_MANIFEST_BITMAP     = MapFlags(0x2)
+	EVENTMAP_INFO_FLAG_MANIFEST_PATTERNMAP = MapFlags(0x4)
+	EVENTMAP_INFO_FLAG_WBEM_VALUEMAP       = MapFlags(0x8)
+	EVENTMAP_INFO_FLAG_WBEM_BITMAP         = MapFlags(0x10)
+	EVENTMAP_INFO_FLAG_WBEM_FLAG           = MapFlags(0x20)
+	EVENTMAP_INFO_FLAG_WBEM_NO_MAP         = MapFlags(0x40)
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/tdh/ne-tdh-map_valuetype
+type MapValueType int32
+
+const (
+	EVENTMAP_ENTRY_VALUETYPE_ULONG  = MapValueType(0)
+	EVENTMAP_ENTRY_VALUETYPE_STRING = MapValueType(1)
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/tdh/ne-tdh-property_flags
+type PropertyFlags int32
+
+const (
+	PropertyStruct      = PropertyFlags(0x1)
+	PropertyParamLength = PropertyFlags(0x2)
+	PropertyParamCount  = PropertyFlags(0x4)
+
+	PropertyWBEMXmlFragment  = PropertyFlags(0x8)
+	PropertyParamFixedLength = PropertyFlags(0x10)
+	PropertyParamFixedCount  = PropertyFlags(0x20)
+	PropertyHasTags          = PropertyFlags(0x40)
+	PropertyHasCustomSchema  = PropertyFlags(0x80)
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/tdh/ne-tdh-_tdh_in_type
+type TdhInType uint32
+
+// winmeta.xml
+// https://github.com/microsoft/ETW2JSON/blob/6721e0438733b316d316d36c488166853a05f836/Deserializer/Tdh.cs
+const (
+	TdhInTypeNull = TdhInType(iota)
+	TdhInTypeUnicodestring
+	TdhInTypeAnsistring
+	TdhInTypeInt8
+	TdhInTypeUint8
+	TdhInTypeInt16
+	TdhInTypeUint16
+	TdhInTypeInt32
+	TdhInTypeUint32
+	TdhInTypeInt64
+	TdhInTypeUint64
+	TdhInTypeFloat
+	TdhInTypeDouble
+	TdhInTypeBoolean
+	TdhInTypeBinary
+	TdhInTypeGUID
+	TdhInTypePointer
+	TdhInTypeFiletime
+	TdhInTypeSystemtime
+	TdhInTypeSid
+	TdhInTypeHexint32
+	TdhInTypeHexint64
+	TdhInTypeManifestCountedstring
+	TdhInTypeManifestCountedansistring
+	TdhInTypeReserved24
+	TdhInTypeManifestCountedbinary
+)
+
+const (
+	TdhInTypeCountedstring = TdhInType(iota + 300)
+	TdhInTypeCountedansistring
+	TdhInTypeReversedcountedstring
+	TdhInTypeReversedcountedansistring
+	TdhInTypeNonnullterminatedstring
+	TdhInTypeNonnullterminatedansistring
+	TdhInTypeUnicodechar
+	TdhInTypeAnsichar
+	TdhInTypeSizet
+	TdhInTypeHexdump
+	TdhInTypeWbemsid
+)
+
+type TdhOutType uint32
+
+const (
+	TdhOutTypeNull = TdhOutType(iota)
+	TdhOutTypeString
+	TdhOutTypeDatetime
+	TdhOutTypeByte
+	TdhOutTypeUnsignedbyte
+	TdhOutTypeShort
+	TdhOutTypeUnsignedshort
+	TdhOutTypeInt
+	TdhOutTypeUnsignedint
+	TdhOutTypeLong
+	TdhOutTypeUnsignedlong
+	TdhOutTypeFloat
+	TdhOutTypeDouble
+	TdhOutTypeBoolean
+	TdhOutTypeGUID
+	TdhOutTypeHexbinary
+	TdhOutTypeHexint8
+	TdhOutTypeHexint16
+	TdhOutTypeHexint32
+	TdhOutTypeHexint64
+	TdhOutTypePid
+	TdhOutTypeTid
+	TdhOutTypePort
+	TdhOutTypeIpv4
+	TdhOutTypeIpv6
+	TdhOutTypeSocketaddress
+	TdhOutTypeCimdatetime
+	TdhOutTypeEtwtime
+	TdhOutTypeXML
+	TdhOutTypeErrorcode
+	TdhOutTypeWin32error
+	TdhOutTypeNtstatus
+	TdhOutTypeHresult
+	TdhOutTypeCultureInsensitiveDatetime
+	TdhOutTypeJSON
+	TdhOutTypeUTF8
+	TdhOutTypePkcs7WithTypeInfo
+	TdhOutTypeCodePointer
+	TdhOutTypeDatetimeUTC
+)
+
+const (
+	TdhOutTypeREDUCEDSTRING = TdhOutType(iota + 300)
+	TdhOutTypeNOPRINT
+)
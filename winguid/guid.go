@@ -0,0 +1,113 @@
+package winguid
+
+import (
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/guiddef/ns-guiddef-guid
+// https://learn.microsoft.com/en-us/windows/win32/api/guiddef/ns-guiddef-guid#members
+
+func MustParse(sguid string) *GUID {
+	guid, err := Parse(sguid)
+	if err != nil {
+		panic(err)
+	}
+	return guid
+}
+
+var guidRegex = regexp.MustCompile(`^\{?[A-F0-9]{8}-[A-F0-9]{4}-[A-F0-9]{4}-[A-F0-9]{4}-[A-F0-9]{12}}?$`)
+
+func Parse(guid string) (*GUID, error) {
+	outGuid := GUID{}
+	var err error
+
+	guid = strings.ToUpper(guid)
+	if !guidRegex.MatchString(guid) {
+		return nil, fmt.Errorf("bad GUID format")
+	}
+
+	digitsGroups := strings.Split(strings.Trim(guid, "{}"), "-")
+
+	var dataGroup uint64
+	if dataGroup, err = strconv.ParseUint(digitsGroups[0], 16, 32); err != nil {
+		return nil, err
+	}
+	outGuid.Data1 = uint32(dataGroup)
+
+	if dataGroup, err = strconv.ParseUint(digitsGroups[1], 16, 16); err != nil {
+		return nil, err
+	}
+	outGuid.Data2 = uint16(dataGroup)
+
+	if dataGroup, err = strconv.ParseUint(digitsGroups[2], 16, 16); err != nil {
+		return nil, err
+	}
+	outGuid.Data3 = uint16(dataGroup)
+
+	if dataGroup, err = strconv.ParseUint(digitsGroups[3], 16, 16); err != nil {
+		return nil, err
+	}
+	outGuid.Data4[0] = uint8(dataGroup >> 8)
+	outGuid.Data4[1] = uint8(dataGroup & 0xff)
+
+	if dataGroup, err = strconv.ParseUint(digitsGroups[4], 16, 64); err != nil {
+		return nil, err
+	}
+	outGuid.Data4[2] = uint8(dataGroup >> 40)
+	outGuid.Data4[3] = uint8((dataGroup >> 32) & 0xff)
+	outGuid.Data4[4] = uint8((dataGroup >> 24) & 0xff)
+	outGuid.Data4[5] = uint8((dataGroup >> 16) & 0xff)
+	outGuid.Data4[6] = uint8((dataGroup >> 8) & 0xff)
+	outGuid.Data4[7] = uint8(dataGroup & 0xff)
+
+	return &outGuid, nil
+}
+
+// FromBytes reads a GUID from its 16 bytes little-endian memory layout, as found in event payloads.
+func FromBytes(b []byte) GUID {
+	guid := GUID{
+		Data1: binary.LittleEndian.Uint32(b[0:4]),
+		Data2: binary.LittleEndian.Uint16(b[4:6]),
+		Data3: binary.LittleEndian.Uint16(b[6:8]),
+	}
+	copy(guid.Data4[:], b[8:16])
+	return guid
+}
+
+// ToBytes is the inverse of FromBytes.
+func ToBytes(g *GUID) []byte {
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint32(b[0:4], g.Data1)
+	binary.LittleEndian.PutUint16(b[4:6], g.Data2)
+	binary.LittleEndian.PutUint16(b[6:8], g.Data3)
+	copy(b[8:16], g.Data4[:])
+	return b
+}
+
+func ToString(g *GUID) string {
+	return fmt.Sprintf("{%08X-%04X-%04X-%02X%02X-%02X%02X%02X%02X%02X%02X}",
+		g.Data1,
+		g.Data2,
+		g.Data3,
+		g.Data4[0], g.Data4[1],
+		g.Data4[2], g.Data4[3], g.Data4[4], g.Data4[5], g.Data4[6], g.Data4[7],
+	)
+}
+
+func Equals(g *GUID, other *GUID) bool {
+	return g.Data1 == other.Data1 &&
+		g.Data2 == other.Data2 &&
+		g.Data3 == other.Data3 &&
+		g.Data4[0] == other.Data4[0] &&
+		g.Data4[1] == other.Data4[1] &&
+		g.Data4[2] == other.Data4[2] &&
+		g.Data4[3] == other.Data4[3] &&
+		g.Data4[4] == other.Data4[4] &&
+		g.Data4[5] == other.Data4[5] &&
+		g.Data4[6] == other.Data4[6] &&
+		g.Data4[7] == other.Data4[7]
+}
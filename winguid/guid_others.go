@@ -0,0 +1,11 @@
+//go:build !windows
+
+package winguid
+
+// GUID mirrors the layout of syscall.GUID on platforms that do not define it.
+type GUID struct {
+	Data1 uint32
+	Data2 uint16
+	Data3 uint16
+	Data4 [8]byte
+}
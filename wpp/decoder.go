@@ -0,0 +1,300 @@
+package wpp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// Names of the properties Decode adds to the arguments of a message, after those of TdhGetWppProperty.
+const (
+	FormattedStringPropertyName = "FormattedString"
+	ComponentNamePropertyName   = "ComponentName"
+	FunctionNamePropertyName    = "FunctionName"
+	FileNamePropertyName        = "FileName"
+	LineNumberPropertyName      = "LineNumber"
+	LevelNamePropertyName       = "LevelName"
+	FlagsNamePropertyName       = "FlagsName"
+)
+
+var (
+	ErrUnsupportedItemType = fmt.Errorf("unsupported WPP item type")
+)
+
+type messageKey struct {
+	guid winguid.GUID
+	id   uint16
+}
+
+type decodedMessage struct {
+	message       *Message
+	schema        *etw.Schema
+	propertyNames map[int]string // by argument index
+}
+
+// Decoder is a DecoderBackend for WPP trace messages, which TDH only decodes with the TMF files at hand.
+// Records without TMF format go to its DecoderBackend, when not nil.
+type Decoder struct {
+	etw.DecoderBackend
+	messages map[messageKey]*decodedMessage
+}
+
+// NewDecoder builds the schemas of messages, the latest declaration of a message number wins.
+func NewDecoder(messages []*Message, backend etw.DecoderBackend) (*Decoder, error) {
+	decoder := &Decoder{
+		DecoderBackend: backend,
+		messages:       make(map[messageKey]*decodedMessage, len(messages)),
+	}
+	for _, message := range messages {
+		decoded, err := newDecodedMessage(message)
+		if err != nil {
+			return nil, err
+		}
+		decoder.messages[messageKey{guid: message.GUID, id: message.ID}] = decoded
+	}
+	return decoder, nil
+}
+
+func newDecodedMessage(message *Message) (*decodedMessage, error) {
+	schema := &etw.Schema{
+		ProviderGUID:   message.GUID,
+		DecodingSource: winapi.DecodingSourceWPP,
+		ProviderName:   message.Component,
+		LevelName:      message.Level,
+		KeywordsName:   message.Flags,
+		EventMessage:   message.Format,
+	}
+	schema.EventDescriptor.Id = message.ID
+
+	decoded := &decodedMessage{
+		message:       message,
+		schema:        schema,
+		propertyNames: make(map[int]string, len(message.Arguments)),
+	}
+	used := map[string]bool{ // names Decode adds
+		FormattedStringPropertyName: true, ComponentNamePropertyName: true, FunctionNamePropertyName: true,
+		FileNamePropertyName: true, LineNumberPropertyName: true, LevelNamePropertyName: true, FlagsNamePropertyName: true,
+	}
+	for _, argument := range message.Arguments {
+		layout, ok := argument.Type.layout()
+		if !ok {
+			return nil, fmt.Errorf("%w %s, argument %d of message %d of %s", ErrUnsupportedItemType, argument.Type, argument.Index, message.ID, message.Component)
+		}
+
+		name := argument.Name
+		if name == "" || used[name] {
+			name = "Arg" + strconv.Itoa(argument.Index)
+		}
+		used[name] = true
+		decoded.propertyNames[argument.Index] = name
+
+		property := etw.PropertyInfo{
+			Name:    name,
+			InType:  layout.inType,
+			OutType: layout.outType,
+			Length:  layout.length,
+		}
+		if len(argument.Values) > 0 && (argument.Type.IsList() || argument.Type.IsSet()) {
+			property.MapName = name
+			if schema.Maps == nil {
+				schema.Maps = make(map[string]*etw.ValueMap)
+			}
+			schema.Maps[name] = newValueMap(name, argument)
+		}
+		schema.Properties = append(schema.Properties, property)
+	}
+	schema.TopLevelPropertyCount = len(schema.Properties)
+
+	return decoded, nil
+}
+
+func newValueMap(name string, argument Argument) *etw.ValueMap {
+	valueMap := &etw.ValueMap{Name: name, Flag: winapi.EVENTMAP_INFO_FLAG_MANIFEST_VALUEMAP}
+	if argument.Type.IsSet() {
+		valueMap.Flag = winapi.EVENTMAP_INFO_FLAG_MANIFEST_BITMAP
+	}
+	for i, output := range argument.Values {
+		value := uint32(i)
+		if argument.Type.IsSet() {
+			if i >= 32 {
+				break
+			}
+			value = 1 << i
+		}
+		valueMap.Entries = append(valueMap.Entries, etw.ValueMapEntry{Value: value, Output: output})
+	}
+	return valueMap
+}
+
+func isTraceMessage(record *etw.Record) bool {
+	return record.EventHeader.Flags&winapi.EVENT_HEADER_FLAG_TRACE_MESSAGE == winapi.EVENT_HEADER_FLAG_TRACE_MESSAGE
+}
+
+func (d *Decoder) lookup(record *etw.Record) (*decodedMessage, bool) {
+	if !isTraceMessage(record) {
+		return nil, false
+	}
+	decoded, ok := d.messages[messageKey{guid: record.EventHeader.ProviderId, id: record.EventHeader.EventDescriptor.Id}]
+	return decoded, ok
+}
+
+// Message returns the format of message id of the message GUID guid.
+func (d *Decoder) Message(guid *winguid.GUID, id uint16) (*Message, bool) {
+	decoded, ok := d.messages[messageKey{guid: *guid, id: id}]
+	if !ok {
+		return nil, false
+	}
+	return decoded.message, true
+}
+
+func (d *Decoder) EventSchema(record *etw.Record) (*etw.Schema, error) {
+	if decoded, ok := d.lookup(record); ok {
+		return decoded.schema, nil
+	}
+	if d.DecoderBackend == nil || isTraceMessage(record) {
+		return nil, etw.ErrSchemaNotFound
+	}
+	return d.DecoderBackend.EventSchema(record)
+}
+
+func (d *Decoder) FormatProperty(record *etw.Record, schema *etw.Schema, property *etw.PropertyInfo, length uint32, data []byte) (string, int, error) {
+	if schema.DecodingSource == winapi.DecodingSourceWPP || d.DecoderBackend == nil {
+		return "", 0, etw.ErrFormatUnsupported
+	}
+	return d.DecoderBackend.FormatProperty(record, schema, property, length, data)
+}
+
+// Decode decodes record, and for trace messages, sets Event.Message, and adds the formatted message and the source
+// of the message as properties: FormattedString, ComponentName, FunctionName, FileName, LineNumber,
+// LevelName and FlagsName.
+func (d *Decoder) Decode(record *etw.Record) (*etw.Event, error) {
+	event, err := etw.DecodeRecord(record, d)
+	if event == nil {
+		return nil, err
+	}
+
+	decoded, ok := d.lookup(record)
+	if !ok {
+		return event, err
+	}
+	message := decoded.message
+
+	event.Message = decoded.format(record, event)
+	addProperty(event, FormattedStringPropertyName, event.Message)
+	addProperty(event, ComponentNamePropertyName, message.Component)
+	addProperty(event, FunctionNamePropertyName, message.Function)
+	addProperty(event, FileNamePropertyName, message.File)
+	addProperty(event, LineNumberPropertyName, uint32(message.Line))
+	addProperty(event, LevelNamePropertyName, message.Level)
+	addProperty(event, FlagsNamePropertyName, message.Flags)
+
+	return event, err
+}
+
+func addProperty(event *etw.Event, name string, v interface{}) {
+	value := etw.NewValue(v, winapi.TdhInTypeNull, winapi.TdhOutTypeNull)
+	event.Properties = append(event.Properties, etw.Property{Name: name, Value: value})
+	event.EventData[name] = value.String()
+}
+
+// format renders the message format with the arguments of event, as tracefmt does without its prefix.
+func (m *decodedMessage) format(record *etw.Record, event *etw.Event) string {
+	format := m.message.Format
+	var builder strings.Builder
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			builder.WriteByte(format[i])
+			continue
+		}
+
+		end := i + 1
+		for end < len(format) && format[end] >= '0' && format[end] <= '9' {
+			end++
+		}
+		if end == i+1 {
+			builder.WriteByte('%')
+			if format[end] == '%' {
+				i = end
+			}
+			continue
+		}
+		index, _ := strconv.Atoi(format[i+1 : end])
+
+		spec := ""
+		if end < len(format) && format[end] == '!' {
+			if closing := strings.IndexByte(format[end+1:], '!'); closing >= 0 {
+				spec = format[end+1 : end+1+closing]
+				end += closing + 2
+			}
+		}
+
+		builder.WriteString(m.insertion(index, spec, record, event))
+		i = end - 1
+	}
+
+	return builder.String()
+}
+
+// insertion renders the argument or the system value numbered index.
+func (m *decodedMessage) insertion(index int, spec string, record *etw.Record, event *etw.Event) string {
+	message := m.message
+	var value etw.Value
+
+	switch index {
+	case 0: // trace prefix
+		return ""
+	case 1:
+		return message.Component
+	case 2:
+		return strings.ReplaceAll(message.File, ".", "_") + strconv.Itoa(message.Line)
+	case 3:
+		value = etw.NewValue(record.EventHeader.ThreadId, winapi.TdhInTypeUint32, winapi.TdhOutTypeTid)
+	case 4:
+		return event.System.TimestampUTC.Format(etw.TimeLayout)
+	case 8:
+		value = etw.NewValue(record.EventHeader.ProcessId, winapi.TdhInTypeUint32, winapi.TdhOutTypePid)
+	case 9:
+		value = etw.NewValue(uint8(record.BufferContext.Union), winapi.TdhInTypeUint8, winapi.TdhOutTypeUnsignedbyte)
+	default:
+		if index < FirstArgumentIndex {
+			return "" // user and kernel times, sequence number
+		}
+		name, ok := m.propertyNames[index]
+		if !ok {
+			return ""
+		}
+		if value, ok = event.Property(name); !ok {
+			return ""
+		}
+		if valueMap, ok := m.schema.Maps[name]; ok {
+			return mapValue(valueMap, value)
+		}
+	}
+
+	return value.Format(spec)
+}
+
+// mapValue names list values, and set values as their |-joined flags, as tracefmt does.
+func mapValue(valueMap *etw.ValueMap, value etw.Value) string {
+	raw := value.Uint()
+	if valueMap.Flag == winapi.EVENTMAP_INFO_FLAG_MANIFEST_BITMAP {
+		var names []string
+		for _, entry := range valueMap.Entries {
+			if raw&uint64(entry.Value) != 0 {
+				names = append(names, entry.Output)
+			}
+		}
+		return strings.Join(names, "|")
+	}
+	for _, entry := range valueMap.Entries {
+		if uint64(entry.Value) == raw {
+			return entry.Output
+		}
+	}
+	return value.String()
+}
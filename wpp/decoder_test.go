@@ -0,0 +1,114 @@
+package wpp
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/etw"
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+func sampleDecoder(t *testing.T) *Decoder {
+	t.Helper()
+
+	messages, err := ParseFile(sampleTMF)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	decoder, err := NewDecoder(messages, nil)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	return decoder
+}
+
+func traceMessage(id uint16, userData []byte) *etw.Record {
+	record := &etw.Record{UserData: userData}
+	record.EventHeader.Flags = winapi.EVENT_HEADER_FLAG_TRACE_MESSAGE | winapi.EVENT_HEADER_FLAG_64_BIT_HEADER
+	record.EventHeader.ProviderId = sampleMessageGUID
+	record.EventHeader.EventDescriptor.Id = id
+	return record
+}
+
+func TestDecode(t *testing.T) {
+	decoder := sampleDecoder(t)
+
+	var opened []byte
+	for _, r := range "a.sys\x00" {
+		opened = append(opened, byte(r), 0)
+	}
+	opened = binary.LittleEndian.AppendUint32(opened, 0xc0000022)
+
+	state := binary.LittleEndian.AppendUint32(nil, 1)
+	state = append(state, 0x03, 10, 0, 0, 1)
+	state = binary.LittleEndian.AppendUint32(state, 443)
+
+	tests := []struct {
+		id       uint16
+		userData []byte
+		message  string
+	}{
+		{10, opened, "Opened a.sys with status c0000022 in MyDriver at driver_c120"},
+		{11, state, "State Running, access Read|Write, from 10.0.0.1:443"},
+		{12, nil, "Unloaded, 100% done"},
+	}
+
+	for _, test := range tests {
+		event, err := decoder.Decode(traceMessage(test.id, test.userData))
+		if err != nil {
+			t.Fatalf("Decode(%d): %v", test.id, err)
+		}
+		if event.Message != test.message || event.EventData[FormattedStringPropertyName] != test.message {
+			t.Errorf("message %d = %q, want %q", test.id, event.Message, test.message)
+		}
+	}
+
+	event, _ := decoder.Decode(traceMessage(10, opened))
+	want := map[string]string{
+		"Arg10":                     "a.sys", // FileName is taken by the source properties
+		"Status":                    "0xC0000022",
+		ComponentNamePropertyName:   "MyDriver",
+		FunctionNamePropertyName:    "OpenDevice",
+		FileNamePropertyName:        "driver.c",
+		LineNumberPropertyName:      "120",
+		LevelNamePropertyName:       "TRACE_LEVEL_ERROR",
+		FlagsNamePropertyName:       "TRACE_OPEN",
+		FormattedStringPropertyName: tests[0].message,
+	}
+	for name, value := range want {
+		if event.EventData[name] != value {
+			t.Errorf("%s = %q, want %q", name, event.EventData[name], value)
+		}
+	}
+}
+
+func TestDecoderLookup(t *testing.T) {
+	decoder := sampleDecoder(t)
+
+	if message, ok := decoder.Message(&sampleMessageGUID, 11); !ok || message.Function != "SetState" {
+		t.Errorf("Message(11) = %+v, %v", message, ok)
+	}
+	if _, err := decoder.EventSchema(traceMessage(99, nil)); !errors.Is(err, etw.ErrSchemaNotFound) {
+		t.Errorf("unknown message, err = %v, want %v", err, etw.ErrSchemaNotFound)
+	}
+
+	record := traceMessage(10, nil)
+	record.EventHeader.Flags = 0
+	if _, err := decoder.EventSchema(record); !errors.Is(err, etw.ErrSchemaNotFound) {
+		t.Errorf("without trace message flag, err = %v, want %v", err, etw.ErrSchemaNotFound)
+	}
+
+	other := &etw.Schema{ProviderGUID: sampleMessageGUID, EventDescriptor: winapi.EventDescriptor{Id: 10}}
+	chained, _ := NewDecoder(nil, etw.NewSchemaBackend(other))
+	if schema, err := chained.EventSchema(record); err != nil || schema != other {
+		t.Errorf("other records go to the backend, got %v, %v", schema, err)
+	}
+}
+
+func TestNewDecoderUnsupportedItem(t *testing.T) {
+	message := &Message{GUID: sampleMessageGUID, ID: 1, Arguments: []Argument{{Name: "Value", Type: "ItemBogus", Index: 10}}}
+	if _, err := NewDecoder([]*Message{message}, nil); !errors.Is(err, ErrUnsupportedItemType) {
+		t.Errorf("err = %v, want %v", err, ErrUnsupportedItemType)
+	}
+}
@@ -0,0 +1,90 @@
+package wpp
+
+import (
+	"github.com/quentin-nozomi/microsoft-etw/winapi"
+)
+
+// https://learn.microsoft.com/en-us/windows-hardware/drivers/devtest/what-are-the-wpp-extended-format-specification-strings
+// https://learn.microsoft.com/en-us/windows-hardware/drivers/devtest/trace-message-format-file
+//
+// The Item types are those of defaultwpp.ini, the arguments of a message follow each other
+// in the user data, with the layout of the TDH type they map to.
+
+// ItemType is the type of a message argument, as named in TMF files, such as ItemLong.
+type ItemType string
+
+type itemLayout struct {
+	inType  winapi.TdhInType
+	outType winapi.TdhOutType
+	length  uint16 // for fixed size binary items
+}
+
+var itemLayouts = map[ItemType]itemLayout{
+	"ItemChar":        {winapi.TdhInTypeInt8, winapi.TdhOutTypeByte, 0},
+	"ItemUChar":       {winapi.TdhInTypeUint8, winapi.TdhOutTypeUnsignedbyte, 0},
+	"ItemShort":       {winapi.TdhInTypeInt16, winapi.TdhOutTypeShort, 0},
+	"ItemUShort":      {winapi.TdhInTypeUint16, winapi.TdhOutTypeUnsignedshort, 0},
+	"ItemLong":        {winapi.TdhInTypeInt32, winapi.TdhOutTypeInt, 0},
+	"ItemULong":       {winapi.TdhInTypeUint32, winapi.TdhOutTypeUnsignedint, 0},
+	"ItemULongX":      {winapi.TdhInTypeUint32, winapi.TdhOutTypeHexint32, 0},
+	"ItemLongLong":    {winapi.TdhInTypeInt64, winapi.TdhOutTypeLong, 0},
+	"ItemULongLong":   {winapi.TdhInTypeUint64, winapi.TdhOutTypeUnsignedlong, 0},
+	"ItemLongLongX":   {winapi.TdhInTypeUint64, winapi.TdhOutTypeHexint64, 0},
+	"ItemULongLongX":  {winapi.TdhInTypeUint64, winapi.TdhOutTypeHexint64, 0},
+	"ItemLongLongXX":  {winapi.TdhInTypeUint64, winapi.TdhOutTypeHexint64, 0},
+	"ItemULongLongXX": {winapi.TdhInTypeUint64, winapi.TdhOutTypeHexint64, 0},
+	"ItemPtr":         {winapi.TdhInTypePointer, winapi.TdhOutTypeHexint64, 0},
+	"ItemDouble":      {winapi.TdhInTypeDouble, winapi.TdhOutTypeDouble, 0},
+
+	"ItemString":   {winapi.TdhInTypeAnsistring, winapi.TdhOutTypeString, 0},
+	"ItemWString":  {winapi.TdhInTypeUnicodestring, winapi.TdhOutTypeString, 0},
+	"ItemPString":  {winapi.TdhInTypeManifestCountedansistring, winapi.TdhOutTypeString, 0},
+	"ItemPWString": {winapi.TdhInTypeManifestCountedstring, winapi.TdhOutTypeString, 0},
+	"ItemHEXDump":  {winapi.TdhInTypeManifestCountedbinary, winapi.TdhOutTypeHexbinary, 0},
+	"ItemHEXBytes": {winapi.TdhInTypeManifestCountedbinary, winapi.TdhOutTypeHexbinary, 0},
+
+	"ItemGuid":      {winapi.TdhInTypeGUID, winapi.TdhOutTypeGUID, 0},
+	"ItemSid":       {winapi.TdhInTypeSid, winapi.TdhOutTypeString, 0},
+	"ItemNTSTATUS":  {winapi.TdhInTypeUint32, winapi.TdhOutTypeNtstatus, 0},
+	"ItemWINERROR":  {winapi.TdhInTypeUint32, winapi.TdhOutTypeWin32error, 0},
+	"ItemHRESULT":   {winapi.TdhInTypeInt32, winapi.TdhOutTypeHresult, 0},
+	"ItemIPAddr":    {winapi.TdhInTypeUint32, winapi.TdhOutTypeIpv4, 0},
+	"ItemIPV6Addr":  {winapi.TdhInTypeBinary, winapi.TdhOutTypeIpv6, 16},
+	"ItemPort":      {winapi.TdhInTypeUint16, winapi.TdhOutTypePort, 0},
+	"ItemTimestamp": {winapi.TdhInTypeFiletime, winapi.TdhOutTypeDatetime, 0},
+	"ItemTimeDelta": {winapi.TdhInTypeInt64, winapi.TdhOutTypeLong, 0},
+	"ItemWaitTime":  {winapi.TdhInTypeInt64, winapi.TdhOutTypeLong, 0},
+	"ItemEnum":      {winapi.TdhInTypeUint32, winapi.TdhOutTypeUnsignedint, 0},
+	"ItemListByte":  {winapi.TdhInTypeUint8, winapi.TdhOutTypeUnsignedbyte, 0},
+	"ItemListShort": {winapi.TdhInTypeUint16, winapi.TdhOutTypeUnsignedshort, 0},
+	"ItemListLong":  {winapi.TdhInTypeUint32, winapi.TdhOutTypeUnsignedint, 0},
+	"ItemSetByte":   {winapi.TdhInTypeUint8, winapi.TdhOutTypeHexint8, 0},
+	"ItemSetShort":  {winapi.TdhInTypeUint16, winapi.TdhOutTypeHexint16, 0},
+	"ItemSetLong":   {winapi.TdhInTypeUint32, winapi.TdhOutTypeHexint32, 0},
+	"ItemCLSID":     {winapi.TdhInTypeGUID, winapi.TdhOutTypeGUID, 0},
+	"ItemLIBID":     {winapi.TdhInTypeGUID, winapi.TdhOutTypeGUID, 0},
+	"ItemIID":       {winapi.TdhInTypeGUID, winapi.TdhOutTypeGUID, 0},
+}
+
+// IsList reports whether values are names the TMF lists, by index: ItemListLong(A,B,C).
+func (t ItemType) IsList() bool {
+	switch t {
+	case "ItemListByte", "ItemListShort", "ItemListLong":
+		return true
+	}
+	return false
+}
+
+// IsSet reports whether values are flags the TMF names, by bit: ItemSetLong(A,B,C).
+func (t ItemType) IsSet() bool {
+	switch t {
+	case "ItemSetByte", "ItemSetShort", "ItemSetLong":
+		return true
+	}
+	return false
+}
+
+func (t ItemType) layout() (itemLayout, bool) {
+	layout, ok := itemLayouts[t]
+	return layout, ok
+}
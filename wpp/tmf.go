@@ -0,0 +1,228 @@
+package wpp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+// https://learn.microsoft.com/en-us/windows-hardware/drivers/devtest/trace-message-format-file
+//
+// A TMF file, as written by tracepdb from the annotations WPP leaves in a PDB, declares the messages
+// of one message GUID:
+//
+//	// PDB:  driver.pdb
+//	3d6b2a5e-fcb2-3e4e-b8b5-ad6e9e4ad8a4 MyDriver // SRC=driver.c MJ= MN=
+//	#typev driver_c120 10 "%0Opened %10!s! with status %11!x!" // LEVEL=TRACE_LEVEL_ERROR FLAGS=TRACE_OPEN FUNC=OpenDevice
+//	{
+//	FileName, ItemWString -- 10
+//	Status, ItemNTSTATUS -- 11
+//	}
+//
+// Message arguments are numbered from 10: %1 to %9 are system values, %0 the trace prefix.
+
+var (
+	ErrInvalidTMF = fmt.Errorf("invalid trace message format")
+)
+
+// FirstArgumentIndex is the insertion number of the first message argument.
+const FirstArgumentIndex = 10
+
+// Message is the format of one trace message.
+type Message struct {
+	GUID      winguid.GUID // message GUID, the provider ID of its records
+	ID        uint16       // message number, the event ID of its records
+	Component string       // module name of the GUID declaration
+
+	File     string // source file, from SRC=
+	Line     int
+	Function string // from FUNC=
+	Level    string // from LEVEL=
+	Flags    string // from FLAGS=
+
+	Format    string
+	Arguments []Argument // in user data order
+}
+
+// Argument is a typed insertion of a message.
+type Argument struct {
+	Name   string // expression logged in source
+	Type   ItemType
+	Index  int      // insertion number, from FirstArgumentIndex
+	Values []string // names of list and set values, by index or bit
+}
+
+var (
+	guidLinePattern     = regexp.MustCompile(`^([0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12})\s+(\S+)\s*(?://(.*))?$`)
+	typeLinePattern     = regexp.MustCompile(`^#typev\s+(\S+)\s+(\d+)\s+"(.*)"\s*(?://([^"]*))?$`)
+	argumentLinePattern = regexp.MustCompile(`^(.*),\s*(Item\w+)\s*(?:\((.*)\))?\s*--\s*(\d+)\s*$`)
+	lineNumberPattern   = regexp.MustCompile(`(\d+)$`)
+)
+
+// ParseFile reads the messages of a TMF file.
+func ParseFile(path string) ([]*Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	messages, err := Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return messages, nil
+}
+
+// ParseDir reads the messages of every .tmf file in dir, as TMF bundles are shipped.
+func ParseDir(dir string) ([]*Message, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmf"))
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []*Message
+	for _, path := range paths {
+		fileMessages, parseErr := ParseFile(path)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		messages = append(messages, fileMessages...)
+	}
+	return messages, nil
+}
+
+// Parse reads TMF text. Several GUID declarations may follow each other, and lines
+// that are neither declarations nor comments are skipped: TMF data extracted from PDB annotations,
+// which start with a "TMF:" line, parses as well.
+func Parse(r io.Reader) ([]*Message, error) {
+	var messages []*Message
+	var guid *winguid.GUID
+	var component, file string
+	var pending *Message // declared, its arguments may follow
+	var message *Message // whose arguments are being read
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		if message != nil {
+			switch {
+			case line == "":
+			case line == "}":
+				messages = append(messages, message)
+				message = nil
+			default:
+				argument, err := parseArgument(line)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+				}
+				message.Arguments = append(message.Arguments, argument)
+			}
+			continue
+		}
+
+		if pending != nil {
+			if line == "" {
+				continue
+			}
+			if line == "{" {
+				message, pending = pending, nil
+				continue
+			}
+			// messages without arguments may omit their braces
+			messages = append(messages, pending)
+			pending = nil
+		}
+
+		if match := guidLinePattern.FindStringSubmatch(line); match != nil {
+			parsed, err := winguid.Parse("{" + match[1] + "}")
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %s", ErrInvalidTMF, lineNumber, err)
+			}
+			guid = parsed
+			component = match[2]
+			file = commentFields(match[3])["SRC"]
+			continue
+		}
+
+		if match := typeLinePattern.FindStringSubmatch(line); match != nil {
+			if guid == nil {
+				return nil, fmt.Errorf("%w: line %d: message without GUID declaration", ErrInvalidTMF, lineNumber)
+			}
+			id, err := strconv.ParseUint(match[2], 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: message number %s", ErrInvalidTMF, lineNumber, match[2])
+			}
+			fields := commentFields(match[4])
+			pending = &Message{
+				GUID:      *guid,
+				ID:        uint16(id),
+				Component: component,
+				File:      file,
+				Function:  fields["FUNC"],
+				Level:     fields["LEVEL"],
+				Flags:     fields["FLAGS"],
+				Format:    match[3],
+			}
+			if lineMatch := lineNumberPattern.FindString(match[1]); lineMatch != "" {
+				pending.Line, _ = strconv.Atoi(lineMatch)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if message != nil {
+		return nil, fmt.Errorf("%w: unterminated arguments of message %d", ErrInvalidTMF, message.ID)
+	}
+	if pending != nil {
+		messages = append(messages, pending)
+	}
+
+	return messages, nil
+}
+
+func parseArgument(line string) (Argument, error) {
+	match := argumentLinePattern.FindStringSubmatch(line)
+	if match == nil {
+		return Argument{}, fmt.Errorf("%w: argument %q", ErrInvalidTMF, line)
+	}
+	index, err := strconv.Atoi(match[4])
+	if err != nil {
+		return Argument{}, fmt.Errorf("%w: argument index %s", ErrInvalidTMF, match[4])
+	}
+
+	argument := Argument{
+		Name:  strings.TrimSpace(match[1]),
+		Type:  ItemType(match[2]),
+		Index: index,
+	}
+	if match[3] != "" {
+		for _, value := range strings.Split(match[3], ",") {
+			argument.Values = append(argument.Values, strings.TrimSpace(value))
+		}
+	}
+	return argument, nil
+}
+
+// commentFields reads the KEY=value pairs of a declaration comment.
+func commentFields(comment string) map[string]string {
+	fields := make(map[string]string)
+	for _, field := range strings.Fields(comment) {
+		if key, value, ok := strings.Cut(field, "="); ok {
+			fields[key] = value
+		}
+	}
+	return fields
+}
@@ -0,0 +1,103 @@
+package wpp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/quentin-nozomi/microsoft-etw/winguid"
+)
+
+var (
+	sampleTMF         = filepath.Join("testdata", "sample.tmf")
+	sampleMessageGUID = *winguid.MustParse("{3D6B2A5E-FCB2-3E4E-B8B5-AD6E9E4AD8A4}")
+)
+
+func TestParseFile(t *testing.T) {
+	messages, err := ParseFile(sampleTMF)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("%d messages, want 3", len(messages))
+	}
+
+	want := &Message{
+		GUID:      sampleMessageGUID,
+		ID:        10,
+		Component: "MyDriver",
+		File:      "driver.c",
+		Line:      120,
+		Function:  "OpenDevice",
+		Level:     "TRACE_LEVEL_ERROR",
+		Flags:     "TRACE_OPEN",
+		Format:    "%0Opened %10!s! with status %11!x! in %1 at %2",
+		Arguments: []Argument{
+			{Name: "FileName", Type: "ItemWString", Index: 10},
+			{Name: "Status", Type: "ItemNTSTATUS", Index: 11},
+		},
+	}
+	if !reflect.DeepEqual(messages[0], want) {
+		t.Errorf("message 10 = %+v, want %+v", messages[0], want)
+	}
+
+	state := messages[1].Arguments
+	if len(state) != 4 || !reflect.DeepEqual(state[0].Values, []string{"Stopped", "Running", "Paused"}) || !state[1].Type.IsSet() {
+		t.Errorf("message 11 arguments = %+v", state)
+	}
+
+	unload := messages[2]
+	if unload.ID != 12 || unload.Line != 150 || unload.Function != "Unload" || len(unload.Arguments) != 0 {
+		t.Errorf("message 12 without braces = %+v", unload)
+	}
+}
+
+func TestParseDir(t *testing.T) {
+	messages, err := ParseDir("testdata")
+	if err != nil {
+		t.Fatalf("ParseDir: %v", err)
+	}
+	if len(messages) != 3 {
+		t.Errorf("%d messages, want 3", len(messages))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	guid := "3d6b2a5e-fcb2-3e4e-b8b5-ad6e9e4ad8a4 MyDriver // SRC=driver.c\n"
+
+	tests := []struct {
+		name string
+		tmf  string
+	}{
+		{"message without GUID", `#typev driver_c1 10 "%0Text"`},
+		{"message number out of range", guid + `#typev driver_c1 70000 "%0Text"`},
+		{"bad argument", guid + "#typev driver_c1 10 \"%0%10!d!\"\n{\nValue ItemLong 10\n}"},
+		{"unterminated arguments", guid + "#typev driver_c1 10 \"%0%10!d!\"\n{\nValue, ItemLong -- 10\n"},
+	}
+
+	for _, test := range tests {
+		if _, err := Parse(strings.NewReader(test.tmf)); !errors.Is(err, ErrInvalidTMF) {
+			t.Errorf("%s: err = %v, want %v", test.name, err, ErrInvalidTMF)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	sample, err := os.ReadFile(sampleTMF)
+	if err != nil {
+		f.Fatalf("ReadFile: %v", err)
+	}
+	f.Add(string(sample))
+
+	f.Fuzz(func(t *testing.T, text string) {
+		messages, err := Parse(strings.NewReader(text))
+		if err != nil {
+			return
+		}
+		// messages whose format the decoder rejects are an error, not a panic
+		NewDecoder(messages, nil)
+	})
+}